@@ -0,0 +1,191 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// WithAutoBatch collects requests made with SendAsync, and the methods built on it, and sends them
+// as a single batch once maxSize requests are waiting or window has elapsed since the first of them,
+// whichever comes first. Each request's future resolves independently. If window is zero requests
+// are only sent once maxSize are waiting.
+//
+// Requests count as in flight while they wait, see InFlightByTenant. A request made with SendContext
+// whose context is done while it waits, or which is cancelled, is taken out of the batch rather
+// than sent, unless it may be shared with other callers, see WithSingleFlight.
+func WithAutoBatch(maxSize int, window time.Duration) ClientOption {
+	return func(opts *ClientOptions) {
+		opts.AutoBatchMaxSize = maxSize
+		opts.AutoBatchWindow = window
+	}
+}
+
+// autoBatcher accumulates requests until they are due to be sent as a batch.
+type autoBatcher struct {
+	mu       sync.Mutex
+	maxSize  int
+	window   time.Duration
+	clock    Clock
+	requests []*inFlightRequest
+	elements []json.RawMessage
+	timer    Timer
+	// gen counts the batches taken, so that the timer of a batch which has already been sent
+	// cannot flush the next one early
+	gen    uint64
+	closed bool
+	send   func(requests []*inFlightRequest, elements []json.RawMessage)
+}
+
+func newAutoBatcher(
+	maxSize int,
+	window time.Duration,
+	clock Clock,
+	send func(requests []*inFlightRequest, elements []json.RawMessage),
+) *autoBatcher {
+	return &autoBatcher{maxSize: maxSize, window: window, clock: clock, send: send}
+}
+
+// add queues a request, sending the batch if it is now full.
+func (b *autoBatcher) add(request *inFlightRequest, data json.RawMessage) {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		request.fail(ErrClosed)
+		return
+	}
+
+	b.requests = append(b.requests, request)
+	b.elements = append(b.elements, data)
+
+	if len(b.requests) == 1 && b.window > 0 {
+		gen := b.gen
+		b.timer = b.clock.AfterFunc(b.window, func() {
+			b.flush(gen)
+		})
+	}
+
+	var requests []*inFlightRequest
+	var elements []json.RawMessage
+	if len(b.requests) >= b.maxSize {
+		requests, elements = b.take()
+	}
+	b.mu.Unlock()
+
+	if requests != nil {
+		b.send(requests, elements)
+	}
+}
+
+// flush sends whatever is waiting, provided it is still batch gen.
+func (b *autoBatcher) flush(gen uint64) {
+	b.mu.Lock()
+	if b.gen != gen {
+		b.mu.Unlock()
+		return
+	}
+	requests, elements := b.take()
+	b.mu.Unlock()
+
+	if requests != nil {
+		b.send(requests, elements)
+	}
+}
+
+// remove takes the request of future out of the waiting batch, returning it, or nil if it is not
+// waiting.
+func (b *autoBatcher) remove(future ResponseFuture) *inFlightRequest {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i, request := range b.requests {
+		if request.future != future {
+			continue
+		}
+		b.requests = append(b.requests[:i], b.requests[i+1:]...)
+		b.elements = append(b.elements[:i], b.elements[i+1:]...)
+		if len(b.requests) == 0 {
+			// nothing is left for the timer to send
+			b.take()
+		}
+		return request
+	}
+	return nil
+}
+
+// take removes and returns the waiting requests, mu must be held.
+func (b *autoBatcher) take() ([]*inFlightRequest, []json.RawMessage) {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	b.gen++
+	requests, elements := b.requests, b.elements
+	b.requests, b.elements = nil, nil
+	return requests, elements
+}
+
+// close fails any waiting requests with ErrClosed.
+func (b *autoBatcher) close() {
+	b.mu.Lock()
+	b.closed = true
+	requests, _ := b.take()
+	b.mu.Unlock()
+
+	for _, request := range requests {
+		request.fail(ErrClosed)
+	}
+}
+
+// withdraw takes the request of future out of the batch being collected and fails it with err, so
+// that a request whose caller has stopped waiting is not sent. It returns false if the request is
+// not waiting to be batched.
+func (c *client) withdraw(future ResponseFuture, err error) bool {
+	if c.batcher == nil {
+		return false
+	}
+	request := c.batcher.remove(future)
+	if request == nil {
+		return false
+	}
+	request.fail(err)
+	c.inFlight.Delete(request.key)
+	return true
+}
+
+// sendAutoBatch sends requests collected by the auto batcher, as a batch if there is more than one.
+func (c *client) sendAutoBatch(requests []*inFlightRequest, elements []json.RawMessage) {
+	if len(requests) == 1 {
+		c.write(requests[0].context(), requests[0].tenant, elements[0], func(err error) {
+			c.onWritten(requests[0], err)
+		})
+		return
+	}
+
+	bytes, err := json.Marshal(elements)
+	if err != nil {
+		for _, request := range requests {
+			request.fail(err)
+		}
+		return
+	}
+
+	pending := &pendingBatch{keys: make([]string, len(requests))}
+	for i, request := range requests {
+		pending.keys[i] = request.key
+		request.batch = pending
+	}
+
+	c.logger().
+		WithField("size", len(requests)).
+		Debug("sending batch")
+
+	// send the batch, on behalf of the tenant of the first element, the calls of which it is made up
+	// cannot abandon the write alone
+	c.write(context.Background(), requests[0].tenant, bytes, func(err error) {
+		for _, request := range requests {
+			c.onWritten(request, err)
+		}
+	})
+}
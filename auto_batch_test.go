@@ -0,0 +1,184 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/41north/jsonrpc.go"
+	"github.com/41north/jsonrpc.go/testutil"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_AutoBatch(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	serverStream := jsonrpc.NewStreamConnection(serverConn, jsonrpc.FramingNewline)
+
+	server := jsonrpc.NewServer()
+	server.Register("echo", echo)
+
+	clock := testutil.NewClock(time.Time{})
+	client := jsonrpc.NewClientWithConnection(
+		jsonrpc.NewStreamConnection(clientConn, jsonrpc.FramingNewline),
+		jsonrpc.WithClock(clock),
+		jsonrpc.WithAutoBatch(3, time.Minute),
+	)
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	// replies to each message, reporting the number of requests it contained
+	sizes := make(chan int, 16)
+	go func() {
+		for {
+			data, err := serverStream.Read()
+			if err != nil {
+				return
+			}
+			_ = serverStream.Write(server.Handle(context.Background(), data))
+
+			var batch []json.RawMessage
+			if json.Unmarshal(data, &batch) != nil {
+				sizes <- 1
+			} else {
+				sizes <- len(batch)
+			}
+		}
+	}()
+
+	// reaching the max size sends a batch immediately
+	var futures []jsonrpc.ResponseFuture
+	for i := 0; i < 3; i++ {
+		futures = append(futures, client.SendAsync(*newRequest("echo", []int{i})))
+	}
+	assert.Equal(t, 3, <-sizes)
+
+	for i, future := range futures {
+		resp, err := (<-future.Get()).Unwrap()
+		assert.Nil(t, err)
+
+		var result []int
+		assert.Nil(t, resp.UnmarshalResult(&result))
+		assert.Equal(t, []int{i}, result)
+	}
+
+	// otherwise requests are sent once the window elapses
+	future := client.SendAsync(*newRequest("echo", []int{3}))
+	clock.BlockUntil(1)
+	clock.Advance(59 * time.Second)
+	assert.Empty(t, sizes)
+	clock.Advance(time.Second)
+	assert.Equal(t, 1, <-sizes)
+
+	_, err := (<-future.Get()).Unwrap()
+	assert.Nil(t, err)
+
+	// closing fails requests which are still waiting
+	future = client.SendAsync(*newRequest("echo", []int{4}))
+	assert.Nil(t, client.Close())
+
+	_, err = (<-future.Get()).Unwrap()
+	assert.ErrorIs(t, err, jsonrpc.ErrClosed)
+}
+
+// lateClock creates timers which cannot be stopped, as if their callback had already started when
+// Stop was called, and reports each callback once it has run.
+type lateClock struct {
+	*testutil.Clock
+	fired chan struct{}
+}
+
+func (c *lateClock) AfterFunc(d time.Duration, f func()) jsonrpc.Timer {
+	return lateTimer{c.Clock.AfterFunc(d, func() {
+		f()
+		c.fired <- struct{}{}
+	})}
+}
+
+type lateTimer struct {
+	jsonrpc.Timer
+}
+
+func (lateTimer) Stop() bool {
+	return false
+}
+
+func TestClient_AutoBatchPending(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	serverStream := jsonrpc.NewStreamConnection(serverConn, jsonrpc.FramingNewline)
+
+	server := jsonrpc.NewServer()
+	server.Register("echo", echo)
+
+	clock := &lateClock{Clock: testutil.NewClock(time.Time{}), fired: make(chan struct{}, 4)}
+	client := jsonrpc.NewClientWithConnection(
+		jsonrpc.NewStreamConnection(clientConn, jsonrpc.FramingNewline),
+		jsonrpc.WithClock(clock),
+		jsonrpc.WithAutoBatch(2, time.Minute),
+	)
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	sizes := make(chan int, 16)
+	go func() {
+		for {
+			data, err := serverStream.Read()
+			if err != nil {
+				return
+			}
+			var batch []json.RawMessage
+			if json.Unmarshal(data, &batch) != nil {
+				sizes <- 1
+			} else {
+				sizes <- len(batch)
+			}
+			_ = serverStream.Write(server.Handle(context.Background(), data))
+		}
+	}()
+
+	// requests are in flight while they wait to be batched
+	futures := []jsonrpc.ResponseFuture{client.SendAsync(*newRequest("echo", []int{1}))}
+	assert.Equal(t, map[string]int{"": 1}, client.InFlightByTenant())
+	futures = append(futures, client.SendAsync(*newRequest("echo", []int{2})))
+	assert.Equal(t, 2, <-sizes)
+
+	// the timer of the batch which has been sent does not flush the next one early
+	clock.Advance(30 * time.Second)
+	futures = append(futures, client.SendAsync(*newRequest("echo", []int{3})))
+	clock.Advance(30 * time.Second)
+	<-clock.fired
+	futures = append(futures, client.SendAsync(*newRequest("echo", []int{4})))
+	assert.Equal(t, 2, <-sizes)
+
+	// requests whose caller stops waiting are taken out of the batch
+	ctx, cancel := context.WithCancel(context.Background())
+	sent := make(chan error, 1)
+	go func() {
+		var resp jsonrpc.Response
+		sent <- client.SendContext(ctx, *newRequest("echo", []int{5}), &resp)
+	}()
+	assert.Eventually(t, func() bool { return len(client.InFlightByTenant()) == 1 }, time.Second, time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-sent, context.Canceled)
+	assert.Empty(t, client.InFlightByTenant())
+
+	cancelable, err := client.SendCancelable(*newRequest("echo", []int{6}))
+	assert.Nil(t, err)
+	assert.True(t, cancelable.Cancel())
+	assert.Empty(t, client.InFlightByTenant())
+
+	futures = append(futures, client.SendAsync(*newRequest("echo", []int{7})))
+	futures = append(futures, client.SendAsync(*newRequest("echo", []int{8})))
+	assert.Equal(t, 2, <-sizes)
+
+	responses, err := jsonrpc.WaitAll(context.Background(), futures)
+	assert.Nil(t, err)
+	for i, expected := range []int{1, 2, 3, 4, 7, 8} {
+		var result []int
+		assert.Nil(t, responses[i].UnmarshalResult(&result))
+		assert.Equal(t, []int{expected}, result)
+	}
+	assert.Empty(t, client.InFlightByTenant())
+}
@@ -0,0 +1,137 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+
+	"github.com/juju/errors"
+)
+
+// DefaultBaggageField is the default top level field of the request which carries baggage.
+const DefaultBaggageField = "baggage"
+
+// TextMapCarrier holds the key value pairs a Propagator injects into, and extracts from, a
+// request. Its method set matches that of the OpenTelemetry propagation.TextMapCarrier, so it can be
+// passed to an OpenTelemetry propagator as is, see AdaptPropagator.
+type TextMapCarrier map[string]string
+
+func (c TextMapCarrier) Get(key string) string {
+	return c[key]
+}
+
+func (c TextMapCarrier) Set(key string, value string) {
+	c[key] = value
+}
+
+func (c TextMapCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Propagator carries values, such as OpenTelemetry baggage and trace context, from the context of a
+// call on the client to the context of its handler on the server.
+type Propagator interface {
+	Inject(ctx context.Context, carrier TextMapCarrier)
+	Extract(ctx context.Context, carrier TextMapCarrier) context.Context
+}
+
+type propagatorFuncs[C any] struct {
+	inject  func(ctx context.Context, carrier C)
+	extract func(ctx context.Context, carrier C) context.Context
+}
+
+func (p propagatorFuncs[C]) Inject(ctx context.Context, carrier TextMapCarrier) {
+	// AdaptPropagator has checked that TextMapCarrier is a C
+	p.inject(ctx, any(carrier).(C))
+}
+
+func (p propagatorFuncs[C]) Extract(ctx context.Context, carrier TextMapCarrier) context.Context {
+	return p.extract(ctx, any(carrier).(C))
+}
+
+// AdaptPropagator creates a Propagator from the methods of a propagator whose carrier is an
+// interface satisfied by TextMapCarrier, e.g. for an OpenTelemetry propagation.TextMapPropagator:
+//
+//	propagator, err := AdaptPropagator(propagator.Inject, propagator.Extract)
+//
+// An error matching errors.NotValid is returned if TextMapCarrier is not a C.
+func AdaptPropagator[C any](
+	inject func(ctx context.Context, carrier C),
+	extract func(ctx context.Context, carrier C) context.Context,
+) (Propagator, error) {
+	if _, ok := any(TextMapCarrier{}).(C); !ok {
+		return nil, errors.NotValidf("carrier %s", reflect.TypeOf((*C)(nil)).Elem())
+	}
+	return propagatorFuncs[C]{inject: inject, extract: extract}, nil
+}
+
+// WithBaggageField sets the top level field of the request which carries the propagated values.
+func WithBaggageField(name string) BaggageOption {
+	return func(opts *BaggageOptions) {
+		opts.Field = name
+	}
+}
+
+type BaggageOption = func(opts *BaggageOptions)
+
+type BaggageOptions struct {
+	Field string
+}
+
+func DefaultBaggageOptions() BaggageOptions {
+	return BaggageOptions{
+		Field: DefaultBaggageField,
+	}
+}
+
+func baggageOptions(options []BaggageOption) BaggageOptions {
+	opts := DefaultBaggageOptions()
+	for _, opt := range options {
+		opt(&opts)
+	}
+	return opts
+}
+
+// NewBaggageInterceptor injects the values propagator finds in the context of each call into the
+// request, as a json object held in the baggage field, see WithBaggageField. Nothing is added if
+// there is nothing to propagate.
+func NewBaggageInterceptor(propagator Propagator, options ...BaggageOption) UnaryInterceptor {
+	opts := baggageOptions(options)
+	return func(ctx context.Context, req Request, invoker UnaryInvoker) (Response, error) {
+		carrier := TextMapCarrier{}
+		propagator.Inject(ctx, carrier)
+		if len(carrier) == 0 {
+			return invoker(ctx, req)
+		}
+
+		// the extensions are shared with the caller's copy of the request
+		req.extensions = cloneExtensions(req.extensions)
+
+		if err := req.SetExtension(opts.Field, carrier); err != nil {
+			return Response{}, err
+		}
+		return invoker(ctx, req)
+	}
+}
+
+// NewBaggageMiddleware extracts the values held in the baggage field of each request, see
+// WithBaggageField, into the context of its handler with propagator. Requests without the field,
+// or whose field is not an object of strings, are handled with their context unchanged.
+func NewBaggageMiddleware(propagator Propagator, options ...BaggageOption) Middleware {
+	opts := baggageOptions(options)
+	return func(next Handler) Handler {
+		return func(ctx context.Context, req Request) (any, error) {
+			if raw := req.Extension(opts.Field); raw != nil {
+				var carrier TextMapCarrier
+				if err := json.Unmarshal(raw, &carrier); err == nil && carrier != nil {
+					ctx = propagator.Extract(ctx, carrier)
+				}
+			}
+			return next(ctx, req)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/41north/jsonrpc.go"
+
+	"github.com/juju/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// carrier has the shape of the OpenTelemetry propagation.TextMapCarrier.
+type carrier interface {
+	Get(key string) string
+	Set(key string, value string)
+	Keys() []string
+}
+
+type baggageKey struct{}
+
+// baggagePropagator has the shape of an OpenTelemetry propagator, carrying a string in the
+// context as a W3C style baggage header.
+type baggagePropagator struct{}
+
+func (baggagePropagator) Inject(ctx context.Context, c carrier) {
+	if baggage, ok := ctx.Value(baggageKey{}).(string); ok {
+		c.Set("baggage", baggage)
+	}
+}
+
+func (baggagePropagator) Extract(ctx context.Context, c carrier) context.Context {
+	if baggage := c.Get("baggage"); baggage != "" {
+		return context.WithValue(ctx, baggageKey{}, baggage)
+	}
+	return ctx
+}
+
+func TestBaggage(t *testing.T) {
+	p := baggagePropagator{}
+	propagator, err := jsonrpc.AdaptPropagator(p.Inject, p.Extract)
+	assert.Nil(t, err)
+
+	server := jsonrpc.NewServer()
+	server.Use(jsonrpc.NewBaggageMiddleware(propagator, jsonrpc.WithBaggageField("ctx")))
+	server.Register("whoami", func(ctx context.Context, req jsonrpc.Request) (any, error) {
+		baggage, _ := ctx.Value(baggageKey{}).(string)
+		return baggage, nil
+	})
+
+	clientConn, serverConn := net.Pipe()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = server.Serve(ctx, jsonrpc.NewStreamConnection(serverConn, jsonrpc.FramingNewline))
+	}()
+
+	client := jsonrpc.NewClientWithConnection(
+		jsonrpc.NewStreamConnection(clientConn, jsonrpc.FramingNewline),
+		jsonrpc.WithInterceptors(jsonrpc.NewBaggageInterceptor(propagator, jsonrpc.WithBaggageField("ctx"))),
+	)
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	whoami := func(ctx context.Context, req jsonrpc.Request) string {
+		var resp jsonrpc.Response
+		assert.Nil(t, client.SendContext(ctx, req, &resp))
+		var baggage string
+		assert.Nil(t, resp.UnmarshalResult(&baggage))
+		return baggage
+	}
+
+	req := *newRequest("whoami", nil)
+	assert.Equal(t, "userId=alice,sessionId=1", whoami(context.WithValue(ctx, baggageKey{}, "userId=alice,sessionId=1"), req))
+	// the caller's request is not modified
+	assert.Nil(t, req.Extension("ctx"))
+
+	// nothing is propagated without baggage
+	assert.Equal(t, "", whoami(ctx, *newRequest("whoami", nil)))
+}
+
+// mapCarrier is a carrier which TextMapCarrier cannot be passed as.
+type mapCarrier interface {
+	Get(key string) string
+	Values() map[string]string
+}
+
+func TestAdaptPropagator_UnsupportedCarrier(t *testing.T) {
+	_, err := jsonrpc.AdaptPropagator(
+		func(ctx context.Context, carrier mapCarrier) {},
+		func(ctx context.Context, carrier mapCarrier) context.Context { return ctx },
+	)
+	assert.ErrorIs(t, err, errors.NotValid)
+}
@@ -0,0 +1,346 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/41north/async.go"
+	"github.com/juju/errors"
+)
+
+var (
+	ErrDeadlineExceeded = errors.ConstError("deadline exceeded")
+	ErrMissingResponse  = errors.ConstError("batch response did not include a response for the request")
+)
+
+// TimedRequest is an element of a BatchRequest. A Timeout greater than zero bounds how long the
+// client waits for the response to this element, independently of the rest of the batch.
+type TimedRequest struct {
+	Request Request
+	Timeout time.Duration
+}
+
+// BatchRequest is a group of requests sent to the server as a single json array.
+type BatchRequest struct {
+	Requests []TimedRequest
+}
+
+// Add appends req to the batch with the given timeout, zero meaning no timeout.
+func (b *BatchRequest) Add(req Request, timeout time.Duration) {
+	b.Requests = append(b.Requests, TimedRequest{Request: req, Timeout: timeout})
+}
+
+// BatchOutcome describes how an element of a batch was resolved.
+type BatchOutcome int
+
+const (
+	// BatchOK is a successful response.
+	BatchOK BatchOutcome = iota
+	// BatchRPCError is an error response from the server.
+	BatchRPCError
+	// BatchMissing is a request which the server did not include in its batch response.
+	BatchMissing
+	// BatchFailed is a request which failed client side, for example because it timed out.
+	BatchFailed
+)
+
+// BatchElementResult is the outcome of an element of a batch, along with its response or error.
+type BatchElementResult struct {
+	Outcome  BatchOutcome
+	Response *Response
+	Err      error
+}
+
+// BatchResult reports the outcome of every element of a batch, in the order they were sent.
+type BatchResult struct {
+	Elements []BatchElementResult
+}
+
+// OK returns true if every element of the batch received a successful response.
+func (r BatchResult) OK() bool {
+	for _, element := range r.Elements {
+		if element.Outcome != BatchOK {
+			return false
+		}
+	}
+	return true
+}
+
+// AwaitBatch waits for the futures returned by SendBatch to resolve and reports their outcomes. An
+// error is only returned if ctx is done first.
+func AwaitBatch(ctx context.Context, futures []ResponseFuture) (BatchResult, error) {
+	result := BatchResult{Elements: make([]BatchElementResult, len(futures))}
+	for i, future := range futures {
+		select {
+		case <-ctx.Done():
+			return result, ctx.Err()
+		case r := <-future.Get():
+			resp, err := r.Unwrap()
+			element := BatchElementResult{Response: resp, Err: err}
+			switch {
+			case errors.Is(err, ErrMissingResponse):
+				element.Outcome = BatchMissing
+			case err != nil:
+				element.Outcome = BatchFailed
+			case resp.Error != nil:
+				element.Outcome = BatchRPCError
+			default:
+				element.Outcome = BatchOK
+			}
+			result.Elements[i] = element
+		}
+	}
+	return result, nil
+}
+
+// isBatch returns true if data is a json array.
+func isBatch(data []byte) bool {
+	trimmed := bytes.TrimLeft(data, " \t\r\n")
+	return len(trimmed) > 0 && trimmed[0] == '['
+}
+
+// SendBatch sends the requests of batch as a single json array and returns a future for each, in
+// the same order. Element timeouts are enforced by the client, with elements which have not
+// received a response in time resolving with ErrDeadlineExceeded. Cancelling ctx fails any
+// elements which are still outstanding. The batch is held by the offline queue, see
+// WithOfflineQueue, and waits for a slot for each element if the number in flight is limited, see
+// WithMaxInFlight.
+func (c *client) SendBatch(ctx context.Context, batch BatchRequest) []ResponseFuture {
+	return c.sendBatch(ctx, batch, false)
+}
+
+func (c *client) sendBatch(ctx context.Context, batch BatchRequest, direct bool) []ResponseFuture {
+	futures := make([]ResponseFuture, len(batch.Requests))
+	keys := make([]string, len(batch.Requests))
+	ids := make([]string, len(batch.Requests))
+	elements := make([]json.RawMessage, len(batch.Requests))
+
+	for i := range futures {
+		futures[i] = async.NewFuture[async.Result[*Response]]()
+	}
+
+	failAll := func(err error) []ResponseFuture {
+		for i, future := range futures {
+			reqErr := &RequestError{Method: batch.Requests[i].Request.Method, ID: ids[i], Err: err}
+			future.Set(async.NewResultErr[*Response](reqErr))
+		}
+		return futures
+	}
+
+	if len(elements) == 0 {
+		return futures
+	}
+
+	if c.closed.Load() {
+		// short circuit
+		return failAll(ErrClosed)
+	}
+
+	if c.opts.LazyConnect && !direct {
+		if err := c.ensureConnected(); err != nil {
+			return failAll(err)
+		}
+	}
+	if !direct {
+		c.awaitHandshake()
+	}
+
+	// marshal each element to json, so that their individual sizes are known
+	for i, timed := range batch.Requests {
+		req := timed.Request
+		if err := c.prepare(&req); err != nil {
+			return failAll(err)
+		}
+		bytes, err := json.Marshal(req)
+		if err != nil {
+			return failAll(errors.Annotate(err, "failed to marshal batch to json"))
+		}
+		elements[i] = bytes
+		keys[i] = c.opts.CorrelateRequest(req)
+		ids[i] = string(req.Id)
+	}
+
+	bytes, err := json.Marshal(elements)
+	if err != nil {
+		return failAll(errors.Annotate(err, "failed to marshal batch to json"))
+	}
+
+	// create the in flight entries
+	start := c.opts.Clock.Now()
+	pending := &pendingBatch{keys: keys}
+	requests := make([]*inFlightRequest, len(futures))
+	for i, future := range futures {
+		requests[i] = &inFlightRequest{
+			key:    keys[i],
+			id:     ids[i],
+			future: future,
+			method: batch.Requests[i].Request.Method,
+			tenant: batch.Requests[i].Request.tenant,
+			ctx:    ctx,
+			batch:  pending,
+			recent: c.recent,
+			start:  start,
+		}
+
+		observeRequest(ctx, c.opts.Observer, batch.Requests[i].Request.Method, len(elements[i]))
+	}
+
+	c.logger().
+		WithField("size", len(elements)).
+		Debug("sending batch")
+
+	// cancelling ctx fails the elements which are outstanding, including while the batch is held
+	if ctx.Done() != nil {
+		go func() {
+			for _, future := range futures {
+				select {
+				case <-future.Get():
+				case <-ctx.Done():
+					for _, request := range requests {
+						c.expire(request, ctx.Err())
+					}
+					return
+				}
+			}
+		}()
+	}
+
+	send := func() {
+		for _, request := range requests {
+			c.inFlight.Store(request.key, request)
+		}
+		if err := ctx.Err(); err != nil {
+			// cancelled whilst held, the entries are removed even if they were failed before being stored
+			for _, request := range requests {
+				request.fail(err)
+				c.inFlight.Delete(request.key)
+			}
+			return
+		}
+
+		// send the batch, on behalf of the tenant of the first element
+		c.write(ctx, batch.Requests[0].Request.tenant, bytes, func(err error) {
+			for _, request := range requests {
+				c.onWritten(request, err)
+			}
+		})
+
+		// enforce the element timeouts, each timer being stopped once its element resolves
+		for i, timed := range batch.Requests {
+			if timed.Timeout > 0 {
+				request := requests[i]
+				request.setTimer(c.opts.Clock.AfterFunc(timed.Timeout, func() {
+					c.expire(request, ErrDeadlineExceeded)
+				}))
+			}
+		}
+	}
+	fail := func(err error) {
+		for _, request := range requests {
+			request.fail(err)
+		}
+	}
+
+	// hold the batch if the client has not yet connected
+	if c.outbox != nil && !direct {
+		if queued, err := c.outbox.offer(&outboxEntry{batch: requests, send: send}); queued {
+			if err != nil {
+				fail(err)
+			}
+			return futures
+		}
+	}
+
+	// wait for a slot for each element if the number in flight is limited, holding them until every
+	// element has completed
+	if c.admission != nil && !direct {
+		n := len(requests)
+		c.admission.admitN(n, PriorityNormal, func() {
+			go func() {
+				for _, future := range futures {
+					<-future.Get()
+				}
+				c.admission.releaseN(n)
+			}()
+			send()
+		}, fail)
+		return futures
+	}
+
+	send()
+	return futures
+}
+
+func (c *client) SendBatchContext(ctx context.Context, batch BatchRequest) ([]*Response, error) {
+	futures := c.SendBatch(ctx, batch)
+	responses := make([]*Response, len(futures))
+
+	// every future resolves, as those still outstanding are failed if ctx is done
+	var firstErr error
+	for i, future := range futures {
+		resp, err := (<-future.Get()).Unwrap()
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		responses[i] = resp
+	}
+
+	if firstErr != nil && ctx.Err() != nil {
+		return responses, ctx.Err()
+	}
+	return responses, firstErr
+}
+
+// pendingBatch groups the in flight entries of the requests sent in a batch.
+type pendingBatch struct {
+	keys []string
+}
+
+// onBatch handles a batch of inbound messages. Once every element has been handled, any requests
+// from the corresponding sent batches which were not included are failed with ErrMissingResponse.
+// Duplicate responses, and those with ids which were never sent, are passed to the unmatched
+// handler.
+func (c *client) onBatch(elements []json.RawMessage, meta *ResponseMeta) {
+	batches := make(map[*pendingBatch]bool)
+
+	for _, element := range elements {
+		var resp Response
+		if err := json.Unmarshal(element, &resp); err != nil {
+			c.logger().WithError(err).Error("unmarshal failure")
+			continue
+		}
+		resp.meta = meta.withSize(len(element))
+		if c.positional != nil {
+			c.positional.correlate(&resp)
+		}
+		if isUnsolicitedError(&resp) {
+			// not a reply to any element of the batch
+		} else if value, ok := c.inFlight.Load(c.opts.CorrelateResponse(resp)); ok && value.(*inFlightRequest).batch != nil {
+			batches[value.(*inFlightRequest).batch] = true
+		}
+		c.onMessage(&resp, len(element))
+	}
+
+	for batch := range batches {
+		for _, key := range batch.keys {
+			if value, ok := c.inFlight.Load(key); ok && value.(*inFlightRequest).batch == batch {
+				c.expire(value.(*inFlightRequest), ErrMissingResponse)
+			}
+		}
+	}
+}
+
+// expire fails request with err and removes its in flight entry, unless it has already resolved.
+// It returns true if the request was failed.
+func (c *client) expire(request *inFlightRequest, err error) bool {
+	if !request.fail(err) {
+		return false
+	}
+	c.inFlight.Delete(request.key)
+	return true
+}
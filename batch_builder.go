@@ -0,0 +1,127 @@
+package jsonrpc
+
+import (
+	"time"
+
+	"github.com/juju/errors"
+)
+
+var ErrBatchTooLarge = errors.ConstError("batch exceeds maximum size")
+
+// WithMaxBatchSize limits the number of requests in a batch, Build failing with ErrBatchTooLarge if
+// more have been added. A size of zero or less removes the limit.
+func WithMaxBatchSize(n int) BatchBuilderOption {
+	return func(opts *BatchBuilderOptions) {
+		opts.MaxSize = n
+	}
+}
+
+// WithBatchIdGenerator sets the generator of the ids of the requests added to the batch. The default
+// is DefaultIdGenerator.
+func WithBatchIdGenerator(gen AnyIdGenerator) BatchBuilderOption {
+	return func(opts *BatchBuilderOptions) {
+		opts.IdGenerator = gen
+	}
+}
+
+type BatchBuilderOption = func(opts *BatchBuilderOptions)
+
+type BatchBuilderOptions struct {
+	MaxSize     int
+	IdGenerator AnyIdGenerator
+}
+
+func DefaultBatchBuilderOptions() BatchBuilderOptions {
+	return BatchBuilderOptions{
+		IdGenerator: DefaultIdGenerator,
+	}
+}
+
+// BatchBuilder constructs the requests of a batch, deferring any error until Build:
+//
+//	reqs, err := NewBatch().
+//		Add("eth_blockNumber", nil).
+//		Add("eth_getBalance", []any{address, "latest"}).
+//		Build()
+type BatchBuilder struct {
+	opts     BatchBuilderOptions
+	requests []Request
+	err      error
+}
+
+// NewBatch starts building a batch.
+func NewBatch(options ...BatchBuilderOption) *BatchBuilder {
+	opts := DefaultBatchBuilderOptions()
+	for _, opt := range options {
+		opt(&opts)
+	}
+	return &BatchBuilder{opts: opts}
+}
+
+// Add appends a request for method with params, and a freshly generated id.
+func (b *BatchBuilder) Add(method string, params any) *BatchBuilder {
+	req, err := NewRequest(method, params)
+	if err != nil {
+		return b.fail(errors.Annotatef(err, "request %d", len(b.requests)))
+	}
+	if err := req.ensureId(b.opts.IdGenerator); err != nil {
+		return b.fail(errors.Annotatef(err, "request %d", len(b.requests)))
+	}
+	b.requests = append(b.requests, *req)
+	return b
+}
+
+// AddNotification appends a notification for method with params.
+func (b *BatchBuilder) AddNotification(method string, params any) *BatchBuilder {
+	req, err := NewRequest(method, params)
+	if err != nil {
+		return b.fail(errors.Annotatef(err, "request %d", len(b.requests)))
+	}
+	b.requests = append(b.requests, *req)
+	return b
+}
+
+// Size returns the number of requests and notifications added.
+func (b *BatchBuilder) Size() int {
+	return len(b.requests)
+}
+
+func (b *BatchBuilder) fail(err error) *BatchBuilder {
+	if b.err == nil {
+		b.err = err
+	}
+	return b
+}
+
+// Build returns the requests and notifications of the batch, in the order they were added, or the
+// first error which occurred while building it. A batch must not be empty.
+func (b *BatchBuilder) Build() ([]Request, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if len(b.requests) == 0 {
+		return nil, errors.NotValidf("empty batch")
+	}
+	if b.opts.MaxSize > 0 && len(b.requests) > b.opts.MaxSize {
+		return nil, errors.Annotatef(ErrBatchTooLarge, "%d requests, limit %d", len(b.requests), b.opts.MaxSize)
+	}
+	return append([]Request(nil), b.requests...), nil
+}
+
+// BuildBatchRequest returns the batch as a BatchRequest to send with SendBatch, each element with
+// the given timeout, zero meaning no timeout. Notifications cannot be sent with SendBatch, so a
+// batch containing them is not valid, send those with NotifyBatch instead.
+func (b *BatchBuilder) BuildBatchRequest(timeout time.Duration) (BatchRequest, error) {
+	reqs, err := b.Build()
+	if err != nil {
+		return BatchRequest{}, err
+	}
+	var batch BatchRequest
+	for _, req := range reqs {
+		if req.Id == nil {
+			return BatchRequest{}, errors.NotValidf("notification %q in a batch request", req.Method)
+		}
+		batch.Add(req, timeout)
+	}
+	return batch, nil
+}
@@ -0,0 +1,64 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/41north/jsonrpc.go"
+
+	"github.com/juju/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchBuilder(t *testing.T) {
+	builder := jsonrpc.NewBatch(jsonrpc.WithBatchIdGenerator(jsonrpc.Sequential())).
+		Add("eth_blockNumber", nil).
+		Add("eth_getBalance", []string{"0x1", "latest"}).
+		AddNotification("log", "hello")
+	assert.Equal(t, 3, builder.Size())
+
+	reqs, err := builder.Build()
+	assert.Nil(t, err)
+	bytes, err := json.Marshal(reqs)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `[
+		{"id":1,"method":"eth_blockNumber","jsonrpc":"2.0"},
+		{"id":2,"method":"eth_getBalance","params":["0x1","latest"],"jsonrpc":"2.0"},
+		{"method":"log","params":"hello","jsonrpc":"2.0"}
+	]`, string(bytes))
+
+	// the size can be limited
+	_, err = jsonrpc.NewBatch(jsonrpc.WithMaxBatchSize(1)).Add("a", nil).Add("b", nil).Build()
+	assert.ErrorIs(t, err, jsonrpc.ErrBatchTooLarge)
+
+	// the first error is returned
+	_, err = jsonrpc.NewBatch().Add("a", nil).Add("b", json.RawMessage(`{`)).Build()
+	assert.NotNil(t, err)
+	_, err = jsonrpc.NewBatch().Build()
+	assert.True(t, errors.Is(err, errors.NotValid))
+
+	// notifications cannot be sent with SendBatch
+	_, err = builder.BuildBatchRequest(time.Second)
+	assert.True(t, errors.Is(err, errors.NotValid))
+}
+
+func TestBatchBuilder_SendBatch(t *testing.T) {
+	server := jsonrpc.NewServer()
+	server.Register("echo", echo)
+	client := jsonrpc.NewClient(serverDialer(server, nil))
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	batch, err := jsonrpc.NewBatch().Add("echo", []int{1}).Add("echo", []int{2}).BuildBatchRequest(time.Second)
+	assert.Nil(t, err)
+
+	responses, err := client.SendBatchContext(context.Background(), batch)
+	assert.Nil(t, err)
+	for i, resp := range responses {
+		var result []int
+		assert.Nil(t, resp.UnmarshalResult(&result))
+		assert.Equal(t, []int{i + 1}, result)
+	}
+}
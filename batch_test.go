@@ -0,0 +1,182 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/41north/jsonrpc.go"
+	"github.com/41north/jsonrpc.go/testutil"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_SendBatch(t *testing.T) {
+	srv := newWsServer(false)
+	defer srv.close()
+
+	dialer := jsonrpc.WebSocketDialer{Url: srv.url("/ws")}
+	client := jsonrpc.NewClient(dialer)
+	err := client.Connect()
+	assert.Nil(t, err)
+
+	var batch jsonrpc.BatchRequest
+	batch.Add(*newRequest("ping", nil, jsonrpc.RequestNumericId(1)), 0)
+	batch.Add(*newRequest("ping", nil, jsonrpc.RequestNumericId(2)), 50*time.Millisecond)
+	batch.Add(*newRequest("ping", nil, jsonrpc.RequestNumericId(3)), time.Minute)
+
+	futures := client.SendBatch(context.Background(), batch)
+	assert.Len(t, futures, 3)
+
+	// the batch is sent as a single array
+	var requests []jsonrpc.Request
+	err = json.Unmarshal(<-srv.receivedMessages, &requests)
+	assert.Nil(t, err)
+	assert.Len(t, requests, 3)
+
+	// wait for the second element to time out before replying
+	_, err = (<-futures[1].Get()).Unwrap()
+	assert.ErrorIs(t, err, jsonrpc.ErrDeadlineExceeded)
+
+	responses := []*jsonrpc.Response{
+		newResponse("pong-1", jsonrpc.ResponseNumericId(1)),
+		newResponse("pong-2", jsonrpc.ResponseNumericId(2)),
+		newResponse("pong-3", jsonrpc.ResponseNumericId(3)),
+	}
+	bytes, err := json.Marshal(responses)
+	assert.Nil(t, err)
+	srv.testMessages <- testMessage{msgType: websocket.TextMessage, data: bytes}
+
+	for _, i := range []int{0, 2} {
+		resp, err := (<-futures[i].Get()).Unwrap()
+		assert.Nil(t, err)
+		assert.Equal(t, responses[i], resp)
+	}
+}
+
+func TestClient_SendBatchCancelled(t *testing.T) {
+	srv := newWsServer(false)
+	defer srv.close()
+
+	dialer := jsonrpc.WebSocketDialer{Url: srv.url("/ws")}
+	client := jsonrpc.NewClient(dialer)
+	err := client.Connect()
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var batch jsonrpc.BatchRequest
+	batch.Add(*newRequest("ping", nil), 0)
+	batch.Add(*newRequest("ping", nil), time.Minute)
+
+	futures := client.SendBatch(ctx, batch)
+	cancel()
+
+	for _, future := range futures {
+		_, err := (<-future.Get()).Unwrap()
+		assert.ErrorIs(t, err, context.Canceled)
+	}
+}
+
+func TestClient_SendBatchStrictMatching(t *testing.T) {
+	srv := newWsServer(false)
+	defer srv.close()
+
+	dialer := jsonrpc.WebSocketDialer{Url: srv.url("/ws")}
+	client := jsonrpc.NewClient(dialer)
+
+	unmatched := make(chan jsonrpc.Response, 16)
+	client.SetUnmatchedHandler(func(resp jsonrpc.Response) {
+		unmatched <- resp
+	})
+
+	err := client.Connect()
+	assert.Nil(t, err)
+
+	// the response omits id 2, duplicates id 1 and includes id 99 which was never sent
+	responses := []*jsonrpc.Response{
+		newResponse("pong-1", jsonrpc.ResponseNumericId(1)),
+		newResponseError(jsonrpc.ErrInvalidParams, jsonrpc.ResponseNumericId(3)),
+		newResponse("pong-1", jsonrpc.ResponseNumericId(1)),
+		newResponse("pong-99", jsonrpc.ResponseNumericId(99)),
+	}
+	bytes, err := json.Marshal(responses)
+	assert.Nil(t, err)
+	srv.testMessages <- testMessage{msgType: websocket.TextMessage, data: bytes}
+
+	var batch jsonrpc.BatchRequest
+	for i := 1; i <= 3; i++ {
+		batch.Add(*newRequest("ping", nil, jsonrpc.RequestNumericId(i)), 0)
+	}
+
+	result, err := jsonrpc.AwaitBatch(context.Background(), client.SendBatch(context.Background(), batch))
+	assert.Nil(t, err)
+	assert.False(t, result.OK())
+
+	assert.Equal(t, jsonrpc.BatchOK, result.Elements[0].Outcome)
+	assert.Equal(t, responses[0], result.Elements[0].Response)
+
+	assert.Equal(t, jsonrpc.BatchMissing, result.Elements[1].Outcome)
+	assert.ErrorIs(t, result.Elements[1].Err, jsonrpc.ErrMissingResponse)
+
+	assert.Equal(t, jsonrpc.BatchRPCError, result.Elements[2].Outcome)
+	assert.Equal(t, jsonrpc.ErrInvalidParams, *result.Elements[2].Response.Error)
+
+	assert.Equal(t, *responses[2], <-unmatched)
+	assert.Equal(t, *responses[3], <-unmatched)
+}
+
+func TestClient_SendBatchContextPartial(t *testing.T) {
+	srv := newWsServer(false)
+	defer srv.close()
+
+	dialer := jsonrpc.WebSocketDialer{Url: srv.url("/ws")}
+	client := jsonrpc.NewClient(dialer)
+	err := client.Connect()
+	assert.Nil(t, err)
+
+	// only the first element receives a response
+	pongBytes, err := json.Marshal(newResponse("pong", jsonrpc.ResponseNumericId(1)))
+	assert.Nil(t, err)
+	srv.testMessages <- testMessage{msgType: websocket.TextMessage, data: pongBytes}
+
+	var batch jsonrpc.BatchRequest
+	batch.Add(*newRequest("ping", nil, jsonrpc.RequestNumericId(1)), 0)
+	batch.Add(*newRequest("ping", nil, jsonrpc.RequestNumericId(2)), 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	responses, err := client.SendBatchContext(ctx, batch)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Len(t, responses, 2)
+	assert.Equal(t, "1", string(responses[0].Id))
+	assert.Nil(t, responses[1])
+}
+
+func TestClient_SendBatchStopsTimers(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	clock := testutil.NewClock(time.Time{})
+	client := jsonrpc.NewClient(
+		serverDialer(endpoint("a", release), nil),
+		jsonrpc.WithClock(clock),
+	)
+	assert.Nil(t, client.Connect())
+	defer func() { _ = client.Close() }()
+
+	var batch jsonrpc.BatchRequest
+	batch.Add(*newRequest("name", nil, jsonrpc.RequestNumericId(1)), time.Minute)
+	batch.Add(*newRequest("name", nil, jsonrpc.RequestNumericId(2)), time.Minute)
+
+	for _, future := range client.SendBatch(context.Background(), batch) {
+		_, err := (<-future.Get()).Unwrap()
+		assert.Nil(t, err)
+	}
+
+	// the element timers are stopped once their elements resolve
+	assert.Equal(t, 0, clock.Waiters())
+}
@@ -0,0 +1,121 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// LSPCancelMethod is the notification used by the Language Server Protocol to cancel a request.
+const LSPCancelMethod = "$/cancelRequest"
+
+// WithCancelNotification notifies the server when a request it has been sent is cancelled, see
+// CancelableFuture. The notification is sent for method, with the id of the request as its "id"
+// param, e.g. LSPCancelMethod.
+func WithCancelNotification(method string) ClientOption {
+	return func(opts *ClientOptions) {
+		opts.CancelMethod = method
+	}
+}
+
+// CancelableFuture is a ResponseFuture whose request can be cancelled on its own, without
+// cancelling a context shared with other requests.
+type CancelableFuture interface {
+	ResponseFuture
+
+	// Cancel resolves the future with context.Canceled and stops waiting for a response, which is
+	// passed to the unmatched handler should it still arrive. A request which has yet to be sent,
+	// e.g. because it is waiting for an in flight slot, is not sent. It returns false if the
+	// future had already resolved.
+	Cancel() bool
+}
+
+type cancelableFuture struct {
+	ResponseFuture
+	client  *client
+	request *inFlightRequest
+}
+
+func (f *cancelableFuture) Cancel() bool {
+	return f.client.cancel(f.request)
+}
+
+// cancel fails r with context.Canceled, notifying the server if it has been sent. It returns false
+// if r had already resolved.
+func (c *client) cancel(r *inFlightRequest) bool {
+	r.canceled.Store(true)
+	if !r.fail(context.Canceled) {
+		return false
+	}
+
+	// a request waiting to be auto batched is taken out of the batch, and like those which have yet
+	// to be sent for any other reason, the server knows nothing of it
+	unsent := c.batcher != nil && c.batcher.remove(r.future) != nil
+	if value, ok := c.inFlight.Load(r.key); ok && value == r {
+		c.inFlight.Delete(r.key)
+		if !unsent {
+			c.notifyCancel(r)
+		}
+	}
+	return true
+}
+
+// CancelByMethod cancels every request for method which is awaiting a response, such as to shed the
+// load of an expensive method during an incident, returning how many were cancelled. Each fails with
+// context.Canceled, and the server is notified if WithCancelNotification is set. Requests waiting to
+// be auto batched are taken out of the batch, while others which have yet to be sent, e.g. because
+// they are waiting for an in flight slot, are unaffected.
+func (c *client) CancelByMethod(method string) int {
+	var cancelled int
+	c.inFlight.Range(func(_, value any) bool {
+		if r := value.(*inFlightRequest); r.method == method && c.cancel(r) {
+			cancelled++
+		}
+		return true
+	})
+	if cancelled > 0 {
+		c.logger().
+			WithField("method", method).
+			WithField("cancelled", cancelled).
+			Info("cancelled requests by method")
+	}
+	return cancelled
+}
+
+// SendCancelable sends req as SendAsync does. An error is returned, instead of a future, if the
+// request fails before it could be sent, e.g. because the client is closed.
+func (c *client) SendCancelable(req Request) (CancelableFuture, error) {
+	request, err := c.sendRequest(req, PriorityNormal, false)
+	if err != nil {
+		return nil, err
+	}
+	return &cancelableFuture{ResponseFuture: request.future, client: c, request: request}, nil
+}
+
+// notifyCancel tells the server that r has been cancelled, if WithCancelNotification is set.
+func (c *client) notifyCancel(r *inFlightRequest) {
+	if c.opts.CancelMethod == "" || r.id == "" {
+		return
+	}
+
+	params, err := json.Marshal(map[string]json.RawMessage{"id": json.RawMessage(r.id)})
+	if err != nil {
+		c.logger().WithError(err).Warn("failed to marshal cancellation")
+		return
+	}
+	req := Request{Method: c.opts.CancelMethod, Params: params, Version: c.opts.RequestVersion, tenant: r.tenant}
+	if c.opts.RequestMutator != nil {
+		c.opts.RequestMutator(&req)
+	}
+
+	bytes, err := json.Marshal(req)
+	if err != nil {
+		c.logger().WithError(err).Warn("failed to marshal cancellation")
+		return
+	}
+
+	c.write(context.Background(), r.tenant, bytes, func(err error) {
+		if err != nil {
+			c.logger().WithError(err).Warn("failed to send cancellation")
+		}
+	})
+}
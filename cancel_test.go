@@ -0,0 +1,143 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/41north/jsonrpc.go"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_SendCancelable(t *testing.T) {
+	release := make(chan struct{})
+	server := endpoint("a", release)
+
+	var calls atomic.Int32
+	server.Use(func(next jsonrpc.Handler) jsonrpc.Handler {
+		return func(ctx context.Context, req jsonrpc.Request) (any, error) {
+			if req.Method == "wait" {
+				calls.Add(1)
+			}
+			return next(ctx, req)
+		}
+	})
+
+	cancelled := make(chan json.RawMessage, 1)
+	server.Register(jsonrpc.LSPCancelMethod, func(ctx context.Context, req jsonrpc.Request) (any, error) {
+		var params struct {
+			Id json.RawMessage `json:"id"`
+		}
+		assert.Nil(t, req.UnmarshalParams(&params))
+		cancelled <- params.Id
+		return nil, nil
+	})
+
+	clientConn, serverConn := net.Pipe()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = server.Serve(ctx, jsonrpc.NewStreamConnection(serverConn, jsonrpc.FramingNewline))
+	}()
+
+	client := jsonrpc.NewClientWithConnection(
+		jsonrpc.NewStreamConnection(clientConn, jsonrpc.FramingNewline),
+		jsonrpc.WithCancelNotification(jsonrpc.LSPCancelMethod),
+		jsonrpc.WithMaxInFlight(1),
+	)
+	unmatched := make(chan jsonrpc.Response, 1)
+	client.SetUnmatchedHandler(func(resp jsonrpc.Response) {
+		unmatched <- resp
+	})
+	assert.Nil(t, client.Connect())
+
+	// the first request occupies the only in flight slot, the second waits for it
+	req := *newRequest("wait", nil, jsonrpc.RequestNumericId(7))
+	sent, err := client.SendCancelable(req)
+	assert.Nil(t, err)
+	waiting, err := client.SendCancelable(*newRequest("wait", nil))
+	assert.Nil(t, err)
+
+	assert.Eventually(t, func() bool {
+		return calls.Load() == 1
+	}, time.Second, time.Millisecond)
+
+	// cancelling a request which has not been sent does not notify the server
+	assert.True(t, waiting.Cancel())
+	_, err = (<-waiting.Get()).Unwrap()
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// cancelling a request which has been sent does
+	assert.True(t, sent.Cancel())
+	assert.False(t, sent.Cancel())
+	_, err = (<-sent.Get()).Unwrap()
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, string(req.Id), string(<-cancelled))
+
+	// the late response is unmatched
+	close(release)
+	assert.Equal(t, string(req.Id), string((<-unmatched).Id))
+
+	// the waiting request was never sent, and its slot has been released
+	name, err := sendName(t, client, "name")
+	assert.Nil(t, err)
+	assert.Equal(t, "a", name)
+	assert.Equal(t, int32(1), calls.Load())
+
+	_ = client.Close()
+	_, err = client.SendCancelable(*newRequest("name", nil))
+	assert.ErrorIs(t, err, jsonrpc.ErrClosed)
+}
+
+func TestClient_CancelByMethod(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	server := endpoint("a", release)
+
+	server.Register("slow", func(ctx context.Context, req jsonrpc.Request) (any, error) {
+		<-release
+		return "slow", nil
+	})
+
+	var started atomic.Int32
+	server.Use(func(next jsonrpc.Handler) jsonrpc.Handler {
+		return func(ctx context.Context, req jsonrpc.Request) (any, error) {
+			started.Add(1)
+			return next(ctx, req)
+		}
+	})
+
+	client := jsonrpc.NewClient(serverDialer(server, nil))
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	waits := []jsonrpc.ResponseFuture{
+		client.SendAsync(*newRequest("wait", nil)),
+		client.SendAsync(*newRequest("wait", nil)),
+	}
+	slow := client.SendAsync(*newRequest("slow", nil))
+	assert.Eventually(t, func() bool {
+		return started.Load() == 3
+	}, time.Second, time.Millisecond)
+
+	// only the requests for the method are cancelled
+	assert.Equal(t, 2, client.CancelByMethod("wait"))
+	assert.Equal(t, 0, client.CancelByMethod("wait"))
+	for _, future := range waits {
+		_, err := (<-future.Get()).Unwrap()
+		assert.ErrorIs(t, err, context.Canceled)
+	}
+	select {
+	case <-slow.Get():
+		assert.Fail(t, "request for another method cancelled")
+	default:
+	}
+
+	name, err := sendName(t, client, "name")
+	assert.Nil(t, err)
+	assert.Equal(t, "a", name)
+}
@@ -3,26 +3,59 @@ package jsonrpc
 import (
 	"context"
 	"encoding/json"
-	"strings"
+	"fmt"
+	"runtime/debug"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/41north/async.go"
 	"github.com/juju/errors"
-	gonanoid "github.com/matoous/go-nanoid"
 	log "github.com/sirupsen/logrus"
 )
 
 var (
-	idGen = func() string { return gonanoid.MustID(20) }
-
-	ErrClosed = errors.ConstError("connection has been closed")
+	ErrClosed             = errors.ConstError("connection has been closed")
+	ErrUnsupportedVersion = errors.ConstError("unsupported json-rpc version")
+	ErrPanic              = errors.ConstError("panic while handling message")
+	ErrNotificationId     = errors.ConstError("notification must not have an id")
+	ErrCloseTimeout       = errors.ConstError("timed out closing client")
 )
 
+// DefaultCloseTimeout is the default time Close waits for the connection to close and the client
+// to stop reading from it.
+const DefaultCloseTimeout = 5 * time.Second
+
+// RequestError annotates an error returned for a request with its method and id, the id being its
+// raw json. It wraps the underlying cause, so errors.Is and errors.As see through it.
+type RequestError struct {
+	Method string
+	ID     string
+	Err    error
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("%s (method %q, id %s)", e.Err, e.Method, e.ID)
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
 type (
 	ResponseFuture = async.Future[async.Result[*Response]]
+	// RequestHandler handles requests and notifications initiated by the server. Handlers are run
+	// off the read loop, by default one at a time in the order they were received, see
+	// WithMaxConcurrentHandlers.
 	RequestHandler = func(req Request)
-	CloseHandler   = func(err error)
+	// ContextRequestHandler is a RequestHandler which is also passed a context, from which the
+	// client which received the request is available with CallerFromContext.
+	ContextRequestHandler = func(ctx context.Context, req Request)
+	CloseHandler          = func(err error)
+	// UnmatchedHandler handles responses which could not be matched with a request awaiting a
+	// response, such as those with an unknown or duplicate id. Like request handlers, it is run off
+	// the read loop.
+	UnmatchedHandler = func(resp Response)
 )
 
 type Client interface {
@@ -32,104 +65,1079 @@ type Client interface {
 	SendContext(ctx context.Context, req Request, resp *Response) error
 	SendAsync(req Request) ResponseFuture
 
+	// SendRequest sends req as SendContext does, first assigning it an id if it has none, so the
+	// caller can read the id the request was sent with once it returns.
+	SendRequest(ctx context.Context, req *Request, resp *Response) error
+
+	// SendCancelable sends req as SendAsync does, returning a future with which the request can be
+	// cancelled, see CancelableFuture.
+	SendCancelable(req Request) (CancelableFuture, error)
+	// CancelByMethod cancels every request for method which is awaiting a response, as
+	// CancelableFuture.Cancel does, returning how many were cancelled.
+	CancelByMethod(method string) int
+
+	// SendWithPriority sends req as SendAsync does, with priority determining its place among the
+	// requests waiting for an in flight slot, see WithPriorityQueue.
+	SendWithPriority(req Request, priority Priority) ResponseFuture
+	SendBatch(ctx context.Context, batch BatchRequest) []ResponseFuture
+
+	// SendBatchContext sends batch and waits for the responses, which are returned in the same order
+	// as the requests. Elements without a response, whether because ctx is done first or because
+	// they failed, are nil. If ctx is done before every element has a response, the responses which
+	// did arrive are returned along with ctx.Err(). Otherwise the error is that of the first element
+	// to fail, if any.
+	SendBatchContext(ctx context.Context, batch BatchRequest) ([]*Response, error)
+
+	// NotifyBatch sends reqs as notifications in a single json array. No responses are expected
+	// and nothing is tracked in flight, so the requests must not have ids.
+	NotifyBatch(reqs []Request) error
+
+	// Subscribe delivers the notifications received for method to a Subscription, instead of the
+	// request handler. A method can only have one subscription at a time.
+	Subscribe(method string) (*Subscription, error)
+
+	// SubscribePattern delivers the notifications whose method matches pattern, in the syntax of
+	// path.Match, e.g. "account/*", to a Subscription. A notification matching several patterns is
+	// delivered to each of them, unless its method has a subscription made with Subscribe, which
+	// takes priority. A pattern can only have one subscription at a time.
+	SubscribePattern(pattern string) (*Subscription, error)
+
+	// UnsubscribePattern ends the subscription for pattern, if there is one, as
+	// Subscription.Unsubscribe does.
+	UnsubscribePattern(pattern string)
+
+	// InFlightByTenant returns the number of requests awaiting a response for each tenant, see
+	// RequestTenant. Requests without a tenant are counted against the empty string.
+	InFlightByTenant() map[string]int
+
+	// RecentCalls returns the most recently completed calls, oldest first, if WithDiagnosticBuffer
+	// has been set.
+	RecentCalls() []CallRecord
+
+	// Ping checks the connection is alive, see client.Ping.
+	Ping(ctx context.Context) error
+
+	// Migrate moves the client to a connection dialled with dialer, see client.Migrate.
+	Migrate(ctx context.Context, dialer Dialer) error
+
+	// Connection returns the connection currently in use, or nil if the client has not connected,
+	// as an escape hatch for inspecting the transport, e.g. the state of a TLS connection. The
+	// connection is replaced by Migrate. Reading from, writing to or otherwise mutating it
+	// concurrently with the client is unsupported.
+	Connection() Connection
+
+	// Update changes options of the client without reconnecting, see client.Update.
+	Update(options ...ClientOption) error
+
 	SetCloseHandler(handler CloseHandler)
 	SetRequestHandler(handler RequestHandler)
+	// SetContextRequestHandler sets a request handler which is passed a context, replacing any set
+	// with SetRequestHandler, see ContextRequestHandler.
+	SetContextRequestHandler(handler ContextRequestHandler)
+	// OnNotification registers handler for the notifications whose method matches pattern,
+	// returning a function which unregisters it, see client.OnNotification.
+	OnNotification(pattern string, handler NotificationHandler) (func(), error)
+	SetUnmatchedHandler(handler UnmatchedHandler)
+	// OnUnsolicitedError sets the handler of error responses with a null id, which are otherwise
+	// only logged at debug level, see UnsolicitedErrorHandler.
+	OnUnsolicitedError(handler UnsolicitedErrorHandler)
 
 	Close() error
 }
 
+// WithBaseContext ties the lifecycle of the client to ctx. The context is used when dialing and,
+// once connected, its cancellation closes the client and fails any in flight requests.
+func WithBaseContext(ctx context.Context) ClientOption {
+	return func(opts *ClientOptions) {
+		opts.BaseContext = ctx
+	}
+}
+
+// WithDialTimeout bounds how long Connect waits for the dialer, failing with an error matching both
+// ErrDial and context.DeadlineExceeded if it takes longer.
+func WithDialTimeout(timeout time.Duration) ClientOption {
+	return func(opts *ClientOptions) {
+		opts.DialTimeout = timeout
+	}
+}
+
+// WithCloseTimeout bounds how long Close waits for the connection to close and the client to stop
+// reading from it, returning an error matching ErrCloseTimeout if it takes longer, in which case
+// they are left to finish in the background. Zero returns without waiting. The default is
+// DefaultCloseTimeout.
+func WithCloseTimeout(timeout time.Duration) ClientOption {
+	return func(opts *ClientOptions) {
+		opts.CloseTimeout = timeout
+	}
+}
+
+// WithClock sets the clock used for the timeouts, intervals and backoffs of the client, see Clock.
+func WithClock(clock Clock) ClientOption {
+	return func(opts *ClientOptions) {
+		opts.Clock = clock
+	}
+}
+
+// WithLazyConnect defers dialing until the first request is sent, making Connect optional.
+// Concurrent first sends share a single dial, and if it fails the next send dials again.
+func WithLazyConnect() ClientOption {
+	return func(opts *ClientOptions) {
+		opts.LazyConnect = true
+	}
+}
+
+// WithIdGenerator sets the generator used to assign ids to requests which do not already have one.
+func WithIdGenerator(gen AnyIdGenerator) ClientOption {
+	return func(opts *ClientOptions) {
+		opts.IdGenerator = gen
+	}
+}
+
+// WithNanoIDLength assigns random string ids of length to requests which do not already have one,
+// in place of the DefaultNanoIDLength of the default generator. It replaces any generator set with
+// WithIdGenerator.
+func WithNanoIDLength(length int) ClientOption {
+	return WithIdGenerator(NanoID(length))
+}
+
+// WithAcceptedVersions sets the json-rpc version strings which are accepted in inbound messages.
+// Messages with any other version are rejected with ErrUnsupportedVersion.
+func WithAcceptedVersions(versions ...string) ClientOption {
+	return func(opts *ClientOptions) {
+		opts.AcceptedVersions = make(map[string]bool, len(versions))
+		for _, version := range versions {
+			opts.AcceptedVersions[version] = true
+		}
+	}
+}
+
+// WithAnyVersion accepts inbound messages regardless of their json-rpc version string.
+func WithAnyVersion() ClientOption {
+	return func(opts *ClientOptions) {
+		opts.AcceptedVersions = nil
+	}
+}
+
+// WithRequestVersion sets the json-rpc version string sent in all outgoing requests. An empty
+// version omits the field entirely.
+func WithRequestVersion(version string) ClientOption {
+	return func(opts *ClientOptions) {
+		opts.RequestVersion = version
+	}
+}
+
+// WithRetryBudget limits retries to ratio of the requests made, plus minPerSec retries a second
+// which are always permitted, measured with the clock of the client. See RetryBudget. The retries
+// of RetryInterceptor and the requests re-issued by Resend are refused once the budget is empty,
+// failing with an error matching ErrRetryBudgetExhausted. Each refusal is reported to a
+// RetryBudgetObserver.
+func WithRetryBudget(ratio float64, minPerSec int) ClientOption {
+	return func(opts *ClientOptions) {
+		opts.RetryBudget = NewRetryBudget(ratio, minPerSec)
+	}
+}
+
+// WithErrorRegistry decodes error responses into the application level errors registered with reg.
+// The decoded error is returned by Response.UnmarshalResult.
+func WithErrorRegistry(reg *ErrorRegistry) ClientOption {
+	return func(opts *ClientOptions) {
+		opts.ErrorRegistry = reg
+	}
+}
+
+// WithRequestMutator sets a function which may modify every outgoing request, for example to add
+// extension fields. It is applied after the id and version have been assigned, and before any
+// interceptors, see WithInterceptors. Extensions set by the mutator are not visible on the caller's
+// Request.
+func WithRequestMutator(mutator func(req *Request)) ClientOption {
+	return func(opts *ClientOptions) {
+		opts.RequestMutator = mutator
+	}
+}
+
+// WithMaxConcurrentHandlers sets the maximum number of request handlers which can run concurrently.
+// Handlers are always started in the order their requests were received, but with a value greater
+// than one they may complete in any order. The default is one.
+func WithMaxConcurrentHandlers(max int) ClientOption {
+	return func(opts *ClientOptions) {
+		opts.MaxConcurrentHandlers = max
+	}
+}
+
+// WithCloseOnPanic closes the client if a panic is recovered while handling an inbound message or
+// running a request handler. By default the panic is logged and the client continues.
+func WithCloseOnPanic() ClientOption {
+	return func(opts *ClientOptions) {
+		opts.CloseOnPanic = true
+	}
+}
+
+// WithObserver sets an observer which is notified of the messages exchanged by the client.
+func WithObserver(observer Observer) ClientOption {
+	return func(opts *ClientOptions) {
+		opts.Observer = observer
+	}
+}
+
+// WithRedactor sets the redactor applied to params before they are logged. By default params are
+// redacted entirely, see RedactAll and RedactRules.
+func WithRedactor(redactor Redactor) ClientOption {
+	return func(opts *ClientOptions) {
+		opts.Redactor = redactor
+	}
+}
+
+// WithLogger sets the logger of the client, in place of the standard logrus logger.
+func WithLogger(logger *log.Entry) ClientOption {
+	return func(opts *ClientOptions) {
+		opts.Logger = logger
+	}
+}
+
+type ClientOption = func(opts *ClientOptions)
+
+type ClientOptions struct {
+	BaseContext      context.Context
+	Clock            Clock
+	DialTimeout      time.Duration
+	CloseTimeout     time.Duration
+	ConnectAttempts  int
+	ConnectBackoff   Backoff
+	LazyConnect      bool
+	OnConnect        ConnectHook
+	IdGenerator      AnyIdGenerator
+	AcceptedVersions map[string]bool
+	RequestVersion   string
+	RetryBudget      *RetryBudget
+	ErrorRegistry    *ErrorRegistry
+	ErrorClassifier  ErrorClassifier
+	RequestMutator   func(req *Request)
+
+	MaxConcurrentHandlers int
+	CloseOnPanic          bool
+	Observer              Observer
+	Redactor              Redactor
+	Logger                *log.Entry
+	FairQueueWeights      map[string]int
+
+	OfflineQueueMaxEntries int
+	OfflineQueueMaxAge     time.Duration
+
+	SubscriptionBuffer   int
+	SubscriptionOverflow OverflowPolicy
+
+	AutoBatchMaxSize int
+	AutoBatchWindow  time.Duration
+
+	WriteCoalesceMaxBytes int
+	WriteCoalesceMaxDelay time.Duration
+
+	PositionalCorrelation bool
+
+	MaxInFlight   int
+	PriorityQueue bool
+
+	DiagnosticBuffer int
+	ResponseMeta     bool
+	UseNumber        bool
+	NullResultError  bool
+
+	Interceptors []UnaryInterceptor
+
+	KeepAliveInterval time.Duration
+	KeepAliveTimeout  time.Duration
+	KeepAliveMethod   string
+
+	CancelMethod string
+
+	ReconnectFailMode ReconnectFailMode
+	ResendMethods     map[string]bool
+
+	CorrelateRequest  func(req Request) string
+	CorrelateResponse func(resp Response) string
+
+	SingleFlightKey func(req Request) (string, bool)
+
+	InFlightTTL           time.Duration
+	InFlightSweepInterval time.Duration
+}
+
+func DefaultClientOptions() ClientOptions {
+	return ClientOptions{
+		BaseContext:      context.Background(),
+		Clock:            RealClock(),
+		CloseTimeout:     DefaultCloseTimeout,
+		IdGenerator:      DefaultIdGenerator,
+		AcceptedVersions: map[string]bool{"2.0": true},
+		RequestVersion:   "2.0",
+		ErrorClassifier:  DefaultErrorClassifier,
+
+		MaxConcurrentHandlers: 1,
+		Redactor:              RedactAll,
+		SubscriptionBuffer:    DefaultSubscriptionBuffer,
+
+		CorrelateRequest:  CorrelateById,
+		CorrelateResponse: CorrelateResponseById,
+	}
+}
+
+// acceptsVersion returns true if version is one of the accepted versions, or if any version is accepted.
+func (o *ClientOptions) acceptsVersion(version string) bool {
+	return o.AcceptedVersions == nil || o.AcceptedVersions[version]
+}
+
+// inFlightRequest tracks a request which is awaiting a response.
+type inFlightRequest struct {
+	// key is the correlation key of the request, which is its id unless a correlator has been set
+	key    string
+	id     string
+	future ResponseFuture
+	method string
+	tenant string
+	// ctx is the context of the call, if any, see ContextObserver
+	ctx context.Context
+	// batch is set if the request was sent as part of a batch
+	batch *pendingBatch
+	// release is set if the request holds a slot limited by WithMaxInFlight
+	release func()
+	// recent is set if calls are recorded, see WithDiagnosticBuffer
+	recent *callRing
+	start  time.Time
+	// written is when the request was written, in unix nanoseconds, if response details are recorded
+	written atomic.Int64
+	// canceled is set once the request has been cancelled, see CancelableFuture
+	canceled atomic.Bool
+	// data is the json of the request, kept if it may be resent, see WithReconnectFailMode
+	data []byte
+	// timer holds the Timer of an element timeout, see BatchRequest, stopped once the request has
+	// resolved, as resolved records
+	timer    atomic.Value
+	resolved atomic.Bool
+}
+
+// context returns the context of the call the request was sent by, or context.Background.
+func (r *inFlightRequest) context() context.Context {
+	if r.ctx == nil {
+		return context.Background()
+	}
+	return r.ctx
+}
+
 type client struct {
-	dialer       Dialer
-	conn         Connection
-	inFlight     sync.Map
-	log          *log.Entry
-	closed       atomic.Bool
-	reqHandler   RequestHandler
-	closeError   error
-	closeHandler CloseHandler
+	opts   ClientOptions
+	dialer Dialer
+	// connMu guards the connection and the state created alongside it
+	connMu   sync.RWMutex
+	conn     Connection
+	inFlight sync.Map
+	// live holds the options which can be changed with Update
+	live       atomic.Pointer[liveOptions]
+	updateMu   sync.Mutex
+	closed     atomic.Bool
+	connected  atomic.Bool
+	connectMu  sync.Mutex
+	done       chan struct{}
+	dispatcher *dispatcher
+	// handshake is closed once the connect hook in progress, if any, has returned
+	handshake chan struct{}
+	// keepAliveReset signals the keep alive loop that its interval has been updated
+	keepAliveReset chan struct{}
+	fairQueue      *fairQueue
+	outbox         *outbox
+	batcher        *autoBatcher
+	coalescer      *writeCoalescer
+	positional     *positionalQueue
+	admission      *admission
+	recent         *callRing
+	flights        *singleFlight
+	ordering       *orderingKeys
+	invoker        UnaryInvoker
+	// sweeper starts the expiry of requests which outlive the in flight ttl on the first connect
+	sweeper sync.Once
+	// subscriptions holds a *Subscription for each subscribed notification method
+	subscriptions sync.Map
+	routes        notificationRouter
+	reqHandler    ContextRequestHandler
+	unmatched     UnmatchedHandler
+	unsolicited   UnsolicitedErrorHandler
+	closeError    error
+	closeHandler  CloseHandler
+	// connClosed is closed once the connection closed by Close has done so
+	connClosed chan struct{}
+	// readers holds the done channel of each read loop which is running
+	readers sync.Map
+	// oneShot is true while the dialer creates one shot connections, which cannot carry
+	// subscriptions
+	oneShot atomic.Bool
+
+	// patternSubscriptions holds a *Subscription for each pattern subscribed to
+	patternSubscriptions sync.Map
 }
 
-func NewClient(dialer Dialer) Client {
-	return &client{
-		dialer: dialer,
+func NewClient(dialer Dialer, options ...ClientOption) Client {
+	opts := DefaultClientOptions()
+	for _, opt := range options {
+		opt(&opts)
+	}
+	if opts.Logger == nil {
+		opts.Logger = log.WithField("connectionId", "tbd")
+	}
+	if opts.RetryBudget != nil {
+		opts.RetryBudget.SetClock(opts.Clock)
+	}
+	c := &client{
+		opts:           opts,
+		dialer:         dialer,
+		done:           make(chan struct{}),
+		keepAliveReset: make(chan struct{}, 1),
+		ordering:       newOrderingKeys(),
+	}
+	c.live.Store(newLiveOptions(opts))
+	c.oneShot.Store(isOneShot(dialer))
+	if opts.OfflineQueueMaxEntries > 0 {
+		c.outbox = newOutbox(opts.OfflineQueueMaxEntries, opts.OfflineQueueMaxAge, opts.Clock)
+	}
+	if opts.AutoBatchMaxSize > 0 {
+		c.batcher = newAutoBatcher(opts.AutoBatchMaxSize, opts.AutoBatchWindow, opts.Clock, c.sendAutoBatch)
+	}
+	if opts.PositionalCorrelation {
+		c.positional = &positionalQueue{}
 	}
+	if opts.WriteCoalesceMaxBytes > 0 {
+		c.coalescer = newWriteCoalescer(opts.WriteCoalesceMaxBytes, opts.WriteCoalesceMaxDelay, opts.Clock, c.positional)
+	}
+	if opts.MaxInFlight > 0 {
+		c.admission = newAdmission(opts.MaxInFlight)
+	}
+	if opts.DiagnosticBuffer > 0 {
+		c.recent = newCallRing(opts.DiagnosticBuffer, opts.Clock)
+	}
+	if opts.SingleFlightKey != nil {
+		c.flights = newSingleFlight()
+	}
+	c.invoker = chainInterceptors(opts.Interceptors, func(ctx context.Context, req Request) (Response, error) {
+		var resp Response
+		err := c.sendContext(ctx, req, &resp, false)
+		return resp, err
+	})
+	if len(opts.Interceptors) > 0 {
+		invoker := c.invoker
+		c.invoker = func(ctx context.Context, req Request) (Response, error) {
+			return invoker(context.WithValue(ctx, senderKey{}, c), req)
+		}
+	}
+	return c
+}
+
+// NewClientWithConnection creates a client which uses an existing connection, such as one created
+// with NewStreamConnection. Connect must still be called to begin processing messages.
+func NewClientWithConnection(conn Connection, options ...ClientOption) Client {
+	return NewClient(&connectionDialer{conn: conn}, options...)
 }
 
 func (c *client) Connect() error {
-	conn, err := c.dialer.Dial()
-	if err != nil {
+	c.connectMu.Lock()
+	defer c.connectMu.Unlock()
+
+	if c.closed.Load() {
+		return ErrClosed
+	}
+	if c.connected.Load() {
+		return nil
+	}
+	return c.connect()
+}
+
+// ensureConnected connects the client if it has not already done so, for use with lazy connect.
+// Concurrent callers wait for a single dial to complete.
+func (c *client) ensureConnected() error {
+	if c.connected.Load() {
+		return nil
+	}
+	return c.Connect()
+}
+
+// connect dials and starts processing messages, retrying as configured by WithConnectRetry. Holding
+// connectMu serialises every dial made by the client, including those of Migrate, so a client never
+// dials concurrently with itself.
+func (c *client) connect() error {
+	if err := c.retryConnect(c.connectOnce); err != nil {
 		return err
 	}
+	go c.watchContext()
+
+	c.connected.Store(true)
+
+	if c.opts.KeepAliveInterval > 0 {
+		go c.keepAlive()
+	}
+	if c.opts.InFlightTTL > 0 {
+		c.sweeper.Do(func() {
+			go c.sweepInFlight()
+		})
+	}
+
+	if c.outbox != nil {
+		c.outbox.flush(c.sendQueued)
+	}
+
+	return nil
+}
+
+// connectOnce makes a single attempt at connecting, dialing a connection and running the connect
+// hook against it. Sends other than those of the hook wait until it returns. If it fails the
+// connection is abandoned, leaving the client open for another attempt.
+func (c *client) connectOnce(ctx context.Context) error {
+	conn, err := c.dialOnce(ctx)
+	if err != nil {
+		return &DialError{Cause: err}
+	}
 
+	// the connection state is published under connMu, so that it cannot be missed by a concurrent close
+	c.connMu.Lock()
+	if c.closed.Load() {
+		c.connMu.Unlock()
+		_ = conn.Close()
+		return ErrClosed
+	}
 	c.conn = conn
-	c.inFlight = sync.Map{}
-	c.log = log.WithField("connectionId", "tbd")
+	// kept across attempts, as the read loop of an abandoned connection may still be using them
+	if c.dispatcher == nil {
+		c.dispatcher = newDispatcher(c.opts.MaxConcurrentHandlers)
+	}
+	if c.fairQueue == nil && c.opts.FairQueueWeights != nil {
+		c.fairQueue = newFairQueue(c.writeMessage, c.opts.FairQueueWeights)
+	}
+	var handshake chan struct{}
+	if c.opts.OnConnect != nil {
+		handshake = make(chan struct{})
+		c.handshake = handshake
+	}
+	c.connMu.Unlock()
 
-	go c.readMessages()
+	c.startReading(conn)
 
+	if handshake == nil {
+		return nil
+	}
+	err = c.opts.OnConnect(ctx, &handshakeClient{c})
+	if err != nil {
+		c.abandon(conn)
+	}
+	c.endHandshake(handshake)
+
+	if err != nil {
+		return errors.Annotate(err, "connect hook failed")
+	}
 	return nil
 }
 
+// abandon closes conn, which the connect hook failed to initialise, without closing the client.
+// Requests sent on it by the hook which have yet to complete fail with ErrClosed.
+func (c *client) abandon(conn Connection) {
+	c.connMu.Lock()
+	if c.conn == conn {
+		c.conn = nil
+	}
+	c.connMu.Unlock()
+	_ = conn.Close()
+
+	c.inFlight.Range(func(_, value any) bool {
+		c.expire(value.(*inFlightRequest), ErrClosed)
+		return true
+	})
+}
+
+// endHandshake releases the sends waiting in awaitHandshake once the connect hook has returned.
+func (c *client) endHandshake(handshake chan struct{}) {
+	c.connMu.Lock()
+	c.handshake = nil
+	c.connMu.Unlock()
+	close(handshake)
+}
+
+// awaitHandshake waits for the connect hook in progress, if there is one, to return, so that nothing
+// but the hook writes to a connection before it has been initialised.
+func (c *client) awaitHandshake() {
+	c.connMu.RLock()
+	handshake := c.handshake
+	c.connMu.RUnlock()
+	if handshake == nil {
+		return
+	}
+	select {
+	case <-handshake:
+	case <-c.done:
+	}
+}
+
+// fail resolves the request with err, annotated with the method and id. It returns false if the
+// request had already resolved.
+func (r *inFlightRequest) fail(err error) bool {
+	return r.settle(async.NewResultErr[*Response](&RequestError{Method: r.method, ID: r.id, Err: err}))
+}
+
+// settle resolves the request with result, freeing its in flight slot if it holds one. It returns
+// false if the request had already resolved.
+func (r *inFlightRequest) settle(result async.Result[*Response]) bool {
+	if !r.future.Set(result) {
+		return false
+	}
+	r.resolved.Store(true)
+	r.stopTimer()
+	if r.recent != nil {
+		resp, err := result.Unwrap()
+		r.recent.record(r, resp, err)
+	}
+	if r.release != nil {
+		r.release()
+	}
+	return true
+}
+
+// setTimer keeps timer so that it is stopped once the request resolves, stopping it at once if the
+// request has already done so.
+func (r *inFlightRequest) setTimer(timer Timer) {
+	r.timer.Store(timer)
+	if r.resolved.Load() {
+		timer.Stop()
+	}
+}
+
+// stopTimer stops the timer of the request, if it has one.
+func (r *inFlightRequest) stopTimer() {
+	if timer, ok := r.timer.Load().(Timer); ok {
+		timer.Stop()
+	}
+}
+
+// sendQueued sends a message which was held in the offline queue until the client connected.
+func (c *client) sendQueued(entry *outboxEntry) {
+	if entry.send != nil {
+		entry.send()
+		return
+	}
+	if entry.request == nil {
+		c.write(context.Background(), entry.tenant, entry.data, func(err error) {
+			if err != nil {
+				c.logger().WithError(err).Warn("failed to send notifications")
+			}
+		})
+		return
+	}
+	if entry.request.canceled.Load() {
+		return
+	}
+	c.inFlight.Store(entry.key, entry.request)
+	c.write(entry.request.context(), entry.tenant, entry.data, func(err error) {
+		c.onWritten(entry.request, err)
+	})
+}
+
+// watchContext closes the client when the base context is cancelled.
+func (c *client) watchContext() {
+	select {
+	case <-c.done:
+	case <-c.opts.BaseContext.Done():
+		_ = c.closeWithError(c.opts.BaseContext.Err())
+	}
+}
+
 func (c *client) SetRequestHandler(handler RequestHandler) {
+	c.SetContextRequestHandler(withRequestContext(handler))
+}
+
+func (c *client) SetContextRequestHandler(handler ContextRequestHandler) {
 	c.reqHandler = handler
 }
 
+// withRequestContext adapts handler, if any, to a ContextRequestHandler which ignores its context.
+func withRequestContext(handler RequestHandler) ContextRequestHandler {
+	if handler == nil {
+		return nil
+	}
+	return func(ctx context.Context, req Request) {
+		handler(req)
+	}
+}
+
+func (c *client) SetUnmatchedHandler(handler UnmatchedHandler) {
+	c.unmatched = handler
+}
+
 func (c *client) SetCloseHandler(handler CloseHandler) {
 	c.closeHandler = handler
 }
 
-func (c *client) readMessages() {
+// Connection returns the connection currently used for writing.
+func (c *client) Connection() Connection {
+	c.connMu.RLock()
+	defer c.connMu.RUnlock()
+	return c.conn
+}
+
+// setConnection replaces the connection used for writing, returning the previous one.
+func (c *client) setConnection(conn Connection) Connection {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+	prev := c.conn
+	c.conn = conn
+	return prev
+}
+
+// queue returns the fair queue, if one has been configured and the client has connected.
+func (c *client) queue() *fairQueue {
+	c.connMu.RLock()
+	defer c.connMu.RUnlock()
+	return c.fairQueue
+}
+
+// writeConnection writes data to the current connection, abandoning the write once ctx is done if
+// the connection is a ContextWriter.
+func (c *client) writeConnection(ctx context.Context, data []byte) error {
+	conn := c.Connection()
+	if conn == nil {
+		return ErrNotConnected
+	}
+	if c.positional != nil {
+		return c.positional.write(ctx, conn, data)
+	}
+	return writeContext(ctx, conn, data)
+}
+
+// writeMessage writes data to the current connection, or queues it to be written with others if
+// write coalescing has been configured, calling onWritten once the write completes. ctx is that of
+// the call data belongs to, see writeConnection.
+func (c *client) writeMessage(ctx context.Context, data []byte, onWritten func(err error)) {
+	if frames, ok := c.Connection().(frameWriter); ok && c.coalescer != nil {
+		c.coalescer.add(frames, data, onWritten)
+		return
+	}
+	onWritten(c.writeConnection(ctx, data))
+}
+
+// startReading starts processing messages from conn, tracking the read loop so that Close can wait
+// for it to exit.
+func (c *client) startReading(conn Connection) {
+	done := make(chan struct{})
+	c.readers.Store(done, struct{}{})
+	go func() {
+		defer func() {
+			c.readers.Delete(done)
+			close(done)
+		}()
+		c.readMessages(conn)
+	}()
+}
+
+// readMessages processes messages from conn until it closes. Closing the current connection closes
+// the client, whereas a connection which has been replaced by Migrate is allowed to close quietly.
+func (c *client) readMessages(conn Connection) {
 	for !c.closed.Load() {
 		// read the next response
-		bytes, err := c.conn.Read()
+		bytes, meta, err := c.readMessage(conn)
 		if err != nil {
-			// set the client has closed and break out of the read loop
-			if err == ErrClosed {
-				c.closeError = err
-				c.Close()
+			if c.onTransportError(err) {
+				continue
+			}
+
+			// set the client has closed and break out of the read loop, including when the
+			// framing is corrupt as the stream cannot be resynchronised
+			if errors.Is(err, ErrClosed) || errors.Is(err, ErrFraming) {
+				if conn == c.Connection() {
+					_ = c.closeWithError(err)
+				}
 				break
 			}
 
 			// otherwise log the error
-			c.log.WithError(err).Error("read failure")
+			c.logger().WithError(err).Error("read failure")
+			continue
 		}
 
-		hasMethod := strings.Contains(string(bytes), "method")
-		if hasMethod {
-			// we assume this is a notification
-			var req Request
-			if err := json.Unmarshal(bytes, &req); err != nil {
-				c.log.WithError(err).Error("unmarshal failure")
-			} else {
-				c.reqHandler(req)
+		if isBatch(bytes) {
+			var batch []json.RawMessage
+			if err := json.Unmarshal(bytes, &batch); err != nil {
+				c.logger().WithError(err).Error("unmarshal failure")
+				continue
 			}
+			c.onBatch(batch, meta)
 		} else {
-			// otherwise we assume it is a response
 			var resp Response
 			if err := json.Unmarshal(bytes, &resp); err != nil {
-				c.log.WithError(err).Error("unmarshal failure")
-			} else {
-				c.onResponse(&resp)
+				c.logger().WithError(err).Error("unmarshal failure")
+				continue
+			}
+			resp.meta = meta.withSize(len(bytes))
+			if c.positional != nil {
+				c.positional.correlate(&resp)
 			}
+			c.onMessage(&resp, len(bytes))
 		}
 	}
 }
 
-func (c *client) onResponse(resp *Response) {
-	future, ok := c.inFlight.LoadAndDelete(string(resp.Id))
+// onTransportError fails the requests of the message which err reports could not be exchanged,
+// returning false if err is not such a report.
+func (c *client) onTransportError(err error) bool {
+	var transportErr *TransportError
+	if !errors.As(err, &transportErr) || transportErr.data == nil {
+		return false
+	}
+	if c.positional != nil {
+		c.positional.discard(transportErr.data)
+	}
+
+	var requests []Request
+	if isBatch(transportErr.data) {
+		if err := json.Unmarshal(transportErr.data, &requests); err != nil {
+			c.logger().WithError(err).Error("unmarshal failure")
+			return true
+		}
+	} else {
+		var req Request
+		if err := json.Unmarshal(transportErr.data, &req); err != nil {
+			c.logger().WithError(err).Error("unmarshal failure")
+			return true
+		}
+		requests = append(requests, req)
+	}
+
+	cause := &TransportError{StatusCode: transportErr.StatusCode, Cause: transportErr.Cause}
+	for _, req := range requests {
+		if req.Id == nil {
+			continue
+		}
+		if value, ok := c.inFlight.Load(c.opts.CorrelateRequest(req)); ok {
+			c.expire(value.(*inFlightRequest), cause)
+		}
+	}
+	return true
+}
+
+// onMessage handles an inbound message, size is the length of its json on the wire.
+func (c *client) onMessage(resp *Response, size int) {
+	resp.useNumber = c.opts.UseNumber
+	resp.nullResultError = c.opts.NullResultError
+
+	defer func() {
+		if r := recover(); r != nil {
+			err := c.onPanic(r)
+			// fail the affected request, if there is one
+			if isUnsolicitedError(resp) {
+				// there is no request to fail
+			} else if value, ok := c.inFlight.LoadAndDelete(c.opts.CorrelateResponse(*resp)); ok {
+				value.(*inFlightRequest).fail(err)
+			}
+			c.closeOnPanic(err)
+		}
+	}()
+
+	switch resp.Kind() {
+	case KindNotification, KindRequest:
+		if !c.opts.acceptsVersion(resp.Version) {
+			c.logger().
+				WithField("version", resp.Version).
+				Warn("request received with unsupported version")
+		} else if subs := c.subscriptionsFor(resp); len(subs) > 0 {
+			req := resp.Request()
+			for _, sub := range subs {
+				sub.deliver(req)
+			}
+		} else if resp.Kind() == KindNotification && c.route(resp.Request()) {
+			// handled by the routes registered with OnNotification
+		} else if c.reqHandler == nil {
+			c.logger().
+				WithField("method", resp.Method).
+				Warn("request received with no request handler set")
+		} else {
+			req := resp.Request()
+			c.logger().
+				WithField("method", req.Method).
+				WithField("params", string(c.liveOptions().redactor(req.Method, req.Params))).
+				Debug("request received")
+			ctx := context.WithValue(c.opts.BaseContext, callerKey{}, Client(c))
+			c.dispatcher.dispatch(func() {
+				defer func() {
+					if r := recover(); r != nil {
+						c.closeOnPanic(c.onPanic(r))
+					}
+				}()
+				c.reqHandler(ctx, req)
+			})
+		}
+	case KindError:
+		if isUnsolicitedError(resp) {
+			c.onUnsolicitedError(resp)
+		} else {
+			c.onResponse(resp, size)
+		}
+	default:
+		c.onResponse(resp, size)
+	}
+}
+
+// onPanic logs a recovered panic and converts it into an error.
+func (c *client) onPanic(r any) error {
+	err := errors.Annotatef(ErrPanic, "%v", r)
+	c.logger().
+		WithError(err).
+		WithField("stack", string(debug.Stack())).
+		Error("recovered from panic")
+	return err
+}
+
+func (c *client) closeOnPanic(err error) {
+	if c.opts.CloseOnPanic {
+		_ = c.closeWithError(err)
+	}
+}
+
+func (c *client) onResponse(resp *Response, size int) {
+	// the entry is only removed once the response has been processed, so that it can be failed
+	// if processing panics
+	key := c.opts.CorrelateResponse(*resp)
+	value, ok := c.inFlight.Load(key)
 	if !ok {
-		c.log.
-			WithField("id", resp.Id).
+		c.onUnmatched(resp)
+		return
+	}
+	inFlight := value.(*inFlightRequest)
+
+	observeResponse(inFlight.context(), c.opts.Observer, inFlight.method, size)
+
+	if !c.opts.acceptsVersion(resp.Version) {
+		err := errors.Annotatef(ErrUnsupportedVersion, "received version %q", resp.Version)
+		c.inFlight.Delete(key)
+		inFlight.fail(err)
+		return
+	}
+	if resp.Error != nil {
+		resp.appError = c.decodeError(*resp.Error)
+	}
+	inFlight.completeMeta(resp)
+	c.inFlight.Delete(key)
+	inFlight.settle(async.NewResultValue[*Response](resp))
+}
+
+// decodeError converts an error response into an application level error, using the error registry
+// and then the translation of the error classifier. It returns nil if neither applies.
+func (c *client) decodeError(e Error) error {
+	var err error
+	if c.opts.ErrorRegistry != nil {
+		err = c.opts.ErrorRegistry.Decode(e)
+		if _, unchanged := err.(Error); !unchanged {
+			return err
+		}
+	}
+	if translate := c.opts.ErrorClassifier.Translate; translate != nil {
+		if translated := translate(&e); translated != nil {
+			return translated
+		}
+	}
+	return err
+}
+
+// onUnmatched passes resp to the unmatched handler, if one has been set.
+func (c *client) onUnmatched(resp *Response) {
+	if c.unmatched == nil {
+		c.logger().
+			WithField("id", string(resp.Id)).
 			Warn("response received with unrecognised id")
+		return
 	}
-	future.(ResponseFuture).Set(async.NewResultValue[*Response](resp))
+	unmatched := *resp
+	c.dispatcher.dispatch(func() {
+		defer func() {
+			if r := recover(); r != nil {
+				c.closeOnPanic(c.onPanic(r))
+			}
+		}()
+		c.unmatched(unmatched)
+	})
 }
 
+// Close closes the client, then waits for the connection to close and for the client to stop
+// reading from it, for up to the close timeout, see WithCloseTimeout.
 func (c *client) Close() error {
+	if err := c.closeWithError(nil); err != nil {
+		return err
+	}
+	if c.opts.CloseTimeout <= 0 {
+		return nil
+	}
+
+	timer := c.opts.Clock.NewTimer(c.opts.CloseTimeout)
+	defer timer.Stop()
+
+	if c.connClosed != nil {
+		select {
+		case <-c.connClosed:
+		case <-timer.C():
+			return errors.Annotatef(ErrCloseTimeout, "connection did not close within %s", c.opts.CloseTimeout)
+		}
+	}
+
+	var err error
+	c.readers.Range(func(key, _ any) bool {
+		select {
+		case <-key.(chan struct{}):
+			return true
+		case <-timer.C():
+			err = errors.Annotatef(ErrCloseTimeout, "read loop did not exit within %s", c.opts.CloseTimeout)
+			return false
+		}
+	})
+	return err
+}
+
+func (c *client) closeWithError(err error) error {
 	if c.closed.CompareAndSwap(false, true) {
-		// cancel any in flight requests
+		c.closeError = err
+		close(c.done)
+
+		c.connMu.RLock()
+		conn, dispatcher, fairQueue := c.conn, c.dispatcher, c.fairQueue
+		c.connMu.RUnlock()
+
+		if conn != nil {
+			// closed in the background, so that a connection which is slow to close cannot block
+			// the caller, whether that is Close or the read loop
+			connClosed := make(chan struct{})
+			c.connClosed = connClosed
+			go func() {
+				defer close(connClosed)
+				_ = conn.Close()
+			}()
+		}
+		if dispatcher != nil {
+			dispatcher.close()
+		}
+		if fairQueue != nil {
+			fairQueue.close()
+		}
+		if c.outbox != nil {
+			c.outbox.close()
+		}
+		if c.batcher != nil {
+			c.batcher.close()
+		}
+		if c.admission != nil {
+			c.admission.close()
+		}
+		c.subscriptions.Range(func(_, value any) bool {
+			value.(*Subscription).Unsubscribe()
+			return true
+		})
+		c.patternSubscriptions.Range(func(_, value any) bool {
+			value.(*Subscription).Unsubscribe()
+			return true
+		})
+
+		// cancel any in flight requests, including the close reason if there is one
+		cause := c.lostCause(err)
 		c.inFlight.Range(func(key, value any) bool {
-			value.(ResponseFuture).Set(async.NewResultErr[*Response](ErrClosed))
+			value.(*inFlightRequest).fail(cause)
 			return true
 		})
 
@@ -143,59 +1151,351 @@ func (c *client) Close() error {
 	}
 }
 
+// GetContext returns a channel which receives the result of future, or a result holding ctx.Err()
+// if ctx is done first. It only stops the caller waiting; the request itself is not cancelled and
+// other readers of future are unaffected.
+func GetContext(ctx context.Context, future ResponseFuture) <-chan async.Result[*Response] {
+	ch := make(chan async.Result[*Response], 1)
+	go func() {
+		defer close(ch)
+		select {
+		case result := <-future.Get():
+			ch <- result
+		case <-ctx.Done():
+			ch <- async.NewResultErr[*Response](ctx.Err())
+		}
+	}()
+	return ch
+}
+
 func (c *client) Send(req Request, resp *Response) error {
 	return c.SendContext(context.Background(), req, resp)
 }
 
 func (c *client) SendContext(ctx context.Context, req Request, resp *Response) error {
-	future := c.SendAsync(req)
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	case result := <-future.Get():
-		r, err := result.Unwrap()
-		if err != nil {
-			return err
+	// interceptors see the request as it is sent
+	if err := c.prepare(&req); err != nil {
+		return &RequestError{Method: req.Method, Err: err}
+	}
+
+	if req.orderingKey != "" && !req.ordered {
+		// hold the place of the request across the whole chain, including any retries
+		req.ordered = true
+		return c.ordering.sendOrdered(ctx, req.orderingKey, func() error {
+			return c.SendContext(ctx, req, resp)
+		})
+	}
+
+	r, err := c.invoker(ctx, req)
+	if err != nil {
+		return err
+	}
+	*resp = r
+	return nil
+}
+
+func (c *client) SendRequest(ctx context.Context, req *Request, resp *Response) error {
+	if err := req.ensureId(c.opts.IdGenerator); err != nil {
+		return err
+	}
+	return c.SendContext(ctx, *req, resp)
+}
+
+func (c *client) sendContext(ctx context.Context, req Request, resp *Response, direct bool) error {
+	req.ctx = ctx
+	future, id := c.sendAsync(req, PriorityNormal, direct)
+	r, err := (<-GetContext(ctx, future)).Unwrap()
+	if err != nil {
+		if ctx.Err() != nil && !c.mayShare(req) {
+			c.withdraw(future, ctx.Err())
 		}
-		// TODO can this copy be removed?
-		resp.Id = r.Id
-		resp.Result = r.Result
-		resp.Error = r.Error
-		resp.Version = r.Version
-		return nil
+		var reqErr *RequestError
+		if !errors.As(err, &reqErr) {
+			err = &RequestError{Method: req.Method, ID: id, Err: err}
+		}
+		return err
 	}
+	*resp = *r
+	return nil
 }
 
 func (c *client) SendAsync(req Request) ResponseFuture {
+	future, _ := c.sendAsync(req, PriorityNormal, false)
+	return future
+}
+
+// sendAsync sends req, returning its future along with its id. A direct send is written
+// immediately, bypassing lazy connect, the offline queue, auto batching and the in flight limit.
+func (c *client) sendAsync(req Request, priority Priority, direct bool) (ResponseFuture, string) {
+	send := func() (ResponseFuture, string) {
+		request, _ := c.sendRequest(req, priority, direct)
+		return request.future, request.id
+	}
+	if c.flights != nil && !direct {
+		if key, ok := c.opts.SingleFlightKey(req); ok {
+			return c.flights.do(key, send)
+		}
+	}
+	return send()
+}
+
+// sendRequest sends req as sendAsync does, returning its in flight entry. If req fails before it
+// could be sent, e.g. because the client is closed, the error is returned too.
+func (c *client) sendRequest(req Request, priority Priority, direct bool) (*inFlightRequest, error) {
 	// create a future for returning the result
 	future := async.NewFuture[async.Result[*Response]]()
+	request := &inFlightRequest{future: future, method: req.Method, tenant: req.tenant, ctx: req.ctx, recent: c.recent, start: c.opts.Clock.Now()}
 
-	// ensure a request id
-	if err := req.EnsureId(idGen); err != nil {
-		future.Set(async.NewResultErr[*Response](err))
-		return future
+	if err := c.prepare(&req); err != nil {
+		request.fail(err)
+		return request, err
 	}
+	request.key = c.opts.CorrelateRequest(req)
+	request.id = string(req.Id)
 
+	// wait for the requests before it with the same ordering key to complete
+	if req.orderingKey != "" && !req.ordered && !direct {
+		wait, done := c.ordering.enter(req.orderingKey)
+		go func() {
+			<-future.Get()
+			done()
+		}()
+		select {
+		case <-wait:
+		default:
+			go func() {
+				<-wait
+				_, _ = c.sendPrepared(req, request, priority, direct)
+			}()
+			return request, nil
+		}
+	}
+
+	return c.sendPrepared(req, request, priority, direct)
+}
+
+// sendPrepared sends req, which has been prepared, as sendRequest does.
+func (c *client) sendPrepared(req Request, request *inFlightRequest, priority Priority, direct bool) (*inFlightRequest, error) {
 	if c.closed.Load() {
 		// short circuit
-		future.Set(async.NewResultErr[*Response](ErrClosed))
-		return future
+		request.fail(ErrClosed)
+		return request, ErrClosed
+	}
+
+	if c.opts.LazyConnect && !direct {
+		if err := c.ensureConnected(); err != nil {
+			request.fail(err)
+			return request, err
+		}
 	}
 
 	// marshal to json
 	bytes, err := json.Marshal(req)
 	if err != nil {
-		future.Set(async.NewResultErr[*Response](errors.Annotate(err, "failed to marshal request to json")))
-		return future
+		err = errors.Annotate(err, "failed to marshal request to json")
+		request.fail(err)
+		return request, err
 	}
 
-	// create an in flight entry
-	c.inFlight.Store(string(req.Id), future)
+	observeRequest(request.context(), c.opts.Observer, req.Method, len(bytes))
 
-	// send the request
-	if err := c.conn.Write(bytes); err != nil {
-		future.Set(async.NewResultErr[*Response](err))
+	if c.opts.ReconnectFailMode == Resend && c.opts.ResendMethods[req.Method] {
+		request.data = bytes
 	}
 
-	return future
+	if c.opts.RetryBudget != nil {
+		c.opts.RetryBudget.Deposit()
+	}
+
+	c.logger().
+		WithField("method", req.Method).
+		WithField("params", string(c.liveOptions().redactor(req.Method, req.Params))).
+		Debug("sending request")
+
+	key := request.key
+
+	// hold the request if the client has not yet connected
+	if c.outbox != nil && !direct {
+		if queued, err := c.outbox.offer(&outboxEntry{key: key, data: bytes, tenant: req.tenant, request: request}); queued {
+			if err != nil {
+				request.fail(err)
+			}
+			return request, err
+		}
+	}
+
+	if !direct {
+		c.awaitHandshake()
+	}
+
+	// collect the request into a batch, it is in flight while it waits
+	if c.batcher != nil && !direct {
+		c.inFlight.Store(key, request)
+		c.batcher.add(request, bytes)
+		return request, nil
+	}
+
+	send := func() {
+		if request.canceled.Load() {
+			// cancelled whilst waiting for a slot
+			if request.release != nil {
+				request.release()
+			}
+			return
+		}
+
+		// create an in flight entry, before writing as the response can be read before Write returns
+		c.inFlight.Store(key, request)
+
+		// send the request
+		c.write(request.context(), req.tenant, bytes, func(err error) {
+			c.onWritten(request, err)
+		})
+	}
+
+	// wait for a slot if the number in flight is limited
+	if c.admission != nil && !direct {
+		c.admission.admit(priority, func() {
+			request.release = c.admission.release
+			send()
+		}, func(err error) {
+			request.fail(err)
+		})
+		return request, nil
+	}
+
+	send()
+	return request, nil
+}
+
+func (c *client) NotifyBatch(reqs []Request) error {
+	return c.notifyBatch(reqs, false)
+}
+
+func (c *client) notifyBatch(reqs []Request, direct bool) error {
+	if len(reqs) == 0 {
+		return nil
+	}
+
+	if c.closed.Load() {
+		// short circuit
+		return ErrClosed
+	}
+
+	if c.opts.LazyConnect && !direct {
+		if err := c.ensureConnected(); err != nil {
+			return err
+		}
+	}
+
+	for i := range reqs {
+		if reqs[i].Id != nil {
+			return errors.Annotatef(ErrNotificationId, "request %d", i)
+		}
+		// stamp the configured version
+		reqs[i].Version = c.opts.RequestVersion
+
+		c.mutate(&reqs[i])
+	}
+
+	bytes, err := json.Marshal(reqs)
+	if err != nil {
+		return errors.Annotate(err, "failed to marshal notifications to json")
+	}
+
+	c.logger().
+		WithField("size", len(reqs)).
+		Debug("sending notifications")
+
+	// hold the notifications if the client has not yet connected
+	if c.outbox != nil && !direct {
+		if queued, err := c.outbox.offer(&outboxEntry{data: bytes, tenant: reqs[0].tenant}); queued {
+			return err
+		}
+	}
+
+	if !direct {
+		c.awaitHandshake()
+	}
+
+	// when queued the write happens later, so failures can only be logged
+	if fairQueue := c.queue(); fairQueue != nil {
+		fairQueue.enqueue(reqs[0].tenant, queuedWrite{ctx: context.Background(), data: bytes, onWritten: func(err error) {
+			if err != nil {
+				c.logger().WithError(err).Warn("failed to send notifications")
+			}
+		}})
+		return nil
+	}
+	// likewise when coalesced
+	if c.coalescer != nil {
+		c.writeMessage(context.Background(), bytes, func(err error) {
+			if err != nil {
+				c.logger().WithError(err).Warn("failed to send notifications")
+			}
+		})
+		return nil
+	}
+	return c.writeConnection(context.Background(), bytes)
+}
+
+// write sends data directly, or via the fair queue if one has been configured. onWritten is called
+// once the write completes, with the error if it failed. ctx is that of the call data belongs to,
+// which can abandon a write which is waiting, e.g. to be paced by a MeteredConnection.
+func (c *client) write(ctx context.Context, tenant string, data []byte, onWritten func(err error)) {
+	if fairQueue := c.queue(); fairQueue != nil {
+		fairQueue.enqueue(tenant, queuedWrite{ctx: ctx, data: data, onWritten: onWritten})
+		return
+	}
+	c.writeMessage(ctx, data, onWritten)
+}
+
+// onWritten records when request was written, or fails it if the write failed.
+func (c *client) onWritten(request *inFlightRequest, err error) {
+	if err != nil {
+		c.expire(request, err)
+		return
+	}
+	if c.opts.ResponseMeta {
+		request.written.Store(c.opts.Clock.Now().UnixNano())
+	}
+}
+
+func (c *client) InFlightByTenant() map[string]int {
+	counts := make(map[string]int)
+	c.inFlight.Range(func(_, value any) bool {
+		counts[value.(*inFlightRequest).tenant]++
+		return true
+	})
+	return counts
+}
+
+// prepare assigns an id and version to req and applies the request mutator, if any, unless it has
+// already been prepared.
+func (c *client) prepare(req *Request) error {
+	if req.prepared {
+		return nil
+	}
+
+	// ensure a request id
+	if err := req.ensureId(c.opts.IdGenerator); err != nil {
+		return err
+	}
+
+	// stamp the configured version
+	req.Version = c.opts.RequestVersion
+
+	c.mutate(req)
+	req.prepared = true
+	return nil
+}
+
+// mutate applies the request mutator, if any, to req, first copying its extensions so that those
+// of the caller's Request are left untouched.
+func (c *client) mutate(req *Request) {
+	if c.opts.RequestMutator == nil {
+		return
+	}
+	req.extensions = cloneExtensions(req.extensions)
+	c.opts.RequestMutator(req)
 }
@@ -0,0 +1,639 @@
+package jsonrpc
+
+import (
+	"context"
+	"hash/fnv"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/41north/async.go"
+	"github.com/juju/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+var ErrNoHealthyMembers = errors.ConstError("client pool has no healthy members")
+
+const (
+	// DefaultProbeInterval is the default interval at which the members of a ClientPool are probed.
+	DefaultProbeInterval = 5 * time.Second
+	// DefaultProbeTimeout is the default time a member of a ClientPool has to respond to a probe.
+	DefaultProbeTimeout = time.Second
+)
+
+// WithMemberClientOptions sets the options of the clients created for the members of the pool.
+func WithMemberClientOptions(options ...ClientOption) ClientPoolOption {
+	return func(opts *ClientPoolOptions) {
+		opts.ClientOptions = options
+	}
+}
+
+// WithMemberProbe checks the health of every member each interval with probe, which must succeed
+// within timeout. Members without a connection are dialled instead. The default probe is
+// Client.Ping.
+func WithMemberProbe(interval time.Duration, timeout time.Duration, probe func(ctx context.Context, c Client) error) ClientPoolOption {
+	return func(opts *ClientPoolOptions) {
+		opts.ProbeInterval = interval
+		opts.ProbeTimeout = timeout
+		if probe != nil {
+			opts.Probe = probe
+		}
+	}
+}
+
+// WithUnhealthyThreshold sets the number of consecutive failed probes after which a member stops
+// receiving requests. A single successful probe makes it healthy again.
+func WithUnhealthyThreshold(n int) ClientPoolOption {
+	return func(opts *ClientPoolOptions) {
+		opts.UnhealthyThreshold = n
+	}
+}
+
+// WithMemberClock sets the clock used for probing and, unless overridden with
+// WithMemberClientOptions, by the clients of the members, see Clock.
+func WithMemberClock(clock Clock) ClientPoolOption {
+	return func(opts *ClientPoolOptions) {
+		opts.Clock = clock
+	}
+}
+
+type ClientPoolOption = func(opts *ClientPoolOptions)
+
+type ClientPoolOptions struct {
+	ClientOptions      []ClientOption
+	ProbeInterval      time.Duration
+	ProbeTimeout       time.Duration
+	Probe              func(ctx context.Context, c Client) error
+	UnhealthyThreshold int
+	Clock              Clock
+}
+
+func DefaultClientPoolOptions() ClientPoolOptions {
+	return ClientPoolOptions{
+		ProbeInterval: DefaultProbeInterval,
+		ProbeTimeout:  DefaultProbeTimeout,
+		Probe: func(ctx context.Context, c Client) error {
+			return c.Ping(ctx)
+		},
+		UnhealthyThreshold: 1,
+		Clock:              RealClock(),
+	}
+}
+
+// MemberStats reports the health of a member of a ClientPool.
+type MemberStats struct {
+	// Index is the position of the member's dialer in those passed to NewClientPool.
+	Index               int
+	Connected           bool
+	Healthy             bool
+	ConsecutiveFailures int
+	LastError           error
+	LastProbed          time.Time
+}
+
+// poolMember is a backend of a ClientPool.
+type poolMember struct {
+	index  int
+	dialer Dialer
+
+	mu     sync.Mutex
+	client Client
+	// dialing is set while a probe dials the member, so that concurrent probes do not dial it too
+	dialing  bool
+	healthy  bool
+	failures int
+	lastErr  error
+	probed   time.Time
+}
+
+// ClientPool is a Client which spreads requests across several backends, each dialled with its
+// own dialer. Requests are routed round robin to the healthy members, while the rest are probed in
+// the background and return to service once they recover.
+type ClientPool struct {
+	opts ClientPoolOptions
+	log  *log.Entry
+
+	members []*poolMember
+	next    atomic.Uint64
+
+	mu               sync.Mutex
+	closed           bool
+	started          bool
+	done             chan struct{}
+	closeHandler     CloseHandler
+	requestHandler   ContextRequestHandler
+	unmatchedHandler UnmatchedHandler
+	unsolicited      UnsolicitedErrorHandler
+	routes           sharedRoutes
+}
+
+var _ Client = (*ClientPool)(nil)
+
+// NewClientPool creates a pool with a member for each of dialers. Members are dialled by Connect.
+func NewClientPool(dialers []Dialer, options ...ClientPoolOption) *ClientPool {
+	opts := DefaultClientPoolOptions()
+	for _, opt := range options {
+		opt(&opts)
+	}
+	p := &ClientPool{
+		opts: opts,
+		log:  log.WithField("component", "clientPool"),
+		done: make(chan struct{}),
+	}
+	for i, dialer := range dialers {
+		p.members = append(p.members, &poolMember{index: i, dialer: dialer})
+	}
+	return p
+}
+
+// Connect dials every member, starting the background probes. It only fails, with
+// ErrNoHealthyMembers, if no member could be connected.
+func (p *ClientPool) Connect() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return ErrClosed
+	}
+	start := !p.started
+	p.started = true
+	p.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, m := range p.members {
+		wg.Add(1)
+		go func(m *poolMember) {
+			defer wg.Done()
+			p.probe(m)
+		}(m)
+	}
+	wg.Wait()
+
+	if start && p.opts.ProbeInterval > 0 {
+		go p.probeMembers()
+	}
+
+	if _, err := p.pick(""); err != nil {
+		var lastErr error
+		for _, m := range p.members {
+			if stats := m.stats(); stats.LastError != nil {
+				lastErr = stats.LastError
+			}
+		}
+		if lastErr == nil {
+			return err
+		}
+		return errors.WithType(errors.Annotate(lastErr, string(ErrNoHealthyMembers)), ErrNoHealthyMembers)
+	}
+	return nil
+}
+
+// Stats returns the health of each member, in the order of the dialers.
+func (p *ClientPool) Stats() []MemberStats {
+	stats := make([]MemberStats, len(p.members))
+	for i, m := range p.members {
+		stats[i] = m.stats()
+	}
+	return stats
+}
+
+func (m *poolMember) stats() MemberStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return MemberStats{
+		Index:               m.index,
+		Connected:           m.client != nil,
+		Healthy:             m.healthy,
+		ConsecutiveFailures: m.failures,
+		LastError:           m.lastErr,
+		LastProbed:          m.probed,
+	}
+}
+
+// probeMembers probes every member each interval until the pool closes.
+func (p *ClientPool) probeMembers() {
+	ticker := p.opts.Clock.NewTicker(p.opts.ProbeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C():
+		}
+
+		var wg sync.WaitGroup
+		for _, m := range p.members {
+			wg.Add(1)
+			go func(m *poolMember) {
+				defer wg.Done()
+				p.probe(m)
+			}(m)
+		}
+		wg.Wait()
+	}
+}
+
+// probe checks the health of m, dialing it if it has no connection. A probe made while another is
+// dialing m is skipped.
+func (p *ClientPool) probe(m *poolMember) {
+	m.mu.Lock()
+	client := m.client
+	if client == nil {
+		if m.dialing {
+			m.mu.Unlock()
+			return
+		}
+		m.dialing = true
+	}
+	m.mu.Unlock()
+
+	var err error
+	if client == nil {
+		client, err = p.dial(m)
+	} else {
+		ctx, cancel := withClockTimeout(context.Background(), p.opts.Clock, p.opts.ProbeTimeout)
+		err = p.opts.Probe(ctx, client)
+		cancel()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	recovering := !m.healthy && !m.probed.IsZero()
+	m.probed = p.opts.Clock.Now()
+	if err == nil {
+		if recovering {
+			p.log.WithField("member", m.index).Info("member has recovered")
+		}
+		m.healthy = true
+		m.failures = 0
+		m.lastErr = nil
+		return
+	}
+
+	m.failures++
+	m.lastErr = err
+	if m.healthy && m.failures >= p.opts.UnhealthyThreshold {
+		p.log.WithError(err).WithField("member", m.index).Warn("member is unhealthy")
+		m.healthy = false
+	}
+	if client == nil {
+		m.healthy = false
+	}
+}
+
+// dial connects a new client for m, clearing its dialing flag once it is done.
+func (p *ClientPool) dial(m *poolMember) (Client, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		m.mu.Lock()
+		m.dialing = false
+		m.mu.Unlock()
+		return nil, ErrClosed
+	}
+	client := NewClient(m.dialer, append([]ClientOption{WithClock(p.opts.Clock)}, p.opts.ClientOptions...)...)
+	if p.requestHandler != nil {
+		client.SetContextRequestHandler(p.requestHandler)
+	}
+	if p.unmatchedHandler != nil {
+		client.SetUnmatchedHandler(p.unmatchedHandler)
+	}
+	if p.unsolicited != nil {
+		client.OnUnsolicitedError(p.unsolicited)
+	}
+	p.mu.Unlock()
+	p.routes.install(client)
+
+	// a member whose client closes is taken out of service until it is dialled again
+	client.SetCloseHandler(func(err error) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if m.client == client {
+			m.client = nil
+			m.healthy = false
+			m.lastErr = err
+		}
+		p.routes.uninstall(client)
+	})
+
+	err := client.Connect()
+
+	m.mu.Lock()
+	m.dialing = false
+	if err == nil {
+		m.client = client
+	}
+	m.mu.Unlock()
+	if err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+
+	// the pool may have closed whilst dialing
+	p.mu.Lock()
+	closed := p.closed
+	p.mu.Unlock()
+	if closed {
+		_ = client.Close()
+		return nil, ErrClosed
+	}
+	return client, nil
+}
+
+// pick returns the client of the next healthy member, or for an ordering key the same member whilst
+// it is healthy, see RequestOrderingKey.
+func (p *ClientPool) pick(orderingKey string) (Client, error) {
+	p.mu.Lock()
+	closed := p.closed
+	p.mu.Unlock()
+	if closed {
+		return nil, ErrClosed
+	}
+
+	n := uint64(len(p.members))
+	var start uint64
+	if orderingKey != "" {
+		hash := fnv.New64a()
+		_, _ = hash.Write([]byte(orderingKey))
+		start = hash.Sum64()
+	} else {
+		start = p.next.Add(1)
+	}
+	for i := uint64(0); i < n; i++ {
+		m := p.members[(start+i)%n]
+		m.mu.Lock()
+		client, healthy := m.client, m.healthy
+		m.mu.Unlock()
+		if healthy && client != nil {
+			return client, nil
+		}
+	}
+	return nil, ErrNoHealthyMembers
+}
+
+// clients returns the clients of every connected member.
+func (p *ClientPool) clients() []Client {
+	var clients []Client
+	for _, m := range p.members {
+		m.mu.Lock()
+		if m.client != nil {
+			clients = append(clients, m.client)
+		}
+		m.mu.Unlock()
+	}
+	return clients
+}
+
+// failedFuture returns a future which has already failed with err.
+func failedFuture(req Request, err error) ResponseFuture {
+	future := async.NewFuture[async.Result[*Response]]()
+	future.Set(async.NewResultErr[*Response](&RequestError{Method: req.Method, ID: string(req.Id), Err: err}))
+	return future
+}
+
+func (p *ClientPool) Send(req Request, resp *Response) error {
+	client, err := p.pick(req.orderingKey)
+	if err != nil {
+		return err
+	}
+	return client.Send(req, resp)
+}
+
+func (p *ClientPool) SendContext(ctx context.Context, req Request, resp *Response) error {
+	client, err := p.pick(req.orderingKey)
+	if err != nil {
+		return err
+	}
+	return client.SendContext(ctx, req, resp)
+}
+
+func (p *ClientPool) SendRequest(ctx context.Context, req *Request, resp *Response) error {
+	client, err := p.pick(req.orderingKey)
+	if err != nil {
+		return err
+	}
+	return client.SendRequest(ctx, req, resp)
+}
+
+func (p *ClientPool) SendAsync(req Request) ResponseFuture {
+	client, err := p.pick(req.orderingKey)
+	if err != nil {
+		return failedFuture(req, err)
+	}
+	return client.SendAsync(req)
+}
+
+func (p *ClientPool) SendCancelable(req Request) (CancelableFuture, error) {
+	client, err := p.pick(req.orderingKey)
+	if err != nil {
+		return nil, err
+	}
+	return client.SendCancelable(req)
+}
+
+func (p *ClientPool) SendWithPriority(req Request, priority Priority) ResponseFuture {
+	client, err := p.pick(req.orderingKey)
+	if err != nil {
+		return failedFuture(req, err)
+	}
+	return client.SendWithPriority(req, priority)
+}
+
+// SendBatch sends the whole batch to a single member.
+func (p *ClientPool) SendBatch(ctx context.Context, batch BatchRequest) []ResponseFuture {
+	client, err := p.pick("")
+	if err != nil {
+		futures := make([]ResponseFuture, len(batch.Requests))
+		for i, timed := range batch.Requests {
+			futures[i] = failedFuture(timed.Request, err)
+		}
+		return futures
+	}
+	return client.SendBatch(ctx, batch)
+}
+
+// SendBatchContext sends the whole batch to a single member.
+func (p *ClientPool) SendBatchContext(ctx context.Context, batch BatchRequest) ([]*Response, error) {
+	client, err := p.pick("")
+	if err != nil {
+		return make([]*Response, len(batch.Requests)), err
+	}
+	return client.SendBatchContext(ctx, batch)
+}
+
+func (p *ClientPool) NotifyBatch(reqs []Request) error {
+	client, err := p.pick("")
+	if err != nil {
+		return err
+	}
+	return client.NotifyBatch(reqs)
+}
+
+// Subscribe subscribes with a single healthy member. The subscription ends if that member's
+// connection closes, and must then be renewed.
+func (p *ClientPool) Subscribe(method string) (*Subscription, error) {
+	client, err := p.pick("")
+	if err != nil {
+		return nil, err
+	}
+	return client.Subscribe(method)
+}
+
+// SubscribePattern subscribes to pattern with a single healthy member, see Subscribe.
+func (p *ClientPool) SubscribePattern(pattern string) (*Subscription, error) {
+	client, err := p.pick("")
+	if err != nil {
+		return nil, err
+	}
+	return client.SubscribePattern(pattern)
+}
+
+// UnsubscribePattern ends the subscription for pattern with whichever member holds it.
+func (p *ClientPool) UnsubscribePattern(pattern string) {
+	for _, client := range p.clients() {
+		client.UnsubscribePattern(pattern)
+	}
+}
+
+// InFlightByTenant returns the requests awaiting a response across every member.
+func (p *ClientPool) InFlightByTenant() map[string]int {
+	counts := make(map[string]int)
+	for _, client := range p.clients() {
+		for tenant, n := range client.InFlightByTenant() {
+			counts[tenant] += n
+		}
+	}
+	return counts
+}
+
+// CancelByMethod cancels the requests for method awaiting a response from any member.
+func (p *ClientPool) CancelByMethod(method string) int {
+	var cancelled int
+	for _, client := range p.clients() {
+		cancelled += client.CancelByMethod(method)
+	}
+	return cancelled
+}
+
+// RecentCalls returns the calls recently completed by the connected members, oldest first.
+func (p *ClientPool) RecentCalls() []CallRecord {
+	var calls []CallRecord
+	for _, client := range p.clients() {
+		calls = append(calls, client.RecentCalls()...)
+	}
+	sort.SliceStable(calls, func(i, j int) bool {
+		return calls[i].Start.Before(calls[j].Start)
+	})
+	return calls
+}
+
+// Ping checks a healthy member is alive.
+func (p *ClientPool) Ping(ctx context.Context) error {
+	client, err := p.pick("")
+	if err != nil {
+		return err
+	}
+	return client.Ping(ctx)
+}
+
+// Connection returns nil, as the members of a pool each have their own connection.
+func (p *ClientPool) Connection() Connection {
+	return nil
+}
+
+// Migrate is not supported, the members of a pool are fixed by the dialers it was created with.
+func (p *ClientPool) Migrate(ctx context.Context, dialer Dialer) error {
+	return errors.NotSupportedf("migrating a client pool")
+}
+
+// Update changes the options of every member, current and future, see client.Update. It stops at
+// the first member which fails to update.
+func (p *ClientPool) Update(options ...ClientOption) error {
+	p.mu.Lock()
+	prev := p.opts.ClientOptions
+	p.opts.ClientOptions = append(append([]ClientOption(nil), prev...), options...)
+	p.mu.Unlock()
+
+	for _, client := range p.clients() {
+		if err := client.Update(options...); err != nil {
+			p.mu.Lock()
+			p.opts.ClientOptions = prev
+			p.mu.Unlock()
+			return err
+		}
+	}
+	return nil
+}
+
+// SetCloseHandler sets a handler which is called once the pool is closed.
+func (p *ClientPool) SetCloseHandler(handler CloseHandler) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closeHandler = handler
+}
+
+// SetRequestHandler sets the request handler of every member, current and future.
+func (p *ClientPool) SetRequestHandler(handler RequestHandler) {
+	p.SetContextRequestHandler(withRequestContext(handler))
+}
+
+// SetContextRequestHandler sets the request handler of every member, current and future, see
+// ContextRequestHandler.
+func (p *ClientPool) SetContextRequestHandler(handler ContextRequestHandler) {
+	p.mu.Lock()
+	p.requestHandler = handler
+	p.mu.Unlock()
+
+	for _, client := range p.clients() {
+		client.SetContextRequestHandler(handler)
+	}
+}
+
+// OnNotification registers handler with every member, current and future, see Client.
+func (p *ClientPool) OnNotification(pattern string, handler NotificationHandler) (func(), error) {
+	return p.routes.add(pattern, handler, p.clients)
+}
+
+// SetUnmatchedHandler sets the unmatched handler of every member, current and future.
+func (p *ClientPool) SetUnmatchedHandler(handler UnmatchedHandler) {
+	p.mu.Lock()
+	p.unmatchedHandler = handler
+	p.mu.Unlock()
+
+	for _, client := range p.clients() {
+		client.SetUnmatchedHandler(handler)
+	}
+}
+
+// OnUnsolicitedError sets the unsolicited error handler of every member, current and future.
+func (p *ClientPool) OnUnsolicitedError(handler UnsolicitedErrorHandler) {
+	p.mu.Lock()
+	p.unsolicited = handler
+	p.mu.Unlock()
+
+	for _, client := range p.clients() {
+		client.OnUnsolicitedError(handler)
+	}
+}
+
+// Close stops probing and closes every member.
+func (p *ClientPool) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return ErrClosed
+	}
+	p.closed = true
+	close(p.done)
+	handler := p.closeHandler
+	p.mu.Unlock()
+
+	for _, client := range p.clients() {
+		_ = client.Close()
+	}
+	if handler != nil {
+		handler(nil)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/41north/jsonrpc.go"
+	"github.com/41north/jsonrpc.go/testutil"
+
+	"github.com/juju/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// backend is a member of a client pool which can be made to refuse dials or fail probes.
+type backend struct {
+	server *jsonrpc.Server
+	refuse atomic.Bool
+	unwell atomic.Bool
+	dialer jsonrpc.Dialer
+}
+
+func newBackend(name string) *backend {
+	b := &backend{server: endpoint(name, nil)}
+	b.server.Register("health", func(ctx context.Context, req jsonrpc.Request) (any, error) {
+		if b.unwell.Load() {
+			return nil, jsonrpc.ErrInternal
+		}
+		return true, nil
+	})
+	b.dialer = serverDialer(b.server, func(n int32) bool { return b.refuse.Load() })
+	return b
+}
+
+// probeHealth probes a member by calling its health method.
+func probeHealth(ctx context.Context, c jsonrpc.Client) error {
+	var resp jsonrpc.Response
+	if err := c.SendContext(ctx, *newRequest("health", nil), &resp); err != nil {
+		return err
+	}
+	var healthy bool
+	return resp.UnmarshalResult(&healthy)
+}
+
+// served returns the set of backends which answer n requests.
+func served(t *testing.T, client jsonrpc.Client, n int) map[string]bool {
+	names := make(map[string]bool)
+	for i := 0; i < n; i++ {
+		name, err := sendName(t, client, "name")
+		assert.Nil(t, err)
+		names[name] = true
+	}
+	return names
+}
+
+func TestClientPool(t *testing.T) {
+	backends := []*backend{newBackend("a"), newBackend("b"), newBackend("c")}
+	backends[2].refuse.Store(true)
+
+	// the probe timeout is measured with the clock too, so it outlasts the time the test advances it
+	// by, as a probe may be in progress when it does
+	clock := testutil.NewClock(time.Time{})
+	pool := jsonrpc.NewClientPool(
+		[]jsonrpc.Dialer{backends[0].dialer, backends[1].dialer, backends[2].dialer},
+		jsonrpc.WithMemberClock(clock),
+		jsonrpc.WithMemberProbe(time.Minute, 24*time.Hour, probeHealth),
+	)
+	defer pool.Close()
+	assert.Nil(t, pool.Connect())
+
+	// c could not be dialled
+	stats := pool.Stats()
+	assert.True(t, stats[0].Healthy)
+	assert.False(t, stats[2].Healthy)
+	assert.False(t, stats[2].Connected)
+	assert.NotNil(t, stats[2].LastError)
+	assert.Equal(t, map[string]bool{"a": true, "b": true}, served(t, pool, 6))
+
+	// c recovers and is dialled by a probe
+	backends[2].refuse.Store(false)
+	assert.Eventually(t, func() bool {
+		clock.Advance(time.Minute)
+		return pool.Stats()[2].Healthy
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, map[string]bool{"a": true, "b": true, "c": true}, served(t, pool, 6))
+
+	// a fails its probes and is taken out of service
+	backends[0].unwell.Store(true)
+	assert.Eventually(t, func() bool {
+		clock.Advance(time.Minute)
+		return !pool.Stats()[0].Healthy
+	}, time.Second, time.Millisecond)
+	assert.True(t, pool.Stats()[0].Connected)
+	assert.Equal(t, map[string]bool{"b": true, "c": true}, served(t, pool, 6))
+
+	// and returns once it passes again
+	backends[0].unwell.Store(false)
+	assert.Eventually(t, func() bool {
+		clock.Advance(time.Minute)
+		return pool.Stats()[0].Healthy
+	}, time.Second, time.Millisecond)
+
+	assert.Nil(t, pool.Ping(context.Background()))
+	assert.ErrorIs(t, pool.Migrate(context.Background(), backends[0].dialer), errors.NotSupported)
+}
+
+func TestClientPool_NoHealthyMembers(t *testing.T) {
+	b := newBackend("a")
+	b.refuse.Store(true)
+
+	pool := jsonrpc.NewClientPool([]jsonrpc.Dialer{b.dialer})
+	err := pool.Connect()
+	assert.ErrorIs(t, err, jsonrpc.ErrNoHealthyMembers)
+
+	_, err = (<-pool.SendAsync(*newRequest("name", nil)).Get()).Unwrap()
+	assert.ErrorIs(t, err, jsonrpc.ErrNoHealthyMembers)
+
+	closed := make(chan struct{})
+	pool.SetCloseHandler(func(err error) {
+		close(closed)
+	})
+	assert.Nil(t, pool.Close())
+	<-closed
+	assert.ErrorIs(t, pool.Connect(), jsonrpc.ErrClosed)
+}
+
+func TestClientPool_ConcurrentProbes(t *testing.T) {
+	b := newBackend("a")
+	var dials atomic.Int32
+	gate := make(chan struct{})
+	dialer := jsonrpc.DialFunc(func() (jsonrpc.Connection, error) {
+		dials.Add(1)
+		<-gate
+		return b.dialer.Dial()
+	})
+
+	pool := jsonrpc.NewClientPool([]jsonrpc.Dialer{dialer})
+	defer pool.Close()
+
+	connected := make(chan error, 1)
+	go func() { connected <- pool.Connect() }()
+	assert.Eventually(t, func() bool { return dials.Load() == 1 }, time.Second, time.Millisecond)
+
+	// a probe made while the member is being dialled does not dial it again
+	assert.ErrorIs(t, pool.Connect(), jsonrpc.ErrNoHealthyMembers)
+	close(gate)
+	assert.Nil(t, <-connected)
+
+	assert.Equal(t, int32(1), dials.Load())
+	assert.True(t, pool.Stats()[0].Connected)
+	assert.Equal(t, map[string]bool{"a": true}, served(t, pool, 2))
+}
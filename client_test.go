@@ -1,14 +1,22 @@
 package jsonrpc_test
 
 import (
+	"context"
 	"encoding/json"
+	"net"
+	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/41north/async.go"
 	"github.com/41north/jsonrpc.go"
+	"github.com/41north/jsonrpc.go/testutil"
 
 	"github.com/gorilla/websocket"
 	"github.com/juju/errors"
+	log "github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -21,6 +29,69 @@ func TestClient_BadHandshake(t *testing.T) {
 	err := client.Connect()
 
 	assert.Error(t, errors.New("websocket: bad handshake"), err)
+	assert.ErrorIs(t, err, jsonrpc.ErrDial)
+	assert.ErrorIs(t, err, websocket.ErrBadHandshake)
+}
+
+func TestClient_DialTimeout(t *testing.T) {
+	release := make(chan struct{})
+	clientConn, serverConn := net.Pipe()
+	dialer := jsonrpc.DialFunc(func() (jsonrpc.Connection, error) {
+		<-release
+		return jsonrpc.NewStreamConnection(clientConn, jsonrpc.FramingNewline), nil
+	})
+
+	// the timeout is measured with the clock of the client
+	clock := testutil.NewClock(time.Time{})
+	client := jsonrpc.NewClient(dialer, jsonrpc.WithClock(clock), jsonrpc.WithDialTimeout(time.Minute))
+	connected := make(chan error)
+	go func() {
+		connected <- client.Connect()
+	}()
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+	err := <-connected
+	assert.ErrorIs(t, err, jsonrpc.ErrDial)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// the connection produced after the timeout is closed
+	close(release)
+	_, err = jsonrpc.NewStreamConnection(serverConn, jsonrpc.FramingNewline).Read()
+	assert.ErrorIs(t, err, jsonrpc.ErrClosed)
+}
+
+func TestClient_LazyConnect(t *testing.T) {
+	server := jsonrpc.NewServer()
+	server.Register("echo", echo)
+
+	var dials atomic.Int32
+	dialer := jsonrpc.DialFunc(func() (jsonrpc.Connection, error) {
+		dials.Add(1)
+		clientConn, serverConn := net.Pipe()
+		go server.Serve(context.Background(), jsonrpc.NewStreamConnection(serverConn, jsonrpc.FramingNewline))
+		return jsonrpc.NewStreamConnection(clientConn, jsonrpc.FramingNewline), nil
+	})
+
+	// Connect is never called
+	client := jsonrpc.NewClient(dialer, jsonrpc.WithLazyConnect())
+	defer client.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var resp jsonrpc.Response
+			assert.Nil(t, client.Send(*newRequest("echo", []int{i}), &resp))
+
+			var result []int
+			assert.Nil(t, resp.UnmarshalResult(&result))
+			assert.Equal(t, []int{i}, result)
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), dials.Load())
 }
 
 func TestClient_ServerDisconnect(t *testing.T) {
@@ -45,8 +116,145 @@ func TestClient_ServerDisconnect(t *testing.T) {
 
 	var resp jsonrpc.Response
 	err = client.Send(*req, &resp)
-	assert.Error(t, jsonrpc.ErrClosed, err)
-	assert.Equal(t, jsonrpc.ErrClosed, closeError.Load())
+	assert.ErrorIs(t, err, jsonrpc.ErrClosed)
+	assert.ErrorIs(t, closeError.Load().(error), jsonrpc.ErrClosed)
+}
+
+func TestClient_ServerCloseReason(t *testing.T) {
+	srv := newWsServer(false)
+	srv.closeOnNextMessage.Store(true)
+	srv.closeMessage = websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "try again later")
+	defer srv.close()
+
+	dialer := jsonrpc.WebSocketDialer{Url: srv.url("/ws")}
+	client := jsonrpc.NewClient(dialer)
+
+	// capture close errors
+	closeError := make(chan error, 1)
+	client.SetCloseHandler(func(err error) {
+		closeError <- err
+	})
+
+	err := client.Connect()
+	assert.Nil(t, err)
+
+	var resp jsonrpc.Response
+	err = client.Send(*newRequest("ping", nil), &resp)
+	assert.ErrorIs(t, err, jsonrpc.ErrClosed)
+
+	expected := &jsonrpc.CloseError{Code: websocket.CloseTryAgainLater, Reason: "try again later"}
+
+	var closeErr *jsonrpc.CloseError
+	assert.True(t, errors.As(err, &closeErr))
+	assert.Equal(t, expected, closeErr)
+	assert.Equal(t, expected, <-closeError)
+}
+
+func TestClient_BaseContextCancelled(t *testing.T) {
+	srv := newWsServer(false)
+	defer srv.close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	dialer := jsonrpc.WebSocketDialer{Url: srv.url("/ws")}
+	client := jsonrpc.NewClient(dialer, jsonrpc.WithBaseContext(ctx))
+
+	// capture close errors
+	closeError := make(chan error, 1)
+	client.SetCloseHandler(func(err error) {
+		closeError <- err
+	})
+
+	err := client.Connect()
+	assert.Nil(t, err)
+
+	// the server will not reply until a test message is queued
+	future := client.SendAsync(*newRequest("ping", nil))
+
+	cancel()
+
+	assert.Equal(t, context.Canceled, <-closeError)
+
+	_, err = (<-future.Get()).Unwrap()
+	assert.ErrorIs(t, err, jsonrpc.ErrClosed)
+
+	var resp jsonrpc.Response
+	err = client.Send(*newRequest("ping", nil, jsonrpc.RequestStringId("req-1")), &resp)
+	assert.ErrorIs(t, err, jsonrpc.ErrClosed)
+
+	// errors identify the request which failed
+	var reqErr *jsonrpc.RequestError
+	assert.True(t, errors.As(err, &reqErr))
+	assert.Equal(t, "ping", reqErr.Method)
+	assert.Equal(t, `"req-1"`, reqErr.ID)
+	assert.Equal(t, `connection has been closed (method "ping", id "req-1")`, err.Error())
+}
+
+// stuckConnection is a connection whose Close, or whose Read once closed, blocks until released.
+type stuckConnection struct {
+	reading   chan struct{}
+	release   chan struct{}
+	closeOnce sync.Once
+	closed    chan struct{}
+	stuckRead bool
+}
+
+func newStuckConnection(stuckRead bool) *stuckConnection {
+	return &stuckConnection{
+		reading:   make(chan struct{}, 1),
+		release:   make(chan struct{}),
+		closed:    make(chan struct{}),
+		stuckRead: stuckRead,
+	}
+}
+
+func (s *stuckConnection) Write(data []byte) error {
+	return nil
+}
+
+func (s *stuckConnection) Read() ([]byte, error) {
+	select {
+	case s.reading <- struct{}{}:
+	default:
+	}
+	<-s.closed
+	if s.stuckRead {
+		<-s.release
+	}
+	return nil, jsonrpc.ErrClosed
+}
+
+func (s *stuckConnection) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.closed)
+	})
+	if !s.stuckRead {
+		<-s.release
+	}
+	return nil
+}
+
+func TestClient_CloseTimeout(t *testing.T) {
+	for _, stuckRead := range []bool{false, true} {
+		conn := newStuckConnection(stuckRead)
+		client := jsonrpc.NewClientWithConnection(conn, jsonrpc.WithCloseTimeout(20*time.Millisecond))
+		assert.Nil(t, client.Connect())
+
+		future := client.SendAsync(*newRequest("ping", nil))
+		<-conn.reading
+
+		start := time.Now()
+		err := client.Close()
+		assert.ErrorIs(t, err, jsonrpc.ErrCloseTimeout)
+		assert.Less(t, time.Since(start), time.Second)
+
+		// in flight requests are failed regardless
+		_, err = (<-future.Get()).Unwrap()
+		assert.ErrorIs(t, err, jsonrpc.ErrClosed)
+
+		close(conn.release)
+		assert.ErrorIs(t, client.Close(), jsonrpc.ErrClosed)
+	}
 }
 
 func TestClient_RequestIdMatching(t *testing.T) {
@@ -76,6 +284,335 @@ func TestClient_RequestIdMatching(t *testing.T) {
 	}
 }
 
+func TestClient_SequentialIds(t *testing.T) {
+	srv := newWsServer(false)
+	defer srv.close()
+
+	dialer := jsonrpc.WebSocketDialer{Url: srv.url("/ws")}
+	client := jsonrpc.NewClient(dialer, jsonrpc.WithIdGenerator(jsonrpc.Sequential()))
+	err := client.Connect()
+	assert.Nil(t, err)
+
+	for i := 1; i <= 100; i++ {
+		pong := newResponse("pong", jsonrpc.ResponseNumericId(i))
+		pongBytes, err := json.Marshal(pong)
+		assert.Nil(t, err)
+		srv.testMessages <- testMessage{msgType: websocket.TextMessage, data: pongBytes}
+
+		var resp jsonrpc.Response
+		err = client.Send(*newRequest("ping", nil), &resp)
+		assert.Nil(t, err)
+		assert.Equal(t, *pong, resp)
+	}
+}
+
+func TestClient_SendRequest(t *testing.T) {
+	server := jsonrpc.NewServer()
+	server.Register("echo", echo)
+
+	client := jsonrpc.NewClient(serverDialer(server, nil), jsonrpc.WithIdGenerator(jsonrpc.Sequential()))
+	assert.Nil(t, client.Connect())
+	defer func() { _ = client.Close() }()
+
+	// a request sent by value is copied, so the caller does not see the id it was sent with
+	req := *newRequest("echo", nil)
+	var resp jsonrpc.Response
+	assert.Nil(t, client.Send(req, &resp))
+	assert.Nil(t, req.Id)
+	assert.Equal(t, "1", string(resp.Id))
+
+	// whereas one sent by pointer is assigned its id in place
+	assert.Nil(t, client.SendRequest(context.Background(), &req, &resp))
+	assert.Equal(t, "2", string(req.Id))
+	assert.Equal(t, req.Id, resp.Id)
+
+	// and keeps an id the caller set
+	req = *newRequest("echo", nil, jsonrpc.RequestStringId("mine"))
+	assert.Nil(t, client.SendRequest(context.Background(), &req, &resp))
+	assert.Equal(t, `"mine"`, string(req.Id))
+	assert.Equal(t, req.Id, resp.Id)
+}
+
+func TestClient_Connection(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer listener.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		accepted <- conn
+		serveStream(jsonrpc.NewStreamConnection(conn, jsonrpc.FramingNewline))
+	}()
+
+	client := jsonrpc.NewClient(jsonrpc.NewNetDialer("tcp", listener.Addr().String()))
+	defer func() { _ = client.Close() }()
+	assert.Nil(t, client.Connect())
+
+	// the raw connection can be inspected
+	conn, ok := client.Connection().(jsonrpc.NetConnection)
+	assert.True(t, ok)
+	assert.Equal(t, (<-accepted).RemoteAddr(), conn.NetConn().LocalAddr())
+
+	// and follows the client when it migrates
+	clientConn, serverConn := net.Pipe()
+	go serveStream(jsonrpc.NewStreamConnection(serverConn, jsonrpc.FramingNewline))
+	migrated := jsonrpc.NewStreamConnection(clientConn, jsonrpc.FramingNewline)
+	assert.Nil(t, client.Migrate(context.Background(), jsonrpc.DialFunc(func() (jsonrpc.Connection, error) {
+		return migrated, nil
+	})))
+	assert.Equal(t, migrated, client.Connection())
+
+	// there is no connection before connecting
+	assert.Nil(t, jsonrpc.NewClient(jsonrpc.NewNetDialer("tcp", listener.Addr().String())).Connection())
+}
+
+func TestClient_AcceptedVersions(t *testing.T) {
+	testCases := []struct {
+		options  []jsonrpc.ClientOption
+		version  string
+		accepted bool
+	}{
+		{nil, "2.0", true},
+		{nil, "2.1", false},
+		{nil, "", false},
+		{[]jsonrpc.ClientOption{jsonrpc.WithAcceptedVersions("2.0", "2.1")}, "2.1", true},
+		{[]jsonrpc.ClientOption{jsonrpc.WithAcceptedVersions("2.0", "2.1")}, "", false},
+		{[]jsonrpc.ClientOption{jsonrpc.WithAcceptedVersions("2.0", "")}, "", true},
+		{[]jsonrpc.ClientOption{jsonrpc.WithAnyVersion()}, "2.1", true},
+		{[]jsonrpc.ClientOption{jsonrpc.WithAnyVersion()}, "", true},
+	}
+
+	srv := newWsServer(false)
+	defer srv.close()
+
+	for i, tc := range testCases {
+		dialer := jsonrpc.WebSocketDialer{Url: srv.url("/ws")}
+		client := jsonrpc.NewClient(dialer, tc.options...)
+		err := client.Connect()
+		assert.Nil(t, err)
+
+		pong := newResponse("pong", jsonrpc.ResponseNumericId(i), jsonrpc.ResponseVersion(tc.version))
+		pongBytes, err := json.Marshal(pong)
+		assert.Nil(t, err)
+		srv.testMessages <- testMessage{msgType: websocket.TextMessage, data: pongBytes}
+
+		var resp jsonrpc.Response
+		err = client.Send(*newRequest("ping", nil, jsonrpc.RequestNumericId(i)), &resp)
+		if tc.accepted {
+			assert.Nil(t, err)
+			assert.Equal(t, *pong, resp)
+		} else {
+			assert.True(t, errors.Is(err, jsonrpc.ErrUnsupportedVersion))
+		}
+
+		assert.Nil(t, client.Close())
+	}
+}
+
+func TestClient_RequestVersion(t *testing.T) {
+	srv := newWsServer(false)
+	defer srv.close()
+
+	dialer := jsonrpc.WebSocketDialer{Url: srv.url("/ws")}
+	client := jsonrpc.NewClient(dialer, jsonrpc.WithRequestVersion("1.0"), jsonrpc.WithAnyVersion())
+	err := client.Connect()
+	assert.Nil(t, err)
+
+	pongBytes, err := json.Marshal(newResponse("pong", jsonrpc.ResponseNumericId(1)))
+	assert.Nil(t, err)
+	srv.testMessages <- testMessage{msgType: websocket.TextMessage, data: pongBytes}
+
+	var resp jsonrpc.Response
+	err = client.Send(*newRequest("ping", nil, jsonrpc.RequestNumericId(1)), &resp)
+	assert.Nil(t, err)
+
+	var req jsonrpc.Request
+	err = json.Unmarshal(<-srv.receivedMessages, &req)
+	assert.Nil(t, err)
+	assert.Equal(t, "1.0", req.Version)
+}
+
+func TestClient_ErrorRegistry(t *testing.T) {
+	srv := newWsServer(false)
+	defer srv.close()
+
+	dialer := jsonrpc.WebSocketDialer{Url: srv.url("/ws")}
+	client := jsonrpc.NewClient(dialer, jsonrpc.WithErrorRegistry(newTestErrorRegistry()))
+	err := client.Connect()
+	assert.Nil(t, err)
+
+	appErr := insufficientFundsError{msg: "insufficient funds", balance: 42}
+	errResp := newResponseError(appErr.JSONRPCError(), jsonrpc.ResponseNumericId(1))
+	errBytes, err := json.Marshal(errResp)
+	assert.Nil(t, err)
+	srv.testMessages <- testMessage{msgType: websocket.TextMessage, data: errBytes}
+
+	var resp jsonrpc.Response
+	err = client.Send(*newRequest("transfer", nil, jsonrpc.RequestNumericId(1)), &resp)
+	assert.Nil(t, err)
+
+	var result string
+	assert.Equal(t, appErr, resp.UnmarshalResult(&result))
+}
+
+func TestClient_RequestMutator(t *testing.T) {
+	srv := newWsServer(false)
+	defer srv.close()
+
+	dialer := jsonrpc.WebSocketDialer{Url: srv.url("/ws")}
+	client := jsonrpc.NewClient(dialer, jsonrpc.WithRequestMutator(func(req *jsonrpc.Request) {
+		_ = req.SetExtension("token", "secret")
+	}))
+	err := client.Connect()
+	assert.Nil(t, err)
+
+	pongBytes, err := json.Marshal(newResponse("pong", jsonrpc.ResponseNumericId(1)))
+	assert.Nil(t, err)
+	srv.testMessages <- testMessage{msgType: websocket.TextMessage, data: pongBytes}
+
+	var resp jsonrpc.Response
+	err = client.Send(*newRequest("ping", nil, jsonrpc.RequestNumericId(1)), &resp)
+	assert.Nil(t, err)
+
+	assert.Equal(
+		t,
+		"{\"id\":1,\"method\":\"ping\",\"jsonrpc\":\"2.0\",\"token\":\"secret\"}",
+		string(<-srv.receivedMessages),
+	)
+}
+
+func TestClient_RequestMutatorBeforeInterceptors(t *testing.T) {
+	server := jsonrpc.NewServer()
+	server.Register("echo", echo)
+
+	var observed []jsonrpc.Request
+	var mu sync.Mutex
+	var tokens atomic.Int32
+	client := jsonrpc.NewClient(
+		serverDialer(server, nil),
+		jsonrpc.WithIdGenerator(jsonrpc.Sequential()),
+		jsonrpc.WithRequestMutator(func(req *jsonrpc.Request) {
+			_ = req.SetExtension("token", tokens.Add(1))
+		}),
+		jsonrpc.WithInterceptors(func(ctx context.Context, req jsonrpc.Request, invoker jsonrpc.UnaryInvoker) (jsonrpc.Response, error) {
+			mu.Lock()
+			observed = append(observed, req)
+			mu.Unlock()
+			return invoker(ctx, req)
+		}),
+	)
+	assert.Nil(t, client.Connect())
+	defer func() { _ = client.Close() }()
+
+	// interceptors see the id, version and extensions the request is sent with
+	var resp jsonrpc.Response
+	assert.Nil(t, client.Send(*newRequest("echo", nil), &resp))
+	assert.Len(t, observed, 1)
+	assert.Equal(t, "1", string(observed[0].Id))
+	assert.Equal(t, "2.0", observed[0].Version)
+	assert.Equal(t, "1", string(observed[0].Extension("token")))
+
+	// concurrent sends of the same request leave its extensions untouched
+	template := *newRequest("echo", nil)
+	assert.Nil(t, template.SetExtension("origin", "test"))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var resp jsonrpc.Response
+			assert.Nil(t, client.Send(template, &resp))
+			_, err := (<-client.SendAsync(template).Get()).Unwrap()
+			assert.Nil(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.Nil(t, template.Extension("token"))
+	assert.Len(t, observed, 17)
+	for _, req := range observed[1:] {
+		assert.Equal(t, "\"test\"", string(req.Extension("origin")))
+		assert.NotNil(t, req.Extension("token"))
+	}
+}
+
+func TestClient_NotifyBatch(t *testing.T) {
+	srv := newWsServer(false)
+	defer srv.close()
+
+	dialer := jsonrpc.WebSocketDialer{Url: srv.url("/ws")}
+	client := jsonrpc.NewClient(dialer)
+	err := client.Connect()
+	assert.Nil(t, err)
+
+	err = client.NotifyBatch([]jsonrpc.Request{
+		*newRequest("update", nil, jsonrpc.RequestNumericId(1)),
+	})
+	assert.ErrorIs(t, err, jsonrpc.ErrNotificationId)
+
+	err = client.NotifyBatch([]jsonrpc.Request{
+		{Method: "update", Params: json.RawMessage("[1]")},
+		{Method: "update", Params: json.RawMessage("[2]")},
+	})
+	assert.Nil(t, err)
+
+	assert.Equal(
+		t,
+		"[{\"method\":\"update\",\"params\":[1],\"jsonrpc\":\"2.0\"},{\"method\":\"update\",\"params\":[2],\"jsonrpc\":\"2.0\"}]",
+		string(<-srv.receivedMessages),
+	)
+	assert.Empty(t, client.InFlightByTenant())
+
+	_ = client.Close()
+	assert.ErrorIs(t, client.NotifyBatch([]jsonrpc.Request{{Method: "update"}}), jsonrpc.ErrClosed)
+}
+
+func TestClient_UnmatchedResponse(t *testing.T) {
+	hook := logtest.NewGlobal()
+	defer hook.Reset()
+
+	for _, withHandler := range []bool{false, true} {
+		clientConn, serverConn := net.Pipe()
+		server := jsonrpc.NewStreamConnection(serverConn, jsonrpc.FramingNewline)
+		client := jsonrpc.NewClientWithConnection(jsonrpc.NewStreamConnection(clientConn, jsonrpc.FramingNewline))
+
+		unmatched := make(chan jsonrpc.Response, 1)
+		if withHandler {
+			client.SetUnmatchedHandler(func(resp jsonrpc.Response) {
+				unmatched <- resp
+			})
+		}
+		handled := make(chan struct{}, 1)
+		client.SetRequestHandler(func(req jsonrpc.Request) {
+			handled <- struct{}{}
+		})
+		assert.Nil(t, client.Connect())
+
+		// an id which has been mangled, e.g. by a proxy
+		assert.Nil(t, server.Write([]byte(`{"id":1.0,"result":true,"jsonrpc":"2.0"}`)))
+		// messages are handled in order, so the response has been processed once this is handled
+		assert.Nil(t, server.Write([]byte(`{"method":"done","jsonrpc":"2.0"}`)))
+		<-handled
+
+		if withHandler {
+			// the handler receives the response with the id in its raw form
+			resp := <-unmatched
+			assert.Equal(t, "1.0", string(resp.Id))
+			assert.Equal(t, "true", string(resp.Result))
+		} else {
+			entry := hook.LastEntry()
+			assert.Equal(t, "response received with unrecognised id", entry.Message)
+			assert.Equal(t, "1.0", entry.Data["id"])
+		}
+
+		assert.Nil(t, client.Close())
+	}
+}
+
 func TestClient_RequestHandling(t *testing.T) {
 	srv := newWsServer(true)
 	defer srv.close()
@@ -108,6 +645,189 @@ func TestClient_RequestHandling(t *testing.T) {
 	assert.Equal(t, expected, received)
 }
 
+func TestClient_HandlerCallback(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	server := jsonrpc.NewStreamConnection(serverConn, jsonrpc.FramingNewline)
+
+	client := jsonrpc.NewClientWithConnection(jsonrpc.NewStreamConnection(clientConn, jsonrpc.FramingNewline))
+	defer client.Close()
+
+	// the handler calls back to the server which sent the request
+	results := make(chan string, 1)
+	client.SetContextRequestHandler(func(ctx context.Context, req jsonrpc.Request) {
+		caller, ok := jsonrpc.CallerFromContext(ctx)
+		assert.True(t, ok)
+
+		var resp jsonrpc.Response
+		err := caller.Send(*newRequest("workspace/configuration", nil), &resp)
+		assert.Nil(t, err)
+
+		var result string
+		assert.Nil(t, resp.UnmarshalResult(&result))
+		results <- result
+	})
+
+	err := client.Connect()
+	assert.Nil(t, err)
+
+	// server initiated request
+	reqBytes, err := json.Marshal(newRequest("initialize", nil, jsonrpc.RequestStringId("srv-1")))
+	assert.Nil(t, err)
+	assert.Nil(t, server.Write(reqBytes))
+
+	// the callback from the handler
+	callbackBytes, err := server.Read()
+	assert.Nil(t, err)
+
+	var callback jsonrpc.Request
+	assert.Nil(t, json.Unmarshal(callbackBytes, &callback))
+	assert.Equal(t, "workspace/configuration", callback.Method)
+
+	respBytes, err := json.Marshal(jsonrpc.Response{Id: callback.Id, Result: []byte("\"tabSize=4\""), Version: "2.0"})
+	assert.Nil(t, err)
+	assert.Nil(t, server.Write(respBytes))
+
+	assert.Equal(t, "tabSize=4", <-results)
+}
+
+func TestClient_PanicRecovery(t *testing.T) {
+	srv := newWsServer(false)
+	defer srv.close()
+
+	// decoding error code 666 panics on the read loop
+	reg := jsonrpc.NewErrorRegistry()
+	reg.Register(666, func(msg string, data json.RawMessage) error {
+		panic("bad type assertion")
+	})
+
+	dialer := jsonrpc.WebSocketDialer{Url: srv.url("/ws")}
+	client := jsonrpc.NewClient(dialer, jsonrpc.WithErrorRegistry(reg))
+	err := client.Connect()
+	assert.Nil(t, err)
+
+	errBytes, err := json.Marshal(newResponseError(jsonrpc.Error{Code: 666}, jsonrpc.ResponseNumericId(1)))
+	assert.Nil(t, err)
+	srv.testMessages <- testMessage{msgType: websocket.TextMessage, data: errBytes}
+
+	// the affected request is failed
+	var resp jsonrpc.Response
+	err = client.Send(*newRequest("ping", nil, jsonrpc.RequestNumericId(1)), &resp)
+	assert.ErrorIs(t, err, jsonrpc.ErrPanic)
+
+	// and the client continues
+	pongBytes, err := json.Marshal(newResponse("pong", jsonrpc.ResponseNumericId(2)))
+	assert.Nil(t, err)
+	srv.testMessages <- testMessage{msgType: websocket.TextMessage, data: pongBytes}
+
+	err = client.Send(*newRequest("ping", nil, jsonrpc.RequestNumericId(2)), &resp)
+	assert.Nil(t, err)
+}
+
+func TestClient_HandlerPanicCloses(t *testing.T) {
+	srv := newWsServer(true)
+	defer srv.close()
+
+	dialer := jsonrpc.WebSocketDialer{Url: srv.url("/ws")}
+	client := jsonrpc.NewClient(dialer, jsonrpc.WithCloseOnPanic())
+
+	closeError := make(chan error, 1)
+	client.SetCloseHandler(func(err error) {
+		closeError <- err
+	})
+	client.SetRequestHandler(func(req jsonrpc.Request) {
+		panic("handler failure")
+	})
+
+	err := client.Connect()
+	assert.Nil(t, err)
+
+	bytes, err := json.Marshal(newRequest("notify", nil))
+	assert.Nil(t, err)
+	srv.testMessages <- testMessage{msgType: websocket.TextMessage, data: bytes}
+
+	assert.ErrorIs(t, <-closeError, jsonrpc.ErrPanic)
+}
+
+type testObserver struct {
+	requests  chan int
+	responses chan int
+}
+
+func (o testObserver) OnRequest(method string, size int) {
+	o.requests <- size
+}
+
+func (o testObserver) OnResponse(method string, size int) {
+	o.responses <- size
+}
+
+func TestClient_Observer(t *testing.T) {
+	srv := newWsServer(false)
+	defer srv.close()
+
+	observer := testObserver{requests: make(chan int, 16), responses: make(chan int, 16)}
+
+	dialer := jsonrpc.WebSocketDialer{Url: srv.url("/ws")}
+	client := jsonrpc.NewClient(dialer, jsonrpc.WithObserver(observer))
+	err := client.Connect()
+	assert.Nil(t, err)
+
+	pongBytes, err := json.Marshal(newResponse("pong", jsonrpc.ResponseNumericId(1)))
+	assert.Nil(t, err)
+	srv.testMessages <- testMessage{msgType: websocket.TextMessage, data: pongBytes}
+
+	var resp jsonrpc.Response
+	err = client.Send(*newRequest("ping", nil, jsonrpc.RequestNumericId(1)), &resp)
+	assert.Nil(t, err)
+
+	assert.Equal(t, len(<-srv.receivedMessages), <-observer.requests)
+	assert.Equal(t, len(pongBytes), <-observer.responses)
+}
+
+func TestClient_RedactedLogs(t *testing.T) {
+	hook := logtest.NewGlobal()
+	defer hook.Reset()
+
+	level := log.GetLevel()
+	log.SetLevel(log.DebugLevel)
+	defer log.SetLevel(level)
+
+	redactor, err := jsonrpc.RedactRules(map[string][]string{"login": {"params[1]"}})
+	assert.Nil(t, err)
+
+	srv := newWsServer(false)
+	defer srv.close()
+
+	dialer := jsonrpc.WebSocketDialer{Url: srv.url("/ws")}
+	client := jsonrpc.NewClient(dialer, jsonrpc.WithRedactor(redactor))
+	err = client.Connect()
+	assert.Nil(t, err)
+
+	pongBytes, err := json.Marshal(newResponse(true, jsonrpc.ResponseNumericId(1)))
+	assert.Nil(t, err)
+	srv.testMessages <- testMessage{msgType: websocket.TextMessage, data: pongBytes}
+
+	var resp jsonrpc.Response
+	params := []string{"alice", "hunter2"}
+	err = client.Send(*newRequest("login", params, jsonrpc.RequestNumericId(1)), &resp)
+	assert.Nil(t, err)
+
+	// the wire is unaffected
+	assert.Contains(t, string(<-srv.receivedMessages), "hunter2")
+
+	var logged bool
+	for _, entry := range hook.AllEntries() {
+		line, err := entry.String()
+		assert.Nil(t, err)
+		assert.NotContains(t, line, "hunter2")
+		if entry.Data["method"] == "login" {
+			logged = true
+			assert.Equal(t, "[\"alice\",\"[REDACTED]\"]", entry.Data["params"])
+		}
+	}
+	assert.True(t, logged)
+}
+
 // newRequest is an internal test utility for creating request objects without having to handle
 // the possible error, panicking instead.
 func newRequest(method string, params any, options ...jsonrpc.RequestOption) *jsonrpc.Request {
@@ -125,3 +845,28 @@ func newResponse(result any, options ...jsonrpc.ResponseOption) *jsonrpc.Respons
 	}
 	return resp
 }
+
+func TestGetContext(t *testing.T) {
+	future := async.NewFuture[async.Result[*jsonrpc.Response]]()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := jsonrpc.GetContext(ctx, future)
+	second := jsonrpc.GetContext(context.Background(), future)
+
+	// cancelling stops the first reader waiting, without affecting the second
+	cancel()
+	_, err := (<-first).Unwrap()
+	assert.ErrorIs(t, err, context.Canceled)
+
+	resp := newResponse("pong", jsonrpc.ResponseNumericId(1))
+	future.Set(async.NewResultValue(resp))
+
+	r, err := (<-second).Unwrap()
+	assert.Nil(t, err)
+	assert.Equal(t, resp, r)
+
+	// the future itself still holds the response
+	r, err = (<-future.Get()).Unwrap()
+	assert.Nil(t, err)
+	assert.Equal(t, resp, r)
+}
@@ -0,0 +1,107 @@
+package jsonrpc
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Clock is the source of time for the timeouts, intervals and backoffs of clients, servers and
+// pools, so that tests can control it, see testutil.Clock. Context deadlines are unaffected, as
+// they always use real time, but the timeouts applied to the contexts created internally, such as
+// those of dials and probes, are measured with the clock. The default is RealClock.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	AfterFunc(d time.Duration, f func()) Timer
+	NewTimer(d time.Duration) Timer
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer is a time.Timer created by a Clock. The channel of a timer created with AfterFunc is nil.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// Ticker is a time.Ticker created by a Clock.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+	Reset(d time.Duration)
+}
+
+// RealClock returns the Clock which uses the time package.
+func RealClock() Clock {
+	return realClock{}
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return realTimer{time.AfterFunc(d, f)}
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+// withClockTimeout returns a copy of ctx which is cancelled once timeout has elapsed on clock, as
+// context.WithTimeout does in real time, after which its Err is context.DeadlineExceeded.
+func withClockTimeout(ctx context.Context, clock Clock, timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	timed := &clockTimeoutContext{Context: ctx}
+	timer := clock.AfterFunc(timeout, func() {
+		if ctx.Err() == nil {
+			timed.expired.Store(true)
+		}
+		cancel()
+	})
+	return timed, func() {
+		timer.Stop()
+		cancel()
+	}
+}
+
+// clockTimeoutContext is a context cancelled by withClockTimeout.
+type clockTimeoutContext struct {
+	context.Context
+	expired atomic.Bool
+}
+
+func (c *clockTimeoutContext) Err() error {
+	err := c.Context.Err()
+	if err != nil && c.expired.Load() {
+		return context.DeadlineExceeded
+	}
+	return err
+}
@@ -0,0 +1,63 @@
+package jsonrpc
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// ConnStore holds state which handlers accumulate across the requests received on a connection,
+// such as an authenticated session or the position of a cursor. Each connection served by Serve has
+// a store of its own, see ConnStoreFrom, which is closed once the connection closes if it
+// implements io.Closer. Implementations must be safe for concurrent use, as requests on the same
+// connection are handled concurrently.
+type ConnStore interface {
+	Get(key string) any
+	Set(key string, val any)
+	Delete(key string)
+}
+
+// ServerConnStore sets the factory which creates the store of each connection, see ConnStore. The
+// default is NewConnStore, a nil factory gives connections no store.
+func ServerConnStore(factory func() ConnStore) ServerOption {
+	return func(opts *ServerOptions) {
+		opts.ConnStore = factory
+	}
+}
+
+// ConnStoreFrom returns the store of the connection a request was received on by Serve, from the
+// context passed to its handler. Nil is returned for messages passed to Handle directly.
+func ConnStoreFrom(ctx context.Context) ConnStore {
+	return ConnectionInfoFrom(ctx).Store
+}
+
+// NewConnStore creates a ConnStore backed by a sync.Map.
+func NewConnStore() ConnStore {
+	return &mapConnStore{}
+}
+
+type mapConnStore struct {
+	m sync.Map
+}
+
+func (s *mapConnStore) Get(key string) any {
+	val, _ := s.m.Load(key)
+	return val
+}
+
+func (s *mapConnStore) Set(key string, val any) {
+	s.m.Store(key, val)
+}
+
+func (s *mapConnStore) Delete(key string) {
+	s.m.Delete(key)
+}
+
+// closeConnStore closes store if it implements io.Closer.
+func (s *Server) closeConnStore(store ConnStore) {
+	if closer, ok := store.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			s.log.WithError(err).Warn("failed to close connection store")
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/41north/jsonrpc.go"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// closingStore reports when it is closed.
+type closingStore struct {
+	jsonrpc.ConnStore
+	closed chan struct{}
+}
+
+func (s *closingStore) Close() error {
+	close(s.closed)
+	return nil
+}
+
+func TestServer_ConnStore(t *testing.T) {
+	stores := make(chan *closingStore, 2)
+	server := jsonrpc.NewServer(jsonrpc.ServerConnStore(func() jsonrpc.ConnStore {
+		store := &closingStore{ConnStore: jsonrpc.NewConnStore(), closed: make(chan struct{})}
+		stores <- store
+		return store
+	}))
+	server.Register("login", func(ctx context.Context, req jsonrpc.Request) (any, error) {
+		var user string
+		if err := req.UnmarshalParams(&user); err != nil {
+			return nil, err
+		}
+		jsonrpc.ConnStoreFrom(ctx).Set("user", user)
+		return true, nil
+	})
+	server.Register("whoami", func(ctx context.Context, req jsonrpc.Request) (any, error) {
+		return jsonrpc.ConnStoreFrom(ctx).Get("user"), nil
+	})
+
+	whoami := func(client jsonrpc.Client) any {
+		var resp jsonrpc.Response
+		assert.Nil(t, client.Send(*newRequest("whoami", nil), &resp))
+		var user any
+		assert.Nil(t, resp.UnmarshalResult(&user))
+		return user
+	}
+
+	alice := jsonrpc.NewClient(serverDialer(server, nil))
+	assert.Nil(t, alice.Connect())
+	bob := jsonrpc.NewClient(serverDialer(server, nil))
+	assert.Nil(t, bob.Connect())
+	defer bob.Close()
+
+	// state is kept across the requests of a connection, and not shared with others
+	var resp jsonrpc.Response
+	assert.Nil(t, alice.Send(*newRequest("login", "alice"), &resp))
+	assert.Equal(t, "alice", whoami(alice))
+	assert.Nil(t, whoami(bob))
+
+	// the store is closed with its connection
+	first, second := <-stores, <-stores
+	assert.Nil(t, alice.Close())
+	select {
+	case <-first.closed:
+	case <-second.closed:
+	}
+
+	assert.Nil(t, jsonrpc.ConnStoreFrom(context.Background()))
+}
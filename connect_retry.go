@@ -0,0 +1,89 @@
+package jsonrpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+// Backoff returns how long to wait before the given retry, counting from one.
+type Backoff = func(retry int) time.Duration
+
+// ConstantBackoff waits d before every retry.
+func ConstantBackoff(d time.Duration) Backoff {
+	return func(retry int) time.Duration {
+		return d
+	}
+}
+
+// ExponentialBackoff waits initial before the first retry, doubling the wait before each retry
+// after it up to max.
+func ExponentialBackoff(initial time.Duration, max time.Duration) Backoff {
+	return func(retry int) time.Duration {
+		d := initial
+		for i := 1; i < retry && d < max; i++ {
+			d *= 2
+		}
+		if d > max {
+			d = max
+		}
+		return d
+	}
+}
+
+// WithConnectRetry makes Connect try up to attempts times before giving up, waiting as given by
+// backoff between attempts, which smooths startup when the server is still coming online. An attempt
+// fails if the dial fails or if the hook set with WithOnConnect returns an error. Each dial is
+// bounded by the dial timeout, see WithDialTimeout, while retrying stops as soon as the base context
+// is done. If every attempt fails, the error of the last is returned, with a failed dial wrapped in
+// ErrDial.
+func WithConnectRetry(attempts int, backoff Backoff) ClientOption {
+	return func(opts *ClientOptions) {
+		opts.ConnectAttempts = attempts
+		opts.ConnectBackoff = backoff
+	}
+}
+
+// retryConnect makes attempts at connecting with attempt, retrying those which fail as configured
+// by WithConnectRetry. An attempt which fails with ErrClosed is not retried.
+func (c *client) retryConnect(attempt func(ctx context.Context) error) error {
+	ctx := c.opts.BaseContext
+
+	for n := 1; ; n++ {
+		err := attempt(ctx)
+		if err == nil {
+			return nil
+		}
+		if n >= c.opts.ConnectAttempts || ctx.Err() != nil || errors.Is(err, ErrClosed) {
+			return err
+		}
+
+		var wait time.Duration
+		if c.opts.ConnectBackoff != nil {
+			wait = c.opts.ConnectBackoff(n)
+		}
+		c.logger().WithError(err).
+			WithField("attempt", n).
+			WithField("wait", wait).
+			Warn("failed to connect, retrying")
+
+		timer := c.opts.Clock.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C():
+		}
+	}
+}
+
+// dialOnce makes a single attempt at dialing, bounded by the dial timeout.
+func (c *client) dialOnce(ctx context.Context) (Connection, error) {
+	if c.opts.DialTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = withClockTimeout(ctx, c.opts.Clock, c.opts.DialTimeout)
+		defer cancel()
+	}
+	return c.dialer.DialContext(ctx)
+}
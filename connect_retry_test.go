@@ -0,0 +1,96 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/41north/jsonrpc.go"
+	"github.com/41north/jsonrpc.go/testutil"
+	"github.com/juju/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff(t *testing.T) {
+	constant := jsonrpc.ConstantBackoff(time.Second)
+	assert.Equal(t, time.Second, constant(1))
+	assert.Equal(t, time.Second, constant(5))
+
+	exponential := jsonrpc.ExponentialBackoff(10*time.Millisecond, 50*time.Millisecond)
+	assert.Equal(t, 10*time.Millisecond, exponential(1))
+	assert.Equal(t, 20*time.Millisecond, exponential(2))
+	assert.Equal(t, 40*time.Millisecond, exponential(3))
+	assert.Equal(t, 50*time.Millisecond, exponential(4))
+	assert.Equal(t, 50*time.Millisecond, exponential(100))
+}
+
+func TestClient_ConnectRetry(t *testing.T) {
+	server := jsonrpc.NewServer()
+	server.Register("echo", echo)
+
+	// the first two dials fail
+	var dials atomic.Int32
+	clock := testutil.NewClock(time.Time{})
+	client := jsonrpc.NewClient(
+		serverDialer(server, func(n int32) bool { dials.Store(n); return n <= 2 }),
+		jsonrpc.WithClock(clock),
+		jsonrpc.WithConnectRetry(3, jsonrpc.ExponentialBackoff(time.Second, time.Minute)),
+	)
+	defer client.Close()
+
+	connected := make(chan error, 1)
+	go func() { connected <- client.Connect() }()
+
+	clock.BlockUntil(1)
+	clock.Advance(999 * time.Millisecond)
+	assert.Equal(t, int32(1), dials.Load())
+	clock.Advance(time.Millisecond)
+
+	// the wait doubles
+	clock.BlockUntil(1)
+	assert.Equal(t, int32(2), dials.Load())
+	clock.Advance(2 * time.Second)
+
+	assert.Nil(t, <-connected)
+	assert.Equal(t, int32(3), dials.Load())
+	var resp jsonrpc.Response
+	assert.Nil(t, client.Send(*newRequest("echo", "hello"), &resp))
+	assert.Nil(t, resp.Error)
+}
+
+func TestClient_ConnectRetry_Exhausted(t *testing.T) {
+	var dials atomic.Int32
+	dialer := jsonrpc.DialFunc(func() (jsonrpc.Connection, error) {
+		return nil, errors.Errorf("dial %d refused", dials.Add(1))
+	})
+
+	client := jsonrpc.NewClient(dialer, jsonrpc.WithConnectRetry(3, nil))
+	err := client.Connect()
+	assert.ErrorIs(t, err, jsonrpc.ErrDial)
+	assert.ErrorContains(t, err, "dial 3 refused")
+	assert.Equal(t, int32(3), dials.Load())
+}
+
+func TestClient_ConnectRetry_Context(t *testing.T) {
+	var dials atomic.Int32
+	dialer := jsonrpc.DialFunc(func() (jsonrpc.Connection, error) {
+		dials.Add(1)
+		return nil, errors.New("connection refused")
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	client := jsonrpc.NewClient(
+		dialer,
+		jsonrpc.WithBaseContext(ctx),
+		jsonrpc.WithConnectRetry(10, jsonrpc.ConstantBackoff(time.Hour)),
+	)
+
+	start := time.Now()
+	err := client.Connect()
+	assert.ErrorIs(t, err, jsonrpc.ErrDial)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, int32(1), dials.Load())
+}
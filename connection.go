@@ -2,15 +2,208 @@ package jsonrpc
 
 import (
 	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/juju/errors"
+)
+
+// ErrDial matches, with errors.Is, any error returned when a connection could not be established.
+var (
+	ErrDial       = errors.ConstError("failed to dial")
+	ErrDialerUsed = errors.ConstError("dialer has already been used")
 )
 
+// DialError wraps the cause of a failure to establish a connection, distinguishing it from errors
+// which occur once connected.
+type DialError struct {
+	Cause error
+}
+
+func (e *DialError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrDial, e.Cause)
+}
+
+func (e *DialError) Is(target error) bool {
+	return target == ErrDial
+}
+
+func (e *DialError) Unwrap() error {
+	return e.Cause
+}
+
+// ErrTransport matches, with errors.Is, a TransportError.
+const ErrTransport = errors.ConstError("transport failure")
+
+// TransportError is the error of a request which was written to a connection that failed to deliver
+// it or to read its response, such as an http request which could not be made or was answered with
+// an unexpected status. It wraps the cause of the failure. Connections which exchange each message
+// separately return it from Read, in which case only the requests of that message are failed.
+type TransportError struct {
+	// StatusCode is the status of the http response, if one was received, otherwise zero.
+	StatusCode int
+	Cause      error
+
+	// data is the message which could not be exchanged, when returned by Read
+	data []byte
+}
+
+func (e *TransportError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrTransport, e.Cause)
+}
+
+func (e *TransportError) Is(target error) bool {
+	return target == ErrTransport
+}
+
+func (e *TransportError) Unwrap() error {
+	return e.Cause
+}
+
+// retryable returns true unless the peer answered with a status which a retry would not change.
+func (e *TransportError) retryable() bool {
+	switch {
+	case e.StatusCode == 0, e.StatusCode >= 500:
+		return true
+	default:
+		return e.StatusCode == http.StatusRequestTimeout || e.StatusCode == http.StatusTooManyRequests
+	}
+}
+
+// CloseError is returned by Connection.Read when the remote peer has closed the connection with a
+// close code and reason, such as a websocket close frame. It matches ErrClosed with errors.Is and
+// is attached as the cause when in flight requests are failed, so that callers and retry policies
+// can inspect the code, e.g. 1013 (try again later).
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("%s: [%d]", ErrClosed, e.Code)
+	}
+	return fmt.Sprintf("%s: [%d] %s", ErrClosed, e.Code, e.Reason)
+}
+
+func (e *CloseError) Is(target error) bool {
+	return target == ErrClosed
+}
+
+// Connection is a transport capable of exchanging json-rpc messages. Read should return ErrClosed,
+// or a *CloseError, once the connection has been closed.
 type Connection interface {
 	Write(data []byte) error
 	Read() ([]byte, error)
 	Close() error
 }
 
+// ContextWriter is implemented by connections whose writes may wait, such as a MeteredConnection
+// which paces them, so that the client can abandon a write once the call it belongs to is
+// cancelled.
+type ContextWriter interface {
+	WriteContext(ctx context.Context, data []byte) error
+}
+
+// writeContext writes data to conn, bounded by ctx if conn is a ContextWriter.
+func writeContext(ctx context.Context, conn Connection, data []byte) error {
+	if writer, ok := conn.(ContextWriter); ok {
+		return writer.WriteContext(ctx, data)
+	}
+	return conn.Write(data)
+}
+
+// NetConnection is implemented by connections which exchange messages over a net.Conn, such as
+// stream and websocket connections, giving access to it for inspection, e.g. of the state of a
+// TLS connection, see Client.Connection.
+type NetConnection interface {
+	Connection
+	NetConn() net.Conn
+}
+
+// Dialer creates connections for a client. A client never dials concurrently with itself, but a
+// Dialer shared between clients, as it is by a Pool, is dialled concurrently and must be safe for
+// concurrent use. Dialers which can only produce a single connection should be wrapped with
+// SingleUseDialer, so that accidental reuse fails fast.
 type Dialer interface {
 	Dial() (Connection, error)
 	DialContext(ctx context.Context) (Connection, error)
 }
+
+// DialFunc adapts a dial function which does not accept a context into a Dialer. DialContext runs
+// the function in a goroutine and returns once it completes or ctx is done, whichever is first. In
+// the latter case the dial continues in the background and any connection it produces is closed.
+type DialFunc func() (Connection, error)
+
+func (f DialFunc) Dial() (Connection, error) {
+	return f()
+}
+
+func (f DialFunc) DialContext(ctx context.Context) (Connection, error) {
+	type dialResult struct {
+		conn Connection
+		err  error
+	}
+
+	ch := make(chan dialResult, 1)
+	go func() {
+		conn, err := f()
+		ch <- dialResult{conn, err}
+	}()
+
+	select {
+	case result := <-ch:
+		return result.conn, result.err
+	case <-ctx.Done():
+		go func() {
+			if result := <-ch; result.conn != nil {
+				_ = result.conn.Close()
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+// SingleUseDialer wraps dialer so that it can only be dialled once, returning ErrDialerUsed from
+// every subsequent call, including one made while the first is still in progress.
+func SingleUseDialer(dialer Dialer) Dialer {
+	return &singleUseDialer{dialer: dialer}
+}
+
+type singleUseDialer struct {
+	dialer Dialer
+	used   atomic.Bool
+}
+
+func (d *singleUseDialer) Dial() (Connection, error) {
+	return d.DialContext(context.Background())
+}
+
+func (d *singleUseDialer) DialContext(ctx context.Context) (Connection, error) {
+	if !d.used.CompareAndSwap(false, true) {
+		return nil, ErrDialerUsed
+	}
+	return d.dialer.DialContext(ctx)
+}
+
+// connectionDialer hands out an existing connection, once.
+type connectionDialer struct {
+	conn Connection
+	used atomic.Bool
+}
+
+func (d *connectionDialer) Dial() (Connection, error) {
+	return d.DialContext(context.Background())
+}
+
+func (d *connectionDialer) DialContext(ctx context.Context) (Connection, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if !d.used.CompareAndSwap(false, true) {
+		return nil, errors.Annotate(ErrDialerUsed, "connection has already been used")
+	}
+	return d.conn, nil
+}
@@ -0,0 +1,267 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+
+	"github.com/juju/errors"
+	"golang.org/x/net/http2"
+)
+
+// HTTPHeader adds a header to every http request.
+func HTTPHeader(key, value string) HTTPOption {
+	return func(opts *HTTPOptions) {
+		opts.Header.Add(key, value)
+	}
+}
+
+// HTTPTLSConfig sets the tls configuration used for https endpoints.
+func HTTPTLSConfig(config *tls.Config) HTTPOption {
+	return func(opts *HTTPOptions) {
+		opts.TLSConfig = config
+	}
+}
+
+// HTTPRequestCompression gzips the bodies of requests larger than threshold bytes, e.g. large
+// batches, sending them with a Content-Encoding: gzip header. A body is sent uncompressed if gzip
+// would not make it smaller. Servers which do not support compressed requests are expected to
+// reply with 415 Unsupported Media Type, as RFC 7694 recommends, in which case the request is sent
+// again uncompressed and compression is disabled for every connection of the dialer.
+func HTTPRequestCompression(threshold int) HTTPOption {
+	return func(opts *HTTPOptions) {
+		opts.RequestCompression = true
+		opts.CompressionThreshold = threshold
+	}
+}
+
+type HTTPOption = func(opts *HTTPOptions)
+
+type HTTPOptions struct {
+	Header               http.Header
+	TLSConfig            *tls.Config
+	RequestCompression   bool
+	CompressionThreshold int
+}
+
+func DefaultHTTPOptions() HTTPOptions {
+	return HTTPOptions{
+		Header: http.Header{},
+	}
+}
+
+// httpDialer creates connections which send each message as an http POST to an endpoint.
+type httpDialer struct {
+	endpoint string
+	opts     HTTPOptions
+	client   *http.Client
+	// compressionRejected is set once the endpoint has rejected a compressed request
+	compressionRejected atomic.Bool
+}
+
+// errCompressionRejected is returned by post when the endpoint rejects a compressed request.
+const errCompressionRejected = errors.ConstError("compressed request rejected")
+
+// NewHTTP2Dialer creates a dialer for an HTTP/2 capable json-rpc endpoint. Cleartext endpoints use
+// h2c with prior knowledge and https endpoints negotiate HTTP/2 with TLS. All connections created
+// by the dialer share a single underlying TCP connection, with every message sent on its own
+// stream so that requests are truly concurrent. If an http request fails, or is answered with an
+// unexpected status and no json-rpc error, the requests it carried fail with a *TransportError.
+func NewHTTP2Dialer(endpoint string, options ...HTTPOption) Dialer {
+	opts := DefaultHTTPOptions()
+	for _, opt := range options {
+		opt(&opts)
+	}
+
+	transport := &http2.Transport{
+		TLSClientConfig: opts.TLSConfig,
+	}
+	if u, err := url.Parse(endpoint); err == nil && u.Scheme == "http" {
+		// h2c, dial without tls
+		transport.AllowHTTP = true
+		transport.DialTLSContext = func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, network, addr)
+		}
+	}
+
+	return &httpDialer{
+		endpoint: endpoint,
+		opts:     opts,
+		client:   &http.Client{Transport: transport},
+	}
+}
+
+func (d *httpDialer) Dial() (Connection, error) {
+	return d.DialContext(context.Background())
+}
+
+func (d *httpDialer) DialContext(ctx context.Context) (Connection, error) {
+	if _, err := url.Parse(d.endpoint); err != nil {
+		return nil, errors.Annotate(err, "invalid endpoint")
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	connCtx, cancel := context.WithCancel(context.Background())
+	return &httpConnection{
+		dialer:    d,
+		ctx:       connCtx,
+		cancel:    cancel,
+		responses: make(chan httpResponse, 16),
+	}, nil
+}
+
+// httpResponse is a response body along with the details of the http response which carried it,
+// or the error of an http request which failed.
+type httpResponse struct {
+	body []byte
+	meta TransportMeta
+	err  error
+}
+
+// httpConnection sends every Write as a separate http request, queueing the response bodies to be
+// returned by Read, or a *TransportError for each http request which failed.
+type httpConnection struct {
+	dialer    *httpDialer
+	ctx       context.Context
+	cancel    context.CancelFunc
+	responses chan httpResponse
+	closeOnce sync.Once
+}
+
+func (h *httpConnection) Write(data []byte) error {
+	if h.ctx.Err() != nil {
+		return ErrClosed
+	}
+
+	opts := h.dialer.opts
+	compress := opts.RequestCompression && len(data) > opts.CompressionThreshold && !h.dialer.compressionRejected.Load()
+	req, err := h.newRequest(data, compress)
+	if err != nil {
+		return err
+	}
+
+	go h.roundTrip(req, data)
+
+	return nil
+}
+
+// newRequest creates the http request which carries data, gzipping the body if compress is true
+// and doing so makes it smaller.
+func (h *httpConnection) newRequest(data []byte, compress bool) (*http.Request, error) {
+	body := data
+	if compress {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(data); err != nil {
+			return nil, errors.Annotate(err, "failed to compress http request")
+		}
+		if err := zw.Close(); err != nil {
+			return nil, errors.Annotate(err, "failed to compress http request")
+		}
+		if buf.Len() < len(data) {
+			body = buf.Bytes()
+		} else {
+			compress = false
+		}
+	}
+
+	req, err := http.NewRequestWithContext(h.ctx, http.MethodPost, h.dialer.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Annotate(err, "failed to create http request")
+	}
+	for key, values := range h.dialer.opts.Header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if compress {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	return req, nil
+}
+
+func (h *httpConnection) roundTrip(req *http.Request, data []byte) {
+	body, meta, err := h.post(req)
+	if errors.Is(err, errCompressionRejected) {
+		// the endpoint does not support compressed requests, so stop sending them and try again
+		h.dialer.compressionRejected.Store(true)
+		if req, err = h.newRequest(data, false); err == nil {
+			body, meta, err = h.post(req)
+		}
+	}
+	resp := httpResponse{body: body, meta: meta}
+	if err != nil {
+		if h.ctx.Err() != nil {
+			// closed
+			return
+		}
+		resp.err = &TransportError{StatusCode: meta.StatusCode, Cause: err, data: data}
+	} else if len(bytes.TrimSpace(body)) == 0 {
+		// no content, e.g. in reply to a notification
+		return
+	}
+	select {
+	case h.responses <- resp:
+	case <-h.ctx.Done():
+	}
+}
+
+func (h *httpConnection) post(req *http.Request) ([]byte, TransportMeta, error) {
+	resp, err := h.dialer.client.Do(req)
+	if err != nil {
+		return nil, TransportMeta{}, err
+	}
+	defer resp.Body.Close()
+
+	meta := TransportMeta{
+		StatusCode:    resp.StatusCode,
+		Header:        resp.Header,
+		ServerLatency: parseServerTiming(resp.Header.Get("Server-Timing")),
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, meta, errors.Annotate(err, "failed to read http response")
+	}
+	if resp.StatusCode == http.StatusUnsupportedMediaType && req.Header.Get("Content-Encoding") != "" {
+		return nil, meta, errCompressionRejected
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		// servers may respond with a valid json-rpc error and an error status code
+		var probe Response
+		if isBatch(body) || json.Unmarshal(body, &probe) == nil && probe.Error != nil {
+			return body, meta, nil
+		}
+		return nil, meta, errors.Errorf("unexpected http status: %s", resp.Status)
+	}
+	return body, meta, nil
+}
+
+func (h *httpConnection) Read() ([]byte, error) {
+	body, _, err := h.ReadWithMeta()
+	return body, err
+}
+
+func (h *httpConnection) ReadWithMeta() ([]byte, TransportMeta, error) {
+	select {
+	case resp := <-h.responses:
+		return resp.body, resp.meta, resp.err
+	case <-h.ctx.Done():
+		return nil, TransportMeta{}, ErrClosed
+	}
+}
+
+func (h *httpConnection) Close() error {
+	h.closeOnce.Do(h.cancel)
+	return nil
+}
@@ -0,0 +1,178 @@
+package jsonrpc_test
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/41north/jsonrpc.go"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// newH2cServer creates an h2c server which echoes the params of each request back as the result,
+// once concurrency requests are being handled at the same time.
+func newH2cServer(t *testing.T, concurrency int) (*httptest.Server, *sync.Map) {
+	remoteAddrs := &sync.Map{}
+	barrier := sync.WaitGroup{}
+	barrier.Add(concurrency)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, 2, r.ProtoMajor)
+		assert.Equal(t, "bar", r.Header.Get("X-Foo"))
+		remoteAddrs.Store(r.RemoteAddr, true)
+
+		body, err := io.ReadAll(r.Body)
+		assert.Nil(t, err)
+
+		var req jsonrpc.Request
+		assert.Nil(t, json.Unmarshal(body, &req))
+
+		// wait for all the requests to be in flight
+		barrier.Done()
+		barrier.Wait()
+
+		resp := jsonrpc.Response{Id: req.Id, Result: req.Params, Version: req.Version}
+		assert.Nil(t, json.NewEncoder(w).Encode(resp))
+	})
+
+	return httptest.NewServer(h2c.NewHandler(handler, &http2.Server{})), remoteAddrs
+}
+
+func TestHTTP2Dialer_Multiplexing(t *testing.T) {
+	concurrency := 32
+
+	srv, remoteAddrs := newH2cServer(t, concurrency)
+	defer srv.Close()
+
+	client := jsonrpc.NewClient(jsonrpc.NewHTTP2Dialer(srv.URL, jsonrpc.HTTPHeader("X-Foo", "bar")))
+	err := client.Connect()
+	assert.Nil(t, err)
+	defer client.Close()
+
+	var futures []jsonrpc.ResponseFuture
+	for i := 0; i < concurrency; i++ {
+		futures = append(futures, client.SendAsync(*newRequest("echo", i)))
+	}
+
+	for i, future := range futures {
+		resp, err := (<-future.Get()).Unwrap()
+		assert.Nil(t, err)
+
+		var result int
+		assert.Nil(t, resp.UnmarshalResult(&result))
+		assert.Equal(t, i, result)
+	}
+
+	// all requests share a single tcp connection
+	count := 0
+	remoteAddrs.Range(func(_, _ any) bool {
+		count++
+		return true
+	})
+	assert.Equal(t, 1, count)
+}
+
+func TestHTTP2Dialer_TransportError(t *testing.T) {
+	srv := httptest.NewServer(h2c.NewHandler(http.NotFoundHandler(), &http2.Server{}))
+	defer srv.Close()
+
+	client := jsonrpc.NewClient(jsonrpc.NewHTTP2Dialer(srv.URL))
+	err := client.Connect()
+	assert.Nil(t, err)
+	defer client.Close()
+
+	var resp jsonrpc.Response
+	err = client.Send(*newRequest("ping", nil), &resp)
+	assert.ErrorIs(t, err, jsonrpc.ErrTransport)
+
+	var transportErr *jsonrpc.TransportError
+	assert.ErrorAs(t, err, &transportErr)
+	assert.Equal(t, http.StatusNotFound, transportErr.StatusCode)
+	assert.False(t, jsonrpc.IsRetryable(err))
+
+	// the client carries on
+	assert.ErrorIs(t, client.Send(*newRequest("ping", nil), &resp), jsonrpc.ErrTransport)
+}
+
+// newDecompressingServer creates an h2c server which echoes the params of each request back as the
+// result, recording the content encoding of each request. Compressed requests are decompressed,
+// unless supported is false, in which case they are rejected.
+func newDecompressingServer(t *testing.T, supported bool) (*httptest.Server, chan string) {
+	encodings := make(chan string, 16)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := r.Header.Get("Content-Encoding")
+		encodings <- encoding
+
+		body := r.Body
+		if encoding == "gzip" {
+			if !supported {
+				w.WriteHeader(http.StatusUnsupportedMediaType)
+				return
+			}
+			zr, err := gzip.NewReader(r.Body)
+			assert.Nil(t, err)
+			body = zr
+		}
+
+		var req jsonrpc.Request
+		assert.Nil(t, json.NewDecoder(body).Decode(&req))
+
+		resp := jsonrpc.Response{Id: req.Id, Result: req.Params, Version: req.Version}
+		assert.Nil(t, json.NewEncoder(w).Encode(resp))
+	})
+
+	return httptest.NewServer(h2c.NewHandler(handler, &http2.Server{})), encodings
+}
+
+func TestHTTP2Dialer_RequestCompression(t *testing.T) {
+	srv, encodings := newDecompressingServer(t, true)
+	defer srv.Close()
+
+	client := jsonrpc.NewClient(jsonrpc.NewHTTP2Dialer(srv.URL, jsonrpc.HTTPRequestCompression(1024)))
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	// small requests are sent as they are
+	var resp jsonrpc.Response
+	assert.Nil(t, client.Send(*newRequest("echo", "small"), &resp))
+	assert.Equal(t, "", <-encodings)
+
+	large := strings.Repeat("large", 1000)
+	assert.Nil(t, client.Send(*newRequest("echo", large), &resp))
+	assert.Equal(t, "gzip", <-encodings)
+
+	var result string
+	assert.Nil(t, resp.UnmarshalResult(&result))
+	assert.Equal(t, large, result)
+}
+
+func TestHTTP2Dialer_RequestCompressionUnsupported(t *testing.T) {
+	srv, encodings := newDecompressingServer(t, false)
+	defer srv.Close()
+
+	client := jsonrpc.NewClient(jsonrpc.NewHTTP2Dialer(srv.URL, jsonrpc.HTTPRequestCompression(1024)))
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	large := strings.Repeat("large", 1000)
+
+	// the rejected request is sent again uncompressed
+	var resp jsonrpc.Response
+	assert.Nil(t, client.Send(*newRequest("echo", large), &resp))
+	assert.Nil(t, resp.Error)
+	assert.Equal(t, "gzip", <-encodings)
+	assert.Equal(t, "", <-encodings)
+
+	// and compression is disabled from then on
+	assert.Nil(t, client.Send(*newRequest("echo", large), &resp))
+	assert.Equal(t, "", <-encodings)
+}
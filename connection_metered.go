@@ -0,0 +1,298 @@
+package jsonrpc
+
+import (
+	"context"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultControlSize is the default size in bytes up to which a message counts as a control
+// message, see MeterControlSize.
+const DefaultControlSize = 256
+
+// ByteObserver is notified of the bytes read and written by a MeteredConnection, for example to
+// collect metrics, see MeterObserver. Implementations must be safe for concurrent use.
+type ByteObserver interface {
+	OnBytesRead(n int)
+	OnBytesWritten(n int)
+}
+
+// MeterStats reports the traffic of a MeteredConnection.
+type MeterStats struct {
+	BytesRead       uint64
+	BytesWritten    uint64
+	MessagesRead    uint64
+	MessagesWritten uint64
+}
+
+// MeterReadLimit paces reads to rate bytes per second, allowing bursts of up to burst bytes.
+func MeterReadLimit(rate float64, burst int) MeterOption {
+	return func(opts *MeterOptions) {
+		opts.ReadRate = rate
+		opts.ReadBurst = burst
+	}
+}
+
+// MeterWriteLimit paces writes to rate bytes per second, allowing bursts of up to burst bytes.
+func MeterWriteLimit(rate float64, burst int) MeterOption {
+	return func(opts *MeterOptions) {
+		opts.WriteRate = rate
+		opts.WriteBurst = burst
+	}
+}
+
+// MeterControlSize sets the size in bytes up to which a message counts as a control message, such
+// as a ping or an unsubscribe. Control messages are let through as soon as the limit of their
+// direction is no more than a burst in debt, rather than waiting for the budget a large transfer
+// has consumed to be refilled. The debt they run up is repaid by the messages which follow, so the
+// rate holds over time. Zero disables the allowance.
+func MeterControlSize(size int) MeterOption {
+	return func(opts *MeterOptions) {
+		opts.ControlSize = size
+	}
+}
+
+// MeterObserver sets an observer to be notified of the bytes read and written.
+func MeterObserver(observer ByteObserver) MeterOption {
+	return func(opts *MeterOptions) {
+		opts.Observer = observer
+	}
+}
+
+// MeterContext sets the context which bounds paced reads and writes, including those made with
+// WriteContext. Once it is done writes fail with its error, while reads return the message being
+// held and then fail with ErrClosed, see Read. The default is context.Background.
+func MeterContext(ctx context.Context) MeterOption {
+	return func(opts *MeterOptions) {
+		opts.Context = ctx
+	}
+}
+
+// MeterClock sets the clock with which reads and writes are paced, see Clock.
+func MeterClock(clock Clock) MeterOption {
+	return func(opts *MeterOptions) {
+		opts.Clock = clock
+	}
+}
+
+type MeterOption = func(opts *MeterOptions)
+
+type MeterOptions struct {
+	ReadRate    float64
+	ReadBurst   int
+	WriteRate   float64
+	WriteBurst  int
+	ControlSize int
+	Observer    ByteObserver
+	Context     context.Context
+	Clock       Clock
+}
+
+func DefaultMeterOptions() MeterOptions {
+	return MeterOptions{
+		ControlSize: DefaultControlSize,
+		Context:     context.Background(),
+		Clock:       RealClock(),
+	}
+}
+
+// MeteredConnection is a Connection which counts the bytes read from and written to the connection
+// it wraps, and optionally paces them to stay within a bandwidth limit in each direction. Sizes are
+// those of the messages as read and written by the wrapped connection, without any framing.
+//
+// Optional interfaces of the wrapped connection, such as Pinger, are not passed through, so keep
+// alive falls back to a request when it is wrapped. It is a ContextWriter, so a client abandons a
+// paced write once the call it belongs to is cancelled.
+type MeteredConnection struct {
+	conn  Connection
+	opts  MeterOptions
+	read  *pacer
+	write *pacer
+
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	bytesRead       atomic.Uint64
+	bytesWritten    atomic.Uint64
+	messagesRead    atomic.Uint64
+	messagesWritten atomic.Uint64
+}
+
+var (
+	_ Connection    = &MeteredConnection{}
+	_ ContextWriter = &MeteredConnection{}
+)
+
+func NewMeteredConnection(conn Connection, options ...MeterOption) *MeteredConnection {
+	opts := DefaultMeterOptions()
+	for _, opt := range options {
+		opt(&opts)
+	}
+
+	m := &MeteredConnection{
+		conn:   conn,
+		opts:   opts,
+		closed: make(chan struct{}),
+	}
+	if opts.ReadRate > 0 {
+		m.read = newPacer(opts.ReadRate, opts.ReadBurst, opts.Clock)
+	}
+	if opts.WriteRate > 0 {
+		m.write = newPacer(opts.WriteRate, opts.WriteBurst, opts.Clock)
+	}
+	return m
+}
+
+// MeteredDialer wraps each connection produced by dialer in a MeteredConnection. The connections
+// are metered and paced independently, so an observer should be used to total their traffic.
+func MeteredDialer(dialer Dialer, options ...MeterOption) Dialer {
+	return &meteredDialer{dialer: dialer, options: options}
+}
+
+type meteredDialer struct {
+	dialer  Dialer
+	options []MeterOption
+}
+
+func (d *meteredDialer) Dial() (Connection, error) {
+	return d.DialContext(context.Background())
+}
+
+func (d *meteredDialer) DialContext(ctx context.Context) (Connection, error) {
+	conn, err := d.dialer.DialContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return NewMeteredConnection(conn, d.options...), nil
+}
+
+// Stats returns the bytes and messages read and written so far.
+func (m *MeteredConnection) Stats() MeterStats {
+	return MeterStats{
+		BytesRead:       m.bytesRead.Load(),
+		BytesWritten:    m.bytesWritten.Load(),
+		MessagesRead:    m.messagesRead.Load(),
+		MessagesWritten: m.messagesWritten.Load(),
+	}
+}
+
+func (m *MeteredConnection) Write(data []byte) error {
+	return m.WriteContext(context.Background(), data)
+}
+
+// WriteContext writes data once the write limit allows it, or fails with the error of ctx, or of
+// the meter context, if it is done first. Nothing is written by a write which fails while it is
+// paced.
+func (m *MeteredConnection) WriteContext(ctx context.Context, data []byte) error {
+	if err := m.pace(ctx, m.write, len(data)); err != nil {
+		return err
+	}
+	if err := m.conn.Write(data); err != nil {
+		return err
+	}
+	m.bytesWritten.Add(uint64(len(data)))
+	m.messagesWritten.Add(1)
+	if m.opts.Observer != nil {
+		m.opts.Observer.OnBytesWritten(len(data))
+	}
+	return nil
+}
+
+// Read reads the next message, holding it until the read limit allows it. Holding messages back
+// exerts back pressure on the peer once the buffers of the connection fill. A message which is held
+// when the meter context is done, or the connection is closed, is returned at once rather than
+// dropped, and the reads after it fail with ErrClosed.
+func (m *MeteredConnection) Read() ([]byte, error) {
+	if m.opts.Context.Err() != nil {
+		return nil, ErrClosed
+	}
+	data, err := m.conn.Read()
+	if err != nil {
+		return nil, err
+	}
+	m.bytesRead.Add(uint64(len(data)))
+	m.messagesRead.Add(1)
+	if m.opts.Observer != nil {
+		m.opts.Observer.OnBytesRead(len(data))
+	}
+	// the message has been read, so it is not dropped if pacing is cut short
+	_ = m.pace(context.Background(), m.read, len(data))
+	return data, nil
+}
+
+// Close closes the wrapped connection, failing any read or write which is being paced with
+// ErrClosed.
+func (m *MeteredConnection) Close() error {
+	m.closeOnce.Do(func() {
+		close(m.closed)
+	})
+	return m.conn.Close()
+}
+
+// pace waits until limit, if any, allows size bytes, unless ctx or the meter context is done first.
+func (m *MeteredConnection) pace(ctx context.Context, limit *pacer, size int) error {
+	if limit == nil {
+		return nil
+	}
+	control := size <= m.opts.ControlSize
+	for {
+		wait := limit.reserve(float64(size), control)
+		if wait == 0 {
+			return nil
+		}
+		timer := m.opts.Clock.NewTimer(wait)
+		select {
+		case <-timer.C():
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-m.opts.Context.Done():
+			timer.Stop()
+			return m.opts.Context.Err()
+		case <-m.closed:
+			timer.Stop()
+			return ErrClosed
+		}
+	}
+}
+
+// pacer is a token bucket which, unlike TokenBucket, can go into debt: a message larger than the
+// burst is let through once the bucket is full, and control messages once the debt they leave is
+// no more than a burst.
+type pacer struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	clock  Clock
+}
+
+func newPacer(rate float64, burst int, clock Clock) *pacer {
+	return &pacer{rate: rate, burst: float64(burst), tokens: float64(burst), last: clock.Now(), clock: clock}
+}
+
+// reserve takes size tokens and returns zero if they can be taken, otherwise it returns how long
+// to wait before trying again.
+func (p *pacer) reserve(size float64, control bool) time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.clock.Now()
+	p.tokens = math.Min(p.tokens+now.Sub(p.last).Seconds()*p.rate, p.burst)
+	p.last = now
+
+	if control && p.tokens-size >= -p.burst {
+		p.tokens -= size
+		return 0
+	}
+	need := math.Min(size, p.burst)
+	if p.tokens >= need {
+		p.tokens -= size
+		return 0
+	}
+	// round up, so that the tokens are there when the caller retries
+	return time.Duration(math.Ceil((need - p.tokens) / p.rate * float64(time.Second)))
+}
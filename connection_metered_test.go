@@ -0,0 +1,251 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/41north/jsonrpc.go"
+	"github.com/41north/jsonrpc.go/testutil"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sinkConnection counts the messages written to it, and returns the messages sent on reads.
+type sinkConnection struct {
+	written chan []byte
+	reads   chan []byte
+}
+
+func newSinkConnection() *sinkConnection {
+	return &sinkConnection{written: make(chan []byte, 16), reads: make(chan []byte, 16)}
+}
+
+func (s *sinkConnection) Write(data []byte) error {
+	s.written <- data
+	return nil
+}
+
+func (s *sinkConnection) Read() ([]byte, error) {
+	data, ok := <-s.reads
+	if !ok {
+		return nil, jsonrpc.ErrClosed
+	}
+	return data, nil
+}
+
+func (s *sinkConnection) Close() error {
+	return nil
+}
+
+func message(size int) []byte {
+	return []byte(strings.Repeat("x", size))
+}
+
+func TestMeteredConnection_Stats(t *testing.T) {
+	server := jsonrpc.NewServer()
+	server.Register("echo", echo)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	conn := jsonrpc.NewMeteredConnection(testutil.ServePipe(ctx, server))
+	client := jsonrpc.NewClientWithConnection(conn)
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	req, err := jsonrpc.NewRequest("echo", "hello", jsonrpc.RequestNumericId(1))
+	assert.Nil(t, err)
+	var resp jsonrpc.Response
+	assert.Nil(t, client.Send(*req, &resp))
+
+	// {"id":1,"method":"echo","params":"hello","jsonrpc":"2.0"}
+	// {"id":1,"result":"hello","jsonrpc":"2.0"}
+	assert.Equal(t, jsonrpc.MeterStats{
+		BytesRead:       41,
+		BytesWritten:    57,
+		MessagesRead:    1,
+		MessagesWritten: 1,
+	}, conn.Stats())
+}
+
+func TestMeteredConnection_WriteLimit(t *testing.T) {
+	clock := testutil.NewClock(time.Time{})
+	sink := newSinkConnection()
+	conn := jsonrpc.NewMeteredConnection(sink, jsonrpc.MeterWriteLimit(1000, 1000), jsonrpc.MeterClock(clock))
+
+	// the burst goes through at once
+	assert.Nil(t, conn.Write(message(1000)))
+
+	written := make(chan error)
+	go func() {
+		written <- conn.Write(message(500))
+	}()
+
+	// the write waits for the 500ms it takes to refill the tokens it needs
+	clock.BlockUntil(1)
+	clock.Advance(250 * time.Millisecond)
+	select {
+	case <-written:
+		t.Fatal("write was not paced")
+	default:
+	}
+
+	clock.Advance(250 * time.Millisecond)
+	assert.Nil(t, <-written)
+	assert.Equal(t, uint64(1500), conn.Stats().BytesWritten)
+}
+
+func TestMeteredConnection_ControlMessages(t *testing.T) {
+	clock := testutil.NewClock(time.Time{})
+	sink := newSinkConnection()
+	conn := jsonrpc.NewMeteredConnection(sink, jsonrpc.MeterWriteLimit(1000, 1000), jsonrpc.MeterClock(clock))
+
+	// a large transfer consumes the budget, and the next is held back
+	assert.Nil(t, conn.Write(message(1000)))
+	written := make(chan error)
+	go func() {
+		written <- conn.Write(message(1000))
+	}()
+	clock.BlockUntil(1)
+
+	// but a ping gets through
+	assert.Nil(t, conn.Write(message(64)))
+	assert.Equal(t, uint64(2), conn.Stats().MessagesWritten)
+
+	// and the large transfer waits for the debt to be repaid
+	clock.Advance(time.Second)
+	clock.BlockUntil(1)
+	select {
+	case <-written:
+		t.Fatal("write was not paced")
+	default:
+	}
+	clock.Advance(64 * time.Millisecond)
+	assert.Nil(t, <-written)
+}
+
+func TestMeteredConnection_WriteCancelled(t *testing.T) {
+	clock := testutil.NewClock(time.Time{})
+	sink := newSinkConnection()
+	conn := jsonrpc.NewMeteredConnection(sink, jsonrpc.MeterWriteLimit(1000, 1000), jsonrpc.MeterClock(clock))
+	assert.Nil(t, conn.Write(message(1000)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	written := make(chan error)
+	go func() {
+		written <- conn.WriteContext(ctx, message(1000))
+	}()
+	clock.BlockUntil(1)
+	cancel()
+	assert.ErrorIs(t, <-written, context.Canceled)
+
+	// closing fails writes which are being paced
+	go func() {
+		written <- conn.Write(message(1000))
+	}()
+	clock.BlockUntil(1)
+	assert.Nil(t, conn.Close())
+	assert.ErrorIs(t, <-written, jsonrpc.ErrClosed)
+
+	assert.Len(t, sink.written, 1)
+}
+
+func TestClient_MeteredWriteCancelled(t *testing.T) {
+	var served atomic.Int32
+	server := jsonrpc.NewServer()
+	server.Register("echo", func(ctx context.Context, req jsonrpc.Request) (any, error) {
+		served.Add(1)
+		return req.Params, nil
+	})
+
+	clock := testutil.NewClock(time.Time{})
+	client := jsonrpc.NewClient(jsonrpc.MeteredDialer(
+		serverDialer(server, nil),
+		jsonrpc.MeterWriteLimit(100, 100),
+		jsonrpc.MeterControlSize(0),
+		jsonrpc.MeterClock(clock),
+	))
+	assert.Nil(t, client.Connect())
+	defer func() { _ = client.Close() }()
+
+	// the first request exhausts the budget
+	var resp jsonrpc.Response
+	assert.Nil(t, client.Send(*newRequest("echo", make([]int, 50)), &resp))
+
+	// so the next is paced until its call is cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	sent := make(chan error)
+	go func() {
+		var resp jsonrpc.Response
+		sent <- client.SendContext(ctx, *newRequest("echo", nil), &resp)
+	}()
+	clock.BlockUntil(1)
+	cancel()
+	assert.ErrorIs(t, <-sent, context.Canceled)
+
+	// which abandons the write
+	assert.Eventually(t, func() bool { return clock.Waiters() == 0 }, time.Second, time.Millisecond)
+	clock.Advance(time.Minute)
+	assert.Nil(t, client.Send(*newRequest("echo", nil), &resp))
+	assert.Equal(t, int32(2), served.Load())
+}
+
+func TestMeteredConnection_ReadLimit(t *testing.T) {
+	clock := testutil.NewClock(time.Time{})
+	sink := newSinkConnection()
+	conn := jsonrpc.NewMeteredConnection(sink, jsonrpc.MeterReadLimit(1000, 1000), jsonrpc.MeterClock(clock))
+
+	sink.reads <- message(1000)
+	sink.reads <- message(1000)
+
+	data, err := conn.Read()
+	assert.Nil(t, err)
+	assert.Len(t, data, 1000)
+
+	read := make(chan []byte)
+	go func() {
+		data, _ := conn.Read()
+		read <- data
+	}()
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	assert.Len(t, <-read, 1000)
+}
+
+func TestMeteredConnection_ReadCancelled(t *testing.T) {
+	clock := testutil.NewClock(time.Time{})
+	sink := newSinkConnection()
+	ctx, cancel := context.WithCancel(context.Background())
+	conn := jsonrpc.NewMeteredConnection(sink, jsonrpc.MeterReadLimit(1000, 1000), jsonrpc.MeterClock(clock), jsonrpc.MeterContext(ctx))
+
+	sink.reads <- message(1000)
+	sink.reads <- message(1000)
+	sink.reads <- message(10)
+
+	_, err := conn.Read()
+	assert.Nil(t, err)
+
+	type result struct {
+		data []byte
+		err  error
+	}
+	read := make(chan result)
+	go func() {
+		data, err := conn.Read()
+		read <- result{data, err}
+	}()
+	clock.BlockUntil(1)
+	cancel()
+
+	// the message being held is returned rather than dropped
+	r := <-read
+	assert.Nil(t, r.err)
+	assert.Len(t, r.data, 1000)
+
+	_, err = conn.Read()
+	assert.ErrorIs(t, err, jsonrpc.ErrClosed)
+	assert.Len(t, sink.reads, 1)
+}
@@ -0,0 +1,208 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/juju/errors"
+)
+
+var (
+	ErrMessageTooLarge = errors.ConstError("message exceeds maximum size")
+	ErrInvalidFrame    = errors.ConstError("invalid frame")
+	// ErrFraming is returned by the Read of a stream connection whose framing has been corrupted,
+	// wrapping the ErrInvalidFrame from the framer. The position of the next message is unknown,
+	// so the connection is closed rather than attempting to resynchronise.
+	ErrFraming = errors.ConstError("stream framing corrupted")
+)
+
+// FramingMode determines how messages are delimited on a stream connection.
+type FramingMode int
+
+const (
+	// FramingNewline delimits each message with a trailing newline.
+	FramingNewline FramingMode = iota
+	// FramingContentLength prefixes each message with a Content-Length header block, as used by the
+	// Language Server Protocol.
+	FramingContentLength
+	// FramingLengthPrefixed prefixes each message with its length as a 4 byte big endian integer.
+	FramingLengthPrefixed
+	// FramingJSON reads each message as a json value, regardless of the whitespace around or within
+	// it, and writes messages delimited with a trailing newline.
+	FramingJSON
+)
+
+// Framer returns the Framer which implements the framing mode.
+func (f FramingMode) Framer() Framer {
+	switch f {
+	case FramingContentLength:
+		return ContentLengthFramer()
+	case FramingLengthPrefixed:
+		return LengthPrefixFramer()
+	case FramingJSON:
+		return JSONFramer()
+	default:
+		return NewlineFramer()
+	}
+}
+
+// DefaultMaxMessageSize is the default limit on the size of a message read from a stream connection.
+const DefaultMaxMessageSize = 16 * 1024 * 1024
+
+// StreamMaxMessageSize limits the size of messages which can be read from the stream, larger
+// messages fail with ErrMessageTooLarge. A size of zero or less removes the limit.
+func StreamMaxMessageSize(size int) StreamOption {
+	return func(opts *StreamOptions) {
+		opts.MaxMessageSize = size
+	}
+}
+
+// StreamFramer sets how messages are delimited on the stream. The default is NewlineFramer.
+func StreamFramer(framer Framer) StreamOption {
+	return func(opts *StreamOptions) {
+		opts.Framer = framer
+	}
+}
+
+type StreamOption = func(opts *StreamOptions)
+
+type StreamOptions struct {
+	MaxMessageSize int
+	Framer         Framer
+}
+
+func DefaultStreamOptions() StreamOptions {
+	return StreamOptions{
+		MaxMessageSize: DefaultMaxMessageSize,
+		Framer:         NewlineFramer(),
+	}
+}
+
+type streamConnection struct {
+	rw      io.ReadWriter
+	opts    StreamOptions
+	reader  *bufio.Reader
+	writeMu sync.Mutex
+
+	// readErr is set once the stream cannot be parsed, failing all subsequent reads
+	readErr error
+}
+
+// NewStreamConnection creates a Connection which exchanges messages over conn, delimited according
+// to framing. The result can be passed to NewClientWithConnection.
+func NewStreamConnection(conn net.Conn, framing FramingMode, options ...StreamOption) Connection {
+	return NewFramedConnection(conn, append([]StreamOption{StreamFramer(framing.Framer())}, options...)...)
+}
+
+// NewFramedConnection creates a Connection which exchanges messages over rw, such as a pipe or the
+// stdin and stdout of a process. rw is closed with the connection if it implements io.Closer.
+func NewFramedConnection(rw io.ReadWriter, options ...StreamOption) Connection {
+	opts := DefaultStreamOptions()
+	for _, opt := range options {
+		opt(&opts)
+	}
+	return &streamConnection{
+		rw:     rw,
+		opts:   opts,
+		reader: bufio.NewReader(rw),
+	}
+}
+
+func (s *streamConnection) Write(data []byte) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	return mapStreamError(s.opts.Framer.WriteFrame(s.rw, data))
+}
+
+// writeFrames writes each of frames to the stream in a single write.
+func (s *streamConnection) writeFrames(frames [][]byte) error {
+	var buf bytes.Buffer
+	for _, data := range frames {
+		if err := s.opts.Framer.WriteFrame(&buf, data); err != nil {
+			return mapStreamError(err)
+		}
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	_, err := s.rw.Write(buf.Bytes())
+	return mapStreamError(err)
+}
+
+func (s *streamConnection) Read() ([]byte, error) {
+	if s.readErr != nil {
+		return nil, s.readErr
+	}
+
+	data, err := s.opts.Framer.ReadFrame(s.reader, s.opts.MaxMessageSize)
+	if errors.Is(err, ErrInvalidFrame) || errors.Is(err, ErrMessageTooLarge) {
+		// the position of the next message is unknown, so the stream cannot be recovered
+		s.readErr = ErrClosed
+		_ = s.Close()
+	}
+	if errors.Is(err, ErrInvalidFrame) {
+		err = errors.WithType(err, ErrFraming)
+	}
+
+	return data, mapStreamError(err)
+}
+
+func (s *streamConnection) Close() error {
+	if closer, ok := s.rw.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// RemoteAddr returns the address of the peer, or nil if the stream is not a net.Conn.
+func (s *streamConnection) RemoteAddr() net.Addr {
+	if conn, ok := s.rw.(net.Conn); ok {
+		return conn.RemoteAddr()
+	}
+	return nil
+}
+
+// NetConn returns the underlying net.Conn, or nil if the stream is not a net.Conn.
+func (s *streamConnection) NetConn() net.Conn {
+	conn, _ := s.rw.(net.Conn)
+	return conn
+}
+
+// NewNetDialer creates a dialer for stream oriented networks such as "tcp" and "unix", see
+// net.Dial. Messages are delimited by the framer set with StreamFramer.
+func NewNetDialer(network string, address string, options ...StreamOption) Dialer {
+	return &netDialer{network: network, address: address, options: options}
+}
+
+type netDialer struct {
+	network string
+	address string
+	options []StreamOption
+}
+
+func (d *netDialer) Dial() (Connection, error) {
+	return d.DialContext(context.Background())
+}
+
+func (d *netDialer) DialContext(ctx context.Context) (Connection, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, d.network, d.address)
+	if err != nil {
+		return nil, err
+	}
+	return NewFramedConnection(conn, d.options...), nil
+}
+
+// mapStreamError re-maps errors indicating the stream has been closed to ErrClosed.
+func mapStreamError(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF || errors.Is(err, net.ErrClosed) || err == io.ErrClosedPipe {
+		return ErrClosed
+	}
+	return err
+}
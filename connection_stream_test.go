@@ -0,0 +1,99 @@
+package jsonrpc_test
+
+import (
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/41north/jsonrpc.go"
+
+	"github.com/juju/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+var framingModes = []struct {
+	name    string
+	framing jsonrpc.FramingMode
+}{
+	{"Newline", jsonrpc.FramingNewline},
+	{"ContentLength", jsonrpc.FramingContentLength},
+	{"LengthPrefixed", jsonrpc.FramingLengthPrefixed},
+}
+
+// serveStream replies to every request received on conn with a response echoing its params.
+func serveStream(conn jsonrpc.Connection) {
+	for {
+		bytes, err := conn.Read()
+		if err != nil {
+			return
+		}
+		var req jsonrpc.Request
+		if err := json.Unmarshal(bytes, &req); err != nil {
+			return
+		}
+		resp := jsonrpc.Response{Id: req.Id, Result: req.Params, Version: req.Version}
+		bytes, err = json.Marshal(resp)
+		if err != nil {
+			return
+		}
+		if err := conn.Write(bytes); err != nil {
+			return
+		}
+	}
+}
+
+func TestStreamConnection_RoundTrip(t *testing.T) {
+	for _, tc := range framingModes {
+		t.Run(tc.name, func(t *testing.T) {
+			clientConn, serverConn := net.Pipe()
+			go serveStream(jsonrpc.NewStreamConnection(serverConn, tc.framing))
+
+			client := jsonrpc.NewClientWithConnection(jsonrpc.NewStreamConnection(clientConn, tc.framing))
+			err := client.Connect()
+			assert.Nil(t, err)
+
+			for i := 0; i < 100; i++ {
+				params := []string{"hello", strings.Repeat("world", i)}
+
+				var resp jsonrpc.Response
+				err = client.Send(*newRequest("echo", params, jsonrpc.RequestNumericId(i)), &resp)
+				assert.Nil(t, err)
+
+				var result []string
+				assert.Nil(t, resp.UnmarshalResult(&result))
+				assert.Equal(t, params, result)
+			}
+
+			assert.Nil(t, client.Close())
+		})
+	}
+}
+
+func TestStreamConnection_MaxMessageSize(t *testing.T) {
+	for _, tc := range framingModes {
+		t.Run(tc.name, func(t *testing.T) {
+			clientConn, serverConn := net.Pipe()
+			defer clientConn.Close()
+
+			server := jsonrpc.NewStreamConnection(serverConn, tc.framing)
+			go func() {
+				_ = server.Write([]byte(strings.Repeat("a", 1024)))
+			}()
+
+			conn := jsonrpc.NewStreamConnection(clientConn, tc.framing, jsonrpc.StreamMaxMessageSize(512))
+			_, err := conn.Read()
+			assert.True(t, errors.Is(err, jsonrpc.ErrMessageTooLarge))
+		})
+	}
+}
+
+func TestStreamConnection_Closed(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	conn := jsonrpc.NewStreamConnection(clientConn, jsonrpc.FramingNewline)
+
+	assert.Nil(t, serverConn.Close())
+
+	_, err := conn.Read()
+	assert.Equal(t, jsonrpc.ErrClosed, err)
+}
@@ -2,7 +2,11 @@ package jsonrpc
 
 import (
 	"context"
+	"net"
 	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
 
 	"github.com/gorilla/websocket"
 	"github.com/juju/errors"
@@ -11,6 +15,51 @@ import (
 
 type webSocketConnection struct {
 	conn *websocket.Conn
+	// pongs holds a channel for each outstanding ping, keyed by its payload
+	pongs   sync.Map
+	pingSeq atomic.Uint64
+}
+
+func newWebSocketConnection(conn *websocket.Conn) *webSocketConnection {
+	w := &webSocketConnection{conn: conn}
+	conn.SetPongHandler(func(data string) error {
+		if ch, ok := w.pongs.LoadAndDelete(data); ok {
+			close(ch.(chan struct{}))
+		}
+		return nil
+	})
+	return w
+}
+
+// RemoteAddr returns the address of the peer.
+func (w *webSocketConnection) RemoteAddr() net.Addr {
+	return w.conn.RemoteAddr()
+}
+
+// NetConn returns the underlying net.Conn.
+func (w *webSocketConnection) NetConn() net.Conn {
+	return w.conn.UnderlyingConn()
+}
+
+// Ping sends a ping control frame and waits for the matching pong. Pongs are only processed while
+// the connection is being read.
+func (w *webSocketConnection) Ping(ctx context.Context) error {
+	payload := strconv.FormatUint(w.pingSeq.Add(1), 10)
+	pong := make(chan struct{})
+	w.pongs.Store(payload, pong)
+	defer w.pongs.Delete(payload)
+
+	deadline, _ := ctx.Deadline()
+	if err := w.conn.WriteControl(websocket.PingMessage, []byte(payload), deadline); err != nil {
+		return errors.Annotate(err, "failed to write ping")
+	}
+
+	select {
+	case <-pong:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 func (w *webSocketConnection) Write(data []byte) error {
@@ -23,12 +72,12 @@ func (w *webSocketConnection) Read() ([]byte, error) {
 
 		log.WithError(err).Error("read failure")
 
-		switch err.(type) {
+		switch e := err.(type) {
 		case *websocket.CloseError:
 			// re-map error
-			return nil, ErrClosed
+			return nil, &CloseError{Code: e.Code, Reason: e.Text}
 		default:
-			return nil, err
+			return nil, mapWebSocketError(err)
 		}
 	}
 
@@ -60,6 +109,8 @@ func (w WebSocketDialer) Dial() (Connection, error) {
 func (w WebSocketDialer) DialContext(ctx context.Context) (Connection, error) {
 	dialer := websocket.Dialer{}
 	wsConn, _, err := dialer.DialContext(ctx, w.Url, w.RequestHeader)
-	conn := webSocketConnection{conn: wsConn}
-	return &conn, err
+	if err != nil {
+		return &webSocketConnection{conn: wsConn}, err
+	}
+	return newWebSocketConnection(wsConn), nil
 }
@@ -27,13 +27,17 @@ func newWsServer(push bool) *wsServer {
 type wsServer struct {
 	srv                *httptest.Server
 	testMessages       chan testMessage
+	receivedMessages   chan []byte
 	push               bool
 	closeOnNextMessage atomic.Bool
+	// closeMessage, if set, is sent as a close frame when closing on the next message
+	closeMessage []byte
 }
 
 func (t *wsServer) start() {
 	t.srv = httptest.NewServer(t)
 	t.testMessages = make(chan testMessage, 16)
+	t.receivedMessages = make(chan []byte, 16)
 }
 
 func (t *wsServer) close() {
@@ -77,13 +81,22 @@ func (t *wsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 			// normal request -> response
 
-			_, _, err := c.ReadMessage()
+			_, data, err := c.ReadMessage()
 			if err != nil {
 				log.WithError(err).Error("failed to read message")
 				return
 			}
 
+			// record the message if there is space, otherwise drop it
+			select {
+			case t.receivedMessages <- data:
+			default:
+			}
+
 			if t.closeOnNextMessage.Load() {
+				if t.closeMessage != nil {
+					_ = c.WriteMessage(websocket.CloseMessage, t.closeMessage)
+				}
 				return
 			}
 
@@ -0,0 +1,80 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// WithCorrelator sets how requests are matched with their responses. request returns the key under
+// which a request is held in flight and response the key of the request it answers, allowing
+// correlation on something other than the id, such as the method and original id of a request
+// whose id is rewritten by a proxy. Requests in flight at the same time must have distinct keys.
+// The default correlates by id.
+func WithCorrelator(request func(req Request) string, response func(resp Response) string) ClientOption {
+	return func(opts *ClientOptions) {
+		opts.CorrelateRequest = request
+		opts.CorrelateResponse = response
+	}
+}
+
+// CorrelateById returns the id of req as its correlation key.
+func CorrelateById(req Request) string {
+	return string(req.Id)
+}
+
+// CorrelateResponseById returns the id of resp as its correlation key.
+func CorrelateResponseById(resp Response) string {
+	return string(resp.Id)
+}
+
+// IdNormalizer maps an id onto a canonical form, so that ids which a server alters in transit still
+// match those sent, see WithIdNormalizer.
+type IdNormalizer = func(id json.RawMessage) json.RawMessage
+
+// WithIdNormalizer correlates requests with their responses by id, after applying normalizers to
+// both the id sent and the id received, in order. It replaces any correlator set with
+// WithCorrelator. Ids which normalize to the same value must not be in flight at the same time.
+func WithIdNormalizer(normalizers ...IdNormalizer) ClientOption {
+	normalize := func(id json.RawMessage) string {
+		for _, n := range normalizers {
+			id = n(id)
+		}
+		return string(id)
+	}
+	return WithCorrelator(
+		func(req Request) string {
+			return normalize(req.Id)
+		},
+		func(resp Response) string {
+			return normalize(resp.Id)
+		},
+	)
+}
+
+// CoerceNumericIds normalizes string ids holding a number to the number itself, for servers which
+// echo numeric ids as strings, or the reverse, e.g. "5" and 5 are treated as the same id.
+func CoerceNumericIds(id json.RawMessage) json.RawMessage {
+	id = bytes.TrimSpace(id)
+	var s string
+	if len(id) == 0 || id[0] != '"' || json.Unmarshal(id, &s) != nil {
+		return id
+	}
+	if s == "" || !(s[0] == '-' || (s[0] >= '0' && s[0] <= '9')) || !json.Valid([]byte(s)) {
+		return id
+	}
+	return json.RawMessage(s)
+}
+
+// LowercaseIds normalizes string ids to lower case, for servers which change the case of hex ids.
+func LowercaseIds(id json.RawMessage) json.RawMessage {
+	var s string
+	if json.Unmarshal(id, &s) != nil {
+		return id
+	}
+	lower, err := json.Marshal(strings.ToLower(s))
+	if err != nil {
+		return id
+	}
+	return lower
+}
@@ -0,0 +1,125 @@
+package jsonrpc_test
+
+import (
+	"encoding/json"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/41north/jsonrpc.go"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_Correlator(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	serverStream := jsonrpc.NewStreamConnection(serverConn, jsonrpc.FramingNewline)
+
+	// correlate on a side channel token, as the server rewrites ids
+	client := jsonrpc.NewClientWithConnection(
+		jsonrpc.NewStreamConnection(clientConn, jsonrpc.FramingNewline),
+		jsonrpc.WithCorrelator(
+			func(req jsonrpc.Request) string { return string(req.Extension("token")) },
+			func(resp jsonrpc.Response) string { return string(resp.Extension("token")) },
+		),
+	)
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	// reply in reverse order, echoing the params and token under a different id
+	go func() {
+		var requests []jsonrpc.Request
+		for i := 0; i < 2; i++ {
+			data, err := serverStream.Read()
+			if err != nil {
+				return
+			}
+			var req jsonrpc.Request
+			_ = json.Unmarshal(data, &req)
+			requests = append(requests, req)
+		}
+		for i := len(requests) - 1; i >= 0; i-- {
+			resp, _ := jsonrpc.NewResponse(requests[i].Params, jsonrpc.ResponseNumericId(i+100))
+			_ = resp.SetExtension("token", requests[i].Extension("token"))
+			data, _ := json.Marshal(resp)
+			_ = serverStream.Write(data)
+		}
+	}()
+
+	var futures []jsonrpc.ResponseFuture
+	for _, token := range []string{"a", "b"} {
+		req := newRequest("echo", []string{token})
+		assert.Nil(t, req.SetExtension("token", token))
+		futures = append(futures, client.SendAsync(*req))
+	}
+
+	for i, token := range []string{"a", "b"} {
+		resp, err := (<-futures[i].Get()).Unwrap()
+		assert.Nil(t, err)
+
+		var result []string
+		assert.Nil(t, resp.UnmarshalResult(&result))
+		assert.Equal(t, []string{token}, result)
+	}
+}
+
+func TestClient_IdNormalizer(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	serverStream := jsonrpc.NewStreamConnection(serverConn, jsonrpc.FramingNewline)
+
+	client := jsonrpc.NewClientWithConnection(
+		jsonrpc.NewStreamConnection(clientConn, jsonrpc.FramingNewline),
+		jsonrpc.WithIdNormalizer(jsonrpc.CoerceNumericIds, jsonrpc.LowercaseIds),
+	)
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	// a legacy server which echoes numeric ids as strings and upper cases string ids
+	go func() {
+		for {
+			data, err := serverStream.Read()
+			if err != nil {
+				return
+			}
+			var req jsonrpc.Request
+			_ = json.Unmarshal(data, &req)
+
+			var id any
+			_ = json.Unmarshal(req.Id, &id)
+			switch v := id.(type) {
+			case float64:
+				req.Id, _ = json.Marshal(strconv.FormatFloat(v, 'f', -1, 64))
+			case string:
+				req.Id, _ = json.Marshal(strings.ToUpper(v))
+			}
+
+			resp := jsonrpc.Response{Id: req.Id, Result: req.Params, Version: "2.0"}
+			data, _ = json.Marshal(resp)
+			_ = serverStream.Write(data)
+		}
+	}()
+
+	for _, option := range []jsonrpc.RequestOption{jsonrpc.RequestNumericId(42), jsonrpc.RequestStringId("0xabc")} {
+		var resp jsonrpc.Response
+		assert.Nil(t, client.Send(*newRequest("echo", []int{1}, option), &resp))
+		assert.Equal(t, "[1]", string(resp.Result))
+	}
+
+	testCases := []struct {
+		id         string
+		normalized string
+	}{
+		{`"5"`, `5`},
+		{`"-1.5e3"`, `-1.5e3`},
+		{`5`, `5`},
+		{`"abc"`, `"abc"`},
+		{`"5a"`, `"5a"`},
+		{`""`, `""`},
+	}
+	for _, tt := range testCases {
+		assert.Equal(t, tt.normalized, string(jsonrpc.CoerceNumericIds(json.RawMessage(tt.id))), tt.id)
+	}
+	assert.Equal(t, `"0xabc"`, string(jsonrpc.LowercaseIds(json.RawMessage(`"0xABC"`))))
+	assert.Equal(t, `7`, string(jsonrpc.LowercaseIds(json.RawMessage(`7`))))
+}
@@ -0,0 +1,81 @@
+package jsonrpc
+
+import (
+	"sync"
+	"time"
+)
+
+// WithDiagnosticBuffer retains a record of the last n completed calls, for post-mortem debugging,
+// see Client.RecentCalls. Params and results are not retained.
+func WithDiagnosticBuffer(n int) ClientOption {
+	return func(opts *ClientOptions) {
+		opts.DiagnosticBuffer = n
+	}
+}
+
+// CallRecord describes a completed call. Err is the error the call failed with, or the error
+// returned by the server.
+type CallRecord struct {
+	Method   string
+	ID       string
+	Start    time.Time
+	Duration time.Duration
+	Err      error
+}
+
+// callRing is a fixed size ring buffer of call records.
+type callRing struct {
+	mu      sync.Mutex
+	records []CallRecord
+	next    int
+	full    bool
+	clock   Clock
+}
+
+func newCallRing(size int, clock Clock) *callRing {
+	return &callRing{records: make([]CallRecord, size), clock: clock}
+}
+
+func (r *callRing) add(record CallRecord) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records[r.next] = record
+	r.next = (r.next + 1) % len(r.records)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// snapshot returns the records, oldest first.
+func (r *callRing) snapshot() []CallRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.full {
+		return append([]CallRecord(nil), r.records[:r.next]...)
+	}
+	return append(append([]CallRecord(nil), r.records[r.next:]...), r.records[:r.next]...)
+}
+
+// record adds the outcome of request to the ring.
+func (r *callRing) record(request *inFlightRequest, resp *Response, err error) {
+	if err == nil && resp != nil && resp.Error != nil {
+		err = resp.appError
+		if err == nil {
+			err = *resp.Error
+		}
+	}
+	r.add(CallRecord{
+		Method:   request.method,
+		ID:       request.id,
+		Start:    request.start,
+		Duration: r.clock.Now().Sub(request.start),
+		Err:      err,
+	})
+}
+
+func (c *client) RecentCalls() []CallRecord {
+	if c.recent == nil {
+		return nil
+	}
+	return c.recent.snapshot()
+}
@@ -0,0 +1,43 @@
+package jsonrpc_test
+
+import (
+	"testing"
+
+	"github.com/41north/jsonrpc.go"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_RecentCalls(t *testing.T) {
+	client := jsonrpc.NewClient(serverDialer(endpoint("a", nil), nil), jsonrpc.WithDiagnosticBuffer(3))
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	var resp jsonrpc.Response
+	for i := 0; i < 4; i++ {
+		assert.Nil(t, client.Send(*newRequest("name", nil, jsonrpc.RequestNumericId(i)), &resp))
+	}
+	assert.Nil(t, client.Send(*newRequest("unknown", nil, jsonrpc.RequestNumericId(4)), &resp))
+
+	// only the last calls are retained, oldest first
+	calls := client.RecentCalls()
+	assert.Len(t, calls, 3)
+	for i, call := range calls {
+		assert.Equal(t, []string{"2", "3", "4"}[i], call.ID)
+		assert.False(t, call.Start.IsZero())
+		assert.Greater(t, call.Duration.Nanoseconds(), int64(0))
+	}
+	assert.Equal(t, "name", calls[1].Method)
+	assert.Nil(t, calls[1].Err)
+	assert.Equal(t, "unknown", calls[2].Method)
+	var e jsonrpc.Error
+	assert.ErrorAs(t, calls[2].Err, &e)
+	assert.Equal(t, jsonrpc.ErrMethodNotFound.Code, e.Code)
+
+	// failures are recorded too
+	assert.Nil(t, client.Close())
+	assert.NotNil(t, client.Send(*newRequest("name", nil), &resp))
+	assert.ErrorIs(t, client.RecentCalls()[2].Err, jsonrpc.ErrClosed)
+
+	assert.Nil(t, jsonrpc.NewClient(nil).RecentCalls())
+}
@@ -0,0 +1,52 @@
+package jsonrpc_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/41north/jsonrpc.go"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// hammers Connect, Close and Send concurrently, for the race detector
+func TestClient_ConnectCloseRace(t *testing.T) {
+	server := jsonrpc.NewServer()
+	server.Register("echo", echo)
+	dialer := serverDialer(server, nil)
+
+	for i := 0; i < 50; i++ {
+		client := jsonrpc.NewClient(dialer)
+		var wg sync.WaitGroup
+		for j := 0; j < 8; j++ {
+			wg.Add(3)
+			go func() { defer wg.Done(); _ = client.Connect() }()
+			go func() { defer wg.Done(); _ = client.Close() }()
+			go func() {
+				defer wg.Done()
+				var resp jsonrpc.Response
+				_ = client.Send(*newRequest("echo", nil), &resp)
+			}()
+		}
+		wg.Wait()
+		_ = client.Close()
+	}
+}
+
+func TestSingleUseDialer(t *testing.T) {
+	server := jsonrpc.NewServer()
+	dialer := jsonrpc.SingleUseDialer(serverDialer(server, nil))
+
+	client := jsonrpc.NewClient(dialer)
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	// a second client sharing the dialer fails fast
+	err := jsonrpc.NewClient(dialer).Connect()
+	assert.ErrorIs(t, err, jsonrpc.ErrDial)
+	assert.ErrorIs(t, err, jsonrpc.ErrDialerUsed)
+
+	// connecting after close is refused without dialing
+	assert.Nil(t, client.Close())
+	assert.ErrorIs(t, client.Connect(), jsonrpc.ErrClosed)
+}
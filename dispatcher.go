@@ -0,0 +1,77 @@
+package jsonrpc
+
+import (
+	"context"
+	"sync"
+)
+
+type callerKey struct{}
+
+// CallerFromContext returns the client which received the request being handled, from the context
+// passed to a ContextRequestHandler. Handlers can use it to call back to the peer which sent the
+// request.
+func CallerFromContext(ctx context.Context) (Client, bool) {
+	c, ok := ctx.Value(callerKey{}).(Client)
+	return c, ok
+}
+
+// dispatcher runs request handlers off the read loop, so that a handler can make calls of its own
+// without preventing the responses to those calls from being read. Work is queued without bound and
+// executed by a fixed number of workers in the order it was received.
+type dispatcher struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queue  []func()
+	closed bool
+}
+
+func newDispatcher(workers int) *dispatcher {
+	d := &dispatcher{}
+	d.cond = sync.NewCond(&d.mu)
+	if workers < 1 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		go d.work()
+	}
+	return d
+}
+
+// dispatch queues fn for execution, it never blocks.
+func (d *dispatcher) dispatch(fn func()) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return
+	}
+	d.queue = append(d.queue, fn)
+	d.cond.Signal()
+}
+
+// close stops the workers, discarding any queued work which has not yet started.
+func (d *dispatcher) close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.closed = true
+	d.queue = nil
+	d.cond.Broadcast()
+}
+
+func (d *dispatcher) work() {
+	for {
+		d.mu.Lock()
+		for len(d.queue) == 0 && !d.closed {
+			d.cond.Wait()
+		}
+		if d.closed {
+			d.mu.Unlock()
+			return
+		}
+		fn := d.queue[0]
+		d.queue[0] = nil
+		d.queue = d.queue[1:]
+		d.mu.Unlock()
+
+		fn()
+	}
+}
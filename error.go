@@ -3,8 +3,12 @@ package jsonrpc
 import (
 	"encoding/json"
 	"fmt"
+
+	"github.com/juju/errors"
 )
 
+var ErrNoErrorData = errors.ConstError("error has no data")
+
 var (
 	ErrInvalidRequest = Error{
 		Code:    -32600,
@@ -36,3 +40,21 @@ type Error struct {
 
 // Error renders e to a human-readable string for the error interface.
 func (e Error) Error() string { return fmt.Sprintf("[%d] %s", e.Code, e.Message) }
+
+// DecodeData unmarshals the data member of e into v. ErrNoErrorData is returned if e has no data.
+func (e Error) DecodeData(v any) error {
+	if len(e.Data) == 0 || string(e.Data) == "null" {
+		return ErrNoErrorData
+	}
+	if err := json.Unmarshal(e.Data, v); err != nil {
+		return errors.Annotate(err, "failed to unmarshal error data from json")
+	}
+	return nil
+}
+
+// DataAs unmarshals the data member of e into a T, see Error.DecodeData.
+func DataAs[T any](e Error) (T, error) {
+	var v T
+	err := e.DecodeData(&v)
+	return v, err
+}
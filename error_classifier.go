@@ -0,0 +1,209 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+var (
+	ErrRateLimited = errors.ConstError("rate limited")
+	ErrServerBusy  = errors.ConstError("server busy")
+)
+
+// ServerBusyError is an error response recognised as the server throttling or shedding load. It
+// matches Kind, either ErrRateLimited or ErrServerBusy, with errors.Is and unwraps to the original
+// Error.
+type ServerBusyError struct {
+	Kind error
+	Err  Error
+}
+
+func (e *ServerBusyError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Kind, e.Err)
+}
+
+func (e *ServerBusyError) Is(target error) bool {
+	return target == e.Kind
+}
+
+func (e *ServerBusyError) Unwrap() error {
+	return e.Err
+}
+
+// CodeRange is an inclusive range of error codes.
+type CodeRange struct {
+	Min int32
+	Max int32
+}
+
+func (r CodeRange) contains(code int32) bool {
+	return code >= r.Min && code <= r.Max
+}
+
+// Classification describes whether a failed request is worth retrying and, if the server asked for
+// it, how long to wait before doing so.
+type Classification struct {
+	Retryable  bool
+	RetryAfter time.Duration
+}
+
+// ErrorClassifier decides whether errors are retryable. Errors from the server are first passed to
+// Inspect, if set, which can examine the error data, falling back to RetryableCodes if Inspect
+// returns false for ok. Throttling errors, see Translate, and transport errors, such as a failed
+// dial or a closed connection, are always retryable. Whether a retry is safe, i.e. the request is
+// idempotent, is for the caller to decide.
+//
+// Translate, if set, maps error responses received by a client onto Go errors, such as
+// ErrRateLimited, which are then returned by Response.UnmarshalResult. Returning nil leaves the
+// error unchanged. Errors decoded by an ErrorRegistry take precedence.
+type ErrorClassifier struct {
+	RetryableCodes []CodeRange
+	Inspect        func(e Error) (c Classification, ok bool)
+	Translate      func(e *Error) error
+}
+
+// DefaultErrorClassifier treats internal errors and the range reserved for implementation defined
+// server errors as retryable, honouring any retry after hint found by InspectRetryAfter.
+var DefaultErrorClassifier = ErrorClassifier{
+	RetryableCodes: []CodeRange{
+		{Min: ErrInternal.Code, Max: ErrInternal.Code},
+		{Min: -32099, Max: -32000},
+	},
+	Inspect:   InspectRetryAfter,
+	Translate: TranslateServerBusy,
+}
+
+// serverBusyPhrases are the messages, matched case insensitively, by which common providers report
+// throttling and overload.
+var serverBusyPhrases = []struct {
+	phrase string
+	kind   error
+}{
+	{"rate limit", ErrRateLimited},
+	{"too many requests", ErrRateLimited},
+	{"request limit", ErrRateLimited},
+	{"limit exceeded", ErrRateLimited},
+	{"throttl", ErrRateLimited},
+	{"busy", ErrServerBusy},
+	{"overload", ErrServerBusy},
+	{"capacity", ErrServerBusy},
+	{"try again later", ErrServerBusy},
+}
+
+// TranslateServerBusy recognises errors in the range reserved for implementation defined server
+// errors, -32099 to -32000, whose message indicates throttling or overload, returning a
+// *ServerBusyError of kind ErrRateLimited or ErrServerBusy. Other errors are left unchanged.
+func TranslateServerBusy(e *Error) error {
+	if e.Code < -32099 || e.Code > -32000 {
+		return nil
+	}
+	msg := strings.ToLower(e.Message)
+	for _, p := range serverBusyPhrases {
+		if strings.Contains(msg, p.phrase) {
+			return &ServerBusyError{Kind: p.kind, Err: *e}
+		}
+	}
+	return nil
+}
+
+// WithErrorClassifier sets the classifier used to decide which failed requests may be retried, and
+// to translate error responses into Go errors.
+func WithErrorClassifier(classifier ErrorClassifier) ClientOption {
+	return func(opts *ClientOptions) {
+		opts.ErrorClassifier = classifier
+	}
+}
+
+// Classify returns the classification of err.
+func (c ErrorClassifier) Classify(err error) Classification {
+	if err == nil {
+		return Classification{}
+	}
+
+	if e, ok := asError(err); ok {
+		if c.Inspect != nil {
+			if classification, ok := c.Inspect(e); ok {
+				return classification
+			}
+		}
+		if errors.Is(err, ErrRateLimited) || errors.Is(err, ErrServerBusy) {
+			return Classification{Retryable: true}
+		}
+		for _, r := range c.RetryableCodes {
+			if r.contains(e.Code) {
+				return Classification{Retryable: true}
+			}
+		}
+		return Classification{}
+	}
+
+	var transportErr *TransportError
+	if errors.As(err, &transportErr) {
+		return Classification{Retryable: transportErr.retryable()}
+	}
+	return Classification{Retryable: errors.Is(err, ErrDial) || errors.Is(err, ErrClosed)}
+}
+
+// IsRetryable returns true if err is classified as retryable.
+func (c ErrorClassifier) IsRetryable(err error) bool {
+	return c.Classify(err).Retryable
+}
+
+// IsRetryable returns true if err is retryable according to DefaultErrorClassifier.
+func IsRetryable(err error) bool {
+	return DefaultErrorClassifier.IsRetryable(err)
+}
+
+// RetryAfter returns the delay requested by the server before retrying, according to
+// DefaultErrorClassifier.
+func RetryAfter(err error) (time.Duration, bool) {
+	c := DefaultErrorClassifier.Classify(err)
+	return c.RetryAfter, c.Retryable && c.RetryAfter > 0
+}
+
+// InspectRetryAfter looks for a retry after hint in the error data, marking the error as retryable
+// if one is found. The hint may be a "retryAfter" or "retry-after" member of an object, holding
+// either a duration string such as "5s" or a number of seconds, or string data of the form
+// "retry-after: 5s".
+func InspectRetryAfter(e Error) (Classification, bool) {
+	var hint json.RawMessage
+
+	if s, err := DataAs[string](e); err == nil {
+		key, value, found := strings.Cut(s, ":")
+		if !found || !isRetryAfterKey(strings.TrimSpace(key)) {
+			return Classification{}, false
+		}
+		hint, _ = json.Marshal(strings.TrimSpace(value))
+	} else if fields, err := DataAs[map[string]json.RawMessage](e); err == nil {
+		for key, value := range fields {
+			if isRetryAfterKey(key) {
+				hint = value
+				break
+			}
+		}
+	}
+
+	if hint == nil {
+		return Classification{}, false
+	}
+
+	var seconds float64
+	if err := json.Unmarshal(hint, &seconds); err == nil && seconds >= 0 {
+		return Classification{Retryable: true, RetryAfter: time.Duration(seconds * float64(time.Second))}, true
+	}
+	var s string
+	if err := json.Unmarshal(hint, &s); err == nil {
+		if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+			return Classification{Retryable: true, RetryAfter: d}, true
+		}
+	}
+	return Classification{}, false
+}
+
+func isRetryAfterKey(key string) bool {
+	return strings.EqualFold(key, "retryAfter") || strings.EqualFold(key, "retry-after")
+}
@@ -0,0 +1,81 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/juju/errors"
+)
+
+// ErrorFactory creates an application level error from the message and data of an error response.
+type ErrorFactory = func(msg string, data json.RawMessage) error
+
+// ErrorCoder may be implemented by application errors to control how they are serialized into an
+// error response.
+type ErrorCoder interface {
+	error
+	JSONRPCError() Error
+}
+
+// ErrorRegistry maps error codes to factories for application level error types. It is safe for
+// concurrent use.
+type ErrorRegistry struct {
+	mu        sync.RWMutex
+	factories map[int]ErrorFactory
+}
+
+func NewErrorRegistry() *ErrorRegistry {
+	return &ErrorRegistry{
+		factories: make(map[int]ErrorFactory),
+	}
+}
+
+// Register associates factory with code, replacing any factory previously registered.
+func (r *ErrorRegistry) Register(code int, factory ErrorFactory) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.factories[code] = factory
+}
+
+// Decode creates the application level error registered for the code of e. If no factory has been
+// registered e is returned unchanged.
+func (r *ErrorRegistry) Decode(e Error) error {
+	r.mu.RLock()
+	factory, ok := r.factories[int(e.Code)]
+	r.mu.RUnlock()
+
+	if !ok {
+		return e
+	}
+	return factory(e.Message, e.Data)
+}
+
+// Encode converts err into an Error suitable for an error response. Errors which are, or wrap, an
+// Error or ErrorCoder are converted directly, anything else is reported as an internal error.
+func (r *ErrorRegistry) Encode(err error) Error {
+	return encodeError(err)
+}
+
+func encodeError(err error) Error {
+	var coder ErrorCoder
+	if errors.As(err, &coder) {
+		return coder.JSONRPCError()
+	}
+	if e, ok := asError(err); ok {
+		return e
+	}
+	return Error{Code: ErrInternal.Code, Message: err.Error()}
+}
+
+// asError returns the Error which err is, or wraps, whether by value or by pointer.
+func asError(err error) (Error, bool) {
+	var e Error
+	if errors.As(err, &e) {
+		return e, true
+	}
+	var ptr *Error
+	if errors.As(err, &ptr) && ptr != nil {
+		return *ptr, true
+	}
+	return Error{}, false
+}
@@ -0,0 +1,57 @@
+package jsonrpc_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/41north/jsonrpc.go"
+
+	"github.com/juju/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type insufficientFundsError struct {
+	msg     string
+	balance int
+}
+
+func (e insufficientFundsError) Error() string { return e.msg }
+
+func (e insufficientFundsError) JSONRPCError() jsonrpc.Error {
+	data, _ := json.Marshal(e.balance)
+	return jsonrpc.Error{Code: 1001, Message: e.msg, Data: data}
+}
+
+func newTestErrorRegistry() *jsonrpc.ErrorRegistry {
+	reg := jsonrpc.NewErrorRegistry()
+	reg.Register(1001, func(msg string, data json.RawMessage) error {
+		e := insufficientFundsError{msg: msg}
+		_ = json.Unmarshal(data, &e.balance)
+		return e
+	})
+	return reg
+}
+
+func TestErrorRegistry_Decode(t *testing.T) {
+	reg := newTestErrorRegistry()
+
+	err := reg.Decode(jsonrpc.Error{Code: 1001, Message: "insufficient funds", Data: []byte("42")})
+	assert.Equal(t, insufficientFundsError{msg: "insufficient funds", balance: 42}, err)
+
+	// unregistered codes are returned unchanged
+	err = reg.Decode(jsonrpc.ErrMethodNotFound)
+	assert.Equal(t, jsonrpc.ErrMethodNotFound, err)
+}
+
+func TestErrorRegistry_Encode(t *testing.T) {
+	reg := newTestErrorRegistry()
+
+	appErr := insufficientFundsError{msg: "insufficient funds", balance: 42}
+	assert.Equal(t, appErr.JSONRPCError(), reg.Encode(appErr))
+	assert.Equal(t, appErr.JSONRPCError(), reg.Encode(errors.Annotate(appErr, "transfer failed")))
+	assert.Equal(t, jsonrpc.ErrInvalidParams, reg.Encode(jsonrpc.ErrInvalidParams))
+	assert.Equal(t, jsonrpc.Error{Code: -32603, Message: "boom"}, reg.Encode(errors.New("boom")))
+
+	// round trip
+	assert.Equal(t, appErr, reg.Decode(reg.Encode(appErr)))
+}
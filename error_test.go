@@ -1,8 +1,11 @@
 package jsonrpc_test
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/41north/jsonrpc.go"
 
@@ -39,3 +42,122 @@ func TestError_Unmarshal(t *testing.T) {
 		assert.Equal(t, tt.value, e)
 	}
 }
+
+func TestError_DecodeData(t *testing.T) {
+	// absent data
+	_, err := jsonrpc.DataAs[string](jsonrpc.ErrInternal)
+	assert.ErrorIs(t, err, jsonrpc.ErrNoErrorData)
+
+	// string data
+	s, err := jsonrpc.DataAs[string](jsonrpc.Error{Code: 1, Data: dataBytes})
+	assert.Nil(t, err)
+	assert.Equal(t, "Some data", s)
+
+	// object data
+	type revert struct {
+		Reason string `json:"reason"`
+	}
+	e := jsonrpc.Error{Code: 3, Data: json.RawMessage(`{"reason":"insufficient funds"}`)}
+	var r revert
+	assert.Nil(t, e.DecodeData(&r))
+	assert.Equal(t, "insufficient funds", r.Reason)
+
+	// invalid json
+	e.Data = json.RawMessage(`{"reason":`)
+	_, err = jsonrpc.DataAs[revert](e)
+	assert.NotNil(t, err)
+}
+
+func TestErrorClassifier(t *testing.T) {
+	testCases := []struct {
+		err        error
+		retryable  bool
+		retryAfter time.Duration
+	}{
+		{nil, false, 0},
+		{jsonrpc.ErrMethodNotFound, false, 0},
+		{jsonrpc.ErrInternal, true, 0},
+		{&jsonrpc.Error{Code: -32005, Message: "limit exceeded"}, true, 0},
+		{fmt.Errorf("wrapped: %w", jsonrpc.ErrInternal), true, 0},
+		{jsonrpc.ErrClosed, true, 0},
+		{&jsonrpc.DialError{Cause: fmt.Errorf("connection refused")}, true, 0},
+		{fmt.Errorf("unknown"), false, 0},
+
+		// hints in the error data make an error retryable regardless of code
+		{jsonrpc.Error{Code: 429, Data: json.RawMessage(`"retry-after: 5s"`)}, true, 5 * time.Second},
+		{jsonrpc.Error{Code: 429, Data: json.RawMessage(`{"retryAfter":"250ms"}`)}, true, 250 * time.Millisecond},
+		{jsonrpc.Error{Code: 429, Data: json.RawMessage(`{"retry-after":2}`)}, true, 2 * time.Second},
+		{jsonrpc.Error{Code: 429, Data: json.RawMessage(`{"retryAfter":"soon"}`)}, false, 0},
+		{jsonrpc.Error{Code: 429, Data: json.RawMessage(`{"retryAfter":`)}, false, 0},
+	}
+
+	for _, tt := range testCases {
+		assert.Equal(t, tt.retryable, jsonrpc.IsRetryable(tt.err), "%v", tt.err)
+		after, ok := jsonrpc.RetryAfter(tt.err)
+		assert.Equal(t, tt.retryAfter, after, "%v", tt.err)
+		assert.Equal(t, tt.retryAfter > 0, ok, "%v", tt.err)
+	}
+
+	// a custom classifier
+	classifier := jsonrpc.ErrorClassifier{
+		RetryableCodes: []jsonrpc.CodeRange{{Min: 429, Max: 429}},
+		Inspect: func(e jsonrpc.Error) (jsonrpc.Classification, bool) {
+			return jsonrpc.Classification{}, e.Message == "permanent"
+		},
+	}
+	assert.True(t, classifier.IsRetryable(jsonrpc.Error{Code: 429}))
+	assert.False(t, classifier.IsRetryable(jsonrpc.Error{Code: 429, Message: "permanent"}))
+	assert.False(t, classifier.IsRetryable(jsonrpc.ErrInternal))
+}
+
+func TestTranslateServerBusy(t *testing.T) {
+	testCases := []struct {
+		e    jsonrpc.Error
+		kind error
+	}{
+		{jsonrpc.Error{Code: -32005, Message: "Too Many Requests"}, jsonrpc.ErrRateLimited},
+		{jsonrpc.Error{Code: -32000, Message: "daily request limit exceeded"}, jsonrpc.ErrRateLimited},
+		{jsonrpc.Error{Code: -32099, Message: "server is overloaded"}, jsonrpc.ErrServerBusy},
+		{jsonrpc.Error{Code: -32000, Message: "execution reverted"}, nil},
+		// outside of the server error range
+		{jsonrpc.Error{Code: 429, Message: "too many requests"}, nil},
+	}
+	for _, tt := range testCases {
+		err := jsonrpc.TranslateServerBusy(&tt.e)
+		if tt.kind == nil {
+			assert.Nil(t, err, tt.e.Message)
+			continue
+		}
+		assert.ErrorIs(t, err, tt.kind, tt.e.Message)
+		assert.True(t, jsonrpc.IsRetryable(err))
+
+		var e jsonrpc.Error
+		assert.ErrorAs(t, err, &e)
+		assert.Equal(t, tt.e, e)
+	}
+
+	// clients translate error responses
+	server := jsonrpc.NewServer()
+	server.Register("limited", func(ctx context.Context, req jsonrpc.Request) (any, error) {
+		return nil, jsonrpc.Error{Code: -32005, Message: "rate limit exceeded", Data: json.RawMessage(`{"retryAfter":"1s"}`)}
+	})
+	client := jsonrpc.NewClient(serverDialer(server, nil))
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	var resp jsonrpc.Response
+	assert.Nil(t, client.Send(*newRequest("limited", nil), &resp))
+	err := resp.UnmarshalResult(nil)
+	assert.ErrorIs(t, err, jsonrpc.ErrRateLimited)
+	after, ok := jsonrpc.RetryAfter(err)
+	assert.True(t, ok)
+	assert.Equal(t, time.Second, after)
+
+	// translation can be disabled
+	client = jsonrpc.NewClient(serverDialer(server, nil), jsonrpc.WithErrorClassifier(jsonrpc.ErrorClassifier{}))
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	assert.Nil(t, client.Send(*newRequest("limited", nil), &resp))
+	assert.NotErrorIs(t, resp.UnmarshalResult(nil), jsonrpc.ErrRateLimited)
+}
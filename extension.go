@@ -0,0 +1,98 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+
+	"github.com/juju/errors"
+)
+
+// ErrReservedExtension is returned when attempting to set an extension using a standard field name.
+var ErrReservedExtension = errors.ConstError("extension key is reserved")
+
+var (
+	requestFields  = []string{"id", "method", "params", "jsonrpc"}
+	responseFields = []string{"id", "method", "params", "result", "error", "jsonrpc"}
+)
+
+// setExtension marshals value and stores it under key, unless key is one of the reserved fields.
+func setExtension(extensions *map[string]json.RawMessage, reserved []string, key string, value any) error {
+	for _, field := range reserved {
+		if key == field {
+			return errors.Annotatef(ErrReservedExtension, "key %q", key)
+		}
+	}
+	bytes, err := json.Marshal(value)
+	if err != nil {
+		return errors.Annotatef(err, "failed to marshal extension %q to json", key)
+	}
+	if *extensions == nil {
+		*extensions = make(map[string]json.RawMessage)
+	}
+	(*extensions)[key] = bytes
+	return nil
+}
+
+// cloneExtensions returns a copy of extensions, so that a copy of a request or response can set
+// its own without affecting the original.
+func cloneExtensions(extensions map[string]json.RawMessage) map[string]json.RawMessage {
+	if extensions == nil {
+		return nil
+	}
+	clone := make(map[string]json.RawMessage, len(extensions))
+	for key, value := range extensions {
+		clone[key] = value
+	}
+	return clone
+}
+
+// marshalWithExtensions marshals v and appends any extensions as additional top level fields, in
+// key order.
+func marshalWithExtensions(v any, extensions map[string]json.RawMessage) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil || len(extensions) == 0 {
+		return data, err
+	}
+
+	keys := make([]string, 0, len(extensions))
+	for key := range extensions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	// drop the closing brace
+	buf.Write(data[:len(data)-1])
+	for _, key := range keys {
+		keyBytes, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		buf.Write(keyBytes)
+		buf.WriteByte(':')
+		buf.Write(extensions[key])
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// unmarshalExtensions returns the top level fields of data which are not one of the known fields,
+// or nil if there are none.
+func unmarshalExtensions(data []byte, known []string) (map[string]json.RawMessage, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for _, field := range known {
+		delete(fields, field)
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	return fields, nil
+}
@@ -0,0 +1,136 @@
+package jsonrpc
+
+import (
+	"context"
+	"sync"
+)
+
+// WithFairQueue routes writes through a queue which interleaves the requests of different tenants,
+// so that a burst from one tenant cannot starve the others. Pending requests are held in a queue
+// per tenant, see RequestTenant, and drained in weighted round robin: each turn a tenant may write
+// up to its weight in requests. Tenants without a weight have a weight of one.
+func WithFairQueue(weights map[string]int) ClientOption {
+	return func(opts *ClientOptions) {
+		opts.FairQueueWeights = weights
+		if opts.FairQueueWeights == nil {
+			opts.FairQueueWeights = make(map[string]int)
+		}
+	}
+}
+
+// queuedWrite is a message waiting to be written, onWritten is called once the write completes,
+// with the error if it failed. ctx is that of the call the message belongs to.
+type queuedWrite struct {
+	ctx       context.Context
+	data      []byte
+	onWritten func(err error)
+}
+
+type fairQueue struct {
+	write   func(ctx context.Context, data []byte, onWritten func(err error))
+	weights map[string]int
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queues map[string][]queuedWrite
+	// order holds the tenants with pending writes, in the order they will next be served
+	order  []string
+	closed bool
+}
+
+func newFairQueue(write func(ctx context.Context, data []byte, onWritten func(err error)), weights map[string]int) *fairQueue {
+	q := &fairQueue{
+		write:   write,
+		weights: weights,
+		queues:  make(map[string][]queuedWrite),
+	}
+	q.cond = sync.NewCond(&q.mu)
+	go q.drain()
+	return q
+}
+
+func (q *fairQueue) weight(tenant string) int {
+	if weight, ok := q.weights[tenant]; ok && weight > 0 {
+		return weight
+	}
+	return 1
+}
+
+// enqueue adds a write to the queue of tenant, it never blocks.
+func (q *fairQueue) enqueue(tenant string, write queuedWrite) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		write.onWritten(ErrClosed)
+		return
+	}
+
+	if len(q.queues[tenant]) == 0 {
+		q.order = append(q.order, tenant)
+	}
+	q.queues[tenant] = append(q.queues[tenant], write)
+	q.cond.Signal()
+}
+
+// close stops the queue, failing any pending writes with ErrClosed.
+func (q *fairQueue) close() {
+	q.mu.Lock()
+	pending := q.queues
+	q.closed = true
+	q.queues = nil
+	q.order = nil
+	q.cond.Broadcast()
+	q.mu.Unlock()
+
+	for _, writes := range pending {
+		for _, write := range writes {
+			write.onWritten(ErrClosed)
+		}
+	}
+}
+
+// next removes and returns the writes for the next tenant's turn, blocking until there are some.
+func (q *fairQueue) next() ([]queuedWrite, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for len(q.order) == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if q.closed {
+		return nil, false
+	}
+
+	tenant := q.order[0]
+	q.order = q.order[1:]
+
+	pending := q.queues[tenant]
+	count := q.weight(tenant)
+	if count > len(pending) {
+		count = len(pending)
+	}
+
+	turn := pending[:count:count]
+	if remaining := pending[count:]; len(remaining) > 0 {
+		q.queues[tenant] = remaining
+		// back of the line
+		q.order = append(q.order, tenant)
+	} else {
+		delete(q.queues, tenant)
+	}
+
+	return turn, true
+}
+
+func (q *fairQueue) drain() {
+	for {
+		writes, ok := q.next()
+		if !ok {
+			return
+		}
+		for _, write := range writes {
+			q.write(write.ctx, write.data, write.onWritten)
+		}
+	}
+}
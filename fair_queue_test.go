@@ -0,0 +1,84 @@
+package jsonrpc_test
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/41north/jsonrpc.go"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// gatedConnection records the method of each request written, blocking the first write until the
+// gate is opened. Reads block until the connection is closed.
+type gatedConnection struct {
+	gate    chan struct{}
+	once    sync.Once
+	closed  chan struct{}
+	mu      sync.Mutex
+	methods []string
+	written chan struct{}
+}
+
+func newGatedConnection() *gatedConnection {
+	return &gatedConnection{
+		gate:    make(chan struct{}),
+		closed:  make(chan struct{}),
+		written: make(chan struct{}, 64),
+	}
+}
+
+func (g *gatedConnection) Write(data []byte) error {
+	g.once.Do(func() { <-g.gate })
+
+	var req jsonrpc.Request
+	if err := json.Unmarshal(data, &req); err != nil {
+		return err
+	}
+
+	g.mu.Lock()
+	g.methods = append(g.methods, req.Method)
+	g.mu.Unlock()
+
+	g.written <- struct{}{}
+	return nil
+}
+
+func (g *gatedConnection) Read() ([]byte, error) {
+	<-g.closed
+	return nil, jsonrpc.ErrClosed
+}
+
+func (g *gatedConnection) Close() error {
+	close(g.closed)
+	return nil
+}
+
+func TestClient_FairQueue(t *testing.T) {
+	conn := newGatedConnection()
+	client := jsonrpc.NewClientWithConnection(conn, jsonrpc.WithFairQueue(map[string]int{"a": 2}))
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	// block the writer so that a backlog builds up
+	client.SendAsync(*newRequest("gate", nil, jsonrpc.RequestTenant("gate")))
+
+	for i := 0; i < 6; i++ {
+		client.SendAsync(*newRequest("a", nil, jsonrpc.RequestTenant("a")))
+	}
+	for i := 0; i < 3; i++ {
+		client.SendAsync(*newRequest("b", nil, jsonrpc.RequestTenant("b")))
+	}
+
+	assert.Equal(t, map[string]int{"gate": 1, "a": 6, "b": 3}, client.InFlightByTenant())
+
+	close(conn.gate)
+	for i := 0; i < 10; i++ {
+		<-conn.written
+	}
+
+	// tenant a has a weight of two, b the default of one
+	expected := []string{"gate", "a", "a", "b", "a", "a", "b", "a", "a", "b"}
+	assert.Equal(t, expected, conn.methods)
+}
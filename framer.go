@@ -0,0 +1,246 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+const (
+	// maxHeaderLineSize limits the length of a Content-Length header line.
+	maxHeaderLineSize = 1024
+	// maxHeaderLines limits the number of header lines preceding a Content-Length framed message.
+	maxHeaderLines = 32
+)
+
+// Framer delimits the messages exchanged over a byte stream. ReadFrame must return an error
+// matching ErrInvalidFrame or ErrMessageTooLarge if the stream cannot be parsed, after which the
+// connection is failed rather than attempting to resynchronise. A maxSize of zero or less means
+// messages of any size are accepted.
+type Framer interface {
+	WriteFrame(w io.Writer, data []byte) error
+	ReadFrame(r *bufio.Reader, maxSize int) ([]byte, error)
+}
+
+// NewlineFramer delimits each message with a trailing newline.
+func NewlineFramer() Framer {
+	return newlineFramer{}
+}
+
+// ContentLengthFramer prefixes each message with a Content-Length header block, as used by the
+// Language Server Protocol. Other headers are ignored.
+func ContentLengthFramer() Framer {
+	return contentLengthFramer{}
+}
+
+// JSONFramer reads each message as a single json object or array, tracking strings and nesting
+// rather than splitting on a delimiter, so that messages can span lines or share one. Messages are
+// written with a trailing newline, which makes it compatible with peers using NewlineFramer. A
+// message which is not valid json fails with ErrInvalidFrame.
+func JSONFramer() Framer {
+	return jsonFramer{}
+}
+
+// LengthPrefixFramer prefixes each message with its length as a 4 byte big endian integer.
+func LengthPrefixFramer() Framer {
+	return lengthPrefixFramer{}
+}
+
+func checkSize(size int, maxSize int) error {
+	if maxSize > 0 && size > maxSize {
+		return errors.Annotatef(ErrMessageTooLarge, "%d bytes", size)
+	}
+	return nil
+}
+
+func readFull(r *bufio.Reader, length int, maxSize int) ([]byte, error) {
+	if err := checkSize(length, maxSize); err != nil {
+		return nil, err
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+type newlineFramer struct{}
+
+func (newlineFramer) WriteFrame(w io.Writer, data []byte) error {
+	frame := make([]byte, 0, len(data)+1)
+	frame = append(frame, data...)
+	frame = append(frame, '\n')
+	_, err := w.Write(frame)
+	return err
+}
+
+func (newlineFramer) ReadFrame(r *bufio.Reader, maxSize int) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		line = append(line, chunk...)
+		if err := checkSize(len(line), maxSize); err != nil {
+			return nil, err
+		}
+		switch err {
+		case nil:
+			// strip the delimiter
+			return bytes.TrimRight(line, "\r\n"), nil
+		case bufio.ErrBufferFull:
+			continue
+		default:
+			return nil, err
+		}
+	}
+}
+
+type jsonFramer struct{}
+
+func (jsonFramer) WriteFrame(w io.Writer, data []byte) error {
+	return newlineFramer{}.WriteFrame(w, data)
+}
+
+func (jsonFramer) ReadFrame(r *bufio.Reader, maxSize int) ([]byte, error) {
+	// skip the whitespace between messages
+	var b byte
+	for {
+		var err error
+		if b, err = r.ReadByte(); err != nil {
+			return nil, err
+		}
+		if b != ' ' && b != '\t' && b != '\r' && b != '\n' {
+			break
+		}
+	}
+	if b != '{' && b != '[' {
+		return nil, errors.Annotatef(ErrInvalidFrame, "unexpected %q at the start of a message", b)
+	}
+
+	data := []byte{b}
+	depth := 1
+	inString, escaped := false, false
+	for depth > 0 {
+		b, err := r.ReadByte()
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		} else if err != nil {
+			return nil, err
+		}
+		data = append(data, b)
+		if err := checkSize(len(data), maxSize); err != nil {
+			return nil, err
+		}
+
+		switch {
+		case escaped:
+			escaped = false
+		case inString:
+			switch b {
+			case '\\':
+				escaped = true
+			case '"':
+				inString = false
+			}
+		case b == '"':
+			inString = true
+		case b == '{' || b == '[':
+			depth++
+		case b == '}' || b == ']':
+			depth--
+		}
+	}
+
+	if !json.Valid(data) {
+		return nil, errors.Annotate(ErrInvalidFrame, "malformed json")
+	}
+	return data, nil
+}
+
+type contentLengthFramer struct{}
+
+func (contentLengthFramer) WriteFrame(w io.Writer, data []byte) error {
+	var frame bytes.Buffer
+	frame.Grow(len(data) + 32)
+	frame.WriteString("Content-Length: ")
+	frame.WriteString(strconv.Itoa(len(data)))
+	frame.WriteString("\r\n\r\n")
+	frame.Write(data)
+	_, err := w.Write(frame.Bytes())
+	return err
+}
+
+func (contentLengthFramer) ReadFrame(r *bufio.Reader, maxSize int) ([]byte, error) {
+	length := -1
+	for lines := 0; ; lines++ {
+		if lines == maxHeaderLines {
+			return nil, errors.Annotate(ErrInvalidFrame, "too many headers")
+		}
+		line, err := readHeaderLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if line == "" {
+			// end of headers
+			break
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, errors.Annotatef(ErrInvalidFrame, "malformed header %q", line)
+		}
+		if strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			length, err = strconv.Atoi(strings.TrimSpace(value))
+			if err != nil || length < 0 {
+				return nil, errors.Annotatef(ErrInvalidFrame, "invalid content length %q", value)
+			}
+		}
+	}
+
+	if length < 0 {
+		return nil, errors.Annotate(ErrInvalidFrame, "missing content length")
+	}
+	return readFull(r, length, maxSize)
+}
+
+// readHeaderLine reads a line without its delimiter, failing if it exceeds maxHeaderLineSize.
+func readHeaderLine(r *bufio.Reader) (string, error) {
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		line = append(line, chunk...)
+		if len(line) > maxHeaderLineSize {
+			return "", errors.Annotate(ErrInvalidFrame, "header line too long")
+		}
+		switch err {
+		case nil:
+			return strings.TrimRight(string(line), "\r\n"), nil
+		case bufio.ErrBufferFull:
+			continue
+		default:
+			return "", err
+		}
+	}
+}
+
+type lengthPrefixFramer struct{}
+
+func (lengthPrefixFramer) WriteFrame(w io.Writer, data []byte) error {
+	frame := make([]byte, 4, 4+len(data))
+	binary.BigEndian.PutUint32(frame, uint32(len(data)))
+	frame = append(frame, data...)
+	_, err := w.Write(frame)
+	return err
+}
+
+func (lengthPrefixFramer) ReadFrame(r *bufio.Reader, maxSize int) ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	return readFull(r, int(binary.BigEndian.Uint32(header[:])), maxSize)
+}
@@ -0,0 +1,108 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"io"
+
+	"github.com/juju/errors"
+)
+
+var (
+	// gzipMagic begins every gzip stream and can never begin a json message.
+	gzipMagic = []byte{0x1f, 0x8b}
+	// base64GzipMagic is gzipMagic, along with the deflate method byte, base64 encoded.
+	base64GzipMagic = []byte("H4sI")
+)
+
+// CompressingFramer wraps framer so that messages larger than threshold bytes are gzipped, for
+// transports which cannot negotiate compression themselves. Compressed messages are recognised on
+// read by the gzip magic bytes, so uncompressed messages from a peer which does not compress are
+// read as before, and a message is sent uncompressed if gzip would not make it smaller. When
+// wrapping NewlineFramer the gzipped message is base64 encoded, so that it cannot contain the
+// delimiter, otherwise it is written as is. Both peers must use a CompressingFramer to exchange
+// compressed messages, with any threshold. The maximum message size applies both to the message as
+// read and once decompressed.
+func CompressingFramer(framer Framer, threshold int) Framer {
+	_, newline := framer.(newlineFramer)
+	return compressingFramer{framer: framer, threshold: threshold, base64: newline}
+}
+
+type compressingFramer struct {
+	framer    Framer
+	threshold int
+	base64    bool
+}
+
+func (f compressingFramer) WriteFrame(w io.Writer, data []byte) error {
+	if len(data) <= f.threshold {
+		return f.framer.WriteFrame(w, data)
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	compressed := buf.Bytes()
+	if f.base64 {
+		encoded := make([]byte, base64.StdEncoding.EncodedLen(len(compressed)))
+		base64.StdEncoding.Encode(encoded, compressed)
+		compressed = encoded
+	}
+	if len(compressed) >= len(data) {
+		// incompressible, so not worth the cost of decompressing
+		return f.framer.WriteFrame(w, data)
+	}
+	return f.framer.WriteFrame(w, compressed)
+}
+
+func (f compressingFramer) ReadFrame(r *bufio.Reader, maxSize int) ([]byte, error) {
+	data, err := f.framer.ReadFrame(r, maxSize)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(data, gzipMagic):
+		return decompress(data, maxSize)
+	case bytes.HasPrefix(data, base64GzipMagic):
+		compressed := make([]byte, base64.StdEncoding.DecodedLen(len(data)))
+		n, err := base64.StdEncoding.Decode(compressed, data)
+		if err != nil {
+			return nil, errors.Annotate(ErrInvalidFrame, "malformed base64 compressed message")
+		}
+		return decompress(compressed[:n], maxSize)
+	default:
+		return data, nil
+	}
+}
+
+// decompress gunzips data, failing with ErrMessageTooLarge if the result exceeds maxSize.
+func decompress(data []byte, maxSize int) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.Annotatef(ErrInvalidFrame, "malformed compressed message: %s", err)
+	}
+	defer zr.Close()
+
+	var src io.Reader = zr
+	if maxSize > 0 {
+		// read one byte more than allowed to detect messages which are too large
+		src = io.LimitReader(zr, int64(maxSize)+1)
+	}
+	result, err := io.ReadAll(src)
+	if err != nil {
+		return nil, errors.Annotatef(ErrInvalidFrame, "malformed compressed message: %s", err)
+	}
+	if err := checkSize(len(result), maxSize); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,143 @@
+package jsonrpc_test
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/41north/jsonrpc.go"
+
+	"github.com/juju/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// compressionCorpus holds messages of differing compressibility.
+func compressionCorpus() map[string][]byte {
+	random := make([]byte, 4096)
+	rand.New(rand.NewSource(1)).Read(random)
+
+	var rows []map[string]any
+	for i := 0; i < 200; i++ {
+		rows = append(rows, map[string]any{"id": i, "status": "active", "balance": "0x0"})
+	}
+	repetitive, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "result": rows})
+
+	return map[string][]byte{
+		"small":          []byte(`{"jsonrpc":"2.0","id":1,"method":"ping"}`),
+		"incompressible": []byte(fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"result":%q}`, random)),
+		"random hex":     []byte(fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"result":"%s"}`, hex.EncodeToString(random))),
+		"compressible":   repetitive,
+		"whitespace":     []byte(`{"jsonrpc":"2.0","id":1,"result":"` + strings.Repeat(" ", 8192) + `"}`),
+	}
+}
+
+func TestCompressingFramer(t *testing.T) {
+	for _, tc := range framers {
+		framer := jsonrpc.CompressingFramer(tc.framer, 256)
+		for name, data := range compressionCorpus() {
+			t.Run(tc.name+"/"+name, func(t *testing.T) {
+				var plain, frame bytes.Buffer
+				assert.Nil(t, tc.framer.WriteFrame(&plain, data))
+				assert.Nil(t, framer.WriteFrame(&frame, data))
+
+				// compression never makes a frame larger
+				assert.LessOrEqual(t, frame.Len(), plain.Len())
+				if len(data) <= 256 {
+					assert.Equal(t, plain.Bytes(), frame.Bytes())
+				}
+
+				read, err := framer.ReadFrame(bufio.NewReader(&frame), jsonrpc.DefaultMaxMessageSize)
+				assert.Nil(t, err)
+				assert.Equal(t, data, read)
+			})
+		}
+	}
+}
+
+func TestCompressingFramer_Ratio(t *testing.T) {
+	corpus := compressionCorpus()
+	for _, tc := range framers {
+		framer := jsonrpc.CompressingFramer(tc.framer, 0)
+
+		var frame bytes.Buffer
+		assert.Nil(t, framer.WriteFrame(&frame, corpus["compressible"]))
+		assert.Less(t, frame.Len(), len(corpus["compressible"])/4, tc.name)
+
+		if tc.name == "Newline" {
+			// the compressed message is base64 encoded so that it cannot contain the delimiter
+			assert.Equal(t, 1, bytes.Count(frame.Bytes(), []byte("\n")))
+		}
+
+		// a framer which does not compress reads the compressed message as is
+		frame.Reset()
+		assert.Nil(t, framer.WriteFrame(&frame, corpus["compressible"]))
+		raw, err := tc.framer.ReadFrame(bufio.NewReader(&frame), 0)
+		assert.Nil(t, err)
+		assert.NotEqual(t, corpus["compressible"], raw)
+	}
+}
+
+func TestCompressingFramer_MaxSize(t *testing.T) {
+	framer := jsonrpc.CompressingFramer(jsonrpc.LengthPrefixFramer(), 0)
+
+	// the compressed message is well under the limit, but not once decompressed
+	var frame bytes.Buffer
+	data := []byte(`{"result":"` + strings.Repeat("a", 1<<20) + `"}`)
+	assert.Nil(t, framer.WriteFrame(&frame, data))
+	assert.Less(t, frame.Len(), 4096)
+
+	_, err := framer.ReadFrame(bufio.NewReader(&frame), 4096)
+	assert.True(t, errors.Is(err, jsonrpc.ErrMessageTooLarge))
+}
+
+func TestCompressingFramer_Malformed(t *testing.T) {
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	_, _ = zw.Write([]byte(`{"id":1,"result":"hello","jsonrpc":"2.0"}`))
+	_ = zw.Close()
+	truncated := compressed.Bytes()[:compressed.Len()/2]
+
+	framer := jsonrpc.CompressingFramer(jsonrpc.LengthPrefixFramer(), 0)
+	var frame bytes.Buffer
+	assert.Nil(t, jsonrpc.LengthPrefixFramer().WriteFrame(&frame, truncated))
+	_, err := framer.ReadFrame(bufio.NewReader(&frame), 0)
+	assert.True(t, errors.Is(err, jsonrpc.ErrInvalidFrame))
+
+	framer = jsonrpc.CompressingFramer(jsonrpc.NewlineFramer(), 0)
+	frame.Reset()
+	frame.WriteString("H4sI!!!!\n")
+	_, err = framer.ReadFrame(bufio.NewReader(&frame), 0)
+	assert.True(t, errors.Is(err, jsonrpc.ErrInvalidFrame))
+}
+
+func TestCompressingFramer_ClientServer(t *testing.T) {
+	server := jsonrpc.NewServer()
+	server.Register("echo", echo)
+
+	framer := jsonrpc.StreamFramer(jsonrpc.CompressingFramer(jsonrpc.LengthPrefixFramer(), 128))
+	dialer := jsonrpc.DialFunc(func() (jsonrpc.Connection, error) {
+		clientConn, serverConn := net.Pipe()
+		go server.Serve(context.Background(), jsonrpc.NewFramedConnection(serverConn, framer))
+		return jsonrpc.NewFramedConnection(clientConn, framer), nil
+	})
+
+	client := jsonrpc.NewClient(dialer)
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	for _, msg := range []string{"hello", strings.Repeat("hello", 1000)} {
+		var resp jsonrpc.Response
+		assert.Nil(t, client.Send(*newRequest("echo", []string{msg}), &resp))
+		var result []string
+		assert.Nil(t, resp.UnmarshalResult(&result))
+		assert.Equal(t, []string{msg}, result)
+	}
+}
@@ -0,0 +1,194 @@
+package jsonrpc_test
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/41north/jsonrpc.go"
+
+	"github.com/juju/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+var framers = []struct {
+	name   string
+	framer jsonrpc.Framer
+}{
+	{"Newline", jsonrpc.NewlineFramer()},
+	{"ContentLength", jsonrpc.ContentLengthFramer()},
+	{"LengthPrefix", jsonrpc.LengthPrefixFramer()},
+}
+
+const fuzzMaxSize = 1024
+
+func addFrameSeeds(f *testing.F) {
+	for _, tc := range framers {
+		var frame bytes.Buffer
+		_ = tc.framer.WriteFrame(&frame, []byte(`{"id":1,"result":"hello","jsonrpc":"2.0"}`))
+		f.Add(frame.Bytes())
+		// truncated
+		f.Add(frame.Bytes()[:frame.Len()/2])
+		// corrupted
+		corrupted := append([]byte(nil), frame.Bytes()...)
+		corrupted[1] ^= 0xff
+		f.Add(corrupted)
+	}
+	f.Add([]byte("Content-Length: -1\r\n\r\n"))
+	f.Add([]byte("Content-Length: 99999999\r\n\r\n"))
+	f.Add([]byte("Content-Type: json\r\n\r\n{}"))
+	f.Add([]byte(strings.Repeat("X-Header: a\r\n", 64)))
+	f.Add([]byte{0xff, 0xff, 0xff, 0xff})
+}
+
+// FuzzFramer_ReadFrame checks that arbitrary input never causes a framer to panic, loop or return
+// a message larger than the limit.
+func FuzzFramer_ReadFrame(f *testing.F) {
+	addFrameSeeds(f)
+	f.Fuzz(func(t *testing.T, input []byte) {
+		for _, tc := range framers {
+			r := bufio.NewReader(bytes.NewReader(input))
+			for {
+				data, err := tc.framer.ReadFrame(r, fuzzMaxSize)
+				if err != nil {
+					break
+				}
+				if len(data) > fuzzMaxSize {
+					t.Fatalf("%s: read %d bytes, limit is %d", tc.name, len(data), fuzzMaxSize)
+				}
+			}
+		}
+	})
+}
+
+// FuzzFramer_RoundTrip checks that every framer reads back exactly what it wrote.
+func FuzzFramer_RoundTrip(f *testing.F) {
+	f.Add([]byte(`{"id":1,"method":"ping","jsonrpc":"2.0"}`))
+	f.Add([]byte{})
+	f.Add([]byte("Content-Length: 4\r\n\r\n"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, tc := range framers {
+			if tc.name == "Newline" && bytes.ContainsAny(data, "\r\n") {
+				// the newline framer cannot carry the delimiter within a message
+				continue
+			}
+			var frame bytes.Buffer
+			if err := tc.framer.WriteFrame(&frame, data); err != nil {
+				t.Fatal(err)
+			}
+			read, err := tc.framer.ReadFrame(bufio.NewReader(&frame), 0)
+			if err != nil {
+				t.Fatalf("%s: %v", tc.name, err)
+			}
+			if !bytes.Equal(data, read) {
+				t.Fatalf("%s: wrote %q, read %q", tc.name, data, read)
+			}
+		}
+	})
+}
+
+func TestJSONFramer_EmbeddedNewlines(t *testing.T) {
+	pretty := "{\n  \"id\": 1,\n  \"result\": \"line one\\nline two } ]\",\n  \"jsonrpc\": \"2.0\"\n}"
+	input := pretty + `{"id":2,"result":[{"a":"\"}"}],"jsonrpc":"2.0"}` + "\n\n  [{\"id\":3,\"result\":null}]\n"
+
+	r := bufio.NewReader(strings.NewReader(input))
+	var messages []string
+	for {
+		data, err := jsonrpc.JSONFramer().ReadFrame(r, 0)
+		if err != nil {
+			assert.Equal(t, io.EOF, err)
+			break
+		}
+		messages = append(messages, string(data))
+	}
+	assert.Equal(t, []string{
+		pretty,
+		`{"id":2,"result":[{"a":"\"}"}],"jsonrpc":"2.0"}`,
+		`[{"id":3,"result":null}]`,
+	}, messages)
+
+	// a raw newline within a string is not valid json
+	_, err := jsonrpc.JSONFramer().ReadFrame(bufio.NewReader(strings.NewReader("{\"result\":\"a\nb\"}")), 0)
+	assert.True(t, errors.Is(err, jsonrpc.ErrInvalidFrame))
+}
+
+func TestClient_FramingCorrupted(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	client := jsonrpc.NewClientWithConnection(jsonrpc.NewStreamConnection(clientConn, jsonrpc.FramingJSON))
+
+	closed := make(chan error, 1)
+	client.SetCloseHandler(func(err error) { closed <- err })
+	assert.Nil(t, client.Connect())
+
+	server := jsonrpc.NewStreamConnection(serverConn, jsonrpc.FramingJSON)
+	received := make(chan error, 1)
+	go func() {
+		_, err := server.Read()
+		received <- err
+	}()
+	future := client.SendAsync(*newRequest("echo", nil, jsonrpc.RequestNumericId(1)))
+	assert.Nil(t, <-received)
+
+	// a message spanning lines is read whole
+	assert.Nil(t, server.Write([]byte("{\n\"id\": 1,\n\"result\": \"a\\nb\",\n\"jsonrpc\": \"2.0\"\n}")))
+	resp, err := (<-future.Get()).Unwrap()
+	assert.Nil(t, err)
+	assert.Equal(t, `"a\nb"`, string(resp.Result))
+
+	// whereas a corrupt stream closes the client with ErrFraming
+	go func() {
+		_ = server.Write([]byte(`"id":2}`))
+	}()
+	err = <-closed
+	assert.ErrorIs(t, err, jsonrpc.ErrFraming)
+	assert.ErrorIs(t, err, jsonrpc.ErrInvalidFrame)
+}
+
+func TestFramedConnection_MalformedHeader(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	conn := jsonrpc.NewFramedConnection(clientConn, jsonrpc.StreamFramer(jsonrpc.ContentLengthFramer()))
+
+	go func() {
+		_, _ = serverConn.Write([]byte("garbage\r\n\r\n"))
+	}()
+
+	_, err := conn.Read()
+	assert.True(t, errors.Is(err, jsonrpc.ErrInvalidFrame))
+	assert.True(t, errors.Is(err, jsonrpc.ErrFraming))
+
+	// the connection is failed rather than attempting to read on from an unknown position
+	_, err = conn.Read()
+	assert.Equal(t, jsonrpc.ErrClosed, err)
+
+	_, err = serverConn.Write([]byte("{}"))
+	assert.Error(t, err)
+}
+
+func TestNetDialer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer listener.Close()
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		serveStream(jsonrpc.NewFramedConnection(conn, jsonrpc.StreamFramer(jsonrpc.LengthPrefixFramer())))
+	}()
+
+	dialer := jsonrpc.NewNetDialer("tcp", listener.Addr().String(), jsonrpc.StreamFramer(jsonrpc.LengthPrefixFramer()))
+	client := jsonrpc.NewClient(dialer)
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	var resp jsonrpc.Response
+	assert.Nil(t, client.Send(*newRequest("echo", []string{"hello"}), &resp))
+
+	var result []string
+	assert.Nil(t, resp.UnmarshalResult(&result))
+	assert.Equal(t, []string{"hello"}, result)
+}
@@ -0,0 +1,162 @@
+package jsonrpc
+
+import (
+	"context"
+
+	"github.com/41north/async.go"
+)
+
+// indexedResult is the result of one of a group of futures, along with its position in the group.
+type indexedResult struct {
+	index  int
+	result async.Result[*Response]
+}
+
+// fanIn delivers the results of futures as they complete. Results which are not received before
+// ctx is done are discarded.
+func fanIn(ctx context.Context, futures []ResponseFuture) <-chan indexedResult {
+	ch := make(chan indexedResult, len(futures))
+	for i, future := range futures {
+		go func(i int, future ResponseFuture) {
+			select {
+			case result := <-future.Get():
+				ch <- indexedResult{index: i, result: result}
+			case <-ctx.Done():
+			}
+		}(i, future)
+	}
+	return ch
+}
+
+// WaitAll waits for every future to resolve, returning their responses in the same order. The
+// first error to occur is returned as soon as it does, as is ctx.Err() if ctx is done first.
+// Responses containing an error from the server are not treated as errors.
+//
+// Cancelling ctx only stops waiting, the requests themselves are not cancelled.
+func WaitAll(ctx context.Context, futures []ResponseFuture) ([]*Response, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	responses := make([]*Response, len(futures))
+	results := fanIn(ctx, futures)
+	for range futures {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case r := <-results:
+			resp, err := r.result.Unwrap()
+			if err != nil {
+				return nil, err
+			}
+			responses[r.index] = resp
+		}
+	}
+	return responses, nil
+}
+
+// WaitAny waits for the first of futures to resolve, returning its index along with its response
+// or error. If ctx is done first, or there are no futures, an index of -1 is returned.
+//
+// Cancelling ctx only stops waiting, the requests themselves are not cancelled.
+func WaitAny(ctx context.Context, futures []ResponseFuture) (int, *Response, error) {
+	if len(futures) == 0 {
+		return -1, nil, nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	select {
+	case <-ctx.Done():
+		return -1, nil, ctx.Err()
+	case r := <-fanIn(ctx, futures):
+		resp, err := r.result.Unwrap()
+		return r.index, resp, err
+	}
+}
+
+// CollectTyped waits for every future to resolve, as with WaitAll, and unmarshals each result into
+// a T. Error responses from the server are returned as errors.
+//
+// Cancelling ctx only stops waiting, the requests themselves are not cancelled.
+func CollectTyped[T any](ctx context.Context, futures []ResponseFuture) ([]T, error) {
+	responses, err := WaitAll(ctx, futures)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]T, len(responses))
+	for i, resp := range responses {
+		if err := resp.UnmarshalResult(&results[i]); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// Then returns a future resolved with the response of future transformed by fn, or with the error
+// of future, in which case fn is not called. A future which resolves with an error response is
+// passed to fn like any other. fn runs on a goroutine of its own once future resolves.
+//
+// If future is a CancelableFuture so is the returned future, cancelling it cancels the request.
+func Then(future ResponseFuture, fn func(resp Response) (Response, error)) ResponseFuture {
+	then := async.NewFuture[async.Result[*Response]]()
+	OnComplete(future, func(resp *Response, err error) {
+		if err == nil {
+			var r Response
+			if r, err = fn(*resp); err == nil {
+				resp = &r
+			}
+		}
+		if err != nil {
+			then.Set(async.NewResultErr[*Response](err))
+			return
+		}
+		then.Set(async.NewResultValue[*Response](resp))
+	})
+
+	if cancelable, ok := future.(CancelableFuture); ok {
+		return &chainedFuture{ResponseFuture: then, source: cancelable}
+	}
+	return then
+}
+
+// chainedFuture is a future derived from a CancelableFuture, which cancels the request of its
+// source.
+type chainedFuture struct {
+	ResponseFuture
+	source CancelableFuture
+}
+
+func (f *chainedFuture) Cancel() bool {
+	return f.source.Cancel()
+}
+
+// MapResult returns a future resolved with the result of future unmarshalled into a T. Error
+// responses from the server are returned as errors, as with Response.UnmarshalResult.
+func MapResult[T any](future ResponseFuture) async.Future[async.Result[T]] {
+	mapped := async.NewFuture[async.Result[T]]()
+	OnComplete(future, func(resp *Response, err error) {
+		var result T
+		if err == nil {
+			err = resp.UnmarshalResult(&result)
+		}
+		if err != nil {
+			mapped.Set(async.NewResultErr[T](err))
+			return
+		}
+		mapped.Set(async.NewResultValue[T](result))
+	})
+	return mapped
+}
+
+// OnComplete calls callback exactly once with the response or error of future, once it resolves,
+// whether it already has or not. Futures which fail because their request was cancelled or the
+// client closed call it with that error.
+//
+// callback runs on a goroutine of its own, never on the goroutine which resolves future, such as the
+// read loop of the client, so it may block without delaying other requests.
+func OnComplete(future ResponseFuture, callback func(resp *Response, err error)) {
+	go func() {
+		callback((<-future.Get()).Unwrap())
+	}()
+}
@@ -0,0 +1,158 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/41north/async.go"
+	"github.com/41north/jsonrpc.go"
+
+	"github.com/juju/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func newFutures(n int) []jsonrpc.ResponseFuture {
+	futures := make([]jsonrpc.ResponseFuture, n)
+	for i := range futures {
+		futures[i] = async.NewFuture[async.Result[*jsonrpc.Response]]()
+	}
+	return futures
+}
+
+func TestWaitAll(t *testing.T) {
+	futures := newFutures(3)
+
+	// resolve out of order
+	for _, i := range []int{2, 0, 1} {
+		futures[i].Set(async.NewResultValue(newResponse(i, jsonrpc.ResponseNumericId(i))))
+	}
+
+	responses, err := jsonrpc.WaitAll(context.Background(), futures)
+	assert.Nil(t, err)
+	for i, resp := range responses {
+		var result int
+		assert.Nil(t, resp.UnmarshalResult(&result))
+		assert.Equal(t, i, result)
+	}
+
+	results, err := jsonrpc.CollectTyped[int](context.Background(), futures)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{0, 1, 2}, results)
+
+	// the first error is returned without waiting for the rest
+	futures = newFutures(3)
+	futures[2].Set(async.NewResultErr[*jsonrpc.Response](errors.New("boom")))
+	_, err = jsonrpc.WaitAll(context.Background(), futures)
+	assert.EqualError(t, err, "boom")
+
+	// cancellation stops waiting
+	ctx, cancel := context.WithDeadline(context.Background(), time.Time{})
+	defer cancel()
+	_, err = jsonrpc.WaitAll(ctx, newFutures(2))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestWaitAny(t *testing.T) {
+	futures := newFutures(3)
+	futures[1].Set(async.NewResultValue(newResponse("pong", jsonrpc.ResponseNumericId(1))))
+
+	index, resp, err := jsonrpc.WaitAny(context.Background(), futures)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, index)
+	assert.Equal(t, "1", string(resp.Id))
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Time{})
+	defer cancel()
+	index, _, err = jsonrpc.WaitAny(ctx, newFutures(2))
+	assert.Equal(t, -1, index)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+// completion records the result passed to an OnComplete callback.
+type completion struct {
+	resp *jsonrpc.Response
+	err  error
+}
+
+func onComplete(future jsonrpc.ResponseFuture) <-chan completion {
+	ch := make(chan completion, 2)
+	jsonrpc.OnComplete(future, func(resp *jsonrpc.Response, err error) {
+		ch <- completion{resp, err}
+	})
+	return ch
+}
+
+func TestOnComplete(t *testing.T) {
+	futures := newFutures(2)
+
+	// registered before resolution
+	before := onComplete(futures[0])
+	futures[0].Set(async.NewResultValue(newResponse("pong", jsonrpc.ResponseNumericId(1))))
+	c := <-before
+	assert.Nil(t, c.err)
+	assert.Equal(t, `"pong"`, string(c.resp.Result))
+
+	// and after
+	futures[1].Set(async.NewResultErr[*jsonrpc.Response](errors.New("boom")))
+	c = <-onComplete(futures[1])
+	assert.EqualError(t, c.err, "boom")
+
+	// each callback runs exactly once, as a future resolves only once
+	assert.False(t, futures[0].Set(async.NewResultErr[*jsonrpc.Response](errors.New("boom"))))
+	assert.Len(t, before, 0)
+}
+
+func TestThen(t *testing.T) {
+	futures := newFutures(3)
+	double := func(resp jsonrpc.Response) (jsonrpc.Response, error) {
+		var n int
+		if err := resp.UnmarshalResult(&n); err != nil {
+			return resp, err
+		}
+		return *newResponse(n*2, jsonrpc.ResponseNumericId(1)), nil
+	}
+
+	doubled := jsonrpc.Then(futures[0], double)
+	futures[0].Set(async.NewResultValue(newResponse(21, jsonrpc.ResponseNumericId(1))))
+	n, err := (<-jsonrpc.MapResult[int](doubled).Get()).Unwrap()
+	assert.Nil(t, err)
+	assert.Equal(t, 42, n)
+
+	// errors pass through without calling fn
+	futures[1].Set(async.NewResultErr[*jsonrpc.Response](errors.New("boom")))
+	_, err = (<-jsonrpc.Then(futures[1], double).Get()).Unwrap()
+	assert.EqualError(t, err, "boom")
+
+	// as do errors returned by fn, and results which cannot be unmarshalled
+	futures[2].Set(async.NewResultValue(newResponse("twenty one", jsonrpc.ResponseNumericId(1))))
+	_, err = (<-jsonrpc.Then(futures[2], double).Get()).Unwrap()
+	assert.NotNil(t, err)
+	_, err = (<-jsonrpc.MapResult[int](futures[2]).Get()).Unwrap()
+	assert.NotNil(t, err)
+}
+
+func TestThen_CancelAndClose(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	client := jsonrpc.NewClient(serverDialer(endpoint("a", release), nil))
+	assert.Nil(t, client.Connect())
+
+	identity := func(resp jsonrpc.Response) (jsonrpc.Response, error) {
+		return resp, nil
+	}
+
+	// cancelling a chained future cancels the request
+	future, err := client.SendCancelable(*newRequest("wait", nil))
+	assert.Nil(t, err)
+	chained := jsonrpc.Then(future, identity)
+	completed := onComplete(chained)
+	assert.True(t, chained.(jsonrpc.CancelableFuture).Cancel())
+	assert.ErrorIs(t, (<-completed).err, context.Canceled)
+
+	// closing the client fails the callbacks of requests in flight
+	completed = onComplete(jsonrpc.Then(client.SendAsync(*newRequest("wait", nil)), identity))
+	assert.Nil(t, client.Close())
+	assert.ErrorIs(t, (<-completed).err, jsonrpc.ErrClosed)
+}
@@ -0,0 +1,432 @@
+package jsonrpc
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// WithCallClientOptions sets the options of the client which sends calls.
+func WithCallClientOptions(options ...ClientOption) HybridClientOption {
+	return func(opts *HybridClientOptions) {
+		opts.CallOptions = options
+	}
+}
+
+// WithStreamClientOptions sets the options of the client which carries subscriptions.
+func WithStreamClientOptions(options ...ClientOption) HybridClientOption {
+	return func(opts *HybridClientOptions) {
+		opts.StreamOptions = options
+	}
+}
+
+// WithKeepStreamOpen keeps the stream connection open once the last subscription has ended, rather
+// than closing it until the next subscription.
+func WithKeepStreamOpen(keep bool) HybridClientOption {
+	return func(opts *HybridClientOptions) {
+		opts.KeepStreamOpen = keep
+	}
+}
+
+type HybridClientOption = func(opts *HybridClientOptions)
+
+type HybridClientOptions struct {
+	CallOptions    []ClientOption
+	StreamOptions  []ClientOption
+	KeepStreamOpen bool
+}
+
+func DefaultHybridClientOptions() HybridClientOptions {
+	return HybridClientOptions{}
+}
+
+// HybridState reports the state of the two transports of a HybridClient.
+type HybridState struct {
+	// CallsConnected is true while the client which sends calls is connected.
+	CallsConnected bool
+	// StreamConnected is true while the client which carries subscriptions is connected. It is
+	// only dialled once there is a subscription.
+	StreamConnected bool
+	// Subscriptions is the number of active subscriptions.
+	Subscriptions int
+}
+
+// HybridClient is a Client which sends calls over one transport, typically HTTP, and carries
+// subscriptions over another, typically a websocket. The stream is dialled by the first
+// subscription and, unless WithKeepStreamOpen is set, closed once the last one ends.
+type HybridClient struct {
+	opts HybridClientOptions
+	log  *log.Entry
+
+	calls        Client
+	streamDialer Dialer
+
+	mu               sync.Mutex
+	stream           Client
+	subscriptions    int
+	closed           bool
+	closeHandler     CloseHandler
+	requestHandler   ContextRequestHandler
+	unmatchedHandler UnmatchedHandler
+	unsolicited      UnsolicitedErrorHandler
+	routes           sharedRoutes
+}
+
+var _ Client = (*HybridClient)(nil)
+
+// NewHybridClient creates a client which sends calls with a connection from callDialer, and
+// subscribes with a connection from streamDialer.
+func NewHybridClient(callDialer Dialer, streamDialer Dialer, options ...HybridClientOption) *HybridClient {
+	opts := DefaultHybridClientOptions()
+	for _, opt := range options {
+		opt(&opts)
+	}
+	return &HybridClient{
+		opts:         opts,
+		log:          log.WithField("component", "hybridClient"),
+		calls:        NewClient(callDialer, opts.CallOptions...),
+		streamDialer: streamDialer,
+	}
+}
+
+// Connect connects the client which sends calls. The stream is dialled by the first subscription.
+func (h *HybridClient) Connect() error {
+	h.mu.Lock()
+	closed := h.closed
+	h.mu.Unlock()
+	if closed {
+		return ErrClosed
+	}
+	return h.calls.Connect()
+}
+
+// State returns the state of each transport.
+func (h *HybridClient) State() HybridState {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return HybridState{
+		CallsConnected:  isConnected(h.calls),
+		StreamConnected: h.stream != nil && isConnected(h.stream),
+		Subscriptions:   h.subscriptions,
+	}
+}
+
+func isConnected(c Client) bool {
+	cl, ok := c.(*client)
+	return ok && cl.connected.Load() && !cl.closed.Load()
+}
+
+func (h *HybridClient) Send(req Request, resp *Response) error {
+	return h.calls.Send(req, resp)
+}
+
+func (h *HybridClient) SendContext(ctx context.Context, req Request, resp *Response) error {
+	return h.calls.SendContext(ctx, req, resp)
+}
+
+func (h *HybridClient) SendRequest(ctx context.Context, req *Request, resp *Response) error {
+	return h.calls.SendRequest(ctx, req, resp)
+}
+
+func (h *HybridClient) SendAsync(req Request) ResponseFuture {
+	return h.calls.SendAsync(req)
+}
+
+func (h *HybridClient) SendCancelable(req Request) (CancelableFuture, error) {
+	return h.calls.SendCancelable(req)
+}
+
+func (h *HybridClient) SendWithPriority(req Request, priority Priority) ResponseFuture {
+	return h.calls.SendWithPriority(req, priority)
+}
+
+func (h *HybridClient) SendBatch(ctx context.Context, batch BatchRequest) []ResponseFuture {
+	return h.calls.SendBatch(ctx, batch)
+}
+
+func (h *HybridClient) SendBatchContext(ctx context.Context, batch BatchRequest) ([]*Response, error) {
+	return h.calls.SendBatchContext(ctx, batch)
+}
+
+func (h *HybridClient) NotifyBatch(reqs []Request) error {
+	return h.calls.NotifyBatch(reqs)
+}
+
+// Subscribe routes notifications for method arriving over the stream to the subscription, dialling
+// the stream if needed.
+func (h *HybridClient) Subscribe(method string) (*Subscription, error) {
+	_, sub, err := h.subscribe(func(stream Client) (*Subscription, error) {
+		return stream.Subscribe(method)
+	})
+	return sub, err
+}
+
+// SubscribePattern routes notifications matching pattern arriving over the stream to the
+// subscription, dialling the stream if needed.
+func (h *HybridClient) SubscribePattern(pattern string) (*Subscription, error) {
+	if err := validatePattern(pattern); err != nil {
+		return nil, err
+	}
+	_, sub, err := h.subscribe(func(stream Client) (*Subscription, error) {
+		return stream.SubscribePattern(pattern)
+	})
+	return sub, err
+}
+
+func (h *HybridClient) UnsubscribePattern(pattern string) {
+	h.mu.Lock()
+	stream := h.stream
+	h.mu.Unlock()
+	if stream != nil {
+		stream.UnsubscribePattern(pattern)
+	}
+}
+
+// SubscribeWith subscribes to method, as Subscribe does, then sends req over the stream, for
+// servers such as Ethereum nodes whose notifications are only sent over the connection the
+// subscribing request was received on. The subscription is ended if the request fails.
+func (h *HybridClient) SubscribeWith(ctx context.Context, method string, req Request, resp *Response) (*Subscription, error) {
+	stream, sub, err := h.subscribe(func(stream Client) (*Subscription, error) {
+		return stream.Subscribe(method)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err = stream.SendContext(ctx, req, resp); err != nil {
+		sub.Unsubscribe()
+		return nil, err
+	}
+	return sub, nil
+}
+
+// subscribe subscribes with the stream, dialling it if needed, and tracks the subscription so that
+// the stream is closed once none remain.
+func (h *HybridClient) subscribe(subscribe func(stream Client) (*Subscription, error)) (Client, *Subscription, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return nil, nil, ErrClosed
+	}
+
+	if h.stream != nil && !isConnected(h.stream) {
+		// the stream closed before its close handler was set
+		h.stream = nil
+		h.subscriptions = 0
+	}
+
+	if h.stream == nil {
+		stream := NewClient(h.streamDialer, h.opts.StreamOptions...)
+		if h.requestHandler != nil {
+			stream.SetContextRequestHandler(h.requestHandler)
+		}
+		if h.unmatchedHandler != nil {
+			stream.SetUnmatchedHandler(h.unmatchedHandler)
+		}
+		if h.unsolicited != nil {
+			stream.OnUnsolicitedError(h.unsolicited)
+		}
+		h.routes.install(stream)
+		if err := stream.Connect(); err != nil {
+			h.routes.uninstall(stream)
+			_ = stream.Close()
+			return nil, nil, err
+		}
+		// set once connected, as the handler takes the lock, which is held when closing after a
+		// failed connect
+		stream.SetCloseHandler(func(err error) {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+			h.routes.uninstall(stream)
+			if h.stream == stream {
+				h.log.WithError(err).Debug("stream closed")
+				h.stream = nil
+				h.subscriptions = 0
+			}
+		})
+		h.stream = stream
+	}
+
+	stream := h.stream
+	sub, err := subscribe(stream)
+	if err != nil {
+		return nil, nil, err
+	}
+	h.subscriptions++
+
+	go h.untrack(stream, sub)
+	return stream, sub, nil
+}
+
+// untrack waits for sub to end, closing the stream once it has no subscriptions left.
+func (h *HybridClient) untrack(stream Client, sub *Subscription) {
+	<-sub.Done()
+
+	h.mu.Lock()
+	if h.stream != stream {
+		// the stream has already closed
+		h.mu.Unlock()
+		return
+	}
+	h.subscriptions--
+	if h.subscriptions > 0 || h.opts.KeepStreamOpen || h.closed {
+		h.mu.Unlock()
+		return
+	}
+	h.stream = nil
+	h.mu.Unlock()
+
+	h.log.Debug("closing stream, no subscriptions remain")
+	_ = stream.Close()
+}
+
+func (h *HybridClient) clients() []Client {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.stream == nil {
+		return []Client{h.calls}
+	}
+	return []Client{h.calls, h.stream}
+}
+
+// InFlightByTenant returns the requests awaiting a response across both transports.
+func (h *HybridClient) InFlightByTenant() map[string]int {
+	counts := make(map[string]int)
+	for _, client := range h.clients() {
+		for tenant, n := range client.InFlightByTenant() {
+			counts[tenant] += n
+		}
+	}
+	return counts
+}
+
+// CancelByMethod cancels the requests for method awaiting a response over either transport.
+func (h *HybridClient) CancelByMethod(method string) int {
+	var cancelled int
+	for _, client := range h.clients() {
+		cancelled += client.CancelByMethod(method)
+	}
+	return cancelled
+}
+
+// RecentCalls returns the calls recently completed over both transports, oldest first.
+func (h *HybridClient) RecentCalls() []CallRecord {
+	var calls []CallRecord
+	for _, client := range h.clients() {
+		calls = append(calls, client.RecentCalls()...)
+	}
+	sort.SliceStable(calls, func(i, j int) bool {
+		return calls[i].Start.Before(calls[j].Start)
+	})
+	return calls
+}
+
+// Ping checks the transport which sends calls is alive.
+func (h *HybridClient) Ping(ctx context.Context) error {
+	return h.calls.Ping(ctx)
+}
+
+// Migrate moves the calls to a connection from dialer. The stream is unaffected.
+func (h *HybridClient) Migrate(ctx context.Context, dialer Dialer) error {
+	return h.calls.Migrate(ctx, dialer)
+}
+
+// Connection returns the connection which sends calls.
+func (h *HybridClient) Connection() Connection {
+	return h.calls.Connection()
+}
+
+// Update changes the options of both transports, including a stream dialled later, see
+// client.Update. The options must be updatable for both.
+func (h *HybridClient) Update(options ...ClientOption) error {
+	h.mu.Lock()
+	prev := h.opts.StreamOptions
+	h.opts.StreamOptions = append(append([]ClientOption(nil), prev...), options...)
+	h.mu.Unlock()
+
+	for _, client := range h.clients() {
+		if err := client.Update(options...); err != nil {
+			h.mu.Lock()
+			h.opts.StreamOptions = prev
+			h.mu.Unlock()
+			return err
+		}
+	}
+	return nil
+}
+
+// SetCloseHandler sets a handler which is called once the client is closed.
+func (h *HybridClient) SetCloseHandler(handler CloseHandler) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closeHandler = handler
+}
+
+// SetRequestHandler sets the request handler of both transports.
+func (h *HybridClient) SetRequestHandler(handler RequestHandler) {
+	h.SetContextRequestHandler(withRequestContext(handler))
+}
+
+// SetContextRequestHandler sets the request handler of both transports, see ContextRequestHandler.
+func (h *HybridClient) SetContextRequestHandler(handler ContextRequestHandler) {
+	h.mu.Lock()
+	h.requestHandler = handler
+	h.mu.Unlock()
+
+	for _, client := range h.clients() {
+		client.SetContextRequestHandler(handler)
+	}
+}
+
+// OnNotification registers handler with both transports, see Client.
+func (h *HybridClient) OnNotification(pattern string, handler NotificationHandler) (func(), error) {
+	return h.routes.add(pattern, handler, h.clients)
+}
+
+// SetUnmatchedHandler sets the unmatched handler of both transports.
+func (h *HybridClient) SetUnmatchedHandler(handler UnmatchedHandler) {
+	h.mu.Lock()
+	h.unmatchedHandler = handler
+	h.mu.Unlock()
+
+	for _, client := range h.clients() {
+		client.SetUnmatchedHandler(handler)
+	}
+}
+
+// OnUnsolicitedError sets the unsolicited error handler of both transports.
+func (h *HybridClient) OnUnsolicitedError(handler UnsolicitedErrorHandler) {
+	h.mu.Lock()
+	h.unsolicited = handler
+	h.mu.Unlock()
+
+	for _, client := range h.clients() {
+		client.OnUnsolicitedError(handler)
+	}
+}
+
+// Close closes both transports, ending every subscription.
+func (h *HybridClient) Close() error {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return ErrClosed
+	}
+	h.closed = true
+	handler := h.closeHandler
+	stream := h.stream
+	h.stream = nil
+	h.subscriptions = 0
+	h.mu.Unlock()
+
+	err := h.calls.Close()
+	if stream != nil {
+		_ = stream.Close()
+	}
+	if handler != nil {
+		handler(nil)
+	}
+	return err
+}
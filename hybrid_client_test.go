@@ -0,0 +1,121 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/41north/jsonrpc.go"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHybridClient(t *testing.T) {
+	// the server end of each stream which is dialled
+	streams := make(chan jsonrpc.Connection, 2)
+	streamDialer := jsonrpc.DialFunc(func() (jsonrpc.Connection, error) {
+		clientConn, serverConn := net.Pipe()
+		streams <- jsonrpc.NewStreamConnection(serverConn, jsonrpc.FramingNewline)
+		return jsonrpc.NewStreamConnection(clientConn, jsonrpc.FramingNewline), nil
+	})
+
+	client := jsonrpc.NewHybridClient(serverDialer(endpoint("calls", nil), nil), streamDialer)
+	defer func() { _ = client.Close() }()
+	assert.Nil(t, client.Connect())
+
+	// calls do not need the stream
+	name, err := sendName(t, client, "name")
+	assert.Nil(t, err)
+	assert.Equal(t, "calls", name)
+	assert.Equal(t, jsonrpc.HybridState{CallsConnected: true}, client.State())
+
+	// the first subscription dials the stream, and its request is sent over it
+	go func() {
+		stream := <-streams
+		data, err := stream.Read()
+		assert.Nil(t, err)
+		var req jsonrpc.Request
+		assert.Nil(t, json.Unmarshal(data, &req))
+		assert.Equal(t, "subscribe", req.Method)
+
+		resp, err := json.Marshal(jsonrpc.Response{Id: req.Id, Result: json.RawMessage(`"0x1"`), Version: "2.0"})
+		assert.Nil(t, err)
+		assert.Nil(t, stream.Write(resp))
+		assert.Nil(t, stream.Write([]byte(`{"method":"update","params":[1],"jsonrpc":"2.0"}`)))
+	}()
+
+	var resp jsonrpc.Response
+	updates, err := client.SubscribeWith(context.Background(), "update", *newRequest("subscribe", nil), &resp)
+	assert.Nil(t, err)
+	assert.Equal(t, `"0x1"`, string(resp.Result))
+	assert.Equal(t, "[1]", string((<-updates.C()).Params))
+
+	others, err := client.Subscribe("other")
+	assert.Nil(t, err)
+	assert.Equal(t, jsonrpc.HybridState{CallsConnected: true, StreamConnected: true, Subscriptions: 2}, client.State())
+
+	// the stream is closed once the last subscription ends
+	updates.Unsubscribe()
+	assert.Eventually(t, func() bool {
+		return client.State().Subscriptions == 1
+	}, time.Second, time.Millisecond)
+	assert.True(t, client.State().StreamConnected)
+
+	others.Unsubscribe()
+	assert.Eventually(t, func() bool {
+		return client.State() == jsonrpc.HybridState{CallsConnected: true}
+	}, time.Second, time.Millisecond)
+
+	// and dialled again by the next, which ends when the client closes
+	sub, err := client.Subscribe("update")
+	assert.Nil(t, err)
+	assert.Len(t, streams, 1)
+	assert.True(t, client.State().StreamConnected)
+
+	assert.Nil(t, client.Close())
+	_, ok := <-sub.C()
+	assert.False(t, ok)
+	assert.Equal(t, jsonrpc.HybridState{}, client.State())
+
+	_, err = client.Subscribe("update")
+	assert.ErrorIs(t, err, jsonrpc.ErrClosed)
+}
+
+func TestHybridClient_KeepStreamOpen(t *testing.T) {
+	client := jsonrpc.NewHybridClient(
+		serverDialer(endpoint("calls", nil), nil),
+		serverDialer(endpoint("stream", nil), nil),
+		jsonrpc.WithKeepStreamOpen(true),
+	)
+	defer func() { _ = client.Close() }()
+	assert.Nil(t, client.Connect())
+
+	sub, err := client.Subscribe("update")
+	assert.Nil(t, err)
+	sub.Unsubscribe()
+
+	assert.Eventually(t, func() bool {
+		return client.State().Subscriptions == 0
+	}, time.Second, time.Millisecond)
+	assert.True(t, client.State().StreamConnected)
+}
+
+func TestHybridClient_StreamDialFails(t *testing.T) {
+	client := jsonrpc.NewHybridClient(
+		serverDialer(endpoint("calls", nil), nil),
+		serverDialer(endpoint("stream", nil), func(n int32) bool { return n == 1 }),
+	)
+	defer func() { _ = client.Close() }()
+	assert.Nil(t, client.Connect())
+
+	_, err := client.Subscribe("update")
+	assert.NotNil(t, err)
+	assert.Equal(t, jsonrpc.HybridState{CallsConnected: true}, client.State())
+
+	// the next subscription dials again
+	_, err = client.Subscribe("update")
+	assert.Nil(t, err)
+	assert.True(t, client.State().StreamConnected)
+}
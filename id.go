@@ -0,0 +1,69 @@
+package jsonrpc
+
+import (
+	"crypto/rand"
+	"fmt"
+	"sync/atomic"
+
+	gonanoid "github.com/matoous/go-nanoid"
+)
+
+// AnyIdGenerator returns a new request id. Unlike IdGenerator, which only returns strings, the
+// value is marshalled to json as it is, so generators such as Sequential can return numbers.
+type AnyIdGenerator = func() any
+
+// DefaultNanoIDLength is the length of the ids assigned by DefaultIdGenerator.
+const DefaultNanoIDLength = 20
+
+// DefaultIdGenerator is used by the client when no generator has been configured. It returns random
+// url friendly string ids of DefaultNanoIDLength, see NanoID.
+func DefaultIdGenerator() any {
+	return gonanoid.MustID(DefaultNanoIDLength)
+}
+
+// Sequential returns a generator of monotonically increasing integer ids, starting at 1.
+func Sequential() AnyIdGenerator {
+	var counter atomic.Uint64
+	return func() any {
+		return counter.Add(1)
+	}
+}
+
+// PrefixedSequential returns a generator of string ids of the form "<prefix>-<n>" where n increases
+// monotonically. It is useful for distinguishing the requests of multiple clients in shared logs.
+func PrefixedSequential(prefix string) AnyIdGenerator {
+	next := Sequential()
+	return func() any {
+		return fmt.Sprintf("%s-%d", prefix, next())
+	}
+}
+
+// NanoIDGenerator returns an IdGenerator of random url friendly strings of the given length, for
+// use with Request.EnsureId.
+func NanoIDGenerator(length int) func() string {
+	return func() string {
+		return gonanoid.MustID(length)
+	}
+}
+
+// NanoID returns a generator of random url friendly string ids of the given length, for servers
+// which limit the length of ids, see WithNanoIDLength and NanoIDGenerator.
+func NanoID(length int) AnyIdGenerator {
+	next := NanoIDGenerator(length)
+	return func() any {
+		return next()
+	}
+}
+
+// UUIDv4 returns a generator of random version 4 UUID string ids as described in RFC 4122.
+func UUIDv4() AnyIdGenerator {
+	return func() any {
+		var uuid [16]byte
+		if _, err := rand.Read(uuid[:]); err != nil {
+			panic(err)
+		}
+		uuid[6] = (uuid[6] & 0x0f) | 0x40 // version 4
+		uuid[8] = (uuid[8] & 0x3f) | 0x80 // variant 10
+		return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:])
+	}
+}
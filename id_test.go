@@ -0,0 +1,117 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"sync"
+	"testing"
+
+	"github.com/41north/jsonrpc.go"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var idGeneratorTestCases = []struct {
+	name string
+	gen  func() jsonrpc.AnyIdGenerator
+}{
+	{"Sequential", jsonrpc.Sequential},
+	{"PrefixedSequential", func() jsonrpc.AnyIdGenerator { return jsonrpc.PrefixedSequential("client-1") }},
+	{"NanoID", func() jsonrpc.AnyIdGenerator { return jsonrpc.NanoID(20) }},
+	{"DefaultIdGenerator", func() jsonrpc.AnyIdGenerator { return jsonrpc.DefaultIdGenerator }},
+	{"UUIDv4", jsonrpc.UUIDv4},
+}
+
+func TestIdGenerator_Collisions(t *testing.T) {
+	routines := 16
+	idsPerRoutine := 10000
+
+	for _, tc := range idGeneratorTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gen := tc.gen()
+
+			ids := sync.Map{}
+			wg := sync.WaitGroup{}
+			wg.Add(routines)
+
+			for i := 0; i < routines; i++ {
+				go func() {
+					defer wg.Done()
+					for j := 0; j < idsPerRoutine; j++ {
+						bytes, err := json.Marshal(gen())
+						assert.Nil(t, err)
+						_, loaded := ids.LoadOrStore(string(bytes), true)
+						assert.False(t, loaded, "duplicate id generated: %s", bytes)
+					}
+				}()
+			}
+
+			wg.Wait()
+		})
+	}
+}
+
+func TestIdGenerator_Format(t *testing.T) {
+	seq := jsonrpc.Sequential()
+	assert.Equal(t, "1", marshalId(seq))
+	assert.Equal(t, "2", marshalId(seq))
+
+	prefixed := jsonrpc.PrefixedSequential("client-1")
+	assert.Equal(t, "\"client-1-1\"", marshalId(prefixed))
+	assert.Equal(t, "\"client-1-2\"", marshalId(prefixed))
+
+	for _, length := range []int{8, 16, 32} {
+		assert.Len(t, jsonrpc.NanoID(length)().(string), length)
+		assert.Len(t, jsonrpc.NanoIDGenerator(length)(), length)
+	}
+	assert.Len(t, jsonrpc.DefaultIdGenerator().(string), jsonrpc.DefaultNanoIDLength)
+
+	uuid := regexp.MustCompile("^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$")
+	assert.Regexp(t, uuid, jsonrpc.UUIDv4()())
+}
+
+func TestRequest_EnsureId(t *testing.T) {
+	// string generators remain usable as they were
+	var req jsonrpc.Request
+	assert.Nil(t, req.EnsureId(func() string { return "abc" }))
+	assert.Equal(t, "\"abc\"", string(req.Id))
+
+	// an existing id is kept
+	assert.Nil(t, req.EnsureId(jsonrpc.NanoIDGenerator(8)))
+	assert.Equal(t, "\"abc\"", string(req.Id))
+}
+
+func marshalId(gen jsonrpc.AnyIdGenerator) string {
+	bytes, err := json.Marshal(gen())
+	if err != nil {
+		panic(err)
+	}
+	return string(bytes)
+}
+
+func TestClient_NanoIDLength(t *testing.T) {
+	server := jsonrpc.NewServer()
+	server.Register("echo", echo)
+
+	for _, tc := range []struct {
+		options []jsonrpc.ClientOption
+		length  int
+	}{
+		{nil, jsonrpc.DefaultNanoIDLength},
+		{[]jsonrpc.ClientOption{jsonrpc.WithNanoIDLength(16)}, 16},
+	} {
+		client := jsonrpc.NewClient(serverDialer(server, nil), tc.options...)
+		assert.Nil(t, client.Connect())
+
+		req := *newRequest("echo", nil)
+		var resp jsonrpc.Response
+		assert.Nil(t, client.SendRequest(context.Background(), &req, &resp))
+
+		var id string
+		assert.Nil(t, resp.UnmarshalId(&id))
+		assert.Len(t, id, tc.length)
+		assert.Equal(t, req.Id, resp.Id)
+		assert.Nil(t, client.Close())
+	}
+}
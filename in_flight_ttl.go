@@ -0,0 +1,64 @@
+package jsonrpc
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+var ErrTimeout = errors.ConstError("request timed out")
+
+// inFlightSweepJitter is the largest fraction of the sweep interval added to each wait, so that
+// many clients created together do not sweep in step.
+const inFlightSweepJitter = 0.1
+
+// WithInFlightTTL fails requests which have been awaiting a response for longer than ttl with
+// ErrTimeout, as a safety net against requests which would otherwise be held forever, such as those
+// whose callers stopped waiting for a server which never responds. Requests are checked every
+// interval, capped at ttl, with some jitter added, so a request may be held for up to twice ttl.
+func WithInFlightTTL(ttl time.Duration, interval time.Duration) ClientOption {
+	return func(opts *ClientOptions) {
+		opts.InFlightTTL = ttl
+		opts.InFlightSweepInterval = interval
+	}
+}
+
+// sweepInFlight expires the requests which have outlived the in flight ttl each interval until the
+// client closes.
+func (c *client) sweepInFlight() {
+	ttl := c.opts.InFlightTTL
+	interval := c.opts.InFlightSweepInterval
+	if interval <= 0 || interval > ttl {
+		interval = ttl
+	}
+	wait := func() time.Duration {
+		return interval + time.Duration(rand.Float64()*inFlightSweepJitter*float64(interval))
+	}
+
+	timer := c.opts.Clock.NewTimer(wait())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-timer.C():
+		}
+
+		expired := 0
+		deadline := c.opts.Clock.Now().Add(-ttl)
+		c.inFlight.Range(func(_, value any) bool {
+			// requests which resolve concurrently are left to their response
+			if request := value.(*inFlightRequest); request.start.Before(deadline) && c.expire(request, ErrTimeout) {
+				expired++
+			}
+			return true
+		})
+		if expired > 0 {
+			c.logger().WithField("expired", expired).Warn("expired requests which outlived the in flight ttl")
+		}
+
+		timer.Reset(wait())
+	}
+}
@@ -0,0 +1,52 @@
+package jsonrpc_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/41north/jsonrpc.go"
+	"github.com/41north/jsonrpc.go/testutil"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_InFlightTTL(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	clock := testutil.NewClock(time.Time{})
+	client := jsonrpc.NewClient(
+		serverDialer(endpoint("a", release), nil),
+		jsonrpc.WithClock(clock),
+		jsonrpc.WithInFlightTTL(time.Minute, 10*time.Second),
+	)
+	assert.Nil(t, client.Connect())
+	defer func() { _ = client.Close() }()
+
+	// the caller abandons the request, which the server never answers
+	future := client.SendAsync(*newRequest("wait", nil))
+	assert.Equal(t, map[string]int{"": 1}, client.InFlightByTenant())
+
+	// sweeps within the ttl leave it in flight
+	for i := 0; i < 5; i++ {
+		clock.BlockUntil(1)
+		clock.Advance(11 * time.Second)
+	}
+	select {
+	case <-future.Get():
+		t.Fatal("request expired within the ttl")
+	default:
+	}
+
+	// the first sweep after the ttl expires it
+	clock.BlockUntil(1)
+	clock.Advance(11 * time.Second)
+	_, err := (<-future.Get()).Unwrap()
+	assert.ErrorIs(t, err, jsonrpc.ErrTimeout)
+	assert.Empty(t, client.InFlightByTenant())
+
+	// requests answered within the ttl are unaffected
+	name, err := sendName(t, client, "name")
+	assert.Nil(t, err)
+	assert.Equal(t, "a", name)
+}
@@ -0,0 +1,41 @@
+package jsonrpc
+
+import "context"
+
+type (
+	// UnaryInvoker sends a request and waits for its response.
+	UnaryInvoker = func(ctx context.Context, req Request) (Response, error)
+
+	// UnaryInterceptor wraps a call made with Send or SendContext. It may modify the request before
+	// passing it to invoker, which continues the chain, and inspect or replace the response.
+	UnaryInterceptor = func(ctx context.Context, req Request, invoker UnaryInvoker) (Response, error)
+)
+
+// WithInterceptors wraps every call made with Send or SendContext in interceptors, the first of
+// which is outermost. Requests made with SendAsync and batches are not intercepted.
+func WithInterceptors(interceptors ...UnaryInterceptor) ClientOption {
+	return func(opts *ClientOptions) {
+		opts.Interceptors = append(opts.Interceptors, interceptors...)
+	}
+}
+
+// chainInterceptors composes interceptors around invoker.
+func chainInterceptors(interceptors []UnaryInterceptor, invoker UnaryInvoker) UnaryInvoker {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], invoker
+		invoker = func(ctx context.Context, req Request) (Response, error) {
+			return interceptor(ctx, req, next)
+		}
+	}
+	return invoker
+}
+
+// senderKey is the context key of the client making a call, which is set for its interceptors.
+type senderKey struct{}
+
+// senderFromContext returns the client making the call which ctx belongs to, or nil outside of an
+// interceptor.
+func senderFromContext(ctx context.Context) *client {
+	c, _ := ctx.Value(senderKey{}).(*client)
+	return c
+}
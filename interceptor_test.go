@@ -0,0 +1,64 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/41north/jsonrpc.go"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_Interceptors(t *testing.T) {
+	server := jsonrpc.NewServer()
+	server.Register("whoami", func(ctx context.Context, req jsonrpc.Request) (any, error) {
+		return req.Extension("auth"), nil
+	})
+
+	var order []string
+	trace := func(name string) jsonrpc.UnaryInterceptor {
+		return func(ctx context.Context, req jsonrpc.Request, invoker jsonrpc.UnaryInvoker) (jsonrpc.Response, error) {
+			order = append(order, name+" before")
+			resp, err := invoker(ctx, req)
+			order = append(order, name+" after")
+			return resp, err
+		}
+	}
+
+	// injects a header into the request and annotates the response
+	auth := func(ctx context.Context, req jsonrpc.Request, invoker jsonrpc.UnaryInvoker) (jsonrpc.Response, error) {
+		if req.Method == "blocked" {
+			return jsonrpc.Response{}, errors.New("blocked by interceptor")
+		}
+		if err := req.SetExtension("auth", "alice"); err != nil {
+			return jsonrpc.Response{}, err
+		}
+		resp, err := invoker(ctx, req)
+		if err == nil {
+			err = resp.SetExtension("intercepted", true)
+		}
+		return resp, err
+	}
+
+	client := jsonrpc.NewClient(
+		serverDialer(server, nil),
+		jsonrpc.WithInterceptors(trace("outer"), trace("inner")),
+		jsonrpc.WithInterceptors(auth),
+	)
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	var resp jsonrpc.Response
+	assert.Nil(t, client.Send(*newRequest("whoami", nil), &resp))
+
+	var result string
+	assert.Nil(t, resp.UnmarshalResult(&result))
+	assert.Equal(t, "alice", result)
+	assert.Equal(t, "true", string(resp.Extension("intercepted")))
+	assert.Equal(t, []string{"outer before", "inner before", "inner after", "outer after"}, order)
+
+	// interceptors can short circuit the call
+	err := client.Send(*newRequest("blocked", nil), &resp)
+	assert.EqualError(t, err, "blocked by interceptor")
+}
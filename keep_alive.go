@@ -0,0 +1,99 @@
+package jsonrpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+var ErrKeepAlive = errors.ConstError("keep alive failed")
+
+// Pinger is implemented by connections whose transport has a native liveness check, such as the
+// ping and pong control frames of a websocket. Ping returns once the remote peer has responded.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// WithKeepAlive checks the connection is alive every interval, closing the client with
+// ErrKeepAlive if a check does not succeed within timeout. Connections which implement Pinger are
+// checked with a native ping, otherwise a request for method is sent, with any response, including
+// an error, counting as success. If method is empty, connections which cannot ping are not checked.
+func WithKeepAlive(interval time.Duration, timeout time.Duration, method string) ClientOption {
+	return func(opts *ClientOptions) {
+		opts.KeepAliveInterval = interval
+		opts.KeepAliveTimeout = timeout
+		opts.KeepAliveMethod = method
+	}
+}
+
+// keepAlive pings the connection every interval until the client closes.
+func (c *client) keepAlive() {
+	ticker := c.opts.Clock.NewTicker(c.liveOptions().keepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-c.keepAliveReset:
+			ticker.Reset(c.liveOptions().keepAliveInterval)
+			continue
+		case <-ticker.C():
+		}
+
+		if err := c.ping(); err != nil {
+			err = errors.WithType(errors.Annotate(err, string(ErrKeepAlive)), ErrKeepAlive)
+			c.logger().WithError(err).Warn("closing connection")
+			_ = c.closeWithError(err)
+			return
+		}
+	}
+}
+
+// ping checks the current connection within the keep alive timeout, measured with the clock of
+// the client.
+func (c *client) ping() error {
+	timeout := c.liveOptions().keepAliveTimeout
+	if timeout <= 0 {
+		return c.Ping(context.Background())
+	}
+
+	ctx, cancel := withClockTimeout(context.Background(), c.opts.Clock, timeout)
+	defer cancel()
+
+	if err := c.Ping(ctx); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return context.DeadlineExceeded
+		}
+		return err
+	}
+	return nil
+}
+
+// Ping checks the connection is alive, preferring a native ping, see Pinger, over a request for
+// the keep alive method. If the connection cannot ping and no method has been set with
+// WithKeepAlive, only whether the client is connected is checked.
+func (c *client) Ping(ctx context.Context) error {
+	if c.closed.Load() {
+		return ErrClosed
+	}
+	conn := c.Connection()
+	if conn == nil {
+		return ErrNotConnected
+	}
+
+	if pinger, ok := conn.(Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	if c.opts.KeepAliveMethod == "" {
+		return nil
+	}
+
+	req, err := NewRequest(c.opts.KeepAliveMethod, nil)
+	if err != nil {
+		return err
+	}
+	var resp Response
+	return c.sendContext(ctx, *req, &resp, true)
+}
@@ -0,0 +1,103 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/41north/jsonrpc.go"
+	"github.com/41north/jsonrpc.go/testutil"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebSocketConnection_Ping(t *testing.T) {
+	srv := newWsServer(false)
+	defer srv.close()
+
+	conn, err := jsonrpc.WebSocketDialer{Url: srv.url("/ws")}.Dial()
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	// pongs are processed by the reader
+	go func() {
+		for {
+			if _, err := conn.Read(); err != nil {
+				return
+			}
+		}
+	}()
+
+	pinger, ok := conn.(jsonrpc.Pinger)
+	assert.True(t, ok)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.Nil(t, pinger.Ping(ctx))
+	assert.Nil(t, pinger.Ping(ctx))
+}
+
+func TestClient_KeepAlive(t *testing.T) {
+	var pings atomic.Int32
+	server := jsonrpc.NewServer()
+	server.Register("ping", func(ctx context.Context, req jsonrpc.Request) (any, error) {
+		pings.Add(1)
+		return "pong", nil
+	})
+
+	// stream connections cannot ping, so fall back to a request
+	clock := testutil.NewClock(time.Time{})
+	client := jsonrpc.NewClient(
+		serverDialer(server, nil),
+		jsonrpc.WithClock(clock),
+		jsonrpc.WithKeepAlive(time.Minute, time.Second, "ping"),
+	)
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	clock.BlockUntil(1)
+	clock.Advance(59 * time.Second)
+	assert.Equal(t, int32(0), pings.Load())
+	for i := int32(1); i <= 3; i++ {
+		clock.Advance(time.Minute)
+		// wait for the ping to complete, leaving only the ticker, so that advancing the clock again
+		// does not also expire its timeout
+		assert.Eventually(t, func() bool {
+			return pings.Load() == i && clock.Waiters() == 1
+		}, time.Second, time.Millisecond)
+	}
+
+	// a peer which stops responding is closed
+	clientConn, serverConn := net.Pipe()
+	go func() {
+		stream := jsonrpc.NewStreamConnection(serverConn, jsonrpc.FramingNewline)
+		for {
+			if _, err := stream.Read(); err != nil {
+				return
+			}
+		}
+	}()
+
+	clock = testutil.NewClock(time.Time{})
+	client = jsonrpc.NewClientWithConnection(
+		jsonrpc.NewStreamConnection(clientConn, jsonrpc.FramingNewline),
+		jsonrpc.WithClock(clock),
+		jsonrpc.WithKeepAlive(time.Minute, 20*time.Millisecond, "ping"),
+	)
+	closed := make(chan error, 1)
+	client.SetCloseHandler(func(err error) { closed <- err })
+	assert.Nil(t, client.Connect())
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+
+	// the timeout is measured with the clock of the client too
+	clock.BlockUntil(2)
+	clock.Advance(20 * time.Millisecond)
+
+	err := <-closed
+	assert.ErrorIs(t, err, jsonrpc.ErrKeepAlive)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,31 @@
+package jsonrpc
+
+import "context"
+
+type metadataKey struct{}
+
+// ContextWithMetadata returns a copy of ctx carrying key with value, along with any metadata already
+// carried by ctx. Metadata describes a call for the application's own instrumentation, such as a
+// tenant or trace id, without being sent to the server: interceptors receive it with the context of
+// the call and a ContextObserver with the context of each message, see MetadataFrom.
+func ContextWithMetadata(ctx context.Context, key string, value string) context.Context {
+	parent := MetadataFrom(ctx)
+	md := make(map[string]string, len(parent)+1)
+	for k, v := range parent {
+		md[k] = v
+	}
+	md[key] = value
+	return context.WithValue(ctx, metadataKey{}, md)
+}
+
+// MetadataFrom returns the metadata carried by ctx, see ContextWithMetadata. The map must not be
+// modified.
+func MetadataFrom(ctx context.Context) map[string]string {
+	md, _ := ctx.Value(metadataKey{}).(map[string]string)
+	return md
+}
+
+// MetadataValue returns the value of key in the metadata carried by ctx, or the empty string.
+func MetadataValue(ctx context.Context, key string) string {
+	return MetadataFrom(ctx)[key]
+}
@@ -0,0 +1,77 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/41north/jsonrpc.go"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// contextObserver records the tenant of each message from the metadata of its context.
+type contextObserver struct {
+	testObserver
+	tenants chan string
+}
+
+func (o contextObserver) OnRequestContext(ctx context.Context, method string, size int) {
+	o.tenants <- "request:" + jsonrpc.MetadataValue(ctx, "tenant")
+}
+
+func (o contextObserver) OnResponseContext(ctx context.Context, method string, size int) {
+	o.tenants <- "response:" + jsonrpc.MetadataValue(ctx, "tenant")
+}
+
+func TestContextWithMetadata(t *testing.T) {
+	ctx := jsonrpc.ContextWithMetadata(context.Background(), "tenant", "acme")
+	child := jsonrpc.ContextWithMetadata(ctx, "traceId", "abc")
+
+	assert.Equal(t, map[string]string{"tenant": "acme"}, jsonrpc.MetadataFrom(ctx))
+	assert.Equal(t, map[string]string{"tenant": "acme", "traceId": "abc"}, jsonrpc.MetadataFrom(child))
+	assert.Equal(t, "abc", jsonrpc.MetadataValue(child, "traceId"))
+	assert.Nil(t, jsonrpc.MetadataFrom(context.Background()))
+}
+
+func TestClient_ContextObserver(t *testing.T) {
+	server := jsonrpc.NewServer()
+	server.Register("echo", echo)
+
+	observer := contextObserver{tenants: make(chan string, 16)}
+	intercepted := make(chan string, 16)
+	client := jsonrpc.NewClient(
+		serverDialer(server, nil),
+		jsonrpc.WithObserver(observer),
+		jsonrpc.WithInterceptors(func(ctx context.Context, req jsonrpc.Request, invoker jsonrpc.UnaryInvoker) (jsonrpc.Response, error) {
+			intercepted <- jsonrpc.MetadataValue(ctx, "tenant")
+			return invoker(ctx, req)
+		}),
+	)
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	// the context of a call is passed to interceptors and observers
+	ctx := jsonrpc.ContextWithMetadata(context.Background(), "tenant", "acme")
+	var resp jsonrpc.Response
+	assert.Nil(t, client.SendContext(ctx, *newRequest("echo", nil), &resp))
+	assert.Equal(t, "acme", <-intercepted)
+	assert.Equal(t, "request:acme", <-observer.tenants)
+	assert.Equal(t, "response:acme", <-observer.tenants)
+
+	// including for each element of a batch
+	var batch jsonrpc.BatchRequest
+	batch.Add(*newRequest("echo", nil), time.Second)
+	batch.Add(*newRequest("echo", nil), time.Second)
+	_, err := client.SendBatchContext(ctx, batch)
+	assert.Nil(t, err)
+	for i := 0; i < 4; i++ {
+		assert.Contains(t, []string{"request:acme", "response:acme"}, <-observer.tenants)
+	}
+
+	// calls without a context are observed with the background context
+	_, err = (<-client.SendAsync(*newRequest("echo", nil)).Get()).Unwrap()
+	assert.Nil(t, err)
+	assert.Equal(t, "request:", <-observer.tenants)
+	assert.Equal(t, "response:", <-observer.tenants)
+}
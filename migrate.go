@@ -0,0 +1,116 @@
+package jsonrpc
+
+import (
+	"context"
+
+	"github.com/juju/errors"
+)
+
+var ErrNotConnected = errors.ConstError("client is not connected")
+
+// Migrate moves the client to a new connection without dropping requests, e.g. ahead of planned
+// maintenance of the current endpoint. The new connection is dialled with dialer and, once
+// established, all new requests are sent on it. Requests already in flight on the old connection
+// are given until ctx is done to complete, after which the old connection is closed and any which
+// remain fail with ErrClosed, or as set by WithReconnectFailMode. Subscriptions stay attached to
+// the client and receive notifications from the new connection; server side state, such as the
+// subscriptions themselves, should be re-established by the OnConnect hook, which is run against
+// the new connection.
+//
+// Other sends wait for the OnConnect hook to return before writing to the new connection. If
+// dialing or the hook fails the new connection is closed and the client carries on using the old
+// one, as if Migrate had not been called.
+func (c *client) Migrate(ctx context.Context, dialer Dialer) error {
+	c.connectMu.Lock()
+	defer c.connectMu.Unlock()
+
+	if c.closed.Load() {
+		return ErrClosed
+	}
+	if !c.connected.Load() {
+		return ErrNotConnected
+	}
+
+	conn, err := dialer.DialContext(ctx)
+	if err != nil {
+		return &DialError{Cause: err}
+	}
+
+	// nothing but the connect hook writes to the new connection until it has been initialised
+	var handshake chan struct{}
+	if c.opts.OnConnect != nil {
+		handshake = make(chan struct{})
+		c.connMu.Lock()
+		c.handshake = handshake
+		c.connMu.Unlock()
+	}
+
+	// requests in flight now were sent, or are about to be sent, on the old connection
+	var draining []*inFlightRequest
+	c.inFlight.Range(func(_, value any) bool {
+		draining = append(draining, value.(*inFlightRequest))
+		return true
+	})
+
+	prevDialer := c.dialer
+	c.dialer = dialer
+	c.oneShot.Store(isOneShot(dialer))
+	old := c.setConnection(conn)
+	c.startReading(conn)
+
+	c.logger().
+		WithField("inFlight", len(draining)).
+		Info("migrating to new connection")
+
+	if handshake != nil {
+		err := c.opts.OnConnect(ctx, &handshakeClient{c})
+		if err != nil {
+			c.revert(prevDialer, old, conn, draining)
+		}
+		c.endHandshake(handshake)
+		if err != nil {
+			return errors.Annotate(err, "connect hook failed")
+		}
+	}
+
+	// responses to the draining requests may still arrive on the old connection
+	var lost []*inFlightRequest
+	for _, request := range draining {
+		select {
+		case <-request.future.Get():
+		case <-ctx.Done():
+			lost = append(lost, request)
+		}
+	}
+
+	_ = old.Close()
+
+	// the rest are failed or resent, see WithReconnectFailMode
+	if remaining := c.drainLost(ctx, lost); remaining > 0 {
+		c.logger().
+			WithField("failed", remaining).
+			Warn("requests did not complete before the old connection was closed")
+	}
+	return nil
+}
+
+// revert switches the client back to the old connection and dialer after the connect hook failed
+// to initialise conn, which is closed. Requests sent on it by the hook which have yet to complete,
+// being those which were not draining, fail with ErrClosed.
+func (c *client) revert(dialer Dialer, old Connection, conn Connection, draining []*inFlightRequest) {
+	c.dialer = dialer
+	c.oneShot.Store(isOneShot(dialer))
+	c.setConnection(old)
+	_ = conn.Close()
+
+	kept := make(map[*inFlightRequest]bool, len(draining))
+	for _, request := range draining {
+		kept[request] = true
+	}
+	c.inFlight.Range(func(_, value any) bool {
+		if request := value.(*inFlightRequest); !kept[request] {
+			c.expire(request, ErrClosed)
+		}
+		return true
+	})
+}
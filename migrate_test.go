@@ -0,0 +1,185 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/41north/jsonrpc.go"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// endpoint returns a server whose "name" method returns name, and whose "wait" method blocks until
+// release is closed before doing the same.
+func endpoint(name string, release chan struct{}) *jsonrpc.Server {
+	server := jsonrpc.NewServer()
+	server.Register("name", func(ctx context.Context, req jsonrpc.Request) (any, error) {
+		return name, nil
+	})
+	server.Register("wait", func(ctx context.Context, req jsonrpc.Request) (any, error) {
+		<-release
+		return name, nil
+	})
+	return server
+}
+
+func sendName(t *testing.T, client jsonrpc.Client, method string) (string, error) {
+	var resp jsonrpc.Response
+	if err := client.Send(*newRequest(method, nil), &resp); err != nil {
+		return "", err
+	}
+	var name string
+	assert.Nil(t, resp.UnmarshalResult(&name))
+	return name, nil
+}
+
+func TestClient_Migrate(t *testing.T) {
+	release := make(chan struct{})
+
+	var hooks atomic.Int32
+	client := jsonrpc.NewClient(
+		serverDialer(endpoint("a", release), nil),
+		jsonrpc.WithOnConnect(func(ctx context.Context, client jsonrpc.Client) error {
+			hooks.Add(1)
+			return nil
+		}),
+	)
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	name, err := sendName(t, client, "name")
+	assert.Nil(t, err)
+	assert.Equal(t, "a", name)
+
+	// a request which straddles the switchover
+	waiting := client.SendAsync(*newRequest("wait", nil))
+
+	// failing to dial leaves the old connection in use
+	refused := jsonrpc.DialFunc(func() (jsonrpc.Connection, error) {
+		return nil, errors.New("connection refused")
+	})
+	assert.ErrorIs(t, client.Migrate(context.Background(), refused), jsonrpc.ErrDial)
+
+	name, err = sendName(t, client, "name")
+	assert.Nil(t, err)
+	assert.Equal(t, "a", name)
+
+	migrated := make(chan error, 1)
+	go func() {
+		migrated <- client.Migrate(context.Background(), serverDialer(endpoint("b", release), nil))
+	}()
+
+	// new requests are sent on the new connection while the old one drains
+	assert.Eventually(t, func() bool {
+		name, err := sendName(t, client, "name")
+		return err == nil && name == "b"
+	}, time.Second, 10*time.Millisecond)
+
+	select {
+	case <-migrated:
+		t.Fatal("migration completed before the old connection drained")
+	default:
+	}
+
+	close(release)
+	assert.Nil(t, <-migrated)
+	assert.Equal(t, int32(2), hooks.Load())
+
+	resp, err := (<-waiting.Get()).Unwrap()
+	assert.Nil(t, err)
+	var result string
+	assert.Nil(t, resp.UnmarshalResult(&result))
+	assert.Equal(t, "a", result)
+
+	// closing the old connection does not close the client
+	name, err = sendName(t, client, "name")
+	assert.Nil(t, err)
+	assert.Equal(t, "b", name)
+}
+
+func TestClient_MigrateDeadline(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	client := jsonrpc.NewClient(serverDialer(endpoint("a", release), nil))
+	assert.ErrorIs(t, client.Migrate(context.Background(), nil), jsonrpc.ErrNotConnected)
+
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	waiting := client.SendAsync(*newRequest("wait", nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Nil(t, client.Migrate(ctx, serverDialer(endpoint("b", release), nil)))
+
+	_, err := (<-waiting.Get()).Unwrap()
+	assert.ErrorIs(t, err, jsonrpc.ErrClosed)
+
+	name, err := sendName(t, client, "name")
+	assert.Nil(t, err)
+	assert.Equal(t, "b", name)
+}
+
+func TestClient_MigrateOnConnectFailure(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	var hooks atomic.Int32
+	var client jsonrpc.Client
+	sent := make(chan string, 1)
+	client = jsonrpc.NewClient(
+		serverDialer(endpoint("a", release), nil),
+		jsonrpc.WithOnConnect(func(ctx context.Context, c jsonrpc.Client) error {
+			if hooks.Add(1) == 1 {
+				return nil
+			}
+
+			// the hook is sent on the new connection
+			name, err := sendName(t, c, "name")
+			assert.Nil(t, err)
+			assert.Equal(t, "b", name)
+
+			// a send made directly on the client waits for the hook to return
+			started := make(chan struct{})
+			go func() {
+				close(started)
+				name, err := sendName(t, client, "name")
+				assert.Nil(t, err)
+				sent <- name
+			}()
+			<-started
+			runtime.Gosched()
+
+			name, err = sendName(t, c, "name")
+			assert.Nil(t, err)
+			assert.Equal(t, "b", name)
+			return errors.New("unauthorized")
+		}),
+	)
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	waiting := client.SendAsync(*newRequest("wait", nil))
+
+	// the failed switch leaves the client on the old connection
+	err := client.Migrate(context.Background(), serverDialer(endpoint("b", release), nil))
+	assert.ErrorContains(t, err, "unauthorized")
+	assert.Equal(t, "a", <-sent)
+
+	name, err := sendName(t, client, "name")
+	assert.Nil(t, err)
+	assert.Equal(t, "a", name)
+
+	// including the requests in flight on it
+	release <- struct{}{}
+	resp, err := (<-waiting.Get()).Unwrap()
+	assert.Nil(t, err)
+	var result string
+	assert.Nil(t, resp.UnmarshalResult(&result))
+	assert.Equal(t, "a", result)
+}
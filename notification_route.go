@@ -0,0 +1,195 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+	"path"
+	"sync"
+
+	"github.com/juju/errors"
+)
+
+// NotificationHandler handles a notification routed to it by OnNotification.
+type NotificationHandler = func(method string, params json.RawMessage)
+
+// validatePattern checks pattern is a valid path.Match pattern.
+func validatePattern(pattern string) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return errors.Annotatef(err, "pattern %q", pattern)
+	}
+	return nil
+}
+
+type notificationRoute struct {
+	pattern string
+	handler NotificationHandler
+}
+
+// notificationRouter holds the handlers registered with OnNotification, in registration order.
+type notificationRouter struct {
+	mu     sync.RWMutex
+	routes []*notificationRoute
+}
+
+func (r *notificationRouter) add(pattern string, handler NotificationHandler) func() {
+	route := &notificationRoute{pattern: pattern, handler: handler}
+
+	r.mu.Lock()
+	r.routes = append(r.routes, route)
+	r.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			for i, other := range r.routes {
+				if other == route {
+					r.routes = append(r.routes[:i:i], r.routes[i+1:]...)
+					return
+				}
+			}
+		})
+	}
+}
+
+// match returns the handlers whose pattern matches method, in registration order.
+func (r *notificationRouter) match(method string) []NotificationHandler {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var handlers []NotificationHandler
+	for _, route := range r.routes {
+		if ok, _ := path.Match(route.pattern, method); ok {
+			handlers = append(handlers, route.handler)
+		}
+	}
+	return handlers
+}
+
+// OnNotification registers handler for the notifications whose method matches pattern, either an
+// exact name or a glob such as "eth_*", see path.Match. It returns a function which unregisters the
+// handler. Any number of handlers may be registered, and a notification is passed to every handler
+// it matches in the order they were registered. Notifications delivered to a subscription are not
+// routed, see Subscribe, and those matching no handler are passed to the request handler, see
+// SetRequestHandler, which acts as the catch all.
+//
+// As with the request handler, handlers are run off the read loop. A handler which panics is
+// recovered and logged, without preventing the handlers after it from running.
+func (c *client) OnNotification(pattern string, handler NotificationHandler) (func(), error) {
+	if err := validatePattern(pattern); err != nil {
+		return nil, err
+	}
+	return c.routes.add(pattern, handler), nil
+}
+
+// route dispatches a notification to the handlers matching its method, returning false if there are
+// none.
+func (c *client) route(req Request) bool {
+	handlers := c.routes.match(req.Method)
+	if len(handlers) == 0 {
+		return false
+	}
+
+	c.dispatcher.dispatch(func() {
+		var panicked error
+		for _, handler := range handlers {
+			func() {
+				defer func() {
+					if r := recover(); r != nil {
+						panicked = c.onPanic(r)
+					}
+				}()
+				handler(req.Method, req.Params)
+			}()
+		}
+		if panicked != nil {
+			c.closeOnPanic(panicked)
+		}
+	})
+	return true
+}
+
+// sharedRoute is a handler registered with each client of a ClientPool or HybridClient.
+type sharedRoute struct {
+	pattern    string
+	handler    NotificationHandler
+	unregister map[Client]func()
+	removed    bool
+}
+
+// sharedRoutes registers notification handlers with a changing set of clients, current and future.
+type sharedRoutes struct {
+	mu     sync.Mutex
+	routes []*sharedRoute
+}
+
+// add registers handler with each of the current clients, and with each client installed later.
+func (s *sharedRoutes) add(pattern string, handler NotificationHandler, clients func() []Client) (func(), error) {
+	if err := validatePattern(pattern); err != nil {
+		return nil, err
+	}
+	route := &sharedRoute{pattern: pattern, handler: handler, unregister: make(map[Client]func())}
+
+	s.mu.Lock()
+	s.routes = append(s.routes, route)
+	s.mu.Unlock()
+
+	// clients are listed once the route has been added, so that none are missed by both this and
+	// install, and without holding mu, which install is called with the lock of clients held
+	for _, client := range clients() {
+		s.mu.Lock()
+		// the pattern has already been validated
+		_ = s.register(route, client)
+		s.mu.Unlock()
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			route.removed = true
+			for i, other := range s.routes {
+				if other == route {
+					s.routes = append(s.routes[:i:i], s.routes[i+1:]...)
+					break
+				}
+			}
+			for _, unregister := range route.unregister {
+				unregister()
+			}
+		})
+	}, nil
+}
+
+// install registers every handler with client.
+func (s *sharedRoutes) install(client Client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, route := range s.routes {
+		// the pattern has already been validated
+		_ = s.register(route, client)
+	}
+}
+
+// register registers route with client. It must be called with mu held.
+func (s *sharedRoutes) register(route *sharedRoute, client Client) error {
+	if _, ok := route.unregister[client]; ok || route.removed {
+		return nil
+	}
+	unregister, err := client.OnNotification(route.pattern, route.handler)
+	if err != nil {
+		return err
+	}
+	route.unregister[client] = unregister
+	return nil
+}
+
+// uninstall forgets client, once it has closed.
+func (s *sharedRoutes) uninstall(client Client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, route := range s.routes {
+		delete(route.unregister, client)
+	}
+}
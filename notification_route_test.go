@@ -0,0 +1,105 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+
+	"github.com/41north/jsonrpc.go"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_OnNotification(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	server := jsonrpc.NewStreamConnection(serverConn, jsonrpc.FramingNewline)
+	client := jsonrpc.NewClientWithConnection(jsonrpc.NewStreamConnection(clientConn, jsonrpc.FramingNewline))
+
+	handled := make(chan string, 16)
+	record := func(name string) jsonrpc.NotificationHandler {
+		return func(method string, params json.RawMessage) {
+			handled <- name + ":" + method + string(params)
+		}
+	}
+
+	_, err := client.OnNotification("eth_*", record("glob"))
+	assert.Nil(t, err)
+	unregister, err := client.OnNotification("eth_newHeads", record("exact"))
+	assert.Nil(t, err)
+	_, err = client.OnNotification("eth_newHeads", func(method string, params json.RawMessage) {
+		panic("boom")
+	})
+	assert.Nil(t, err)
+	_, err = client.OnNotification("eth_newHeads", record("after"))
+	assert.Nil(t, err)
+	_, err = client.OnNotification("[", record("invalid"))
+	assert.NotNil(t, err)
+
+	// anything unmatched is passed to the request handler
+	client.SetRequestHandler(func(req jsonrpc.Request) {
+		handled <- "catchAll:" + req.Method
+	})
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	// matching handlers run in registration order, a panic not preventing those after it
+	assert.Nil(t, server.Write([]byte(`{"method":"eth_newHeads","params":[1],"jsonrpc":"2.0"}`)))
+	assert.Equal(t, "glob:eth_newHeads[1]", <-handled)
+	assert.Equal(t, "exact:eth_newHeads[1]", <-handled)
+	assert.Equal(t, "after:eth_newHeads[1]", <-handled)
+
+	assert.Nil(t, server.Write([]byte(`{"method":"eth_syncing","params":[2],"jsonrpc":"2.0"}`)))
+	assert.Equal(t, "glob:eth_syncing[2]", <-handled)
+
+	assert.Nil(t, server.Write([]byte(`{"method":"net_peers","jsonrpc":"2.0"}`)))
+	assert.Equal(t, "catchAll:net_peers", <-handled)
+
+	// unregistered handlers are no longer called
+	unregister()
+	unregister()
+	assert.Nil(t, server.Write([]byte(`{"method":"eth_newHeads","params":[3],"jsonrpc":"2.0"}`)))
+	assert.Equal(t, "glob:eth_newHeads[3]", <-handled)
+	assert.Equal(t, "after:eth_newHeads[3]", <-handled)
+}
+
+func TestClientPool_OnNotification(t *testing.T) {
+	backends := []*backend{newBackend("a"), newBackend("b")}
+	backends[0].server.Register("announce", announce)
+	backends[1].server.Register("announce", announce)
+
+	pool := jsonrpc.NewClientPool([]jsonrpc.Dialer{backends[0].dialer, backends[1].dialer})
+	defer pool.Close()
+	assert.Nil(t, pool.Connect())
+
+	handled := make(chan string, 4)
+	unregister, err := pool.OnNotification("announced", func(method string, params json.RawMessage) {
+		var name string
+		assert.Nil(t, json.Unmarshal(params, &name))
+		handled <- name
+	})
+	assert.Nil(t, err)
+
+	// handlers are registered with every member
+	var resp jsonrpc.Response
+	assert.Nil(t, pool.Send(*newRequest("announce", "first"), &resp))
+	assert.Nil(t, pool.Send(*newRequest("announce", "second"), &resp))
+	assert.ElementsMatch(t, []string{"first", "second"}, []string{<-handled, <-handled})
+
+	unregister()
+	pool.SetRequestHandler(func(req jsonrpc.Request) {
+		handled <- "unrouted"
+	})
+	assert.Nil(t, pool.Send(*newRequest("announce", "third"), &resp))
+	assert.Equal(t, "unrouted", <-handled)
+}
+
+// announce notifies the caller of the params it was called with.
+func announce(ctx context.Context, req jsonrpc.Request) (any, error) {
+	conn := jsonrpc.ConnectionInfoFrom(ctx).Conn
+	notification, err := json.Marshal(jsonrpc.Request{Method: "announced", Params: req.Params, Version: "2.0"})
+	if err != nil {
+		return nil, err
+	}
+	return true, conn.Write(notification)
+}
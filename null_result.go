@@ -0,0 +1,32 @@
+package jsonrpc
+
+import (
+	"bytes"
+
+	"github.com/juju/errors"
+)
+
+var (
+	// ErrNullResult is returned by Response.UnmarshalResult when the result is null, if enabled
+	// with WithNullResultError.
+	ErrNullResult = errors.ConstError("result is null")
+	// ErrMissingResult is returned by Response.UnmarshalResult when a response has neither a result
+	// nor an error.
+	ErrMissingResult = errors.ConstError("response has neither a result nor an error")
+)
+
+// WithNullResultError makes Response.UnmarshalResult, and the helpers built on it such as
+// CollectTyped, return ErrNullResult for a null result, rather than returning nil and leaving the
+// target unchanged. Servers commonly answer with null when nothing was found, e.g.
+// eth_getTransactionByHash for an unknown hash, which is otherwise easily mistaken for a zero value.
+func WithNullResultError(enabled bool) ClientOption {
+	return func(opts *ClientOptions) {
+		opts.NullResultError = enabled
+	}
+}
+
+// IsNull returns true if the result is present and null, as opposed to absent, as it is in error
+// responses.
+func (r *Response) IsNull() bool {
+	return r.Error == nil && bytes.Equal(bytes.TrimSpace(r.Result), []byte("null"))
+}
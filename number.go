@@ -0,0 +1,38 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"github.com/juju/errors"
+)
+
+// WithUseNumber decodes numbers as json.Number, instead of float64, when the results, ids and
+// params of messages received by the client are unmarshalled into interface values, so that
+// integers beyond 2^53, such as Ethereum quantities, keep their precision. Ids are always
+// correlated using their raw json, so are unaffected either way.
+func WithUseNumber(useNumber bool) ClientOption {
+	return func(opts *ClientOptions) {
+		opts.UseNumber = useNumber
+	}
+}
+
+// unmarshal decodes data into v as json.Unmarshal does, decoding numbers held in interface values
+// as json.Number if useNumber is set.
+func unmarshal(data []byte, v any, useNumber bool) error {
+	if !useNumber {
+		return json.Unmarshal(data, v)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	// as with json.Unmarshal, anything after the value is invalid
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("invalid data after top-level value")
+	}
+	return nil
+}
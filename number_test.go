@@ -0,0 +1,64 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+
+	"github.com/41north/jsonrpc.go"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_UseNumber(t *testing.T) {
+	// 2^53 + 1, the smallest integer a float64 cannot represent
+	const big = 9007199254740993
+
+	server := jsonrpc.NewServer()
+	server.Register("echo", func(ctx context.Context, req jsonrpc.Request) (any, error) {
+		return req.Params, nil
+	})
+
+	for _, useNumber := range []bool{false, true} {
+		clientConn, serverConn := net.Pipe()
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			_ = server.Serve(ctx, jsonrpc.NewStreamConnection(serverConn, jsonrpc.FramingNewline))
+		}()
+
+		client := jsonrpc.NewClientWithConnection(
+			jsonrpc.NewStreamConnection(clientConn, jsonrpc.FramingNewline),
+			jsonrpc.WithUseNumber(useNumber),
+		)
+		assert.Nil(t, client.Connect())
+
+		// the id is correlated exactly either way
+		var resp jsonrpc.Response
+		assert.Nil(t, client.Send(*newRequest("echo", map[string]any{"value": uint64(big)}, jsonrpc.RequestNumericId(big)), &resp))
+
+		var id any
+		assert.Nil(t, resp.UnmarshalId(&id))
+		var result map[string]any
+		assert.Nil(t, resp.UnmarshalResult(&result))
+
+		if useNumber {
+			assert.Equal(t, json.Number("9007199254740993"), id)
+			assert.Equal(t, json.Number("9007199254740993"), result["value"])
+		} else {
+			// precision is lost
+			assert.Equal(t, float64(9007199254740992), id)
+			assert.Equal(t, float64(9007199254740992), result["value"])
+		}
+
+		// typed results are unaffected
+		var typed struct {
+			Value uint64 `json:"value"`
+		}
+		assert.Nil(t, resp.UnmarshalResult(&typed))
+		assert.Equal(t, uint64(big), typed.Value)
+
+		_ = client.Close()
+		cancel()
+	}
+}
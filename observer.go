@@ -0,0 +1,44 @@
+package jsonrpc
+
+import "context"
+
+// Observer is notified of the requests sent and responses received by a client, for example to
+// collect metrics. Sizes are the length in bytes of each message's json as sent or received on the
+// wire, with batches reported element by element. Responses are reported with the method of the
+// request they correspond to. Implementations must be safe for concurrent use.
+type Observer interface {
+	OnRequest(method string, size int)
+	OnResponse(method string, size int)
+}
+
+// ContextObserver is an Observer which is also passed the context of the call each message belongs
+// to, from which it can read values attached by the caller, see ContextWithMetadata. The context is
+// the one passed to SendContext or SendBatch, or context.Background for calls which take none, such
+// as SendAsync. When an observer implements ContextObserver only its context methods are called.
+type ContextObserver interface {
+	Observer
+	OnRequestContext(ctx context.Context, method string, size int)
+	OnResponseContext(ctx context.Context, method string, size int)
+}
+
+// observeRequest notifies observer, if any, of a request.
+func observeRequest(ctx context.Context, observer Observer, method string, size int) {
+	switch o := observer.(type) {
+	case nil:
+	case ContextObserver:
+		o.OnRequestContext(ctx, method, size)
+	default:
+		o.OnRequest(method, size)
+	}
+}
+
+// observeResponse notifies observer, if any, of a response.
+func observeResponse(ctx context.Context, observer Observer, method string, size int) {
+	switch o := observer.(type) {
+	case nil:
+	case ContextObserver:
+		o.OnResponseContext(ctx, method, size)
+	default:
+		o.OnResponse(method, size)
+	}
+}
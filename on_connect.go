@@ -0,0 +1,53 @@
+package jsonrpc
+
+import (
+	"context"
+)
+
+// ConnectHook performs an initialization exchange with the server, such as authenticating or
+// negotiating a protocol version, see WithOnConnect.
+type ConnectHook = func(ctx context.Context, c Client) error
+
+// WithOnConnect runs hook each time Connect establishes a connection, before the client is
+// considered connected. Every other send, whether held by the offline queue, waiting on a lazy
+// connect or written directly, only proceeds once it returns. The client passed to hook writes
+// directly to the new connection, so its calls are not queued, batched or held behind the connect
+// in progress. If hook returns an error the connection is closed and the attempt fails, to be
+// retried as set with WithConnectRetry. Connect returns the error if no attempt succeeds, leaving
+// the client open so that it can be connected again.
+func WithOnConnect(hook ConnectHook) ClientOption {
+	return func(opts *ClientOptions) {
+		opts.OnConnect = hook
+	}
+}
+
+// handshakeClient is the view of a client given to a ConnectHook, its sends are direct.
+type handshakeClient struct {
+	*client
+}
+
+func (h *handshakeClient) Send(req Request, resp *Response) error {
+	return h.sendContext(context.Background(), req, resp, true)
+}
+
+func (h *handshakeClient) SendContext(ctx context.Context, req Request, resp *Response) error {
+	return h.sendContext(ctx, req, resp, true)
+}
+
+func (h *handshakeClient) SendAsync(req Request) ResponseFuture {
+	future, _ := h.sendAsync(req, PriorityNormal, true)
+	return future
+}
+
+func (h *handshakeClient) SendBatch(ctx context.Context, batch BatchRequest) []ResponseFuture {
+	return h.sendBatch(ctx, batch, true)
+}
+
+func (h *handshakeClient) NotifyBatch(reqs []Request) error {
+	return h.notifyBatch(reqs, true)
+}
+
+// Connect is a no-op, the hook is called once the connection has been established.
+func (h *handshakeClient) Connect() error {
+	return nil
+}
@@ -0,0 +1,172 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"net"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/41north/jsonrpc.go"
+	"github.com/41north/jsonrpc.go/testutil"
+
+	"github.com/juju/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_OnConnect(t *testing.T) {
+	var mu sync.Mutex
+	var methods []string
+
+	server := jsonrpc.NewServer()
+	server.Use(func(next jsonrpc.Handler) jsonrpc.Handler {
+		return func(ctx context.Context, req jsonrpc.Request) (any, error) {
+			mu.Lock()
+			methods = append(methods, req.Method)
+			mu.Unlock()
+			return next(ctx, req)
+		}
+	})
+	server.Register("auth", echo)
+	server.Register("echo", echo)
+
+	clientConn, serverConn := net.Pipe()
+	go server.Serve(context.Background(), jsonrpc.NewStreamConnection(serverConn, jsonrpc.FramingNewline))
+
+	client := jsonrpc.NewClientWithConnection(
+		jsonrpc.NewStreamConnection(clientConn, jsonrpc.FramingNewline),
+		jsonrpc.WithOfflineQueue(10, 0),
+		jsonrpc.WithOnConnect(func(ctx context.Context, c jsonrpc.Client) error {
+			var resp jsonrpc.Response
+			return c.SendContext(ctx, *newRequest("auth", []string{"token"}), &resp)
+		}),
+	)
+	defer client.Close()
+
+	// queued before connecting, it is only sent once the hook has completed
+	future := client.SendAsync(*newRequest("echo", []int{1}))
+
+	assert.Nil(t, client.Connect())
+
+	_, err := (<-future.Get()).Unwrap()
+	assert.Nil(t, err)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"auth", "echo"}, methods)
+}
+
+func TestClient_OnConnectFailure(t *testing.T) {
+	server := jsonrpc.NewServer()
+	server.Register("echo", echo)
+
+	var dials atomic.Int32
+	client := jsonrpc.NewClient(
+		serverDialer(server, func(n int32) bool { dials.Store(n); return false }),
+		jsonrpc.WithOnConnect(func(ctx context.Context, c jsonrpc.Client) error {
+			return errors.New("unauthorized")
+		}),
+	)
+	defer client.Close()
+
+	err := client.Connect()
+	assert.ErrorContains(t, err, "unauthorized")
+
+	// the client stays open, without a connection
+	_, err = (<-client.SendAsync(*newRequest("echo", nil)).Get()).Unwrap()
+	assert.ErrorIs(t, err, jsonrpc.ErrNotConnected)
+	assert.ErrorContains(t, client.Connect(), "unauthorized")
+	assert.Equal(t, int32(2), dials.Load())
+}
+
+func TestClient_OnConnectRetry(t *testing.T) {
+	server := jsonrpc.NewServer()
+	server.Register("auth", echo)
+	server.Register("echo", echo)
+
+	var dials, attempts atomic.Int32
+	clock := testutil.NewClock(time.Time{})
+	client := jsonrpc.NewClient(
+		serverDialer(server, func(n int32) bool { dials.Store(n); return false }),
+		jsonrpc.WithClock(clock),
+		jsonrpc.WithConnectRetry(2, jsonrpc.ConstantBackoff(time.Second)),
+		jsonrpc.WithOnConnect(func(ctx context.Context, c jsonrpc.Client) error {
+			var resp jsonrpc.Response
+			if err := c.SendContext(ctx, *newRequest("auth", nil), &resp); err != nil {
+				return err
+			}
+			if attempts.Add(1) == 1 {
+				return errors.New("unauthorized")
+			}
+			return nil
+		}),
+	)
+	defer client.Close()
+
+	connected := make(chan error, 1)
+	go func() { connected <- client.Connect() }()
+
+	// a failed hook is retried after the backoff, on a new connection
+	clock.BlockUntil(1)
+	assert.Equal(t, int32(1), dials.Load())
+	clock.Advance(time.Second)
+
+	assert.Nil(t, <-connected)
+	assert.Equal(t, int32(2), dials.Load())
+	assert.Equal(t, int32(2), attempts.Load())
+
+	var resp jsonrpc.Response
+	assert.Nil(t, client.Send(*newRequest("echo", "hello"), &resp))
+	assert.Nil(t, resp.Error)
+}
+
+func TestClient_OnConnectGatesSends(t *testing.T) {
+	var mu sync.Mutex
+	var methods []string
+
+	server := jsonrpc.NewServer()
+	server.Use(func(next jsonrpc.Handler) jsonrpc.Handler {
+		return func(ctx context.Context, req jsonrpc.Request) (any, error) {
+			mu.Lock()
+			methods = append(methods, req.Method)
+			mu.Unlock()
+			return next(ctx, req)
+		}
+	})
+	server.Register("auth", echo)
+	server.Register("echo", echo)
+
+	var client jsonrpc.Client
+	sent := make(chan error, 1)
+	client = jsonrpc.NewClient(
+		serverDialer(server, nil),
+		jsonrpc.WithOnConnect(func(ctx context.Context, c jsonrpc.Client) error {
+			var resp jsonrpc.Response
+			if err := c.SendContext(ctx, *newRequest("auth", []int{1}), &resp); err != nil {
+				return err
+			}
+
+			// a send made directly on the client waits for the hook to return
+			started := make(chan struct{})
+			go func() {
+				close(started)
+				var resp jsonrpc.Response
+				sent <- client.Send(*newRequest("echo", nil), &resp)
+			}()
+			<-started
+			runtime.Gosched()
+
+			return c.SendContext(ctx, *newRequest("auth", []int{2}), &resp)
+		}),
+	)
+	defer client.Close()
+
+	assert.Nil(t, client.Connect())
+	assert.Nil(t, <-sent)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"auth", "auth", "echo"}, methods)
+}
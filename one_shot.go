@@ -0,0 +1,216 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/juju/errors"
+)
+
+// DefaultOneShotMaxConns is the default number of one shot connections which may be open at once.
+const DefaultOneShotMaxConns = 4
+
+// OneShotMaxConns sets the number of connections which may be open at once, and so the number of
+// requests which can be exchanged concurrently. Messages written beyond it wait for a connection
+// to close before they are sent, so WithMaxInFlight should be used to bound how many can wait. The
+// default is DefaultOneShotMaxConns.
+func OneShotMaxConns(n int) OneShotOption {
+	return func(opts *OneShotOptions) {
+		opts.MaxConns = n
+	}
+}
+
+type OneShotOption = func(opts *OneShotOptions)
+
+type OneShotOptions struct {
+	MaxConns int
+}
+
+func DefaultOneShotOptions() OneShotOptions {
+	return OneShotOptions{
+		MaxConns: DefaultOneShotMaxConns,
+	}
+}
+
+// OneShotDialer adapts dialer for servers which close the connection after every response. The
+// connection it creates dials a new connection with dialer for each message written, writes the
+// message, reads its response and closes, so that the client can be used as it would be with a
+// persistent connection. Messages which expect no response, such as notifications, are closed
+// once written. Several requests can share a connection by sending them as a batch, e.g. with
+// WithAutoBatch.
+//
+// Notifications from the server cannot be received, so Subscribe and SubscribePattern fail with an
+// error matching errors.NotSupported. If a connection fails before the response is read, the
+// requests it carried fail with a *TransportError, as they do with the http transport.
+func OneShotDialer(dialer Dialer, options ...OneShotOption) Dialer {
+	opts := DefaultOneShotOptions()
+	for _, opt := range options {
+		opt(&opts)
+	}
+	if opts.MaxConns < 1 {
+		opts.MaxConns = 1
+	}
+	return &oneShotDialer{dialer: dialer, opts: opts}
+}
+
+type oneShotDialer struct {
+	dialer Dialer
+	opts   OneShotOptions
+}
+
+func (d *oneShotDialer) Dial() (Connection, error) {
+	return d.DialContext(context.Background())
+}
+
+func (d *oneShotDialer) DialContext(ctx context.Context) (Connection, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	connCtx, cancel := context.WithCancel(context.Background())
+	return &oneShotConnection{
+		dialer:    d,
+		ctx:       connCtx,
+		cancel:    cancel,
+		slots:     make(chan struct{}, d.opts.MaxConns),
+		responses: make(chan oneShotResponse, 16),
+	}, nil
+}
+
+// isOneShot returns true if dialer creates one shot connections.
+func isOneShot(dialer Dialer) bool {
+	_, ok := dialer.(*oneShotDialer)
+	return ok
+}
+
+// oneShotConnection exchanges each message written over a connection of its own, queueing the
+// responses to be returned by Read.
+type oneShotConnection struct {
+	dialer    *oneShotDialer
+	ctx       context.Context
+	cancel    context.CancelFunc
+	slots     chan struct{}
+	responses chan oneShotResponse
+	closeOnce sync.Once
+}
+
+// oneShotResponse is a response read from a one shot connection, or the error of an exchange which
+// failed.
+type oneShotResponse struct {
+	body []byte
+	err  error
+}
+
+// Write exchanges data over a connection of its own in the background, once one is available.
+func (o *oneShotConnection) Write(data []byte) error {
+	if o.ctx.Err() != nil {
+		return ErrClosed
+	}
+	go func() {
+		select {
+		case o.slots <- struct{}{}:
+		case <-o.ctx.Done():
+			return
+		}
+		defer func() {
+			<-o.slots
+		}()
+		o.exchange(data)
+	}()
+	return nil
+}
+
+// exchange dials a connection, writes data and reads the response, if one is expected.
+func (o *oneShotConnection) exchange(data []byte) {
+	body, err := o.roundTrip(data)
+	resp := oneShotResponse{body: body}
+	if err != nil {
+		if o.ctx.Err() != nil {
+			// closed
+			return
+		}
+		resp.err = &TransportError{Cause: err, data: data}
+	} else if body == nil {
+		return
+	}
+	select {
+	case o.responses <- resp:
+	case <-o.ctx.Done():
+	}
+}
+
+func (o *oneShotConnection) roundTrip(data []byte) ([]byte, error) {
+	conn, err := o.dialer.dialer.DialContext(o.ctx)
+	if err != nil {
+		return nil, &DialError{Cause: err}
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		// closing unblocks a read which is waiting on a connection that has been abandoned
+		select {
+		case <-o.ctx.Done():
+		case <-done:
+		}
+		_ = conn.Close()
+	}()
+
+	if err := conn.Write(data); err != nil {
+		return nil, err
+	}
+	if !expectsResponse(data) {
+		return nil, nil
+	}
+	for {
+		body, err := conn.Read()
+		if err != nil {
+			return nil, errors.Annotate(err, "connection closed before the response was read")
+		}
+		if isBatch(body) {
+			return body, nil
+		}
+		var resp Response
+		if err := json.Unmarshal(body, &resp); err != nil || resp.Kind() != KindNotification {
+			// the client logs anything which cannot be parsed
+			return body, nil
+		}
+		// notifications cannot be delivered, as there are no subscriptions
+	}
+}
+
+// expectsResponse returns true if data is a request, or a batch containing a request, with an id.
+func expectsResponse(data []byte) bool {
+	var requests []Request
+	if isBatch(data) {
+		if err := json.Unmarshal(data, &requests); err != nil {
+			return true
+		}
+	} else {
+		var req Request
+		if err := json.Unmarshal(data, &req); err != nil {
+			return true
+		}
+		requests = append(requests, req)
+	}
+	for _, req := range requests {
+		if req.Id != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (o *oneShotConnection) Read() ([]byte, error) {
+	select {
+	case resp := <-o.responses:
+		return resp.body, resp.err
+	case <-o.ctx.Done():
+		return nil, ErrClosed
+	}
+}
+
+func (o *oneShotConnection) Close() error {
+	o.closeOnce.Do(o.cancel)
+	return nil
+}
@@ -0,0 +1,109 @@
+package jsonrpc_test
+
+import (
+	"encoding/json"
+	"net"
+	"sync/atomic"
+	"testing"
+
+	"github.com/41north/jsonrpc.go"
+
+	"github.com/juju/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// oneShotServer returns a dialer for a server which replies to a single request with its params, or
+// closes without replying to requests for "drop", then closes the connection. Each connection
+// is held open until release is closed, signalling waiting, if set, once it has read its request.
+func oneShotServer(release <-chan struct{}, waiting chan<- struct{}) (jsonrpc.Dialer, *atomic.Int32) {
+	var dials atomic.Int32
+	dialer := jsonrpc.DialFunc(func() (jsonrpc.Connection, error) {
+		dials.Add(1)
+		clientConn, serverConn := net.Pipe()
+		go func() {
+			conn := jsonrpc.NewStreamConnection(serverConn, jsonrpc.FramingNewline)
+			defer conn.Close()
+
+			data, err := conn.Read()
+			if err != nil {
+				return
+			}
+			var req jsonrpc.Request
+			if err := json.Unmarshal(data, &req); err != nil || req.Method == "drop" {
+				return
+			}
+			if waiting != nil {
+				waiting <- struct{}{}
+			}
+			<-release
+			data, _ = json.Marshal(jsonrpc.Response{Id: req.Id, Result: req.Params, Version: req.Version})
+			_ = conn.Write(data)
+		}()
+		return jsonrpc.NewStreamConnection(clientConn, jsonrpc.FramingNewline), nil
+	})
+	return dialer, &dials
+}
+
+func TestOneShotDialer(t *testing.T) {
+	release := make(chan struct{})
+	waiting := make(chan struct{}, 6)
+	dialer, dials := oneShotServer(release, waiting)
+
+	client := jsonrpc.NewClient(jsonrpc.OneShotDialer(dialer, jsonrpc.OneShotMaxConns(2)))
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	var futures []jsonrpc.ResponseFuture
+	for i := 0; i < 6; i++ {
+		futures = append(futures, client.SendAsync(*newRequest("echo", i)))
+	}
+
+	// only two connections are opened while the responses are held back, as neither can close
+	<-waiting
+	<-waiting
+	assert.Equal(t, int32(2), dials.Load())
+	close(release)
+
+	for i, future := range futures {
+		resp, err := (<-future.Get()).Unwrap()
+		assert.Nil(t, err)
+		var result int
+		assert.Nil(t, resp.UnmarshalResult(&result))
+		assert.Equal(t, i, result)
+	}
+
+	// every request was sent over a connection of its own
+	assert.Equal(t, int32(6), dials.Load())
+}
+
+func TestOneShotDialer_ClosedBeforeResponse(t *testing.T) {
+	release := make(chan struct{})
+	close(release)
+	dialer, _ := oneShotServer(release, nil)
+
+	client := jsonrpc.NewClient(jsonrpc.OneShotDialer(dialer))
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	var resp jsonrpc.Response
+	err := client.Send(*newRequest("drop", nil), &resp)
+	assert.ErrorIs(t, err, jsonrpc.ErrTransport)
+	assert.ErrorContains(t, err, "connection closed before the response was read")
+	assert.True(t, jsonrpc.IsRetryable(err))
+
+	// the client carries on
+	assert.Nil(t, client.Send(*newRequest("echo", "ok"), &resp))
+	assert.Equal(t, json.RawMessage(`"ok"`), resp.Result)
+}
+
+func TestOneShotDialer_Subscribe(t *testing.T) {
+	dialer, _ := oneShotServer(nil, nil)
+	client := jsonrpc.NewClient(jsonrpc.OneShotDialer(dialer))
+	defer client.Close()
+
+	_, err := client.Subscribe("update")
+	assert.ErrorIs(t, err, errors.NotSupported)
+
+	_, err = client.SubscribePattern("update/*")
+	assert.ErrorIs(t, err, errors.NotSupported)
+}
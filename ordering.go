@@ -0,0 +1,85 @@
+package jsonrpc
+
+import (
+	"context"
+	"sync"
+)
+
+// RequestOrderingKey tags the request with a key which orders it with the other requests sharing the
+// key, for sequences of dependent calls such as fetching a nonce and then sending a transaction.
+// Requests with the same key are sent on the same connection, in the order they were submitted, and
+// one at a time: each is only sent once the previous request with the key has completed, so the
+// server executes them in order however it schedules its handlers, and auto batching and the
+// priority queue cannot reorder them. Requests with different keys, or none, are unaffected.
+//
+// A request sent with Send or SendContext holds its place until it completes, including any retries
+// made by an interceptor such as RetryInterceptor, so a retried request is never overtaken by a
+// later request with the same key.
+func RequestOrderingKey(key string) RequestOption {
+	return func(opts *RequestOptions) error {
+		opts.OrderingKey = key
+		return nil
+	}
+}
+
+// orderingKeys sequences the requests which share an ordering key.
+type orderingKeys struct {
+	mu sync.Mutex
+	// tails holds, for each key, a channel closed once the last request to enter has completed
+	tails map[string]chan struct{}
+}
+
+func newOrderingKeys() *orderingKeys {
+	return &orderingKeys{tails: make(map[string]chan struct{})}
+}
+
+// closedChan is returned by enter when there is nothing to wait for.
+var closedChan = func() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}()
+
+// enter takes a place in the sequence of key, returning a channel which is closed once every request
+// which entered before has completed, and the function to call once this request has completed.
+func (o *orderingKeys) enter(key string) (<-chan struct{}, func()) {
+	ch := make(chan struct{})
+
+	o.mu.Lock()
+	wait, ok := o.tails[key]
+	o.tails[key] = ch
+	o.mu.Unlock()
+
+	if !ok {
+		wait = closedChan
+	}
+	var once sync.Once
+	return wait, func() {
+		once.Do(func() {
+			o.mu.Lock()
+			if o.tails[key] == ch {
+				delete(o.tails, key)
+			}
+			o.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// sendOrdered calls send once the requests which entered the sequence of key before it have
+// completed, holding the place of the request until send returns. If ctx is done first the error of
+// ctx is returned, and the place is released once the requests before it have completed.
+func (o *orderingKeys) sendOrdered(ctx context.Context, key string, send func() error) error {
+	wait, done := o.enter(key)
+	select {
+	case <-wait:
+	case <-ctx.Done():
+		go func() {
+			<-wait
+			done()
+		}()
+		return ctx.Err()
+	}
+	defer done()
+	return send()
+}
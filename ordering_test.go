@@ -0,0 +1,120 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/41north/jsonrpc.go"
+	"github.com/41north/jsonrpc.go/testutil"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_OrderingKey(t *testing.T) {
+	steps := map[string]chan struct{}{
+		"a1": make(chan struct{}),
+		"a2": make(chan struct{}),
+		"b1": make(chan struct{}),
+	}
+	received := make(chan string, len(steps))
+
+	server := jsonrpc.NewServer()
+	server.Register("step", func(ctx context.Context, req jsonrpc.Request) (any, error) {
+		var name string
+		if err := req.UnmarshalParams(&name); err != nil {
+			return nil, err
+		}
+		received <- name
+		<-steps[name]
+		return name, nil
+	})
+
+	client := jsonrpc.NewClient(serverDialer(server, nil))
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	futures := []jsonrpc.ResponseFuture{
+		client.SendAsync(*newRequest("step", "a1", jsonrpc.RequestOrderingKey("a"))),
+		client.SendAsync(*newRequest("step", "a2", jsonrpc.RequestOrderingKey("a"))),
+		client.SendAsync(*newRequest("step", "b1", jsonrpc.RequestOrderingKey("b"))),
+	}
+
+	// requests with different keys run in parallel
+	assert.ElementsMatch(t, []string{"a1", "b1"}, []string{<-received, <-received})
+
+	// whilst those with the same key wait for the request before them to complete, without being
+	// sent
+	assert.Equal(t, map[string]int{"": 2}, client.InFlightByTenant())
+	select {
+	case name := <-received:
+		assert.Fail(t, "received out of order", name)
+	default:
+	}
+	close(steps["a1"])
+	assert.Equal(t, "a2", <-received)
+
+	close(steps["a2"])
+	close(steps["b1"])
+	_, err := jsonrpc.WaitAll(context.Background(), futures)
+	assert.Nil(t, err)
+}
+
+func TestClient_OrderingKeyRetry(t *testing.T) {
+	server, calls := flakyServer(1, jsonrpc.ErrInternal)
+	clock := testutil.NewClock(time.Time{})
+	client := jsonrpc.NewClient(
+		serverDialer(server, nil),
+		jsonrpc.WithInterceptors(jsonrpc.RetryInterceptor(
+			jsonrpc.WithIdempotentMethods("read"),
+			jsonrpc.WithRetryBackoff(jsonrpc.ConstantBackoff(time.Second)),
+			jsonrpc.WithRetryClock(clock),
+		)),
+	)
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	read := make(chan error, 1)
+	go func() {
+		var resp jsonrpc.Response
+		read <- client.Send(*newRequest("read", nil, jsonrpc.RequestOrderingKey("a")), &resp)
+	}()
+
+	// the first attempt has failed and the retry is waiting
+	clock.BlockUntil(1)
+	assert.Equal(t, 1, calls("read"))
+
+	// a later request with the same key is not sent ahead of the retry
+	future := client.SendAsync(*newRequest("write", nil, jsonrpc.RequestOrderingKey("a")))
+	assert.Empty(t, client.InFlightByTenant())
+	assert.Equal(t, 0, calls("write"))
+
+	clock.Advance(time.Second)
+	assert.Nil(t, <-read)
+	assert.Equal(t, 2, calls("read"))
+
+	_, err := (<-future.Get()).Unwrap()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, calls("write"))
+}
+
+func TestClientPool_OrderingKey(t *testing.T) {
+	backends := []*backend{newBackend("a"), newBackend("b"), newBackend("c")}
+	pool := jsonrpc.NewClientPool([]jsonrpc.Dialer{backends[0].dialer, backends[1].dialer, backends[2].dialer})
+	defer pool.Close()
+	assert.Nil(t, pool.Connect())
+
+	// requests with the same key are sent to the same member
+	names := make(map[string]bool)
+	for i := 0; i < 6; i++ {
+		var resp jsonrpc.Response
+		assert.Nil(t, pool.Send(*newRequest("name", nil, jsonrpc.RequestOrderingKey("a")), &resp))
+		var name string
+		assert.Nil(t, resp.UnmarshalResult(&name))
+		names[name] = true
+	}
+	assert.Len(t, names, 1)
+
+	// whilst others are spread across them
+	assert.Len(t, served(t, pool, 6), 3)
+}
@@ -0,0 +1,137 @@
+package jsonrpc
+
+import (
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+var (
+	ErrQueueOverflow = errors.ConstError("offline queue is full")
+	ErrQueueExpired  = errors.ConstError("request expired in offline queue")
+)
+
+// WithOfflineQueue holds requests, batches and notifications sent before the client has connected,
+// instead of failing them, and sends them in order once Connect succeeds. At most maxEntries are
+// held, each batch counting as one, further sends failing with ErrQueueOverflow, and entries held
+// for longer than maxAge fail with ErrQueueExpired. A maxAge of zero means entries never expire.
+// Closing the client fails anything still queued with ErrClosed.
+//
+// The client does not reconnect, so the queue only applies before the first connection.
+func WithOfflineQueue(maxEntries int, maxAge time.Duration) ClientOption {
+	return func(opts *ClientOptions) {
+		opts.OfflineQueueMaxEntries = maxEntries
+		opts.OfflineQueueMaxAge = maxAge
+	}
+}
+
+// outboxEntry is a message held until the client connects. request is nil for notifications and
+// batches, the requests of a batch are held in batch and it is sent by send.
+type outboxEntry struct {
+	key     string
+	data    []byte
+	tenant  string
+	request *inFlightRequest
+	batch   []*inFlightRequest
+	send    func()
+	timer   Timer
+}
+
+func (e *outboxEntry) fail(err error) {
+	if e.request != nil {
+		e.request.fail(err)
+	}
+	for _, request := range e.batch {
+		request.fail(err)
+	}
+}
+
+type outbox struct {
+	mu         sync.Mutex
+	maxEntries int
+	maxAge     time.Duration
+	clock      Clock
+	entries    []*outboxEntry
+	// flushed is set once connected, after which nothing more is queued
+	flushed bool
+	closed  bool
+}
+
+func newOutbox(maxEntries int, maxAge time.Duration, clock Clock) *outbox {
+	return &outbox{maxEntries: maxEntries, maxAge: maxAge, clock: clock}
+}
+
+// offer queues entry if the client has not yet connected, returning false if it should instead be
+// sent immediately. If true is returned along with an error the entry was rejected.
+func (o *outbox) offer(entry *outboxEntry) (bool, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	switch {
+	case o.closed:
+		return true, ErrClosed
+	case o.flushed:
+		return false, nil
+	case len(o.entries) >= o.maxEntries:
+		return true, ErrQueueOverflow
+	}
+
+	if o.maxAge > 0 {
+		entry.timer = o.clock.AfterFunc(o.maxAge, func() {
+			o.expire(entry)
+		})
+	}
+	o.entries = append(o.entries, entry)
+	return true, nil
+}
+
+// expire removes entry from the queue and fails it, unless it has already been flushed.
+func (o *outbox) expire(entry *outboxEntry) {
+	o.mu.Lock()
+	found := false
+	for i, e := range o.entries {
+		if e == entry {
+			o.entries = append(o.entries[:i], o.entries[i+1:]...)
+			found = true
+			break
+		}
+	}
+	o.mu.Unlock()
+
+	if found {
+		entry.fail(ErrQueueExpired)
+	}
+}
+
+// flush passes every queued entry to send, in the order they were queued. Sends which race with the
+// flush wait for it to complete, so that ordering is preserved.
+func (o *outbox) flush(send func(entry *outboxEntry)) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.flushed = true
+	for _, entry := range o.entries {
+		if entry.timer != nil {
+			entry.timer.Stop()
+		}
+		send(entry)
+	}
+	o.entries = nil
+}
+
+// close fails any queued entries with ErrClosed.
+func (o *outbox) close() {
+	o.mu.Lock()
+	pending := o.entries
+	o.closed = true
+	o.entries = nil
+	o.mu.Unlock()
+
+	for _, entry := range pending {
+		if entry.timer != nil {
+			entry.timer.Stop()
+		}
+		entry.fail(ErrClosed)
+	}
+}
@@ -0,0 +1,111 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/41north/jsonrpc.go"
+	"github.com/41north/jsonrpc.go/testutil"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_OfflineQueue(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	server := jsonrpc.NewStreamConnection(serverConn, jsonrpc.FramingNewline)
+
+	client := jsonrpc.NewClientWithConnection(
+		jsonrpc.NewStreamConnection(clientConn, jsonrpc.FramingNewline),
+		jsonrpc.WithOfflineQueue(2, 0),
+	)
+	defer client.Close()
+
+	// sent before connecting, so they are queued
+	future := client.SendAsync(*newRequest("ping", nil, jsonrpc.RequestNumericId(1)))
+	assert.Nil(t, client.NotifyBatch([]jsonrpc.Request{{Method: "update"}}))
+
+	// the queue is full
+	_, err := (<-client.SendAsync(*newRequest("ping", nil, jsonrpc.RequestNumericId(2))).Get()).Unwrap()
+	assert.ErrorIs(t, err, jsonrpc.ErrQueueOverflow)
+
+	// the pipe is synchronous, so connect whilst the server reads
+	connected := make(chan error, 1)
+	go func() {
+		connected <- client.Connect()
+	}()
+
+	// the queue is flushed in order
+	data, err := server.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, "{\"id\":1,\"method\":\"ping\",\"jsonrpc\":\"2.0\"}", string(data))
+
+	data, err = server.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, "[{\"method\":\"update\",\"jsonrpc\":\"2.0\"}]", string(data))
+
+	assert.Nil(t, <-connected)
+
+	respBytes, err := json.Marshal(newResponse("pong", jsonrpc.ResponseNumericId(1)))
+	assert.Nil(t, err)
+	assert.Nil(t, server.Write(respBytes))
+
+	resp, err := (<-future.Get()).Unwrap()
+	assert.Nil(t, err)
+
+	var result string
+	assert.Nil(t, resp.UnmarshalResult(&result))
+	assert.Equal(t, "pong", result)
+}
+
+func TestClient_OfflineQueueExpiryAndClose(t *testing.T) {
+	clientConn, _ := net.Pipe()
+	clock := testutil.NewClock(time.Time{})
+	client := jsonrpc.NewClientWithConnection(
+		jsonrpc.NewStreamConnection(clientConn, jsonrpc.FramingNewline),
+		jsonrpc.WithClock(clock),
+		jsonrpc.WithOfflineQueue(10, time.Minute),
+	)
+
+	expired := client.SendAsync(*newRequest("ping", nil))
+	clock.Advance(59 * time.Second)
+	select {
+	case <-expired.Get():
+		t.Fatal("request expired early")
+	default:
+	}
+
+	clock.Advance(time.Second)
+	_, err := (<-expired.Get()).Unwrap()
+	assert.ErrorIs(t, err, jsonrpc.ErrQueueExpired)
+
+	// closing fails anything still queued
+	queued := client.SendAsync(*newRequest("ping", nil))
+	assert.Nil(t, client.Close())
+
+	_, err = (<-queued.Get()).Unwrap()
+	assert.ErrorIs(t, err, jsonrpc.ErrClosed)
+}
+
+func TestClient_OfflineQueueBatch(t *testing.T) {
+	client := jsonrpc.NewClient(serverDialer(endpoint("a", nil), nil), jsonrpc.WithOfflineQueue(1, 0))
+	defer client.Close()
+
+	// a batch takes a single entry
+	queued := client.SendBatch(context.Background(), jsonrpc.BatchRequest{Requests: []jsonrpc.TimedRequest{
+		{Request: *newRequest("name", nil)},
+		{Request: *newRequest("name", nil)},
+	}})
+	overflow := client.SendBatch(context.Background(), jsonrpc.BatchRequest{Requests: []jsonrpc.TimedRequest{
+		{Request: *newRequest("name", nil)},
+	}})
+	_, err := (<-overflow[0].Get()).Unwrap()
+	assert.ErrorIs(t, err, jsonrpc.ErrQueueOverflow)
+
+	assert.Nil(t, client.Connect())
+	responses, err := jsonrpc.WaitAll(context.Background(), queued)
+	assert.Nil(t, err)
+	assert.Len(t, responses, 2)
+}
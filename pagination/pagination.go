@@ -0,0 +1,147 @@
+// Package pagination iterates over the results of methods which return their results a page at a
+// time, feeding a cursor from each response into the params of the next call.
+package pagination
+
+import (
+	"context"
+
+	"github.com/41north/jsonrpc.go"
+
+	"github.com/juju/errors"
+)
+
+var ErrMaxPages = errors.ConstError("maximum number of pages reached")
+
+// Extractor returns the items held in a page along with the params with which to request the next
+// one, or done if it is the last page.
+type Extractor[T any] func(resp jsonrpc.Response) (items []T, nextParams any, done bool, err error)
+
+// WithMaxPages stops iteration with ErrMaxPages if more than n pages would be requested, as a
+// safety net against servers which never report the last page. Zero is unlimited.
+func WithMaxPages(n int) Option {
+	return func(opts *Options) {
+		opts.MaxPages = n
+	}
+}
+
+type Option = func(opts *Options)
+
+type Options struct {
+	MaxPages int
+}
+
+func DefaultOptions() Options {
+	return Options{}
+}
+
+// Iterator returns the items of successive pages, requesting each page once the items of the one
+// before have been consumed. It is used as a bufio.Scanner is:
+//
+//	it := pagination.Paginate(ctx, client, "list", params, extract)
+//	for it.Next() {
+//		item := it.Item()
+//	}
+//	if err := it.Err(); err != nil {
+//	}
+//
+// An Iterator must not be used concurrently.
+type Iterator[T any] struct {
+	ctx     context.Context
+	client  jsonrpc.Client
+	method  string
+	params  any
+	extract Extractor[T]
+	opts    Options
+
+	items []T
+	item  T
+	pages int
+	done  bool
+	err   error
+}
+
+// Paginate returns an iterator over the items of method, whose first page is requested with
+// params, and each subsequent page with the params returned for it by extract. Pages are requested
+// with SendContext and ctx. Error responses, failed requests, and errors returned by extract end
+// iteration, the error being returned by Err.
+func Paginate[T any](
+	ctx context.Context,
+	client jsonrpc.Client,
+	method string,
+	params any,
+	extract Extractor[T],
+	options ...Option,
+) *Iterator[T] {
+	opts := DefaultOptions()
+	for _, opt := range options {
+		opt(&opts)
+	}
+	return &Iterator[T]{
+		ctx:     ctx,
+		client:  client,
+		method:  method,
+		params:  params,
+		extract: extract,
+		opts:    opts,
+	}
+}
+
+// Next advances to the next item, requesting pages as needed. It returns false once every item
+// has been returned or an error has occurred, see Err.
+func (it *Iterator[T]) Next() bool {
+	for len(it.items) == 0 {
+		if it.done || it.err != nil {
+			return false
+		}
+		if it.err = it.fetch(); it.err != nil {
+			return false
+		}
+	}
+	it.item, it.items = it.items[0], it.items[1:]
+	return true
+}
+
+// Item returns the item Next advanced to.
+func (it *Iterator[T]) Item() T {
+	return it.item
+}
+
+// Err returns the error which ended iteration, if any.
+func (it *Iterator[T]) Err() error {
+	return it.err
+}
+
+// Pages returns the number of pages requested so far.
+func (it *Iterator[T]) Pages() int {
+	return it.pages
+}
+
+// fetch requests the next page.
+func (it *Iterator[T]) fetch() error {
+	if err := it.ctx.Err(); err != nil {
+		return err
+	}
+	if it.opts.MaxPages > 0 && it.pages >= it.opts.MaxPages {
+		return errors.Annotatef(ErrMaxPages, "%d pages of %s", it.pages, it.method)
+	}
+
+	req, err := jsonrpc.NewRequest(it.method, it.params)
+	if err != nil {
+		return err
+	}
+	var resp jsonrpc.Response
+	if err := it.client.SendContext(it.ctx, *req, &resp); err != nil {
+		return err
+	}
+	it.pages++
+	if resp.Error != nil {
+		return *resp.Error
+	}
+
+	items, next, done, err := it.extract(resp)
+	if err != nil {
+		return err
+	}
+	it.items, it.params, it.done = items, next, done
+	return nil
+}
@@ -0,0 +1,102 @@
+package pagination_test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/41north/jsonrpc.go"
+	"github.com/41north/jsonrpc.go/pagination"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type listParams struct {
+	Cursor int `json:"cursor"`
+}
+
+type listResult struct {
+	Items      []int `json:"items"`
+	NextCursor *int  `json:"nextCursor"`
+}
+
+// newClient returns a client of a server whose "list" method pages through the numbers below 7,
+// three at a time, failing for the cursor fail if it is positive.
+func newClient(t *testing.T, fail int) jsonrpc.Client {
+	server := jsonrpc.NewServer()
+	jsonrpc.Handle(server, "list", func(ctx context.Context, params listParams) (listResult, error) {
+		if fail > 0 && params.Cursor == fail {
+			return listResult{}, jsonrpc.ErrInternal
+		}
+		var result listResult
+		for i := params.Cursor; i < params.Cursor+3 && i < 7; i++ {
+			result.Items = append(result.Items, i)
+		}
+		if next := params.Cursor + 3; next < 7 {
+			result.NextCursor = &next
+		}
+		return result, nil
+	})
+
+	clientConn, serverConn := net.Pipe()
+	go func() {
+		_ = server.Serve(context.Background(), jsonrpc.NewStreamConnection(serverConn, jsonrpc.FramingNewline))
+	}()
+	client := jsonrpc.NewClientWithConnection(jsonrpc.NewStreamConnection(clientConn, jsonrpc.FramingNewline))
+	assert.Nil(t, client.Connect())
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+func extract(resp jsonrpc.Response) ([]int, any, bool, error) {
+	var result listResult
+	if err := resp.UnmarshalResult(&result); err != nil {
+		return nil, nil, false, err
+	}
+	if result.NextCursor == nil {
+		return result.Items, nil, true, nil
+	}
+	return result.Items, listParams{Cursor: *result.NextCursor}, false, nil
+}
+
+func collect(it *pagination.Iterator[int]) []int {
+	var items []int
+	for it.Next() {
+		items = append(items, it.Item())
+	}
+	return items
+}
+
+func TestPaginate(t *testing.T) {
+	client := newClient(t, 0)
+
+	it := pagination.Paginate(context.Background(), client, "list", listParams{}, extract)
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5, 6}, collect(it))
+	assert.Nil(t, it.Err())
+	assert.Equal(t, 3, it.Pages())
+
+	// iteration stops at the page limit
+	it = pagination.Paginate(context.Background(), client, "list", listParams{}, extract, pagination.WithMaxPages(2))
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5}, collect(it))
+	assert.ErrorIs(t, it.Err(), pagination.ErrMaxPages)
+	assert.Equal(t, 2, it.Pages())
+
+	// and when the context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	it = pagination.Paginate(ctx, client, "list", listParams{}, extract)
+	assert.True(t, it.Next())
+	cancel()
+	assert.Equal(t, []int{1, 2}, collect(it))
+	assert.ErrorIs(t, it.Err(), context.Canceled)
+}
+
+func TestPaginate_Error(t *testing.T) {
+	client := newClient(t, 3)
+
+	// the items before the error are returned, then iteration stops
+	it := pagination.Paginate(context.Background(), client, "list", listParams{}, extract)
+	assert.Equal(t, []int{0, 1, 2}, collect(it))
+	assert.Equal(t, jsonrpc.ErrInternal, it.Err())
+	assert.False(t, it.Next())
+	assert.Equal(t, 2, it.Pages())
+}
@@ -0,0 +1,123 @@
+package jsonrpc
+
+import (
+	"context"
+
+	"github.com/juju/errors"
+)
+
+// PipelineResult is the outcome of a request sent by Pipeline. Index is the position of the
+// request in the input. Err holds the error the request failed with or, for an error response, the
+// Error from the server, in which case Response is set too.
+type PipelineResult struct {
+	Index    int
+	Request  Request
+	Response *Response
+	Err      error
+}
+
+// WithPipelineAbortOnError stops the pipeline at the first request to fail, or to receive an error
+// response, once its result has been delivered. By default every request is sent and each result
+// carries its own error.
+func WithPipelineAbortOnError() PipelineOption {
+	return func(opts *PipelineOptions) {
+		opts.AbortOnError = true
+	}
+}
+
+type PipelineOption = func(opts *PipelineOptions)
+
+type PipelineOptions struct {
+	AbortOnError bool
+}
+
+// Pipeline sends the requests received from requests with at most concurrency of them in flight,
+// delivering their results in the order the requests were received, e.g. to replay a long run of
+// sequential calls at the rate the connection allows with bounded memory. The results channel is
+// closed once requests is closed and every result has been delivered, when ctx is done, or after
+// the first error if WithPipelineAbortOnError is set. Results which are not read hold up the
+// requests behind them, so the caller must keep reading until the channel is closed or cancel ctx.
+//
+// Requests are sent with SendContext, so they pass through the interceptors of the client and
+// are combined into batches if WithAutoBatch is set, in which case concurrency should be at least
+// the maximum batch size.
+func Pipeline(
+	ctx context.Context,
+	client Client,
+	requests <-chan Request,
+	concurrency int,
+	options ...PipelineOption,
+) (<-chan PipelineResult, error) {
+	if concurrency < 1 {
+		return nil, errors.NotValidf("pipeline concurrency %d", concurrency)
+	}
+	opts := PipelineOptions{}
+	for _, opt := range options {
+		opt(&opts)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	// pending holds a channel for the result of each request in flight, in the order they were
+	// sent, less the one the collector is waiting on
+	pending := make(chan chan PipelineResult, concurrency-1)
+	results := make(chan PipelineResult)
+
+	go func() {
+		defer close(pending)
+		for index := 0; ; index++ {
+			var req Request
+			var ok bool
+			select {
+			case <-ctx.Done():
+				return
+			case req, ok = <-requests:
+				if !ok {
+					return
+				}
+			}
+
+			result := make(chan PipelineResult, 1)
+			select {
+			case <-ctx.Done():
+				return
+			case pending <- result:
+			}
+
+			go func(index int, req Request) {
+				var resp Response
+				err := client.SendContext(ctx, req, &resp)
+				r := PipelineResult{Index: index, Request: req, Err: err}
+				if err == nil {
+					r.Response = &resp
+					if resp.Error != nil {
+						r.Err = *resp.Error
+					}
+				}
+				result <- r
+			}(index, req)
+		}
+	}()
+
+	go func() {
+		defer close(results)
+		defer cancel()
+		for result := range pending {
+			var r PipelineResult
+			select {
+			case <-ctx.Done():
+				return
+			case r = <-result:
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case results <- r:
+			}
+			if r.Err != nil && opts.AbortOnError {
+				return
+			}
+		}
+	}()
+
+	return results, nil
+}
@@ -0,0 +1,188 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/41north/jsonrpc.go"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sequence returns a channel which yields n requests for method, with their index as params.
+func sequence(method string, n int) <-chan jsonrpc.Request {
+	requests := make(chan jsonrpc.Request)
+	go func() {
+		defer close(requests)
+		for i := 0; i < n; i++ {
+			requests <- *newRequest(method, []int{i})
+		}
+	}()
+	return requests
+}
+
+func TestPipeline(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	// completed holds a channel for each odd request, closed once it has been handled
+	var mu sync.Mutex
+	completed := make(map[int]chan struct{})
+	done := func(i int) chan struct{} {
+		mu.Lock()
+		defer mu.Unlock()
+		if completed[i] == nil {
+			completed[i] = make(chan struct{})
+		}
+		return completed[i]
+	}
+	finish := func(i int) {
+		ch := done(i)
+		mu.Lock()
+		defer mu.Unlock()
+		select {
+		case <-ch:
+		default:
+			close(ch)
+		}
+	}
+	server := jsonrpc.NewServer()
+	server.Register("echo", func(ctx context.Context, req jsonrpc.Request) (any, error) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			max := maxInFlight.Load()
+			if n <= max || maxInFlight.CompareAndSwap(max, n) {
+				break
+			}
+		}
+		// responses complete out of order, each even request after the odd one which follows it
+		var params []int
+		_ = req.UnmarshalParams(&params)
+		if i := params[0]; i%2 == 1 {
+			finish(i)
+		} else if i < 99 {
+			<-done(i + 1)
+		}
+		return params[0], nil
+	})
+	server.Register("fail", func(ctx context.Context, req jsonrpc.Request) (any, error) {
+		var params []int
+		_ = req.UnmarshalParams(&params)
+		if params[0] == 5 {
+			return nil, jsonrpc.ErrInvalidParams
+		}
+		return params[0], nil
+	})
+
+	client := jsonrpc.NewClient(serverDialer(server, nil))
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	_, err := jsonrpc.Pipeline(context.Background(), client, sequence("echo", 1), 0)
+	assert.Error(t, err)
+
+	// results are delivered in order, with at most concurrency requests in flight
+	results, err := jsonrpc.Pipeline(context.Background(), client, sequence("echo", 100), 4)
+	assert.Nil(t, err)
+	var next int
+	for r := range results {
+		assert.Nil(t, r.Err)
+		assert.Equal(t, next, r.Index)
+		var result int
+		assert.Nil(t, r.Response.UnmarshalResult(&result))
+		assert.Equal(t, next, result)
+		next++
+	}
+	assert.Equal(t, 100, next)
+	assert.LessOrEqual(t, maxInFlight.Load(), int32(4))
+
+	// errors are delivered with their result, and carried on past by default
+	var failed []int
+	results, _ = jsonrpc.Pipeline(context.Background(), client, sequence("fail", 10), 4)
+	next = 0
+	for r := range results {
+		if r.Err != nil {
+			var e jsonrpc.Error
+			assert.ErrorAs(t, r.Err, &e)
+			assert.Equal(t, jsonrpc.ErrInvalidParams.Code, e.Code)
+			failed = append(failed, r.Index)
+		}
+		next++
+	}
+	assert.Equal(t, []int{5}, failed)
+	assert.Equal(t, 10, next)
+
+	// or stop the pipeline
+	results, _ = jsonrpc.Pipeline(context.Background(), client, sequence("fail", 10), 4, jsonrpc.WithPipelineAbortOnError())
+	var delivered []int
+	for r := range results {
+		delivered = append(delivered, r.Index)
+	}
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5}, delivered)
+
+	// cancelling ctx closes the results
+	ctx, cancel := context.WithCancel(context.Background())
+	results, _ = jsonrpc.Pipeline(ctx, client, sequence("echo", 100), 4)
+	<-results
+	cancel()
+	for range results {
+	}
+}
+
+// benchServer returns a server whose "echo" method replies immediately, so that the benchmarks
+// measure the cost of the client rather than that of a server.
+func benchServer() *jsonrpc.Server {
+	server := jsonrpc.NewServer()
+	server.Register("echo", func(ctx context.Context, req jsonrpc.Request) (any, error) {
+		return req.Params, nil
+	})
+	return server
+}
+
+func BenchmarkPipeline(b *testing.B) {
+	server := benchServer()
+
+	for _, bench := range []struct {
+		name    string
+		options []jsonrpc.ClientOption
+	}{
+		{"Default", nil},
+		{"AutoBatch", []jsonrpc.ClientOption{jsonrpc.WithAutoBatch(32, time.Millisecond)}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			client := jsonrpc.NewClient(serverDialer(server, nil), bench.options...)
+			if err := client.Connect(); err != nil {
+				b.Fatal(err)
+			}
+			defer client.Close()
+
+			b.ResetTimer()
+			results, _ := jsonrpc.Pipeline(context.Background(), client, sequence("echo", b.N), 64)
+			for r := range results {
+				if r.Err != nil {
+					b.Fatal(r.Err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkSequentialSend(b *testing.B) {
+	server := benchServer()
+
+	client := jsonrpc.NewClient(serverDialer(server, nil))
+	if err := client.Connect(); err != nil {
+		b.Fatal(err)
+	}
+	defer client.Close()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var resp jsonrpc.Response
+		if err := client.Send(*newRequest("echo", []int{i}), &resp); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
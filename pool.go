@@ -0,0 +1,696 @@
+package jsonrpc
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/juju/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+var ErrNoConnections = errors.ConstError("pool has no connections")
+
+// DefaultDialConcurrency is the default number of connections dialled in parallel when the pool
+// is filled.
+const DefaultDialConcurrency = 4
+
+// DefaultDrainTimeout is the default time requests in flight are given to complete when the pool
+// shuts down on a signal.
+const DefaultDrainTimeout = 30 * time.Second
+
+const (
+	// replacementInitialBackoff is the wait before retrying a failed dial of a replacement
+	// connection, doubling before each retry after it up to replacementMaxBackoff.
+	replacementInitialBackoff = 100 * time.Millisecond
+	replacementMaxBackoff     = 5 * time.Second
+)
+
+// DefaultWarmUpConcurrency is the default number of connections dialled in parallel during warm up.
+//
+// Deprecated: use DefaultDialConcurrency.
+const DefaultWarmUpConcurrency = DefaultDialConcurrency
+
+// WithMinIdleConnections sets the number of connections which are established when the pool is
+// connected or warmed up, so that the first requests do not wait for a dial.
+func WithMinIdleConnections(n int) PoolOption {
+	return func(opts *PoolOptions) {
+		opts.MinIdleConnections = n
+	}
+}
+
+// WithDialConcurrency limits the number of dials which run in parallel when the pool is filled, see
+// WarmUp. No more than the number of connections missing are dialled at once.
+func WithDialConcurrency(n int) PoolOption {
+	return func(opts *PoolOptions) {
+		opts.DialConcurrency = n
+	}
+}
+
+// WithWarmUpConcurrency limits the number of dials which run in parallel during warm up.
+//
+// Deprecated: use WithDialConcurrency.
+func WithWarmUpConcurrency(n int) PoolOption {
+	return WithDialConcurrency(n)
+}
+
+// WithPoolClientOptions sets the options of the clients created by the pool.
+func WithPoolClientOptions(options ...ClientOption) PoolOption {
+	return func(opts *PoolOptions) {
+		opts.ClientOptions = options
+	}
+}
+
+// WithConnectionHealthCheck runs healthFn against every connection in the pool each interval,
+// evicting those for which it returns false and dialing replacements. Each check runs in its own
+// goroutine and the connection remains available for requests while it runs. The context passed
+// to healthFn expires after interval.
+func WithConnectionHealthCheck(interval time.Duration, healthFn func(ctx context.Context, c Client) bool) PoolOption {
+	return func(opts *PoolOptions) {
+		opts.HealthCheckInterval = interval
+		opts.HealthCheck = healthFn
+	}
+}
+
+// WithMaxConnections allows the pool to grow to n connections. When every connection has requests
+// in flight, Get dials another rather than sharing a busy one, until n is reached. By default the
+// pool does not grow beyond the connections established by warm up.
+func WithMaxConnections(n int) PoolOption {
+	return func(opts *PoolOptions) {
+		opts.MaxConnections = n
+	}
+}
+
+// WithMaxIdleTime closes connections which have not sent a request for d, while the pool holds
+// more than the minimum set with WithMinIdleConnections.
+func WithMaxIdleTime(d time.Duration) PoolOption {
+	return func(opts *PoolOptions) {
+		opts.MaxIdleTime = d
+	}
+}
+
+// WithDrainTimeout sets how long requests in flight are given to complete when the pool shuts
+// down on a signal, see HandleSignal.
+func WithDrainTimeout(d time.Duration) PoolOption {
+	return func(opts *PoolOptions) {
+		opts.DrainTimeout = d
+	}
+}
+
+// WithWarmStandby keeps n connections dialled in addition to those in use, which carry no traffic
+// until they are promoted, so that the pool does not have to dial during an outage. A standby is
+// promoted instantly when a connection in use closes or fails its health check, or when the pool
+// needs to grow, and a replacement standby is dialled in the background. Standbys are created with
+// the same client options, so a keep alive set with WithPoolClientOptions(WithKeepAlive(...))
+// keeps them alive, and are covered by the health check. See Stats.
+func WithWarmStandby(n int) PoolOption {
+	return func(opts *PoolOptions) {
+		opts.WarmStandby = n
+	}
+}
+
+// WithPoolClock sets the clock used for the intervals of the pool and, unless overridden with
+// WithPoolClientOptions, of its clients, see Clock.
+func WithPoolClock(clock Clock) PoolOption {
+	return func(opts *PoolOptions) {
+		opts.Clock = clock
+	}
+}
+
+type PoolOption = func(opts *PoolOptions)
+
+type PoolOptions struct {
+	MinIdleConnections int
+	MaxConnections     int
+	MaxIdleTime        time.Duration
+	DialConcurrency    int
+	DrainTimeout       time.Duration
+	WarmStandby        int
+	Clock              Clock
+	ClientOptions      []ClientOption
+
+	HealthCheckInterval time.Duration
+	HealthCheck         func(ctx context.Context, c Client) bool
+}
+
+func DefaultPoolOptions() PoolOptions {
+	return PoolOptions{
+		DialConcurrency: DefaultDialConcurrency,
+		DrainTimeout:    DefaultDrainTimeout,
+		Clock:           RealClock(),
+	}
+}
+
+// Pool maintains a set of connected clients which share a dialer. Each client multiplexes many
+// requests, so the pool spreads load across connections rather than lending them out exclusively.
+// Clients which close are removed from the pool.
+type Pool struct {
+	dialer Dialer
+	opts   PoolOptions
+	log    *log.Entry
+
+	mu       sync.Mutex
+	clients  []*pooledClient
+	standby  []*pooledClient
+	next     int
+	closed   bool
+	done     chan struct{}
+	checking map[*pooledClient]bool
+
+	// dialing is the number of dials started by Get which are in progress, and dialed is closed
+	// and replaced as each finishes.
+	dialing int
+	dialed  chan struct{}
+
+	promotions atomic.Uint64
+}
+
+// PoolStats reports the connections held by a Pool.
+type PoolStats struct {
+	// Active is the number of connections carrying traffic.
+	Active int
+	// Standby is the number of connections held in reserve, see WithWarmStandby.
+	Standby int
+	// Promotions is the number of standbys which have been promoted to carry traffic.
+	Promotions uint64
+}
+
+// pooledClient records when a client in the pool was last used to send a request.
+type pooledClient struct {
+	Client
+	clock    Clock
+	lastUsed atomic.Int64
+}
+
+func (c *pooledClient) touch() {
+	c.lastUsed.Store(c.clock.Now().UnixNano())
+}
+
+func (c *pooledClient) idleSince() time.Time {
+	return time.Unix(0, c.lastUsed.Load())
+}
+
+// busy returns true if the client has requests in flight.
+func (c *pooledClient) busy() bool {
+	for _, n := range c.InFlightByTenant() {
+		if n > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *pooledClient) Send(req Request, resp *Response) error {
+	c.touch()
+	return c.Client.Send(req, resp)
+}
+
+func (c *pooledClient) SendContext(ctx context.Context, req Request, resp *Response) error {
+	c.touch()
+	return c.Client.SendContext(ctx, req, resp)
+}
+
+func (c *pooledClient) SendRequest(ctx context.Context, req *Request, resp *Response) error {
+	c.touch()
+	return c.Client.SendRequest(ctx, req, resp)
+}
+
+func (c *pooledClient) SendAsync(req Request) ResponseFuture {
+	c.touch()
+	return c.Client.SendAsync(req)
+}
+
+func (c *pooledClient) SendCancelable(req Request) (CancelableFuture, error) {
+	c.touch()
+	return c.Client.SendCancelable(req)
+}
+
+func (c *pooledClient) SendWithPriority(req Request, priority Priority) ResponseFuture {
+	c.touch()
+	return c.Client.SendWithPriority(req, priority)
+}
+
+func (c *pooledClient) SendBatch(ctx context.Context, batch BatchRequest) []ResponseFuture {
+	c.touch()
+	return c.Client.SendBatch(ctx, batch)
+}
+
+func (c *pooledClient) SendBatchContext(ctx context.Context, batch BatchRequest) ([]*Response, error) {
+	c.touch()
+	return c.Client.SendBatchContext(ctx, batch)
+}
+
+func (c *pooledClient) NotifyBatch(reqs []Request) error {
+	c.touch()
+	return c.Client.NotifyBatch(reqs)
+}
+
+func NewPool(dialer Dialer, options ...PoolOption) *Pool {
+	opts := DefaultPoolOptions()
+	for _, opt := range options {
+		opt(&opts)
+	}
+	p := &Pool{
+		dialer:   dialer,
+		opts:     opts,
+		log:      log.WithField("component", "pool"),
+		done:     make(chan struct{}),
+		checking: make(map[*pooledClient]bool),
+		dialed:   make(chan struct{}),
+	}
+	if opts.HealthCheck != nil && opts.HealthCheckInterval > 0 {
+		go p.checkHealth()
+	}
+	if opts.MaxIdleTime > 0 {
+		go p.evictIdle()
+	}
+	return p
+}
+
+// Connect warms up the pool, see WarmUp.
+func (p *Pool) Connect() error {
+	return p.WarmUp(context.Background())
+}
+
+// WarmUp dials connections until the pool holds at least the minimum set with
+// WithMinIdleConnections, along with any standbys, see WithWarmStandby, running up to the number
+// set with WithDialConcurrency in parallel. Failed dials are logged and do not fail the warm up,
+// unless the pool is left without any connections. If ctx is done no further dials are started and
+// ctx.Err() is returned, although dials already in progress still add their connections to the
+// pool.
+func (p *Pool) WarmUp(ctx context.Context) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return ErrClosed
+	}
+	missing := p.opts.MinIdleConnections - len(p.clients)
+	if missing < 0 {
+		missing = 0
+	}
+	missingStandby := p.opts.WarmStandby - len(p.standby)
+	if missingStandby < 0 {
+		missingStandby = 0
+	}
+	missing += missingStandby
+	p.mu.Unlock()
+
+	if missing <= 0 {
+		return nil
+	}
+
+	workers := p.opts.DialConcurrency
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > missing {
+		workers = missing
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var lastErr error
+	failed := 0
+
+	// each worker dials until every missing connection has been attempted
+	var remaining atomic.Int32
+	remaining.Store(int32(missing))
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				n := remaining.Add(-1)
+				if n < 0 {
+					return
+				}
+				// the last of the connections to be dialled are the standbys
+				if _, err := p.dial(n < int32(missingStandby)); err != nil {
+					mu.Lock()
+					failed++
+					lastErr = err
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-done:
+	}
+
+	if failed > 0 {
+		p.log.
+			WithError(lastErr).
+			WithField("failed", failed).
+			WithField("connections", p.Len()).
+			Warn("warm up did not establish all connections")
+	}
+	if p.Stats().Active == 0 && lastErr != nil {
+		return errors.WithType(errors.Annotate(lastErr, string(ErrNoConnections)), ErrNoConnections)
+	}
+	return nil
+}
+
+// dial connects a new client and adds it to the pool, as a standby if standby is set. A standby is
+// discarded, returning nil, if the pool already holds enough of them.
+func (p *Pool) dial(standby bool) (*pooledClient, error) {
+	options := append([]ClientOption{WithClock(p.opts.Clock)}, p.opts.ClientOptions...)
+	client := &pooledClient{Client: NewClient(p.dialer, options...), clock: p.opts.Clock}
+	client.touch()
+	client.SetCloseHandler(func(error) {
+		p.onClose(client)
+	})
+	if err := client.Connect(); err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		_ = client.Close()
+		return nil, ErrClosed
+	}
+	if standby {
+		if len(p.standby) >= p.opts.WarmStandby {
+			// filled concurrently, so this one is surplus
+			_ = client.Close()
+			return nil, nil
+		}
+		p.standby = append(p.standby, client)
+	} else {
+		p.clients = append(p.clients, client)
+	}
+	return client, nil
+}
+
+// onClose removes a client which has closed, replacing it with a standby if it was in use.
+func (p *Pool) onClose(client *pooledClient) {
+	p.mu.Lock()
+	standby := removeClient(&p.standby, client)
+	promoted := !standby && removeClient(&p.clients, client) && p.promote() != nil
+	p.mu.Unlock()
+
+	if standby || promoted {
+		go p.replenish()
+	}
+}
+
+// remove takes client out of the pool, returning true if it was in use rather than a standby.
+func (p *Pool) remove(client *pooledClient) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return !removeClient(&p.standby, client) && removeClient(&p.clients, client)
+}
+
+func removeClient(clients *[]*pooledClient, client *pooledClient) bool {
+	for i, c := range *clients {
+		if c == client {
+			*clients = append((*clients)[:i], (*clients)[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// promote moves a standby into use, returning nil if there are none. It must be called with mu held.
+func (p *Pool) promote() *pooledClient {
+	if p.closed || len(p.standby) == 0 {
+		return nil
+	}
+	client := p.standby[0]
+	p.standby = p.standby[1:]
+	client.touch()
+	p.clients = append(p.clients, client)
+	p.promotions.Add(1)
+	p.log.
+		WithField("active", len(p.clients)).
+		WithField("standby", len(p.standby)).
+		Info("promoted standby connection")
+	return client
+}
+
+// replenish dials standbys until the pool holds as many as configured, retrying with backoff until
+// it succeeds or the pool closes.
+func (p *Pool) replenish() {
+	backoff := ExponentialBackoff(replacementInitialBackoff, replacementMaxBackoff)
+	for retry := 1; ; {
+		p.mu.Lock()
+		full := p.closed || len(p.standby) >= p.opts.WarmStandby
+		p.mu.Unlock()
+		if full {
+			return
+		}
+
+		_, err := p.dial(true)
+		switch {
+		case err == nil:
+			retry = 1
+			continue
+		case errors.Is(err, ErrClosed):
+			return
+		}
+
+		wait := backoff(retry)
+		retry++
+		p.log.WithError(err).WithField("wait", wait).Warn("failed to dial standby connection")
+
+		timer := p.opts.Clock.NewTimer(wait)
+		select {
+		case <-p.done:
+			timer.Stop()
+			return
+		case <-timer.C():
+		}
+	}
+}
+
+// Get returns one of the pool's clients in round robin order, dialing a new one if the pool is
+// empty, or if every client is busy and the pool may grow. Dials started by Get count towards the
+// maximum set with WithMaxConnections while they run, so a Get which would exceed it shares a busy
+// client instead or, if the pool is empty, waits for a dial in progress to finish.
+func (p *Pool) Get() (Client, error) {
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return nil, ErrClosed
+		}
+		limit := p.opts.MaxConnections
+		if limit < 1 {
+			limit = 1
+		}
+		grow := len(p.clients)+p.dialing < limit
+		for range p.clients {
+			client := p.clients[p.next%len(p.clients)]
+			p.next++
+			if !grow || !client.busy() {
+				p.mu.Unlock()
+				return client, nil
+			}
+		}
+		// grow with a standby if there is one
+		if client := p.promote(); client != nil {
+			p.mu.Unlock()
+			go p.replenish()
+			return client, nil
+		}
+		if !grow {
+			// the pool is empty and as many dials as it may hold are in progress
+			dialed := p.dialed
+			p.mu.Unlock()
+			select {
+			case <-dialed:
+				continue
+			case <-p.done:
+				return nil, ErrClosed
+			}
+		}
+		// the slot is reserved until the dial has finished
+		p.dialing++
+		p.mu.Unlock()
+
+		client, err := p.dial(false)
+
+		p.mu.Lock()
+		p.dialing--
+		close(p.dialed)
+		p.dialed = make(chan struct{})
+		p.mu.Unlock()
+
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
+	}
+}
+
+// Len returns the number of connected clients in the pool which are in use, excluding standbys.
+func (p *Pool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.clients)
+}
+
+// Stats returns the number of connections in use and on standby, and the number of promotions.
+func (p *Pool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return PoolStats{Active: len(p.clients), Standby: len(p.standby), Promotions: p.promotions.Load()}
+}
+
+// Close closes every client in the pool.
+func (p *Pool) Close() error {
+	clients, err := p.stop()
+	if err != nil {
+		return err
+	}
+	for _, client := range clients {
+		_ = client.Close()
+	}
+	return nil
+}
+
+// stop marks the pool as closed, returning the clients it held.
+func (p *Pool) stop() ([]*pooledClient, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil, ErrClosed
+	}
+	p.closed = true
+	close(p.done)
+	clients := append(p.clients, p.standby...)
+	p.clients = nil
+	p.standby = nil
+	return clients, nil
+}
+
+// checkHealth runs the health check against each connection every interval until the pool closes.
+func (p *Pool) checkHealth() {
+	ticker := p.opts.Clock.NewTicker(p.opts.HealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C():
+		}
+
+		p.mu.Lock()
+		clients := append(append([]*pooledClient(nil), p.clients...), p.standby...)
+		p.mu.Unlock()
+
+		for _, client := range clients {
+			p.checkClient(client)
+		}
+	}
+}
+
+// checkClient runs the health check against client in the background, unless a check of it is
+// already running.
+func (p *Pool) checkClient(client *pooledClient) {
+	p.mu.Lock()
+	if p.checking[client] {
+		p.mu.Unlock()
+		return
+	}
+	p.checking[client] = true
+	p.mu.Unlock()
+
+	go func() {
+		defer func() {
+			p.mu.Lock()
+			delete(p.checking, client)
+			p.mu.Unlock()
+		}()
+
+		ctx, cancel := withClockTimeout(context.Background(), p.opts.Clock, p.opts.HealthCheckInterval)
+		defer cancel()
+		go func() {
+			select {
+			case <-p.done:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		if p.opts.HealthCheck(ctx, client) {
+			return
+		}
+
+		p.log.Warn("evicting unhealthy connection")
+		p.mu.Lock()
+		active := removeClient(&p.clients, client)
+		standby := !active && removeClient(&p.standby, client)
+		promoted := active && p.promote() != nil
+		p.mu.Unlock()
+		_ = client.Close()
+
+		switch {
+		case standby || promoted:
+			go p.replenish()
+		case active:
+			if _, err := p.dial(false); err != nil && !errors.Is(err, ErrClosed) {
+				p.log.WithError(err).Warn("failed to replace unhealthy connection")
+			}
+		}
+	}()
+}
+
+// evictIdle periodically closes connections which have been idle for longer than the max idle
+// time, longest idle first, while the pool is above its minimum size.
+func (p *Pool) evictIdle() {
+	ticker := p.opts.Clock.NewTicker(p.opts.MaxIdleTime / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C():
+		}
+
+		cutoff := p.opts.Clock.Now().Add(-p.opts.MaxIdleTime)
+
+		p.mu.Lock()
+		var idle []*pooledClient
+		for _, client := range p.clients {
+			if client.idleSince().Before(cutoff) && !client.busy() {
+				idle = append(idle, client)
+			}
+		}
+		sort.Slice(idle, func(i, j int) bool {
+			return idle[i].idleSince().Before(idle[j].idleSince())
+		})
+		excess := len(p.clients) - p.opts.MinIdleConnections
+		if excess < 0 {
+			excess = 0
+		}
+		if excess < len(idle) {
+			idle = idle[:excess]
+		}
+		// removed before closing, so that they are not replaced
+		for _, client := range idle {
+			removeClient(&p.clients, client)
+		}
+		p.mu.Unlock()
+
+		for _, client := range idle {
+			p.log.
+				WithField("idleSince", client.idleSince()).
+				Debug("closing idle connection")
+			_ = client.Close()
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package jsonrpc
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// drainPollInterval is how often a shutting down pool checks whether its requests have completed.
+const drainPollInterval = 10 * time.Millisecond
+
+// Shutdown closes the pool gracefully. Get fails with ErrClosed from the start, while the clients
+// already handed out remain usable until the requests in flight on every client have completed,
+// after which the clients are closed. If ctx is done first the clients are closed regardless,
+// failing the requests still in flight, and ctx.Err() is returned.
+func (p *Pool) Shutdown(ctx context.Context) error {
+	clients, err := p.stop()
+	if err != nil {
+		return err
+	}
+
+	ticker := p.opts.Clock.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+drain:
+	for _, client := range clients {
+		for client.busy() {
+			select {
+			case <-ctx.Done():
+				err = ctx.Err()
+				break drain
+			case <-ticker.C():
+			}
+		}
+	}
+
+	if err != nil {
+		p.log.WithError(err).Warn("closing pool before requests in flight completed")
+	}
+	for _, client := range clients {
+		_ = client.Close()
+	}
+	return err
+}
+
+// HandleSignal shuts the pool down, see Shutdown, when one of signals is received, giving requests
+// in flight the time set with WithDrainTimeout to complete. SIGINT and SIGTERM are handled if no
+// signals are given. The returned context is cancelled once the pool has shut down, or if it is
+// closed some other way, so that the rest of the application can wait on it:
+//
+//	ctx := pool.HandleSignal()
+//	...
+//	<-ctx.Done()
+func (p *Pool) HandleSignal(signals ...os.Signal) context.Context {
+	if len(signals) == 0 {
+		signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, signals...)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		defer cancel()
+		defer signal.Stop(ch)
+
+		select {
+		case <-p.done:
+			return
+		case sig := <-ch:
+			p.log.WithField("signal", sig).Info("draining pool")
+		}
+
+		drainCtx, drainCancel := withClockTimeout(context.Background(), p.opts.Clock, p.opts.DrainTimeout)
+		defer drainCancel()
+		_ = p.Shutdown(drainCtx)
+	}()
+	return ctx
+}
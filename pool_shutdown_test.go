@@ -0,0 +1,76 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/41north/jsonrpc.go"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPool_HandleSignal(t *testing.T) {
+	release := make(chan struct{})
+
+	pool := jsonrpc.NewPool(serverDialer(endpoint("a", release), nil), jsonrpc.WithMinIdleConnections(2))
+	assert.Nil(t, pool.Connect())
+	ctx := pool.HandleSignal(syscall.SIGHUP)
+
+	client, err := pool.Get()
+	assert.Nil(t, err)
+	future := client.SendAsync(*newRequest("wait", nil))
+
+	process, err := os.FindProcess(os.Getpid())
+	assert.Nil(t, err)
+	assert.Nil(t, process.Signal(syscall.SIGHUP))
+
+	// no more clients are handed out, while requests in flight are allowed to complete
+	assert.Eventually(t, func() bool {
+		_, err := pool.Get()
+		return err != nil
+	}, time.Second, time.Millisecond)
+	select {
+	case <-ctx.Done():
+		t.Fatal("shut down before requests completed")
+	default:
+	}
+
+	close(release)
+	resp, err := (<-future.Get()).Unwrap()
+	assert.Nil(t, err)
+	assert.Equal(t, `"a"`, string(resp.Result))
+
+	<-ctx.Done()
+	assert.Equal(t, 0, pool.Len())
+	assert.ErrorIs(t, client.Connect(), jsonrpc.ErrClosed)
+}
+
+func TestPool_Shutdown(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	pool := jsonrpc.NewPool(serverDialer(endpoint("a", release), nil), jsonrpc.WithMinIdleConnections(1))
+	assert.Nil(t, pool.Connect())
+
+	client, err := pool.Get()
+	assert.Nil(t, err)
+	future := client.SendAsync(*newRequest("wait", nil))
+
+	// requests which do not complete in time fail once the clients are closed
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, pool.Shutdown(ctx), context.DeadlineExceeded)
+	_, err = (<-future.Get()).Unwrap()
+	assert.ErrorIs(t, err, jsonrpc.ErrClosed)
+
+	assert.ErrorIs(t, pool.Shutdown(context.Background()), jsonrpc.ErrClosed)
+
+	// a pool which is closed some other way cancels the context of its signal handler
+	pool = jsonrpc.NewPool(serverDialer(endpoint("a", release), nil))
+	ctx = pool.HandleSignal(syscall.SIGHUP)
+	assert.Nil(t, pool.Close())
+	<-ctx.Done()
+}
@@ -0,0 +1,311 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/41north/jsonrpc.go"
+	"github.com/41north/jsonrpc.go/testutil"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// serverDialer returns a dialer which connects to server over a pipe, failing every dial for which
+// fail returns true.
+func serverDialer(server *jsonrpc.Server, fail func(n int32) bool) jsonrpc.Dialer {
+	var dials atomic.Int32
+	return jsonrpc.DialFunc(func() (jsonrpc.Connection, error) {
+		if fail != nil && fail(dials.Add(1)) {
+			return nil, errors.New("connection refused")
+		}
+		clientConn, serverConn := net.Pipe()
+		go server.Serve(context.Background(), jsonrpc.NewStreamConnection(serverConn, jsonrpc.FramingNewline))
+		return jsonrpc.NewStreamConnection(clientConn, jsonrpc.FramingNewline), nil
+	})
+}
+
+func TestPool_WarmUp(t *testing.T) {
+	server := jsonrpc.NewServer()
+	server.Register("echo", echo)
+
+	// every third dial fails
+	pool := jsonrpc.NewPool(
+		serverDialer(server, func(n int32) bool { return n%3 == 0 }),
+		jsonrpc.WithMinIdleConnections(6),
+		jsonrpc.WithDialConcurrency(2),
+	)
+	defer pool.Close()
+
+	assert.Nil(t, pool.Connect())
+	assert.Equal(t, 4, pool.Len())
+
+	// warming up again only dials the missing connections
+	assert.Nil(t, pool.WarmUp(context.Background()))
+	assert.Equal(t, 6, pool.Len())
+
+	client, err := pool.Get()
+	assert.Nil(t, err)
+
+	var resp jsonrpc.Response
+	assert.Nil(t, client.Send(*newRequest("echo", []string{"hello"}), &resp))
+
+	// closed clients are removed from the pool
+	assert.Nil(t, client.Close())
+	assert.Equal(t, 5, pool.Len())
+
+	assert.Nil(t, pool.Close())
+	assert.Equal(t, 0, pool.Len())
+
+	_, err = pool.Get()
+	assert.ErrorIs(t, err, jsonrpc.ErrClosed)
+}
+
+func TestPool_DialConcurrency(t *testing.T) {
+	server := jsonrpc.NewServer()
+
+	testCases := []struct {
+		connections int
+		concurrency int
+		parallel    int32
+	}{
+		{6, 3, 3},
+		// no more dials run than there are connections missing
+		{2, 10, 2},
+	}
+
+	for _, tc := range testCases {
+		var dialing, parallel atomic.Int32
+		// dials are held until as many are running as are expected to run in parallel
+		overlapping := make(chan struct{})
+		var once sync.Once
+		inner := serverDialer(server, func(n int32) bool { return n == 1 })
+		dialer := jsonrpc.DialFunc(func() (jsonrpc.Connection, error) {
+			n := dialing.Add(1)
+			defer dialing.Add(-1)
+			for {
+				peak := parallel.Load()
+				if n <= peak || parallel.CompareAndSwap(peak, n) {
+					break
+				}
+			}
+			if n == tc.parallel {
+				once.Do(func() { close(overlapping) })
+			}
+			<-overlapping
+			return inner.Dial()
+		})
+
+		pool := jsonrpc.NewPool(dialer, jsonrpc.WithMinIdleConnections(tc.connections), jsonrpc.WithDialConcurrency(tc.concurrency))
+		// the first dial fails without preventing the rest being added
+		assert.Nil(t, pool.Connect())
+		assert.Equal(t, tc.connections-1, pool.Len())
+		assert.Equal(t, tc.parallel, parallel.Load())
+		assert.Nil(t, pool.Close())
+	}
+}
+
+func TestPool_WarmUpFailure(t *testing.T) {
+	pool := jsonrpc.NewPool(
+		serverDialer(jsonrpc.NewServer(), func(int32) bool { return true }),
+		jsonrpc.WithMinIdleConnections(3),
+	)
+	defer pool.Close()
+
+	err := pool.Connect()
+	assert.ErrorIs(t, err, jsonrpc.ErrNoConnections)
+	assert.ErrorIs(t, err, jsonrpc.ErrDial)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, pool.WarmUp(ctx), context.Canceled)
+}
+
+func TestPool_HealthCheck(t *testing.T) {
+	server := jsonrpc.NewServer()
+	server.Register("echo", echo)
+
+	var unhealthy sync.Map
+	var checks atomic.Int32
+	clock := testutil.NewClock(time.Time{})
+	pool := jsonrpc.NewPool(
+		serverDialer(server, nil),
+		jsonrpc.WithPoolClock(clock),
+		jsonrpc.WithMinIdleConnections(3),
+		jsonrpc.WithConnectionHealthCheck(time.Minute, func(ctx context.Context, c jsonrpc.Client) bool {
+			checks.Add(1)
+			if _, ok := unhealthy.Load(c); ok {
+				return false
+			}
+			// slow checks do not make the connection unavailable
+			<-ctx.Done()
+			return true
+		}),
+	)
+	defer pool.Close()
+	assert.Nil(t, pool.Connect())
+
+	bad, err := pool.Get()
+	assert.Nil(t, err)
+	unhealthy.Store(bad, true)
+
+	// the unhealthy connection is closed and replaced
+	var resp jsonrpc.Response
+	assert.Eventually(t, func() bool {
+		clock.Advance(time.Minute)
+		return errors.Is(bad.Send(*newRequest("echo", nil), &resp), jsonrpc.ErrClosed)
+	}, time.Second, 10*time.Millisecond)
+	assert.Eventually(t, func() bool { return pool.Len() == 3 }, time.Second, 10*time.Millisecond)
+
+	// connections being checked remain available
+	assert.Greater(t, checks.Load(), int32(1))
+	for i := 0; i < 3; i++ {
+		client, err := pool.Get()
+		assert.Nil(t, err)
+		assert.Nil(t, client.Send(*newRequest("echo", nil), &resp))
+	}
+}
+
+func TestPool_MaxIdleTime(t *testing.T) {
+	release := make(chan struct{})
+	clock := testutil.NewClock(time.Time{})
+	pool := jsonrpc.NewPool(
+		serverDialer(endpoint("a", release), nil),
+		jsonrpc.WithPoolClock(clock),
+		jsonrpc.WithMinIdleConnections(1),
+		jsonrpc.WithMaxConnections(3),
+		jsonrpc.WithMaxIdleTime(time.Minute),
+	)
+	defer pool.Close()
+	assert.Nil(t, pool.Connect())
+	assert.Equal(t, 1, pool.Len())
+
+	// the pool grows while every connection is busy
+	var futures []jsonrpc.ResponseFuture
+	for i := 0; i < 4; i++ {
+		client, err := pool.Get()
+		assert.Nil(t, err)
+		futures = append(futures, client.SendAsync(*newRequest("wait", nil)))
+	}
+	assert.Equal(t, 3, pool.Len())
+
+	close(release)
+	_, err := jsonrpc.WaitAll(context.Background(), futures)
+	assert.Nil(t, err)
+
+	// and shrinks back to the minimum once they have been idle for longer than the max idle time
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+	assert.Equal(t, 3, pool.Len())
+	clock.Advance(30 * time.Second)
+	assert.Eventually(t, func() bool { return pool.Len() == 1 }, time.Second, time.Millisecond)
+
+	client, err := pool.Get()
+	assert.Nil(t, err)
+	var resp jsonrpc.Response
+	assert.Nil(t, client.Send(*newRequest("name", nil), &resp))
+}
+
+func TestPool_MaxConnectionsConcurrentGet(t *testing.T) {
+	var dials atomic.Int32
+	gate := make(chan struct{})
+	inner := serverDialer(endpoint("a", nil), nil)
+	dialer := jsonrpc.DialFunc(func() (jsonrpc.Connection, error) {
+		dials.Add(1)
+		<-gate
+		return inner.Dial()
+	})
+
+	pool := jsonrpc.NewPool(dialer, jsonrpc.WithMaxConnections(2))
+	defer pool.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := pool.Get()
+			assert.Nil(t, err)
+		}()
+	}
+
+	// no more dials are started than the pool may hold, the other gets wait for them
+	assert.Eventually(t, func() bool { return dials.Load() == 2 }, time.Second, time.Millisecond)
+	close(gate)
+	wg.Wait()
+
+	assert.Equal(t, int32(2), dials.Load())
+	assert.Equal(t, 2, pool.Len())
+
+	// the slot of a failed dial is released
+	pool = jsonrpc.NewPool(serverDialer(endpoint("a", nil), func(n int32) bool { return n == 1 }), jsonrpc.WithMaxConnections(1))
+	defer pool.Close()
+	_, err := pool.Get()
+	assert.ErrorIs(t, err, jsonrpc.ErrDial)
+	_, err = pool.Get()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, pool.Len())
+}
+
+func TestPool_WarmStandby(t *testing.T) {
+	pool := jsonrpc.NewPool(
+		serverDialer(endpoint("a", nil), nil),
+		jsonrpc.WithMinIdleConnections(1),
+		jsonrpc.WithMaxConnections(1),
+		jsonrpc.WithWarmStandby(2),
+	)
+	defer pool.Close()
+	assert.Nil(t, pool.Connect())
+	assert.Equal(t, jsonrpc.PoolStats{Active: 1, Standby: 2}, pool.Stats())
+	assert.Equal(t, 1, pool.Len())
+
+	// a standby is promoted when the active connection fails, and replaced in the background
+	active, err := pool.Get()
+	assert.Nil(t, err)
+	assert.Nil(t, active.Close())
+	assert.Eventually(t, func() bool {
+		return pool.Stats() == jsonrpc.PoolStats{Active: 1, Standby: 2, Promotions: 1}
+	}, time.Second, time.Millisecond)
+
+	promoted, err := pool.Get()
+	assert.Nil(t, err)
+	assert.NotEqual(t, active, promoted)
+	name, err := sendName(t, promoted, "name")
+	assert.Nil(t, err)
+	assert.Equal(t, "a", name)
+
+	// closing the pool closes the standbys
+	assert.Nil(t, pool.Close())
+	assert.Equal(t, jsonrpc.PoolStats{Promotions: 1}, pool.Stats())
+}
+
+func TestPool_WarmStandbyReplenish(t *testing.T) {
+	clock := testutil.NewClock(time.Time{})
+	var failing atomic.Bool
+	pool := jsonrpc.NewPool(
+		serverDialer(endpoint("a", nil), func(int32) bool { return failing.Load() }),
+		jsonrpc.WithPoolClock(clock),
+		jsonrpc.WithMinIdleConnections(1),
+		jsonrpc.WithWarmStandby(1),
+	)
+	defer pool.Close()
+	assert.Nil(t, pool.Connect())
+
+	// a replacement standby which cannot be dialled is retried with backoff
+	failing.Store(true)
+	active, err := pool.Get()
+	assert.Nil(t, err)
+	assert.Nil(t, active.Close())
+	clock.BlockUntil(1)
+	assert.Equal(t, jsonrpc.PoolStats{Active: 1, Standby: 0, Promotions: 1}, pool.Stats())
+
+	failing.Store(false)
+	clock.Advance(time.Second)
+	assert.Eventually(t, func() bool {
+		return pool.Stats().Standby == 1
+	}, time.Second, time.Millisecond)
+}
@@ -0,0 +1,123 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+// WithPositionalCorrelation correlates responses with requests by the order in which they arrive,
+// for non-standard servers which handle requests strictly one after another but do not echo the id
+// of the request in its response. Each response, including each element of a batch response, is
+// given the id of the oldest request written without having received a response, after which it is
+// matched as usual, so that a correlator set with WithCorrelator still applies. The server must
+// answer every request, in the order received, including those the client has stopped waiting for.
+// Notifications are not counted. Requests are still sent with their ids.
+func WithPositionalCorrelation() ClientOption {
+	return func(opts *ClientOptions) {
+		opts.PositionalCorrelation = true
+	}
+}
+
+// positionalQueue holds the ids of the requests written and awaiting a response, in the order they
+// were written.
+type positionalQueue struct {
+	// writeMu orders writes, so that ids are queued in the order their requests are written
+	writeMu sync.Mutex
+
+	mu  sync.Mutex
+	ids []json.RawMessage
+}
+
+// write writes data to conn, bounded by ctx as writeContext is, queueing the ids of the requests it
+// contains.
+func (q *positionalQueue) write(ctx context.Context, conn Connection, data []byte) error {
+	q.writeMu.Lock()
+	defer q.writeMu.Unlock()
+
+	// queued before writing, as the response can be read before Write returns
+	n := q.push(data)
+	err := writeContext(ctx, conn, data)
+	if err != nil {
+		q.mu.Lock()
+		q.ids = q.ids[:len(q.ids)-n]
+		q.mu.Unlock()
+	}
+	return err
+}
+
+// push queues the ids of the requests in data, a single message or batch, returning how many there
+// were.
+func (q *positionalQueue) push(data []byte) int {
+	type message struct {
+		Id     json.RawMessage `json:"id"`
+		Method string          `json:"method"`
+	}
+	var messages []message
+	if isBatch(data) {
+		if err := json.Unmarshal(data, &messages); err != nil {
+			return 0
+		}
+	} else {
+		messages = make([]message, 1)
+		if err := json.Unmarshal(data, &messages[0]); err != nil {
+			return 0
+		}
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	n := 0
+	for _, message := range messages {
+		// notifications receive no response, and responses to the server none either
+		if message.Id != nil && message.Method != "" {
+			q.ids = append(q.ids, message.Id)
+			n++
+		}
+	}
+	return n
+}
+
+// correlate gives resp the id of the oldest request awaiting a response, unless resp is a request or
+// notification sent by the server.
+func (q *positionalQueue) correlate(resp *Response) {
+	if resp.Method != "" {
+		return
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.ids) == 0 {
+		// unexpected, so left to the unmatched handler
+		return
+	}
+	resp.Id = q.ids[0]
+	q.ids = q.ids[1:]
+}
+
+// discard removes the ids of the requests in data, a message which could not be exchanged, so that
+// they are not given to the responses of other requests.
+func (q *positionalQueue) discard(data []byte) {
+	var requests []Request
+	if isBatch(data) {
+		if err := json.Unmarshal(data, &requests); err != nil {
+			return
+		}
+	} else {
+		requests = make([]Request, 1)
+		if err := json.Unmarshal(data, &requests[0]); err != nil {
+			return
+		}
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, req := range requests {
+		for i, id := range q.ids {
+			if string(id) == string(req.Id) {
+				q.ids = append(q.ids[:i], q.ids[i+1:]...)
+				break
+			}
+		}
+	}
+}
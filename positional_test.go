@@ -0,0 +1,118 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/41north/jsonrpc.go"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// serveWithoutIds handles the requests received on conn one at a time, stripping the ids from the
+// responses.
+func serveWithoutIds(server *jsonrpc.Server, conn jsonrpc.Connection) {
+	strip := func(data json.RawMessage) json.RawMessage {
+		var fields map[string]json.RawMessage
+		if json.Unmarshal(data, &fields) != nil {
+			return data
+		}
+		delete(fields, "id")
+		stripped, _ := json.Marshal(fields)
+		return stripped
+	}
+
+	for {
+		data, err := conn.Read()
+		if err != nil {
+			return
+		}
+		resp := server.Handle(context.Background(), data)
+		if resp == nil {
+			continue
+		}
+		var batch []json.RawMessage
+		if json.Unmarshal(resp, &batch) == nil {
+			for i := range batch {
+				batch[i] = strip(batch[i])
+			}
+			resp, _ = json.Marshal(batch)
+		} else {
+			resp = strip(resp)
+		}
+		if conn.Write(resp) != nil {
+			return
+		}
+	}
+}
+
+func TestClient_PositionalCorrelation(t *testing.T) {
+	server := jsonrpc.NewServer()
+	server.Register("echo", echo)
+	server.Register("log", func(ctx context.Context, req jsonrpc.Request) (any, error) {
+		return nil, nil
+	})
+
+	clientConn, serverConn := net.Pipe()
+	go serveWithoutIds(server, jsonrpc.NewStreamConnection(serverConn, jsonrpc.FramingNewline))
+	client := jsonrpc.NewClientWithConnection(
+		jsonrpc.NewStreamConnection(clientConn, jsonrpc.FramingNewline),
+		jsonrpc.WithPositionalCorrelation(),
+	)
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	// pipelined requests are matched in the order they were sent, notifications not counting
+	var futures []jsonrpc.ResponseFuture
+	for i := 0; i < 5; i++ {
+		futures = append(futures, client.SendAsync(*newRequest("echo", []int{i})))
+		assert.Nil(t, client.NotifyBatch([]jsonrpc.Request{*newRequest("log", nil)}))
+	}
+	for i, future := range futures {
+		resp, err := (<-future.Get()).Unwrap()
+		assert.Nil(t, err)
+		var result []int
+		assert.Nil(t, resp.UnmarshalResult(&result))
+		assert.Equal(t, []int{i}, result)
+	}
+
+	// as are the elements of a batch
+	var batch jsonrpc.BatchRequest
+	batch.Add(*newRequest("echo", []int{5}), time.Second)
+	batch.Add(*newRequest("echo", []int{6}), time.Second)
+	responses, err := client.SendBatchContext(context.Background(), batch)
+	assert.Nil(t, err)
+	for i, resp := range responses {
+		var result []int
+		assert.Nil(t, resp.UnmarshalResult(&result))
+		assert.Equal(t, []int{i + 5}, result)
+	}
+}
+
+func TestClient_WithoutPositionalCorrelation(t *testing.T) {
+	server := jsonrpc.NewServer()
+	server.Register("echo", echo)
+
+	clientConn, serverConn := net.Pipe()
+	go serveWithoutIds(server, jsonrpc.NewStreamConnection(serverConn, jsonrpc.FramingNewline))
+	client := jsonrpc.NewClientWithConnection(jsonrpc.NewStreamConnection(clientConn, jsonrpc.FramingNewline))
+
+	unmatched := make(chan jsonrpc.Response, 1)
+	client.SetUnmatchedHandler(func(resp jsonrpc.Response) {
+		unmatched <- resp
+	})
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	// responses without ids cannot be matched by default
+	future := client.SendAsync(*newRequest("echo", []int{1}))
+	assert.Nil(t, (<-unmatched).Id)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := (<-jsonrpc.GetContext(ctx, future)).Unwrap()
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,187 @@
+package jsonrpc
+
+import (
+	"container/heap"
+	"sync"
+)
+
+// Priority orders requests which are waiting for a slot when the number in flight is limited, see
+// WithMaxInFlight and WithPriorityQueue.
+type Priority int
+
+const (
+	PriorityLow Priority = iota - 1
+	PriorityNormal
+	PriorityHigh
+)
+
+// WithMaxInFlight limits the number of requests awaiting a response to n. Further requests wait
+// until a slot is freed by a response, in the order they were sent unless WithPriorityQueue is
+// also set. A batch takes a slot for each of its elements, which it holds until every element has
+// completed. Auto batched requests and those held in the offline queue are not counted.
+func WithMaxInFlight(n int) ClientOption {
+	return func(opts *ClientOptions) {
+		opts.MaxInFlight = n
+	}
+}
+
+// WithPriorityQueue orders requests waiting for an in flight slot by priority, then by the order
+// they were sent, so that interactive calls made with SendWithPriority are not held up behind
+// bulk work. It has no effect unless WithMaxInFlight is also set.
+func WithPriorityQueue() ClientOption {
+	return func(opts *ClientOptions) {
+		opts.PriorityQueue = true
+	}
+}
+
+// pendingSend is a request, or a batch, waiting for in flight slots.
+type pendingSend struct {
+	priority Priority
+	seq      uint64
+	// weight is the number of slots needed, one for each request
+	weight int
+	send   func()
+	fail   func(err error)
+}
+
+// pendingHeap orders pending sends by priority, highest first, then by sequence.
+type pendingHeap []*pendingSend
+
+func (h pendingHeap) Len() int { return len(h) }
+
+func (h pendingHeap) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority > h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h pendingHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *pendingHeap) Push(x any) { *h = append(*h, x.(*pendingSend)) }
+
+func (h *pendingHeap) Pop() any {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return item
+}
+
+// admission gates the number of requests in flight, holding the rest until a slot is released.
+type admission struct {
+	mu       sync.Mutex
+	limit    int
+	inFlight int
+	pending  pendingHeap
+	seq      uint64
+	closed   bool
+}
+
+func newAdmission(limit int) *admission {
+	return &admission{limit: limit}
+}
+
+// admit calls send once a slot is available, or fail if the client closes first.
+func (a *admission) admit(priority Priority, send func(), fail func(err error)) {
+	a.admitN(1, priority, send, fail)
+}
+
+// admitN calls send once n slots are available, or fail if the client closes first. Sends are
+// admitted in order, so a send which needs more slots than are free holds up those queued behind
+// it. A send which needs more slots than the limit is admitted once nothing else is in flight.
+func (a *admission) admitN(n int, priority Priority, send func(), fail func(err error)) {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		fail(ErrClosed)
+		return
+	}
+	if a.pending.Len() == 0 && a.fits(n) {
+		a.inFlight += n
+		a.mu.Unlock()
+		send()
+		return
+	}
+	a.seq++
+	heap.Push(&a.pending, &pendingSend{priority: priority, seq: a.seq, weight: n, send: send, fail: fail})
+	a.mu.Unlock()
+}
+
+// fits returns true if n more slots can be taken. It must be called with mu held.
+func (a *admission) fits(n int) bool {
+	return a.inFlight+n <= a.limit || (a.inFlight == 0 && n > a.limit)
+}
+
+// release frees a slot, handing it to the next pending send if there is one and the limit has not
+// been lowered below the number in flight.
+func (a *admission) release() {
+	a.releaseN(1)
+}
+
+// releaseN frees n slots, starting as many pending sends as they make room for.
+func (a *admission) releaseN(n int) {
+	a.mu.Lock()
+	a.inFlight -= n
+	ready := a.ready()
+	a.mu.Unlock()
+
+	for _, p := range ready {
+		p.send()
+	}
+}
+
+// ready takes the pending sends which can now be admitted, in order. It must be called with mu
+// held.
+func (a *admission) ready() []*pendingSend {
+	var ready []*pendingSend
+	for a.pending.Len() > 0 && a.fits(a.pending[0].weight) {
+		next := heap.Pop(&a.pending).(*pendingSend)
+		a.inFlight += next.weight
+		ready = append(ready, next)
+	}
+	return ready
+}
+
+// currentLimit returns the limit on the number of requests in flight.
+func (a *admission) currentLimit() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.limit
+}
+
+// setLimit changes the limit, starting as many pending sends as the new limit allows. Lowering
+// the limit does not affect requests already in flight, the number in flight falls to the new
+// limit as they complete.
+func (a *admission) setLimit(limit int) {
+	a.mu.Lock()
+	a.limit = limit
+	ready := a.ready()
+	a.mu.Unlock()
+
+	for _, p := range ready {
+		p.send()
+	}
+}
+
+// close fails every pending send with ErrClosed.
+func (a *admission) close() {
+	a.mu.Lock()
+	a.closed = true
+	pending := a.pending
+	a.pending = nil
+	a.mu.Unlock()
+
+	for _, p := range pending {
+		p.fail(ErrClosed)
+	}
+}
+
+func (c *client) SendWithPriority(req Request, priority Priority) ResponseFuture {
+	if !c.opts.PriorityQueue {
+		priority = PriorityNormal
+	}
+	future, _ := c.sendAsync(req, priority, false)
+	return future
+}
@@ -0,0 +1,115 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/41north/jsonrpc.go"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_PriorityQueue(t *testing.T) {
+	release := make(chan struct{})
+	server := endpoint("a", release)
+
+	var mu sync.Mutex
+	var order []string
+	server.Register("record", func(ctx context.Context, req jsonrpc.Request) (any, error) {
+		var params []string
+		_ = req.UnmarshalParams(&params)
+		mu.Lock()
+		order = append(order, params[0])
+		mu.Unlock()
+		return nil, nil
+	})
+
+	client := jsonrpc.NewClient(
+		serverDialer(server, nil),
+		jsonrpc.WithMaxInFlight(1),
+		jsonrpc.WithPriorityQueue(),
+	)
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	// occupy the only slot, so that the rest queue up
+	futures := []jsonrpc.ResponseFuture{client.SendAsync(*newRequest("wait", nil))}
+
+	sends := []struct {
+		name     string
+		priority jsonrpc.Priority
+	}{
+		{"low-1", jsonrpc.PriorityLow},
+		{"normal-1", jsonrpc.PriorityNormal},
+		{"high-1", jsonrpc.PriorityHigh},
+		{"low-2", jsonrpc.PriorityLow},
+		{"high-2", jsonrpc.PriorityHigh},
+		{"normal-2", jsonrpc.PriorityNormal},
+	}
+	for _, send := range sends {
+		futures = append(futures, client.SendWithPriority(*newRequest("record", []string{send.name}), send.priority))
+	}
+
+	close(release)
+	_, err := jsonrpc.WaitAll(context.Background(), futures)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"high-1", "high-2", "normal-1", "normal-2", "low-1", "low-2"}, order)
+
+	// requests still waiting fail on close
+	release = make(chan struct{})
+	defer close(release)
+	blocked := jsonrpc.NewClient(serverDialer(endpoint("b", release), nil), jsonrpc.WithMaxInFlight(1))
+	assert.Nil(t, blocked.Connect())
+	blocked.SendAsync(*newRequest("wait", nil))
+	waiting := blocked.SendAsync(*newRequest("name", nil))
+	assert.Nil(t, blocked.Close())
+
+	_, err = (<-waiting.Get()).Unwrap()
+	assert.ErrorIs(t, err, jsonrpc.ErrClosed)
+}
+
+func TestClient_MaxInFlightBatch(t *testing.T) {
+	release := make(chan struct{})
+	client := jsonrpc.NewClient(serverDialer(endpoint("a", release), nil), jsonrpc.WithMaxInFlight(2))
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	// occupy one of the slots, so that the batch waits for a slot for each of its elements
+	futures := []jsonrpc.ResponseFuture{client.SendAsync(*newRequest("wait", nil))}
+	futures = append(futures, client.SendBatch(context.Background(), jsonrpc.BatchRequest{Requests: []jsonrpc.TimedRequest{
+		{Request: *newRequest("name", nil)},
+		{Request: *newRequest("name", nil)},
+	}})...)
+	// and requests sent after it wait behind it
+	futures = append(futures, client.SendAsync(*newRequest("name", nil)))
+	assert.Equal(t, map[string]int{"": 1}, client.InFlightByTenant())
+
+	close(release)
+	_, err := jsonrpc.WaitAll(context.Background(), futures)
+	assert.Nil(t, err)
+	assert.Empty(t, client.InFlightByTenant())
+
+	// a batch larger than the limit is sent once nothing else is in flight
+	responses, err := client.SendBatchContext(context.Background(), jsonrpc.BatchRequest{Requests: []jsonrpc.TimedRequest{
+		{Request: *newRequest("name", nil)},
+		{Request: *newRequest("name", nil)},
+		{Request: *newRequest("name", nil)},
+	}})
+	assert.Nil(t, err)
+	assert.Len(t, responses, 3)
+
+	// batches waiting for slots fail on close
+	release = make(chan struct{})
+	defer close(release)
+	blocked := jsonrpc.NewClient(serverDialer(endpoint("b", release), nil), jsonrpc.WithMaxInFlight(1))
+	assert.Nil(t, blocked.Connect())
+	blocked.SendAsync(*newRequest("wait", nil))
+	waiting := blocked.SendBatch(context.Background(), jsonrpc.BatchRequest{Requests: []jsonrpc.TimedRequest{
+		{Request: *newRequest("name", nil)},
+	}})
+	assert.Nil(t, blocked.Close())
+
+	_, err = (<-waiting[0].Get()).Unwrap()
+	assert.ErrorIs(t, err, jsonrpc.ErrClosed)
+}
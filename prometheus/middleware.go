@@ -0,0 +1,126 @@
+// Package prometheus provides a jsonrpc.Observer which exposes client metrics to Prometheus, and a
+// jsonrpc.HandlerObserver which does the same for servers.
+package prometheus
+
+import (
+	"strconv"
+
+	"github.com/41north/jsonrpc.go"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultPayloadBuckets are the default histogram buckets, in bytes, for payload sizes.
+var DefaultPayloadBuckets = []float64{64, 256, 1024, 4096, 16384, 65536, 262144}
+
+// WithPayloadBuckets sets the histogram buckets, in bytes, used for payload sizes.
+func WithPayloadBuckets(buckets []float64) Option {
+	return func(opts *Options) {
+		opts.PayloadBuckets = buckets
+	}
+}
+
+type Option = func(opts *Options)
+
+type Options struct {
+	PayloadBuckets  []float64
+	DurationBuckets []float64
+}
+
+func DefaultOptions() Options {
+	return Options{
+		PayloadBuckets:  DefaultPayloadBuckets,
+		DurationBuckets: DefaultDurationBuckets,
+	}
+}
+
+// Middleware collects client metrics. It must be registered with a prometheus registry and passed
+// to the client with jsonrpc.WithObserver.
+type Middleware struct {
+	requestBytes      *prom.HistogramVec
+	responseBytes     *prom.HistogramVec
+	unsolicitedErrors *prom.CounterVec
+	transferredBytes  *prom.CounterVec
+	refusedRetries    *prom.CounterVec
+}
+
+var (
+	_ jsonrpc.UnsolicitedErrorObserver = &Middleware{}
+	_ jsonrpc.RetryBudgetObserver      = &Middleware{}
+	_ jsonrpc.ByteObserver             = &Middleware{}
+	_ prom.Collector                   = &Middleware{}
+)
+
+func NewMiddleware(options ...Option) *Middleware {
+	opts := DefaultOptions()
+	for _, opt := range options {
+		opt(&opts)
+	}
+
+	return &Middleware{
+		requestBytes: prom.NewHistogramVec(prom.HistogramOpts{
+			Name:    "jsonrpc_client_request_bytes",
+			Help:    "Size in bytes of the json of requests sent.",
+			Buckets: opts.PayloadBuckets,
+		}, []string{"method"}),
+		responseBytes: prom.NewHistogramVec(prom.HistogramOpts{
+			Name:    "jsonrpc_client_response_bytes",
+			Help:    "Size in bytes of the json of responses received.",
+			Buckets: opts.PayloadBuckets,
+		}, []string{"method"}),
+		unsolicitedErrors: prom.NewCounterVec(prom.CounterOpts{
+			Name: "jsonrpc_client_unsolicited_errors_total",
+			Help: "Number of error responses received with a null id.",
+		}, []string{"code"}),
+		transferredBytes: prom.NewCounterVec(prom.CounterOpts{
+			Name: "jsonrpc_client_transferred_bytes_total",
+			Help: "Number of bytes read and written by metered connections.",
+		}, []string{"direction"}),
+		refusedRetries: prom.NewCounterVec(prom.CounterOpts{
+			Name: "jsonrpc_client_retry_budget_exhausted_total",
+			Help: "Number of retries refused because the retry budget was empty.",
+		}, []string{"method"}),
+	}
+}
+
+func (m *Middleware) OnRequest(method string, size int) {
+	m.requestBytes.WithLabelValues(method).Observe(float64(size))
+}
+
+func (m *Middleware) OnResponse(method string, size int) {
+	m.responseBytes.WithLabelValues(method).Observe(float64(size))
+}
+
+func (m *Middleware) OnUnsolicitedError(err jsonrpc.Error) {
+	m.unsolicitedErrors.WithLabelValues(strconv.Itoa(int(err.Code))).Inc()
+}
+
+func (m *Middleware) OnRetryBudgetExhausted(method string) {
+	m.refusedRetries.WithLabelValues(method).Inc()
+}
+
+// OnBytesRead and OnBytesWritten count the traffic of connections wrapped with
+// jsonrpc.NewMeteredConnection, when the middleware is passed to jsonrpc.MeterObserver.
+func (m *Middleware) OnBytesRead(n int) {
+	m.transferredBytes.WithLabelValues("read").Add(float64(n))
+}
+
+func (m *Middleware) OnBytesWritten(n int) {
+	m.transferredBytes.WithLabelValues("written").Add(float64(n))
+}
+
+func (m *Middleware) Describe(ch chan<- *prom.Desc) {
+	m.requestBytes.Describe(ch)
+	m.responseBytes.Describe(ch)
+	m.unsolicitedErrors.Describe(ch)
+	m.transferredBytes.Describe(ch)
+	m.refusedRetries.Describe(ch)
+}
+
+func (m *Middleware) Collect(ch chan<- prom.Metric) {
+	m.requestBytes.Collect(ch)
+	m.responseBytes.Collect(ch)
+	m.unsolicitedErrors.Collect(ch)
+	m.transferredBytes.Collect(ch)
+	m.refusedRetries.Collect(ch)
+}
@@ -0,0 +1,194 @@
+package prometheus_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+
+	"github.com/41north/jsonrpc.go"
+	"github.com/41north/jsonrpc.go/prometheus"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware_PayloadHistograms(t *testing.T) {
+	mw := prometheus.NewMiddleware(prometheus.WithPayloadBuckets([]float64{32, 128}))
+
+	registry := prom.NewRegistry()
+	assert.Nil(t, registry.Register(mw))
+
+	clientConn, serverConn := net.Pipe()
+	go echo(jsonrpc.NewStreamConnection(serverConn, jsonrpc.FramingNewline))
+
+	client := jsonrpc.NewClientWithConnection(
+		jsonrpc.NewStreamConnection(clientConn, jsonrpc.FramingNewline),
+		jsonrpc.WithObserver(mw),
+	)
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	req, err := jsonrpc.NewRequest("echo", "hello", jsonrpc.RequestNumericId(1))
+	assert.Nil(t, err)
+
+	var resp jsonrpc.Response
+	assert.Nil(t, client.Send(*req, &resp))
+
+	families, err := registry.Gather()
+	assert.Nil(t, err)
+	assert.Len(t, families, 2)
+
+	// {"id":1,"method":"echo","params":"hello","jsonrpc":"2.0"}
+	// {"id":1,"result":"hello","jsonrpc":"2.0"}
+	expected := map[string]float64{
+		"jsonrpc_client_request_bytes":  57,
+		"jsonrpc_client_response_bytes": 41,
+	}
+
+	for _, family := range families {
+		assert.Len(t, family.Metric, 1)
+		metric := family.Metric[0]
+
+		assert.Equal(t, "method", metric.Label[0].GetName())
+		assert.Equal(t, "echo", metric.Label[0].GetValue())
+
+		histogram := metric.GetHistogram()
+		assert.Equal(t, uint64(1), histogram.GetSampleCount())
+		assert.Equal(t, expected[family.GetName()], histogram.GetSampleSum())
+		assert.Len(t, histogram.Bucket, 2)
+	}
+}
+
+func TestMiddleware_UnsolicitedErrors(t *testing.T) {
+	mw := prometheus.NewMiddleware()
+
+	registry := prom.NewRegistry()
+	assert.Nil(t, registry.Register(mw))
+
+	clientConn, serverConn := net.Pipe()
+	server := jsonrpc.NewStreamConnection(serverConn, jsonrpc.FramingNewline)
+
+	client := jsonrpc.NewClientWithConnection(
+		jsonrpc.NewStreamConnection(clientConn, jsonrpc.FramingNewline),
+		jsonrpc.WithObserver(mw),
+	)
+	received := make(chan struct{}, 2)
+	client.OnUnsolicitedError(func(err *jsonrpc.Error) {
+		received <- struct{}{}
+	})
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	for i := 0; i < 2; i++ {
+		assert.Nil(t, server.Write([]byte(`{"id":null,"error":{"code":-32600,"message":"invalid request"},"jsonrpc":"2.0"}`)))
+		<-received
+	}
+
+	families, err := registry.Gather()
+	assert.Nil(t, err)
+	assert.Len(t, families, 1)
+	assert.Equal(t, "jsonrpc_client_unsolicited_errors_total", families[0].GetName())
+	assert.Equal(t, "-32600", families[0].Metric[0].Label[0].GetValue())
+	assert.Equal(t, float64(2), families[0].Metric[0].GetCounter().GetValue())
+}
+
+// echo replies to every request with its params as the result.
+func echo(conn jsonrpc.Connection) {
+	for {
+		bytes, err := conn.Read()
+		if err != nil {
+			return
+		}
+		var req jsonrpc.Request
+		if err := json.Unmarshal(bytes, &req); err != nil {
+			return
+		}
+		bytes, err = json.Marshal(jsonrpc.Response{Id: req.Id, Result: req.Params, Version: req.Version})
+		if err != nil {
+			return
+		}
+		if err := conn.Write(bytes); err != nil {
+			return
+		}
+	}
+}
+
+func TestMiddleware_TransferredBytes(t *testing.T) {
+	mw := prometheus.NewMiddleware()
+
+	registry := prom.NewRegistry()
+	assert.Nil(t, registry.Register(mw))
+
+	clientConn, serverConn := net.Pipe()
+	go echo(jsonrpc.NewStreamConnection(serverConn, jsonrpc.FramingNewline))
+
+	client := jsonrpc.NewClientWithConnection(jsonrpc.NewMeteredConnection(
+		jsonrpc.NewStreamConnection(clientConn, jsonrpc.FramingNewline),
+		jsonrpc.MeterObserver(mw),
+	))
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	req, err := jsonrpc.NewRequest("echo", "hello", jsonrpc.RequestNumericId(1))
+	assert.Nil(t, err)
+
+	var resp jsonrpc.Response
+	assert.Nil(t, client.Send(*req, &resp))
+
+	families, err := registry.Gather()
+	assert.Nil(t, err)
+	assert.Len(t, families, 1)
+	assert.Equal(t, "jsonrpc_client_transferred_bytes_total", families[0].GetName())
+
+	expected := map[string]float64{"read": 41, "written": 57}
+	assert.Len(t, families[0].Metric, 2)
+	for _, metric := range families[0].Metric {
+		assert.Equal(t, expected[metric.Label[0].GetValue()], metric.GetCounter().GetValue())
+	}
+}
+
+func TestMiddleware_RetryBudgetExhausted(t *testing.T) {
+	mw := prometheus.NewMiddleware()
+
+	registry := prom.NewRegistry()
+	assert.Nil(t, registry.Register(mw))
+
+	clientConn, serverConn := net.Pipe()
+	go echo(jsonrpc.NewStreamConnection(serverConn, jsonrpc.FramingNewline))
+
+	failing := func(ctx context.Context, req jsonrpc.Request, invoker jsonrpc.UnaryInvoker) (jsonrpc.Response, error) {
+		resp, _ := invoker(ctx, req)
+		resp.Error = &jsonrpc.ErrInternal
+		return resp, nil
+	}
+	client := jsonrpc.NewClientWithConnection(
+		jsonrpc.NewStreamConnection(clientConn, jsonrpc.FramingNewline),
+		jsonrpc.WithObserver(mw),
+		jsonrpc.WithRetryBudget(0, 0),
+		jsonrpc.WithInterceptors(
+			jsonrpc.RetryInterceptor(jsonrpc.WithIdempotentMethods("echo"), jsonrpc.WithRetryBackoff(nil)),
+			failing,
+		),
+	)
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	req, err := jsonrpc.NewRequest("echo", "hello", jsonrpc.RequestNumericId(1))
+	assert.Nil(t, err)
+
+	var resp jsonrpc.Response
+	assert.ErrorIs(t, client.Send(*req, &resp), jsonrpc.ErrRetryBudgetExhausted)
+
+	families, err := registry.Gather()
+	assert.Nil(t, err)
+	for _, family := range families {
+		if family.GetName() != "jsonrpc_client_retry_budget_exhausted_total" {
+			continue
+		}
+		assert.Equal(t, "echo", family.Metric[0].Label[0].GetValue())
+		assert.Equal(t, float64(1), family.Metric[0].GetCounter().GetValue())
+		return
+	}
+	t.Fatal("retry budget metric not gathered")
+}
@@ -0,0 +1,78 @@
+package prometheus
+
+import (
+	"time"
+
+	"github.com/41north/jsonrpc.go"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultDurationBuckets are the default histogram buckets, in seconds, for handler durations.
+var DefaultDurationBuckets = prom.DefBuckets
+
+// WithDurationBuckets sets the histogram buckets, in seconds, used for handler durations.
+func WithDurationBuckets(buckets []float64) Option {
+	return func(opts *Options) {
+		opts.DurationBuckets = buckets
+	}
+}
+
+// ServerMetrics collects server metrics. It must be registered with a prometheus registry and
+// passed to the server with jsonrpc.ServerHandlerObserver, or both can be done with WithMetrics.
+type ServerMetrics struct {
+	requestDuration *prom.HistogramVec
+	activeHandlers  prom.Gauge
+}
+
+var (
+	_ jsonrpc.HandlerObserver = &ServerMetrics{}
+	_ prom.Collector          = &ServerMetrics{}
+)
+
+func NewServerMetrics(options ...Option) *ServerMetrics {
+	opts := DefaultOptions()
+	for _, opt := range options {
+		opt(&opts)
+	}
+
+	return &ServerMetrics{
+		requestDuration: prom.NewHistogramVec(prom.HistogramOpts{
+			Name:    "jsonrpc_server_request_duration_seconds",
+			Help:    "Time in seconds taken by handlers, from when they are called after middleware.",
+			Buckets: opts.DurationBuckets,
+		}, []string{"method", "status"}),
+		activeHandlers: prom.NewGauge(prom.GaugeOpts{
+			Name: "jsonrpc_server_active_handlers",
+			Help: "Number of handlers currently running.",
+		}),
+	}
+}
+
+// WithMetrics creates ServerMetrics, registering them with reg, and returns the option which
+// passes them to the server. It panics if the metrics cannot be registered, e.g. because they
+// already have been.
+func WithMetrics(reg prom.Registerer, options ...Option) jsonrpc.ServerOption {
+	metrics := NewServerMetrics(options...)
+	reg.MustRegister(metrics)
+	return jsonrpc.ServerHandlerObserver(metrics)
+}
+
+func (m *ServerMetrics) OnHandlerStart(method string) {
+	m.activeHandlers.Inc()
+}
+
+func (m *ServerMetrics) OnHandlerEnd(method string, duration time.Duration, status jsonrpc.HandlerStatus) {
+	m.activeHandlers.Dec()
+	m.requestDuration.WithLabelValues(method, string(status)).Observe(duration.Seconds())
+}
+
+func (m *ServerMetrics) Describe(ch chan<- *prom.Desc) {
+	m.requestDuration.Describe(ch)
+	m.activeHandlers.Describe(ch)
+}
+
+func (m *ServerMetrics) Collect(ch chan<- prom.Metric) {
+	m.requestDuration.Collect(ch)
+	m.activeHandlers.Collect(ch)
+}
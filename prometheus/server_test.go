@@ -0,0 +1,90 @@
+package prometheus_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/41north/jsonrpc.go"
+	"github.com/41north/jsonrpc.go/prometheus"
+	"github.com/41north/jsonrpc.go/testutil"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerMetrics(t *testing.T) {
+	registry := prom.NewRegistry()
+	clock := testutil.NewClock(time.Time{})
+	server := jsonrpc.NewServer(prometheus.WithMetrics(registry), jsonrpc.ServerClock(clock))
+
+	gauge := func() float64 {
+		families, err := registry.Gather()
+		assert.Nil(t, err)
+		for _, family := range families {
+			if family.GetName() == "jsonrpc_server_active_handlers" {
+				return family.Metric[0].GetGauge().GetValue()
+			}
+		}
+		return -1
+	}
+
+	// time spent in middleware is not counted
+	server.Use(func(next jsonrpc.Handler) jsonrpc.Handler {
+		return func(ctx context.Context, req jsonrpc.Request) (any, error) {
+			clock.Advance(time.Second)
+			return next(ctx, req)
+		}
+	})
+	server.Register("ok", func(ctx context.Context, req jsonrpc.Request) (any, error) {
+		assert.Equal(t, float64(1), gauge())
+		clock.Advance(10 * time.Millisecond)
+		return true, nil
+	})
+	server.Register("fail", func(ctx context.Context, req jsonrpc.Request) (any, error) {
+		return nil, jsonrpc.ErrInvalidParams
+	})
+	server.Register("panic", func(ctx context.Context, req jsonrpc.Request) (any, error) {
+		panic("boom")
+	})
+	server.Register("deadline", func(ctx context.Context, req jsonrpc.Request) (any, error) {
+		return nil, context.DeadlineExceeded
+	})
+
+	for _, method := range []string{"ok", "ok", "fail", "panic", "deadline"} {
+		server.Handle(context.Background(), []byte(`{"id":1,"method":"`+method+`","jsonrpc":"2.0"}`))
+	}
+	assert.Equal(t, float64(0), gauge())
+
+	families, err := registry.Gather()
+	assert.Nil(t, err)
+
+	counts := make(map[string]uint64)
+	for _, family := range families {
+		if family.GetName() != "jsonrpc_server_request_duration_seconds" {
+			continue
+		}
+		for _, metric := range family.Metric {
+			counts[label(metric, "method")+"/"+label(metric, "status")] = metric.GetHistogram().GetSampleCount()
+			if label(metric, "method") == "ok" {
+				assert.InDelta(t, 0.02, metric.GetHistogram().GetSampleSum(), 1e-9)
+			}
+		}
+	}
+	assert.Equal(t, map[string]uint64{
+		"ok/success":          2,
+		"fail/rpc_error":      1,
+		"panic/handler_panic": 1,
+		"deadline/timeout":    1,
+	}, counts)
+}
+
+func label(metric *dto.Metric, name string) string {
+	for _, l := range metric.Label {
+		if l.GetName() == name {
+			return l.GetValue()
+		}
+	}
+	return ""
+}
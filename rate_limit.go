@@ -0,0 +1,306 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrQuotaExceeded is the default error sent in response to requests over their quota, see
+	// RateLimitError. Its data holds a retry after hint, see InspectRetryAfter, and clients
+	// recognise it as ErrRateLimited, see TranslateServerBusy.
+	ErrQuotaExceeded = Error{
+		Code:    -32005,
+		Message: "rate limit exceeded",
+	}
+
+	// ErrCostExceedsBurst is sent in response to requests whose cost is more than the limiter of
+	// their key can ever allow, see BurstLimiter. Unlike ErrQuotaExceeded it has no retry after
+	// hint, as retrying cannot succeed.
+	ErrCostExceedsBurst = Error{
+		Code:    -32006,
+		Message: "request cost exceeds rate limit burst",
+	}
+)
+
+// DefaultRateLimitIdleTimeout is the default time after which the limiter of a key which has not
+// been used is discarded.
+const DefaultRateLimitIdleTimeout = 5 * time.Minute
+
+// RateLimiter holds the quota of a single key, see NewRateLimit.
+type RateLimiter interface {
+	// Allow takes cost tokens and returns true if they are available, otherwise it returns false
+	// along with how long the caller should wait before trying again.
+	Allow(cost float64) (bool, time.Duration)
+}
+
+// BurstLimiter is a RateLimiter which allows no more than a burst of tokens at once. RateLimit
+// rejects requests which cost more than the burst with ErrCostExceedsBurst, rather than asking
+// them to retry.
+type BurstLimiter interface {
+	RateLimiter
+	Burst() float64
+}
+
+// TokenBucket is a RateLimiter which refills at rate tokens per second, holding at most burst.
+// It starts full.
+type TokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	clock  Clock
+}
+
+func NewTokenBucket(rate float64, burst float64) *TokenBucket {
+	clock := RealClock()
+	return &TokenBucket{rate: rate, burst: burst, tokens: burst, last: clock.Now(), clock: clock}
+}
+
+// SetClock sets the clock with which the bucket is refilled, see Clock. The bucket is full as of
+// the current time of clock.
+func (b *TokenBucket) SetClock(clock Clock) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.clock = clock
+	b.tokens = b.burst
+	b.last = clock.Now()
+}
+
+// SetLimit changes the rate and burst of the bucket, keeping the tokens it holds up to the new
+// burst.
+func (b *TokenBucket) SetLimit(rate float64, burst float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(b.clock.Now())
+	b.rate = rate
+	b.burst = burst
+	b.tokens = math.Min(b.tokens, burst)
+}
+
+// Burst returns the most tokens the bucket can hold.
+func (b *TokenBucket) Burst() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.burst
+}
+
+// Allow takes cost tokens if they are available. A cost greater than the burst is never allowed,
+// and is refused without a wait.
+func (b *TokenBucket) Allow(cost float64) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(b.clock.Now())
+	if b.tokens >= cost {
+		b.tokens -= cost
+		return true, 0
+	}
+	if b.rate <= 0 || cost > b.burst {
+		return false, 0
+	}
+	return false, time.Duration((cost - b.tokens) / b.rate * float64(time.Second))
+}
+
+func (b *TokenBucket) refill(now time.Time) {
+	b.tokens = math.Min(b.tokens+now.Sub(b.last).Seconds()*b.rate, b.burst)
+	b.last = now
+}
+
+// RateLimitCost sets the number of tokens taken by a request for method. Methods without a cost
+// take one token.
+func RateLimitCost(method string, cost float64) RateLimitOption {
+	return func(opts *RateLimitOptions) {
+		if opts.Costs == nil {
+			opts.Costs = make(map[string]float64)
+		}
+		opts.Costs[method] = cost
+	}
+}
+
+// RateLimitError sets the error sent in response to requests over their quota, in place of
+// ErrQuotaExceeded. Any data it holds is replaced with the retry after hint.
+func RateLimitError(e Error) RateLimitOption {
+	return func(opts *RateLimitOptions) {
+		opts.Error = e
+	}
+}
+
+// RateLimitIdleTimeout sets the time after which the limiter of a key which has not been used is
+// discarded, bounding the number held. A key which returns starts again with a new limiter.
+func RateLimitIdleTimeout(d time.Duration) RateLimitOption {
+	return func(opts *RateLimitOptions) {
+		opts.IdleTimeout = d
+	}
+}
+
+// RateLimitClock sets the clock used to discard idle limiters, see Clock. Limiters which depend on
+// time, such as TokenBucket, are given their clock when created.
+func RateLimitClock(clock Clock) RateLimitOption {
+	return func(opts *RateLimitOptions) {
+		opts.Clock = clock
+	}
+}
+
+type RateLimitOption = func(opts *RateLimitOptions)
+
+type RateLimitOptions struct {
+	Costs       map[string]float64
+	Error       Error
+	IdleTimeout time.Duration
+	Clock       Clock
+}
+
+func DefaultRateLimitOptions() RateLimitOptions {
+	return RateLimitOptions{
+		Error:       ErrQuotaExceeded,
+		IdleTimeout: DefaultRateLimitIdleTimeout,
+		Clock:       RealClock(),
+	}
+}
+
+type rateLimitEntry struct {
+	limiter  RateLimiter
+	lastUsed time.Time
+}
+
+// RateLimit limits the requests handled by a Server per caller, as identified by a key such as a
+// tenant or api key. Each key is given its own RateLimiter, and each request takes the cost of its
+// method from it. Requests over quota are answered with an error holding a retry after hint, and
+// those which cost more than a BurstLimiter can ever allow with ErrCostExceedsBurst, while
+// notifications over quota are dropped and counted. Install it with Server.Use(r.Middleware()).
+type RateLimit struct {
+	key  func(ctx context.Context, info ConnectionInfo) string
+	opts RateLimitOptions
+
+	mu        sync.Mutex
+	newLimit  func(key string) RateLimiter
+	costs     map[string]float64
+	limiters  map[string]*rateLimitEntry
+	lastSweep time.Time
+
+	dropped atomic.Uint64
+}
+
+// NewRateLimit creates a rate limit keyed by key, which is passed the context of the request and
+// the connection it was received on, see ConnectionInfoFrom. Requests for which key returns an
+// empty string are not limited. limiter creates the limiter of a key when it is first seen.
+func NewRateLimit(
+	key func(ctx context.Context, info ConnectionInfo) string,
+	limiter func(key string) RateLimiter,
+	options ...RateLimitOption,
+) *RateLimit {
+	opts := DefaultRateLimitOptions()
+	for _, opt := range options {
+		opt(&opts)
+	}
+	costs := make(map[string]float64, len(opts.Costs))
+	for method, cost := range opts.Costs {
+		costs[method] = cost
+	}
+	return &RateLimit{
+		key:       key,
+		opts:      opts,
+		newLimit:  limiter,
+		costs:     costs,
+		limiters:  make(map[string]*rateLimitEntry),
+		lastSweep: opts.Clock.Now(),
+	}
+}
+
+// SetLimiter replaces the function which creates the limiter of a key. The limiters already created
+// are discarded along with their state, so every key starts afresh with a new limiter from its next
+// request, with any quota it had used forgotten. To change a limit without resetting it, keep the
+// limiters created and update them in place, e.g. with TokenBucket.SetLimit.
+func (r *RateLimit) SetLimiter(limiter func(key string) RateLimiter) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.newLimit = limiter
+	r.limiters = make(map[string]*rateLimitEntry)
+}
+
+// SetCost sets the number of tokens taken by a request for method.
+func (r *RateLimit) SetCost(method string, cost float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.costs[method] = cost
+}
+
+// Keys returns the number of keys with a limiter.
+func (r *RateLimit) Keys() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.limiters)
+}
+
+// Dropped returns the number of notifications dropped because they were over quota.
+func (r *RateLimit) Dropped() uint64 {
+	return r.dropped.Load()
+}
+
+// Middleware returns the middleware which applies the limit.
+func (r *RateLimit) Middleware() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, req Request) (any, error) {
+			key := r.key(ctx, ConnectionInfoFrom(ctx))
+			if key == "" {
+				return next(ctx, req)
+			}
+
+			limiter, cost := r.limiter(key, req.Method)
+			if burst, ok := limiter.(BurstLimiter); ok && cost > burst.Burst() {
+				if req.Id == nil {
+					r.dropped.Add(1)
+					return nil, nil
+				}
+				return nil, ErrCostExceedsBurst
+			}
+			if ok, retryAfter := limiter.Allow(cost); !ok {
+				if req.Id == nil {
+					r.dropped.Add(1)
+					return nil, nil
+				}
+				e := r.opts.Error
+				e.Data, _ = json.Marshal(map[string]float64{"retryAfter": retryAfter.Seconds()})
+				return nil, e
+			}
+			return next(ctx, req)
+		}
+	}
+}
+
+// limiter returns the limiter for key, creating it if needed, along with the cost of method.
+func (r *RateLimit) limiter(key string, method string) (RateLimiter, float64) {
+	now := r.opts.Clock.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// discard idle limiters at most once per idle timeout
+	if r.opts.IdleTimeout > 0 && now.Sub(r.lastSweep) >= r.opts.IdleTimeout {
+		for k, entry := range r.limiters {
+			if now.Sub(entry.lastUsed) >= r.opts.IdleTimeout {
+				delete(r.limiters, k)
+			}
+		}
+		r.lastSweep = now
+	}
+
+	entry, ok := r.limiters[key]
+	if !ok {
+		entry = &rateLimitEntry{limiter: r.newLimit(key)}
+		r.limiters[key] = entry
+	}
+	entry.lastUsed = now
+
+	cost, ok := r.costs[method]
+	if !ok {
+		cost = 1
+	}
+	return entry.limiter, cost
+}
@@ -0,0 +1,156 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/41north/jsonrpc.go"
+	"github.com/41north/jsonrpc.go/testutil"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type tenantKey struct{}
+
+func TestRateLimit(t *testing.T) {
+	clock := testutil.NewClock(time.Time{})
+	limits := map[string]float64{"a": 5, "b": 2}
+	limiter := func(key string) jsonrpc.RateLimiter {
+		bucket := jsonrpc.NewTokenBucket(1, limits[key])
+		bucket.SetClock(clock)
+		return bucket
+	}
+	rl := jsonrpc.NewRateLimit(
+		func(ctx context.Context, info jsonrpc.ConnectionInfo) string {
+			tenant, _ := ctx.Value(tenantKey{}).(string)
+			return tenant
+		},
+		limiter,
+		jsonrpc.RateLimitCost("expensive", 3),
+		jsonrpc.RateLimitIdleTimeout(time.Minute),
+		jsonrpc.RateLimitClock(clock),
+	)
+
+	server := jsonrpc.NewServer()
+	// identify the tenant before the limit is applied
+	server.Use(func(next jsonrpc.Handler) jsonrpc.Handler {
+		return func(ctx context.Context, req jsonrpc.Request) (any, error) {
+			var tenant string
+			_ = json.Unmarshal(req.Extension("tenant"), &tenant)
+			return next(context.WithValue(ctx, tenantKey{}, tenant), req)
+		}
+	}, rl.Middleware())
+	server.Register("cheap", echo)
+	server.Register("expensive", echo)
+
+	call := func(tenant string, method string, notify bool) *jsonrpc.Response {
+		var options []jsonrpc.RequestOption
+		if !notify {
+			options = append(options, jsonrpc.RequestNumericId(1))
+		}
+		req, err := jsonrpc.NewRequest(method, nil, options...)
+		assert.Nil(t, err)
+		if notify {
+			req.Id = nil
+		}
+		if tenant != "" {
+			assert.Nil(t, req.SetExtension("tenant", tenant))
+		}
+		data, err := json.Marshal(req)
+		assert.Nil(t, err)
+
+		reply := server.Handle(context.Background(), data)
+		if reply == nil {
+			return nil
+		}
+		var resp jsonrpc.Response
+		assert.Nil(t, json.Unmarshal(reply, &resp))
+		return &resp
+	}
+
+	// a has a quota of 5, the expensive method taking 3 of it
+	assert.Nil(t, call("a", "expensive", false).Error)
+	assert.Nil(t, call("a", "cheap", false).Error)
+	assert.Nil(t, call("a", "cheap", false).Error)
+	resp := call("a", "cheap", false)
+	assert.NotNil(t, resp.Error)
+	assert.Equal(t, jsonrpc.ErrQuotaExceeded.Code, resp.Error.Code)
+	class, ok := jsonrpc.InspectRetryAfter(*resp.Error)
+	assert.True(t, ok)
+	assert.Equal(t, time.Second, class.RetryAfter)
+
+	// b has its own, smaller, quota, which the expensive method can never fit into
+	resp = call("b", "expensive", false)
+	assert.Equal(t, jsonrpc.ErrCostExceedsBurst.Code, resp.Error.Code)
+	_, ok = jsonrpc.InspectRetryAfter(*resp.Error)
+	assert.False(t, ok)
+	assert.Nil(t, call("b", "cheap", false).Error)
+	assert.Nil(t, call("b", "expensive", true))
+	assert.Equal(t, uint64(1), rl.Dropped())
+
+	// notifications over quota are dropped
+	assert.Nil(t, call("a", "cheap", true))
+	assert.Equal(t, uint64(2), rl.Dropped())
+
+	// requests without a key are not limited
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, call("", "expensive", false).Error)
+	}
+
+	// limits can be changed at runtime, and the costs of methods too
+	limits["a"] = 10
+	rl.SetLimiter(limiter)
+	rl.SetCost("expensive", 10)
+	assert.Nil(t, call("a", "expensive", false).Error)
+	assert.Equal(t, jsonrpc.ErrQuotaExceeded.Code, call("a", "cheap", false).Error.Code)
+
+	// idle keys are discarded
+	assert.Equal(t, 1, rl.Keys())
+	clock.Advance(time.Minute)
+	assert.Nil(t, call("b", "cheap", false).Error)
+	assert.Equal(t, 1, rl.Keys())
+}
+
+func TestTokenBucket(t *testing.T) {
+	clock := testutil.NewClock(time.Time{})
+	bucket := jsonrpc.NewTokenBucket(100, 2)
+	bucket.SetClock(clock)
+	ok, _ := bucket.Allow(2)
+	assert.True(t, ok)
+	ok, retryAfter := bucket.Allow(1)
+	assert.False(t, ok)
+	assert.Equal(t, 10*time.Millisecond, retryAfter)
+
+	clock.Advance(retryAfter)
+	ok, _ = bucket.Allow(1)
+	assert.True(t, ok)
+
+	// shrinking the burst discards the excess tokens, and a cost over the burst is refused without a
+	// wait
+	bucket.SetLimit(1000, 0.5)
+	assert.Equal(t, 0.5, bucket.Burst())
+	clock.Advance(5 * time.Millisecond)
+	ok, retryAfter = bucket.Allow(1)
+	assert.False(t, ok)
+	assert.Zero(t, retryAfter)
+}
+
+func TestServer_ConnectionInfo(t *testing.T) {
+	server := jsonrpc.NewServer()
+	server.Register("addr", func(ctx context.Context, req jsonrpc.Request) (any, error) {
+		return jsonrpc.ConnectionInfoFrom(ctx).RemoteAddr.String(), nil
+	})
+
+	client := jsonrpc.NewClient(serverDialer(server, nil))
+	assert.Nil(t, client.Connect())
+	defer func() { _ = client.Close() }()
+
+	addr, err := sendName(t, client, "addr")
+	assert.Nil(t, err)
+	assert.Equal(t, "pipe", addr)
+
+	// messages handled directly have no connection
+	assert.Equal(t, jsonrpc.ConnectionInfo{}, jsonrpc.ConnectionInfoFrom(context.Background()))
+}
@@ -0,0 +1,232 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/juju/errors"
+)
+
+var ErrInvalidJSON = errors.ConstError("invalid json")
+
+// RawResponse holds the undecoded json of a message received from the server, deferring parsing
+// and allocation until a field is requested. Top level fields are located by scanning rather than
+// decoding the whole document.
+type RawResponse struct {
+	data json.RawMessage
+}
+
+// NewRawResponse wraps data without parsing it. data is retained, not copied.
+func NewRawResponse(data []byte) RawResponse {
+	return RawResponse{data: data}
+}
+
+// Bytes returns the full json of the response.
+func (r RawResponse) Bytes() json.RawMessage {
+	return r.data
+}
+
+// ID returns the raw json of the id field, matching string(Response.Id), or an empty string if
+// it is not present or the response is malformed.
+func (r RawResponse) ID() string {
+	id, _ := r.Field("id")
+	return string(id)
+}
+
+// Method returns the method of a server initiated request or notification, if any.
+func (r RawResponse) Method() (string, error) {
+	raw, err := r.Field("method")
+	if err != nil || raw == nil {
+		return "", err
+	}
+	var method string
+	err = json.Unmarshal(raw, &method)
+	return method, err
+}
+
+// Result returns the raw json of the result field, or nil if not present.
+func (r RawResponse) Result() (json.RawMessage, error) {
+	return r.Field("result")
+}
+
+// Error decodes the error field, returning nil if not present.
+func (r RawResponse) Error() (*Error, error) {
+	raw, err := r.Field("error")
+	if err != nil || raw == nil || string(raw) == "null" {
+		return nil, err
+	}
+	var rpcErr Error
+	if err = json.Unmarshal(raw, &rpcErr); err != nil {
+		return nil, err
+	}
+	return &rpcErr, nil
+}
+
+// UnmarshalResult behaves like Response.UnmarshalResult, returning the error field if present.
+func (r RawResponse) UnmarshalResult(payload any) error {
+	rpcErr, err := r.Error()
+	if err != nil {
+		return err
+	}
+	if rpcErr != nil {
+		return rpcErr
+	}
+	result, err := r.Result()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(result, &payload)
+}
+
+// Response fully decodes the raw json into a Response.
+func (r RawResponse) Response() (*Response, error) {
+	var resp Response
+	if err := json.Unmarshal(r.data, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Field returns the raw json value of the named top level field, or nil if not present. Only the
+// fields preceding it are scanned, and they are skipped without being decoded.
+func (r RawResponse) Field(name string) (json.RawMessage, error) {
+	s := scanner{data: r.data}
+	s.skipSpace()
+	if !s.consume('{') {
+		return nil, ErrInvalidJSON
+	}
+	s.skipSpace()
+	if s.consume('}') {
+		return nil, nil
+	}
+	for {
+		s.skipSpace()
+		start := s.pos
+		if !s.skipString() {
+			return nil, ErrInvalidJSON
+		}
+		key := s.data[start:s.pos]
+		s.skipSpace()
+		if !s.consume(':') {
+			return nil, ErrInvalidJSON
+		}
+		s.skipSpace()
+		valueStart := s.pos
+		if !s.skipValue() {
+			return nil, ErrInvalidJSON
+		}
+		if keyEquals(key, name) {
+			return json.RawMessage(s.data[valueStart:s.pos]), nil
+		}
+		s.skipSpace()
+		if s.consume('}') {
+			return nil, nil
+		}
+		if !s.consume(',') {
+			return nil, ErrInvalidJSON
+		}
+	}
+}
+
+// keyEquals compares a quoted json key with name, only unescaping the key if necessary.
+func keyEquals(quoted []byte, name string) bool {
+	unquoted := quoted[1 : len(quoted)-1]
+	if bytes.IndexByte(unquoted, '\\') < 0 {
+		return string(unquoted) == name
+	}
+	var key string
+	if err := json.Unmarshal(quoted, &key); err != nil {
+		return false
+	}
+	return key == name
+}
+
+// scanner skips over json values without decoding them. It validates structure only so far as is
+// needed to find where each value ends.
+type scanner struct {
+	data []byte
+	pos  int
+}
+
+func (s *scanner) skipSpace() {
+	for s.pos < len(s.data) {
+		switch s.data[s.pos] {
+		case ' ', '\t', '\r', '\n':
+			s.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (s *scanner) consume(c byte) bool {
+	if s.pos < len(s.data) && s.data[s.pos] == c {
+		s.pos++
+		return true
+	}
+	return false
+}
+
+func (s *scanner) skipString() bool {
+	if !s.consume('"') {
+		return false
+	}
+	for s.pos < len(s.data) {
+		switch s.data[s.pos] {
+		case '\\':
+			s.pos += 2
+		case '"':
+			s.pos++
+			return true
+		default:
+			s.pos++
+		}
+	}
+	return false
+}
+
+func (s *scanner) skipValue() bool {
+	if s.pos >= len(s.data) {
+		return false
+	}
+	switch s.data[s.pos] {
+	case '"':
+		return s.skipString()
+	case '{', '[':
+		return s.skipNested()
+	default:
+		// numbers, booleans and null
+		start := s.pos
+		for s.pos < len(s.data) {
+			switch s.data[s.pos] {
+			case ',', '}', ']', ' ', '\t', '\r', '\n':
+				return s.pos > start
+			}
+			s.pos++
+		}
+		return s.pos > start
+	}
+}
+
+func (s *scanner) skipNested() bool {
+	depth := 0
+	for s.pos < len(s.data) {
+		switch s.data[s.pos] {
+		case '"':
+			if !s.skipString() {
+				return false
+			}
+			continue
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+			if depth == 0 {
+				s.pos++
+				return true
+			}
+		}
+		s.pos++
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package jsonrpc_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/41north/jsonrpc.go"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var rawResponseJSON = []byte(`{"jsonrpc":"2.0","result":{"blocks":[{"hash":"0x1","txs":["a","b\"}"]},{"hash":"0x2"}],"ok":true},"id":"req-1"}`)
+
+func TestRawResponse(t *testing.T) {
+	raw := jsonrpc.NewRawResponse(rawResponseJSON)
+	assert.Equal(t, `"req-1"`, raw.ID())
+
+	result, err := raw.Result()
+	assert.Nil(t, err)
+	assert.Equal(t, `{"blocks":[{"hash":"0x1","txs":["a","b\"}"]},{"hash":"0x2"}],"ok":true}`, string(result))
+
+	rpcErr, err := raw.Error()
+	assert.Nil(t, err)
+	assert.Nil(t, rpcErr)
+
+	var payload struct {
+		Ok bool `json:"ok"`
+	}
+	assert.Nil(t, raw.UnmarshalResult(&payload))
+	assert.True(t, payload.Ok)
+
+	// the id matches that of a fully decoded response
+	resp, err := raw.Response()
+	assert.Nil(t, err)
+	assert.Equal(t, string(resp.Id), raw.ID())
+
+	raw = jsonrpc.NewRawResponse([]byte(` { "id" : 7 , "error" : {"code":-32601,"message":"not found"}, "jsonrpc":"2.0" } `))
+	assert.Equal(t, "7", raw.ID())
+	rpcErr, err = raw.Error()
+	assert.Nil(t, err)
+	assert.Equal(t, jsonrpc.ErrMethodNotFound.Code, rpcErr.Code)
+	assert.Equal(t, rpcErr, raw.UnmarshalResult(&payload))
+
+	raw = jsonrpc.NewRawResponse([]byte(`{"id":"escaped","method":"update"}`))
+	assert.Equal(t, `"escaped"`, raw.ID())
+	method, err := raw.Method()
+	assert.Nil(t, err)
+	assert.Equal(t, "update", method)
+
+	for _, invalid := range []string{``, `[]`, `{"id"`, `{"id":}`, `{"result":{"a":1}`, `{"a":"b" "id":1}`} {
+		_, err = jsonrpc.NewRawResponse([]byte(invalid)).Field("id")
+		assert.ErrorIs(t, err, jsonrpc.ErrInvalidJSON, invalid)
+	}
+
+	field, err := jsonrpc.NewRawResponse([]byte(`{}`)).Field("id")
+	assert.Nil(t, err)
+	assert.Nil(t, field)
+}
+
+func BenchmarkResponse_Id(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var resp jsonrpc.Response
+		if err := json.Unmarshal(rawResponseJSON, &resp); err != nil {
+			b.Fatal(err)
+		}
+		_ = string(resp.Id)
+	}
+}
+
+func BenchmarkRawResponse_ID(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = jsonrpc.NewRawResponse(rawResponseJSON).ID()
+	}
+}
@@ -0,0 +1,95 @@
+package jsonrpc
+
+import (
+	"context"
+
+	"github.com/juju/errors"
+)
+
+// ReconnectFailMode determines what happens to the requests in flight on a connection which is
+// lost, whether because the client closes or because Migrate moves away from it before they
+// complete, see WithReconnectFailMode.
+type ReconnectFailMode int
+
+const (
+	// FailWithClose fails the requests with ErrClosed, or the CloseError sent by the peer. It is
+	// the default.
+	FailWithClose ReconnectFailMode = iota
+	// FailWithCause fails the requests with the reason the connection was lost, such as the read
+	// error, keep alive failure or Migrate deadline, typed so that it still matches ErrClosed.
+	FailWithCause
+	// Resend re-issues the requests for idempotent methods on the connection Migrate moves to, with
+	// the same id, failing the rest as FailWithCause does. Resending a request which is not
+	// idempotent risks duplicating its side effects, as the server may have handled it already.
+	// Requests cannot be resent once the client has closed, nor once the retry budget is empty,
+	// see WithRetryBudget.
+	Resend
+)
+
+// WithReconnectFailMode sets what happens to the requests in flight on a connection which is lost,
+// see ReconnectFailMode. idempotent lists the methods which Resend may re-issue.
+func WithReconnectFailMode(mode ReconnectFailMode, idempotent ...string) ClientOption {
+	return func(opts *ClientOptions) {
+		opts.ReconnectFailMode = mode
+		opts.ResendMethods = make(map[string]bool, len(idempotent))
+		for _, method := range idempotent {
+			opts.ResendMethods[method] = true
+		}
+	}
+}
+
+// lostCause returns the error to fail requests with when their connection is lost because of
+// cause, according to the fail mode. CloseErrors are always passed on as they are.
+func (c *client) lostCause(cause error) error {
+	var closeErr *CloseError
+	if errors.As(cause, &closeErr) {
+		return closeErr
+	}
+	if cause == nil || c.opts.ReconnectFailMode == FailWithClose || errors.Is(cause, ErrClosed) {
+		return ErrClosed
+	}
+	return errors.WithType(cause, ErrClosed)
+}
+
+// resends returns true if request is re-issued on a new connection, rather than failed, when the
+// connection it was sent on is lost.
+func (c *client) resends(request *inFlightRequest) bool {
+	return c.opts.ReconnectFailMode == Resend && request.data != nil && c.opts.ResendMethods[request.method]
+}
+
+// resend writes request, which was in flight on a connection which has been lost, to the current
+// connection. The in flight entry is kept, so the response is matched with the same key.
+func (c *client) resend(request *inFlightRequest) {
+	c.logger().
+		WithField("method", request.method).
+		WithField("id", request.id).
+		Debug("resending request")
+	c.write(request.context(), request.tenant, request.data, func(err error) {
+		c.onWritten(request, err)
+	})
+}
+
+// drainLost handles the requests in flight on a connection which Migrate has moved away from,
+// which did not complete before ctx was done.
+func (c *client) drainLost(ctx context.Context, lost []*inFlightRequest) (failed int) {
+	cause := c.lostCause(errors.Annotate(ctx.Err(), "connection closed by migration"))
+	for _, request := range lost {
+		if value, ok := c.inFlight.Load(request.key); !ok || value != request {
+			// completed in the meantime
+			continue
+		}
+		failure := cause
+		if c.resends(request) {
+			if c.withdrawRetry(request.method) {
+				c.resend(request)
+				continue
+			}
+			failure = retryBudgetExhausted(cause, request.method)
+		}
+		if request.fail(failure) {
+			c.inFlight.Delete(request.key)
+			failed++
+		}
+	}
+	return failed
+}
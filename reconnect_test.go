@@ -0,0 +1,104 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/41north/jsonrpc.go"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_ReconnectFailMode(t *testing.T) {
+	migrate := func(client jsonrpc.Client, to *jsonrpc.Server) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		assert.Nil(t, client.Migrate(ctx, serverDialer(to, nil)))
+	}
+
+	// requests which outlive the migration are failed with its cause
+	release := make(chan struct{})
+	defer close(release)
+	released := make(chan struct{})
+	close(released)
+
+	client := jsonrpc.NewClient(serverDialer(endpoint("a", release), nil), jsonrpc.WithReconnectFailMode(jsonrpc.FailWithCause))
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	waiting := client.SendAsync(*newRequest("wait", nil))
+	migrate(client, endpoint("b", released))
+
+	_, err := (<-waiting.Get()).Unwrap()
+	assert.ErrorIs(t, err, jsonrpc.ErrClosed)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// or resent on the new connection if they are idempotent
+	transfer := func(name string, release chan struct{}) *jsonrpc.Server {
+		server := endpoint(name, release)
+		server.Register("transfer", func(ctx context.Context, req jsonrpc.Request) (any, error) {
+			<-release
+			return name, nil
+		})
+		return server
+	}
+
+	client = jsonrpc.NewClient(serverDialer(transfer("a", release), nil), jsonrpc.WithReconnectFailMode(jsonrpc.Resend, "wait"))
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	resent := client.SendAsync(*newRequest("wait", nil))
+	failed := client.SendAsync(*newRequest("transfer", nil))
+	migrate(client, transfer("b", released))
+
+	resp, err := (<-resent.Get()).Unwrap()
+	assert.Nil(t, err)
+	assert.Equal(t, `"b"`, string(resp.Result))
+
+	_, err = (<-failed.Get()).Unwrap()
+	assert.ErrorIs(t, err, jsonrpc.ErrClosed)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// unless the retry budget is empty
+	client = jsonrpc.NewClient(
+		serverDialer(transfer("a", release), nil),
+		jsonrpc.WithReconnectFailMode(jsonrpc.Resend, "wait"),
+		jsonrpc.WithRetryBudget(0, 0),
+	)
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	refused := client.SendAsync(*newRequest("wait", nil))
+	migrate(client, transfer("b", released))
+
+	_, err = (<-refused.Get()).Unwrap()
+	assert.ErrorIs(t, err, jsonrpc.ErrRetryBudgetExhausted)
+	assert.ErrorIs(t, err, jsonrpc.ErrClosed)
+}
+
+func TestClient_ReconnectFailModeClose(t *testing.T) {
+	for _, mode := range []jsonrpc.ReconnectFailMode{jsonrpc.FailWithClose, jsonrpc.FailWithCause} {
+		clientConn, serverConn := net.Pipe()
+		client := jsonrpc.NewClientWithConnection(
+			jsonrpc.NewStreamConnection(clientConn, jsonrpc.FramingJSON),
+			jsonrpc.WithReconnectFailMode(mode),
+		)
+		assert.Nil(t, client.Connect())
+
+		server := jsonrpc.NewStreamConnection(serverConn, jsonrpc.FramingJSON)
+		go func() {
+			_, _ = server.Read()
+			_ = server.Write([]byte(`"corrupt"`))
+		}()
+
+		_, err := (<-client.SendAsync(*newRequest("echo", nil)).Get()).Unwrap()
+		assert.ErrorIs(t, err, jsonrpc.ErrClosed)
+		if mode == jsonrpc.FailWithCause {
+			assert.ErrorIs(t, err, jsonrpc.ErrFraming)
+		} else {
+			assert.NotErrorIs(t, err, jsonrpc.ErrFraming)
+		}
+	}
+}
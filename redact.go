@@ -0,0 +1,140 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// Redacted replaces values removed by a redactor.
+const Redacted = "[REDACTED]"
+
+// Redactor returns a copy of params which is safe to log, with any sensitive values removed. It is
+// never applied to the params sent on the wire.
+type Redactor = func(method string, params json.RawMessage) json.RawMessage
+
+// RedactAll is a Redactor which replaces params entirely. It is used by default.
+func RedactAll(method string, params json.RawMessage) json.RawMessage {
+	if params == nil {
+		return nil
+	}
+	return json.RawMessage(strconv.Quote(Redacted))
+}
+
+// RedactRules creates a Redactor which replaces the values at the given paths with Redacted. Rules
+// are keyed by method, with "*" applying to all methods. Paths begin with "params" followed by any
+// number of field accesses or array indices, e.g. "params[1]", "params.password" or
+// "params[0].auth.token". Paths which do not match the params are ignored.
+func RedactRules(rules map[string][]string) (Redactor, error) {
+	parsed := make(map[string][][]pathSegment, len(rules))
+	for method, paths := range rules {
+		for _, path := range paths {
+			segments, err := parsePath(path)
+			if err != nil {
+				return nil, err
+			}
+			parsed[method] = append(parsed[method], segments)
+		}
+	}
+
+	// the paths of each method are merged with those for all methods once, so that the redactor
+	// only reads them and can be called concurrently
+	wildcard := parsed["*"]
+	merged := make(map[string][][]pathSegment, len(parsed))
+	for method, paths := range parsed {
+		if method == "*" {
+			continue
+		}
+		merged[method] = append(append(make([][]pathSegment, 0, len(wildcard)+len(paths)), wildcard...), paths...)
+	}
+
+	return func(method string, params json.RawMessage) json.RawMessage {
+		paths, ok := merged[method]
+		if !ok {
+			paths = wildcard
+		}
+		if len(paths) == 0 || params == nil {
+			return params
+		}
+
+		var value any
+		if err := json.Unmarshal(params, &value); err != nil {
+			// we cannot tell what is sensitive in params we cannot parse
+			return RedactAll(method, params)
+		}
+		for _, segments := range paths {
+			value = redactPath(value, segments)
+		}
+
+		redacted, err := json.Marshal(value)
+		if err != nil {
+			return RedactAll(method, params)
+		}
+		return redacted
+	}, nil
+}
+
+// pathSegment is either a field name or an array index.
+type pathSegment struct {
+	field string
+	index int
+}
+
+func parsePath(path string) ([]pathSegment, error) {
+	if !strings.HasPrefix(path, "params") {
+		return nil, errors.NotValidf("redaction path %q", path)
+	}
+	rest := strings.TrimPrefix(path, "params")
+
+	var segments []pathSegment
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			field := rest[1 : end+1]
+			if field == "" {
+				return nil, errors.NotValidf("redaction path %q", path)
+			}
+			segments = append(segments, pathSegment{field: field, index: -1})
+			rest = rest[end+1:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, errors.NotValidf("redaction path %q", path)
+			}
+			index, err := strconv.Atoi(rest[1:end])
+			if err != nil || index < 0 {
+				return nil, errors.NotValidf("redaction path %q", path)
+			}
+			segments = append(segments, pathSegment{index: index})
+			rest = rest[end+1:]
+		default:
+			return nil, errors.NotValidf("redaction path %q", path)
+		}
+	}
+	return segments, nil
+}
+
+// redactPath replaces the value found by following segments from value with Redacted.
+func redactPath(value any, segments []pathSegment) any {
+	if len(segments) == 0 {
+		return Redacted
+	}
+	segment := segments[0]
+	switch v := value.(type) {
+	case map[string]any:
+		if child, ok := v[segment.field]; ok && segment.index < 0 {
+			v[segment.field] = redactPath(child, segments[1:])
+		}
+	case []any:
+		if segment.index >= 0 && segment.index < len(v) {
+			v[segment.index] = redactPath(v[segment.index], segments[1:])
+		}
+	}
+	return value
+}
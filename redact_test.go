@@ -0,0 +1,79 @@
+package jsonrpc_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/41north/jsonrpc.go"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactRules(t *testing.T) {
+	redactor, err := jsonrpc.RedactRules(map[string][]string{
+		"*":                      {"params.password"},
+		"eth_sendRawTransaction": {"params[0]"},
+		"login":                  {"params[1].token", "params[5]"},
+	})
+	assert.Nil(t, err)
+
+	testCases := []struct {
+		method   string
+		params   string
+		expected string
+	}{
+		{"eth_sendRawTransaction", "[\"0xdeadbeef\",\"latest\"]", "[\"[REDACTED]\",\"latest\"]"},
+		{"login", "[\"alice\",{\"token\":\"secret\",\"ttl\":60}]", "[\"alice\",{\"token\":\"[REDACTED]\",\"ttl\":60}]"},
+		{"connect", "{\"password\":\"hunter2\",\"user\":\"bob\"}", "{\"password\":\"[REDACTED]\",\"user\":\"bob\"}"},
+		{"eth_blockNumber", "[]", "[]"},
+		{"connect", "not json", "\"[REDACTED]\""},
+	}
+
+	for _, tc := range testCases {
+		actual := redactor(tc.method, json.RawMessage(tc.params))
+		assert.Equal(t, tc.expected, string(actual), tc.method)
+	}
+
+	assert.Nil(t, redactor("eth_sendRawTransaction", nil))
+}
+
+func TestRedactRules_InvalidPath(t *testing.T) {
+	for _, path := range []string{"password", "params.", "params[", "params[-1]", "params[a]", "params..a"} {
+		_, err := jsonrpc.RedactRules(map[string][]string{"*": {path}})
+		assert.NotNil(t, err, path)
+	}
+}
+
+func TestRedactRules_Concurrent(t *testing.T) {
+	// three paths for all methods leave room in the slice holding them, which the paths of each
+	// method must not be appended into
+	redactor, err := jsonrpc.RedactRules(map[string][]string{
+		"*":     {"params.a", "params.b", "params.c"},
+		"login": {"params.password"},
+		"auth":  {"params.token"},
+	})
+	assert.Nil(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			method, field := "login", "password"
+			if i%2 == 1 {
+				method, field = "auth", "token"
+			}
+			params := json.RawMessage(fmt.Sprintf(`{%q:"secret"}`, field))
+			for j := 0; j < 1000; j++ {
+				redacted := string(redactor(method, params))
+				if !assert.False(t, strings.Contains(redacted, "secret"), redacted) {
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -1,6 +1,8 @@
 package jsonrpc
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 
 	"github.com/juju/errors"
@@ -35,11 +37,21 @@ func RequestNumericId(id int) RequestOption {
 	}
 }
 
+// RequestTenant tags the request with the tenant it is sent on behalf of, see WithFairQueue.
+func RequestTenant(tenant string) RequestOption {
+	return func(opts *RequestOptions) error {
+		opts.Tenant = tenant
+		return nil
+	}
+}
+
 type RequestOption = func(opts *RequestOptions) error
 
 type RequestOptions struct {
-	Version string
-	Id      json.RawMessage
+	Version     string
+	Id          json.RawMessage
+	Tenant      string
+	OrderingKey string
 }
 
 func DefaultRequestOptions() RequestOptions {
@@ -60,25 +72,152 @@ func NewRequest(method string, params any, options ...RequestOption) (*Request,
 	var paramBytes json.RawMessage
 
 	if params != nil {
-		paramBytes, err = json.Marshal(params)
+		paramBytes, err = marshalParam(params)
 		if err != nil {
 			return nil, errors.New("failed to marshal params to json")
 		}
 	}
 
-	return &Request{Id: opts.Id, Method: method, Params: paramBytes, Version: opts.Version}, nil
+	return &Request{Id: opts.Id, Method: method, Params: paramBytes, Version: opts.Version, tenant: opts.Tenant, orderingKey: opts.OrderingKey}, nil
+}
+
+// NamedParam is a member of the params object of a request created with NewRequestNamed.
+type NamedParam struct {
+	Name  string
+	Value any
+}
+
+// NewRequestNamed creates a request whose params are an object with the members of params, in the
+// order given. As with NewRequest, json.RawMessage values are included verbatim.
+func NewRequestNamed(method string, params []NamedParam, options ...RequestOption) (*Request, error) {
+	var buf bytes.Buffer
+	names := make(map[string]bool, len(params))
+
+	buf.WriteByte('{')
+	for i, param := range params {
+		if names[param.Name] {
+			return nil, errors.Errorf("duplicate param %q", param.Name)
+		}
+		names[param.Name] = true
+
+		name, err := json.Marshal(param.Name)
+		if err != nil {
+			return nil, errors.Annotatef(err, "failed to marshal param name %q", param.Name)
+		}
+		value, err := marshalParam(param.Value)
+		if err != nil {
+			return nil, errors.Annotatef(err, "failed to marshal param %q to json", param.Name)
+		}
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+
+	return NewRequest(method, json.RawMessage(buf.Bytes()), options...)
+}
+
+// marshalParam marshals v to json, except for a json.RawMessage which is validated and returned as
+// is, avoiding the cost of re-encoding and preserving the order of its fields.
+func marshalParam(v any) (json.RawMessage, error) {
+	if raw, ok := v.(json.RawMessage); ok {
+		if !json.Valid(raw) {
+			return nil, errors.New("invalid raw json")
+		}
+		return raw, nil
+	}
+	return json.Marshal(v)
 }
 
 type IdGenerator = func() string
 
+// Request is a value type: the client methods which take a Request send a copy, so an id assigned
+// when sending, see WithIdGenerator, is not visible on the caller's Request. Use
+// Client.SendRequest, or assign an id beforehand with EnsureId, to read the id a request was sent
+// with. The extensions of a copy share storage with the original, see SetExtension.
 type Request struct {
 	Id      json.RawMessage `json:"id,omitempty"`
 	Method  string          `json:"method"`
 	Params  json.RawMessage `json:"params,omitempty"`
 	Version string          `json:"jsonrpc,omitempty"`
+
+	// extensions are additional, non-standard, top level fields.
+	extensions map[string]json.RawMessage
+	// tenant is not sent on the wire, see RequestTenant.
+	tenant string
+	// orderingKey is not sent on the wire, see RequestOrderingKey.
+	orderingKey string
+	// ordered is set once the request holds its place in the sequence of its ordering key.
+	ordered bool
+	// prepared is set once the client has assigned an id and version and applied its mutator.
+	prepared bool
+	// ctx is the context of the call the request is sent by, if any, see ContextObserver.
+	ctx context.Context
+	// useNumber is set if numbers are decoded as json.Number, see WithUseNumber.
+	useNumber bool
+}
+
+// request has the same fields as Request without the custom json marshalling.
+type request Request
+
+func (r Request) MarshalJSON() ([]byte, error) {
+	return marshalWithExtensions(request(r), r.extensions)
+}
+
+func (r *Request) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, (*request)(r)); err != nil {
+		return err
+	}
+	extensions, err := unmarshalExtensions(data, requestFields)
+	if err != nil {
+		return err
+	}
+	r.extensions = extensions
+	return nil
+}
+
+// Tenant returns the tenant the request is sent on behalf of, if any.
+func (r *Request) Tenant() string {
+	return r.tenant
+}
+
+// SetTenant tags the request with the tenant it is sent on behalf of, see WithFairQueue.
+func (r *Request) SetTenant(tenant string) {
+	r.tenant = tenant
+}
+
+// OrderingKey returns the key which orders the request with others sharing it, if any.
+func (r *Request) OrderingKey() string {
+	return r.orderingKey
+}
+
+// SetOrderingKey tags the request with a key which orders it with others sharing it, see
+// RequestOrderingKey.
+func (r *Request) SetOrderingKey(key string) {
+	r.orderingKey = key
+}
+
+// SetExtension adds a non-standard top level field which is included when the request is
+// marshalled. The standard field names are reserved.
+func (r *Request) SetExtension(key string, value any) error {
+	return setExtension(&r.extensions, requestFields, key, value)
+}
+
+// Extension returns the raw json value of a non-standard top level field, or nil if not present.
+func (r *Request) Extension(key string) json.RawMessage {
+	return r.extensions[key]
 }
 
 func (r *Request) EnsureId(gen IdGenerator) error {
+	return r.ensureId(func() any { return gen() })
+}
+
+// ensureId assigns an id from gen, which may return any value which marshals to a valid id, unless
+// the request already has one.
+func (r *Request) ensureId(gen AnyIdGenerator) error {
 	if r.Id != nil {
 		return nil
 	}
@@ -91,9 +230,9 @@ func (r *Request) EnsureId(gen IdGenerator) error {
 }
 
 func (r *Request) UnmarshalId(id any) error {
-	return json.Unmarshal(r.Id, &id)
+	return unmarshal(r.Id, &id, r.useNumber)
 }
 
 func (r *Request) UnmarshalParams(payload any) error {
-	return json.Unmarshal(r.Params, &payload)
+	return unmarshal(r.Params, &payload, r.useNumber)
 }
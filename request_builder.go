@@ -0,0 +1,104 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/juju/errors"
+)
+
+// RequestBuilder constructs a Request step by step, deferring any error until Build:
+//
+//	req, err := NewRequestBuilder("eth_getBalance").WithIntID(1).WithParamsArray(address, "latest").Build()
+//
+// A request built without an id is assigned one by the client it is sent with, unless it is marked
+// with AsNotification, in which case it must be sent as a notification, see Client.NotifyBatch.
+type RequestBuilder struct {
+	method       string
+	id           json.RawMessage
+	params       json.RawMessage
+	notification bool
+	err          error
+}
+
+// NewRequestBuilder starts building a request for method.
+func NewRequestBuilder(method string) *RequestBuilder {
+	return &RequestBuilder{method: method}
+}
+
+// WithStringID sets a string id.
+func (b *RequestBuilder) WithStringID(id string) *RequestBuilder {
+	b.id, _ = json.Marshal(id)
+	return b
+}
+
+// WithIntID sets a numeric id.
+func (b *RequestBuilder) WithIntID(id int64) *RequestBuilder {
+	b.id, _ = json.Marshal(id)
+	return b
+}
+
+// WithParamsObject sets the params to v given by name, v being anything which marshals to a json
+// object, such as a struct or map. It replaces any params already set.
+func (b *RequestBuilder) WithParamsObject(v any) *RequestBuilder {
+	params, err := marshalParam(v)
+	if err != nil {
+		return b.fail(errors.Annotate(err, "failed to marshal params to json"))
+	}
+	if trimmed := bytes.TrimSpace(params); len(trimmed) == 0 || trimmed[0] != '{' {
+		return b.fail(errors.NotValidf("params object %s", params))
+	}
+	b.params = params
+	return b
+}
+
+// WithParamsArray sets the params to values given by position. It replaces any params already set.
+func (b *RequestBuilder) WithParamsArray(values ...any) *RequestBuilder {
+	if values == nil {
+		values = []any{}
+	}
+	params, err := json.Marshal(values)
+	if err != nil {
+		return b.fail(errors.Annotate(err, "failed to marshal params to json"))
+	}
+	b.params = params
+	return b
+}
+
+// AsNotification marks the request as a notification, which has no id.
+func (b *RequestBuilder) AsNotification() *RequestBuilder {
+	b.notification = true
+	return b
+}
+
+func (b *RequestBuilder) fail(err error) *RequestBuilder {
+	if b.err == nil {
+		b.err = err
+	}
+	return b
+}
+
+// Build returns the request, or the first error which occurred while building it. A method is
+// required, and a notification must not have an id.
+func (b *RequestBuilder) Build() (Request, error) {
+	if b.err != nil {
+		return Request{}, b.err
+	}
+	if b.method == "" {
+		return Request{}, errors.NotValidf("request without a method")
+	}
+	if b.notification && b.id != nil {
+		return Request{}, errors.Annotate(ErrNotificationId, b.method)
+	}
+	return Request{Id: b.id, Method: b.method, Params: b.params, Version: "2.0"}, nil
+}
+
+// MustBuild returns the request as Build does, panicking if it is not valid. It is intended for
+// test fixtures and requests fixed at compile time.
+func (b *RequestBuilder) MustBuild() Request {
+	req, err := b.Build()
+	if err != nil {
+		panic(err)
+	}
+	return req
+}
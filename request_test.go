@@ -6,6 +6,7 @@ import (
 
 	"github.com/41north/jsonrpc.go"
 
+	"github.com/juju/errors"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -56,3 +57,98 @@ func TestRequest_UnmarshalParams(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, expected, actual)
 }
+
+func TestRequest_RawParams(t *testing.T) {
+	// keys deliberately out of order, and a number which would be reformatted if re-encoded
+	raw := json.RawMessage(`{"z":1.50,"a":{"y":[],"b":null}}`)
+
+	req, err := jsonrpc.NewRequest("ping", raw, jsonrpc.RequestNumericId(1))
+	assert.Nil(t, err)
+	assert.Equal(t, raw, req.Params)
+
+	bytes, err := json.Marshal(req)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"id":1,"method":"ping","params":{"z":1.50,"a":{"y":[],"b":null}},"jsonrpc":"2.0"}`, string(bytes))
+
+	req, err = jsonrpc.NewRequestNamed("ping", []jsonrpc.NamedParam{
+		{Name: "z", Value: json.RawMessage(`1.50`)},
+		{Name: "a", Value: json.RawMessage(`{"y":[],"b":null}`)},
+		{Name: "m", Value: "hello"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, `{"z":1.50,"a":{"y":[],"b":null},"m":"hello"}`, string(req.Params))
+
+	_, err = jsonrpc.NewRequest("ping", json.RawMessage(`{"z":`))
+	assert.Error(t, err)
+
+	_, err = jsonrpc.NewRequestNamed("ping", []jsonrpc.NamedParam{{Name: "a", Value: 1}, {Name: "a", Value: 2}})
+	assert.Error(t, err)
+}
+
+func TestRequest_Extensions(t *testing.T) {
+	req := newRequest("ping", nil, jsonrpc.RequestNumericId(1))
+	assert.Nil(t, req.SetExtension("token", "secret"))
+	assert.Nil(t, req.SetExtension("origin", map[string]int{"region": 3}))
+	assert.ErrorIs(t, req.SetExtension("method", "pong"), jsonrpc.ErrReservedExtension)
+
+	expected := "{\"id\":1,\"method\":\"ping\",\"jsonrpc\":\"2.0\",\"origin\":{\"region\":3},\"token\":\"secret\"}"
+
+	bytes, err := json.Marshal(req)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, string(bytes))
+
+	var actual jsonrpc.Request
+	err = json.Unmarshal(bytes, &actual)
+	assert.Nil(t, err)
+	assert.Equal(t, *req, actual)
+	assert.Equal(t, json.RawMessage("\"secret\""), actual.Extension("token"))
+	assert.Nil(t, actual.Extension("missing"))
+
+	bytes, err = json.Marshal(actual)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, string(bytes))
+}
+
+func TestRequestBuilder(t *testing.T) {
+	testCases := []struct {
+		builder *jsonrpc.RequestBuilder
+		json    string
+	}{
+		{jsonrpc.NewRequestBuilder("ping").WithIntID(1), `{"id":1,"method":"ping","jsonrpc":"2.0"}`},
+		{jsonrpc.NewRequestBuilder("ping").WithStringID("a").WithParamsArray(), `{"id":"a","method":"ping","params":[],"jsonrpc":"2.0"}`},
+		{
+			jsonrpc.NewRequestBuilder("eth_getBalance").WithIntID(2).WithParamsArray("0x1", json.RawMessage(`"latest"`)),
+			`{"id":2,"method":"eth_getBalance","params":["0x1","latest"],"jsonrpc":"2.0"}`,
+		},
+		{
+			jsonrpc.NewRequestBuilder("transfer").WithParamsObject(map[string]int{"amount": 5}).AsNotification(),
+			`{"method":"transfer","params":{"amount":5},"jsonrpc":"2.0"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		req, err := tc.builder.Build()
+		assert.Nil(t, err)
+		bytes, err := json.Marshal(req)
+		assert.Nil(t, err)
+		assert.Equal(t, tc.json, string(bytes))
+	}
+
+	invalid := []struct {
+		builder *jsonrpc.RequestBuilder
+		kind    error
+	}{
+		{jsonrpc.NewRequestBuilder(""), errors.NotValid},
+		{jsonrpc.NewRequestBuilder("ping").WithParamsObject([]int{1}), errors.NotValid},
+		{jsonrpc.NewRequestBuilder("ping").WithIntID(1).AsNotification(), jsonrpc.ErrNotificationId},
+	}
+	for _, tc := range invalid {
+		_, err := tc.builder.Build()
+		assert.ErrorIs(t, err, tc.kind)
+		assert.Panics(t, func() { tc.builder.MustBuild() })
+	}
+
+	// marshalling errors are reported by Build
+	_, err := jsonrpc.NewRequestBuilder("ping").WithParamsArray(make(chan int)).Build()
+	assert.NotNil(t, err)
+}
@@ -2,6 +2,7 @@ package jsonrpc
 
 import (
 	"encoding/json"
+	"fmt"
 
 	"github.com/juju/errors"
 )
@@ -74,20 +75,124 @@ func NewResponseError(error Error, options ...ResponseOption) (*Response, error)
 	return &Response{Id: opts.Id, Result: nil, Error: &error, Version: opts.Version}, nil
 }
 
+// Kind classifies an inbound message based on which of the id, method and error fields are present.
+type Kind int
+
+const (
+	// KindReply is a reply to a request we have sent.
+	KindReply Kind = iota
+	// KindNotification is a server initiated request without an id, which expects no reply.
+	KindNotification
+	// KindRequest is a server initiated request with an id, which expects a reply.
+	KindRequest
+	// KindError is an error reply to a request we have sent.
+	KindError
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindReply:
+		return "reply"
+	case KindNotification:
+		return "notification"
+	case KindRequest:
+		return "request"
+	case KindError:
+		return "error"
+	default:
+		return fmt.Sprintf("Kind(%d)", int(k))
+	}
+}
+
+// Response represents any message received from the server. Method and Params are only present
+// for server initiated requests and notifications, see Kind.
 type Response struct {
 	Id      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
 	Result  json.RawMessage `json:"result,omitempty"`
 	Error   *Error          `json:"error,omitempty"`
 	Version string          `json:"jsonrpc"`
+
+	// extensions are additional, non-standard, top level fields.
+	extensions map[string]json.RawMessage
+
+	// appError is the application level error decoded from Error, if any. See ErrorRegistry.
+	appError error
+
+	// meta is set if the client records response details, see WithResponseMeta.
+	meta *ResponseMeta
+	// useNumber is set if numbers are decoded as json.Number, see WithUseNumber.
+	useNumber bool
+	// nullResultError is set if a null result is an error, see WithNullResultError.
+	nullResultError bool
+}
+
+// response has the same fields as Response without the custom json marshalling.
+type response Response
+
+func (r Response) MarshalJSON() ([]byte, error) {
+	return marshalWithExtensions(response(r), r.extensions)
+}
+
+func (r *Response) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, (*response)(r)); err != nil {
+		return err
+	}
+	extensions, err := unmarshalExtensions(data, responseFields)
+	if err != nil {
+		return err
+	}
+	r.extensions = extensions
+	return nil
+}
+
+// SetExtension adds a non-standard top level field which is included when the response is
+// marshalled. The standard field names are reserved.
+func (r *Response) SetExtension(key string, value any) error {
+	return setExtension(&r.extensions, responseFields, key, value)
+}
+
+// Extension returns the raw json value of a non-standard top level field, or nil if not present.
+func (r *Response) Extension(key string) json.RawMessage {
+	return r.extensions[key]
+}
+
+// Kind classifies the response as a reply, notification, request or error.
+func (r *Response) Kind() Kind {
+	switch {
+	case r.Error != nil:
+		return KindError
+	case r.Method != "" && r.Id == nil:
+		return KindNotification
+	case r.Method != "":
+		return KindRequest
+	default:
+		return KindReply
+	}
+}
+
+// Request converts a server initiated request or notification into a Request.
+func (r *Response) Request() Request {
+	return Request{Id: r.Id, Method: r.Method, Params: r.Params, Version: r.Version, useNumber: r.useNumber}
 }
 
 func (r *Response) UnmarshalId(payload any) error {
-	return json.Unmarshal(r.Id, &payload)
+	return unmarshal(r.Id, &payload, r.useNumber)
 }
 
 func (r *Response) UnmarshalResult(payload any) error {
+	if r.appError != nil {
+		return r.appError
+	}
 	if r.Error != nil {
 		return r.Error
 	}
-	return json.Unmarshal(r.Result, &payload)
+	if r.Result == nil {
+		return ErrMissingResult
+	}
+	if r.nullResultError && r.IsNull() {
+		return ErrNullResult
+	}
+	return unmarshal(r.Result, &payload, r.useNumber)
 }
@@ -0,0 +1,128 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/41north/jsonrpc.go"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// loopbackMessage is a reply waiting to be read, done is closed once the client has processed it.
+type loopbackMessage struct {
+	data []byte
+	done chan struct{}
+}
+
+// loopbackConnection answers each write with server, only returning from Write once the client
+// has finished processing the reply. Any request whose in flight entry is stored after it is
+// written therefore has its response arrive first.
+type loopbackConnection struct {
+	server  *jsonrpc.Server
+	replies chan loopbackMessage
+	closed  chan struct{}
+	// last is the reply most recently returned by Read, only accessed by the reader
+	last *loopbackMessage
+}
+
+func newLoopbackConnection(server *jsonrpc.Server) *loopbackConnection {
+	return &loopbackConnection{
+		server:  server,
+		replies: make(chan loopbackMessage),
+		closed:  make(chan struct{}),
+	}
+}
+
+func (l *loopbackConnection) Write(data []byte) error {
+	reply := l.server.Handle(context.Background(), data)
+	if reply == nil {
+		return nil
+	}
+	msg := loopbackMessage{data: reply, done: make(chan struct{})}
+	select {
+	case l.replies <- msg:
+	case <-l.closed:
+		return jsonrpc.ErrClosed
+	}
+	select {
+	case <-msg.done:
+	case <-l.closed:
+	}
+	return nil
+}
+
+func (l *loopbackConnection) Read() ([]byte, error) {
+	// the reader only returns for more once the previous reply has been processed
+	if l.last != nil {
+		close(l.last.done)
+		l.last = nil
+	}
+	select {
+	case msg := <-l.replies:
+		l.last = &msg
+		return msg.data, nil
+	case <-l.closed:
+		return nil, jsonrpc.ErrClosed
+	}
+}
+
+func (l *loopbackConnection) Close() error {
+	select {
+	case <-l.closed:
+		return jsonrpc.ErrClosed
+	default:
+		close(l.closed)
+		return nil
+	}
+}
+
+func TestClient_ResponseBeforeWriteReturns(t *testing.T) {
+	testCases := []struct {
+		name    string
+		options []jsonrpc.ClientOption
+	}{
+		{"direct", nil},
+		{"auto batch", []jsonrpc.ClientOption{jsonrpc.WithAutoBatch(2, time.Millisecond)}},
+		{"in flight limit", []jsonrpc.ClientOption{jsonrpc.WithMaxInFlight(1)}},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			client := jsonrpc.NewClientWithConnection(newLoopbackConnection(endpoint("a", nil)), tt.options...)
+			client.SetUnmatchedHandler(func(resp jsonrpc.Response) {
+				t.Errorf("response %s was not matched", resp.Id)
+			})
+			assert.Nil(t, client.Connect())
+			defer client.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			var resp jsonrpc.Response
+			assert.Nil(t, client.SendContext(ctx, *newRequest("name", nil), &resp))
+
+			responses, err := client.SendBatchContext(ctx, jsonrpc.BatchRequest{Requests: []jsonrpc.TimedRequest{
+				{Request: *newRequest("name", nil)},
+				{Request: *newRequest("name", nil)},
+			}})
+			assert.Nil(t, err)
+			assert.Len(t, responses, 2)
+		})
+	}
+
+	// requests held until the client connects
+	client := jsonrpc.NewClientWithConnection(
+		newLoopbackConnection(endpoint("a", nil)),
+		jsonrpc.WithOfflineQueue(10, time.Second),
+	)
+	future := client.SendAsync(*newRequest("name", nil))
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err := (<-jsonrpc.GetContext(ctx, future)).Unwrap()
+	assert.Nil(t, err)
+}
@@ -0,0 +1,138 @@
+package jsonrpc
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WithResponseMeta records transport and timing details of each response, see Response.Meta. It is
+// off by default, so that clients which do not need the details do not pay for them.
+func WithResponseMeta() ClientOption {
+	return func(opts *ClientOptions) {
+		opts.ResponseMeta = true
+	}
+}
+
+// TransportMeta holds the transport level details of a received message. Fields which a transport
+// does not support are left empty.
+type TransportMeta struct {
+	// StatusCode is the http status of the response.
+	StatusCode int
+	// Header holds the http headers of the response, such as rate limits.
+	Header http.Header
+	// ServerLatency is the time the server reports having spent on the request, e.g. from the
+	// Server-Timing header.
+	ServerLatency time.Duration
+}
+
+// MetaConnection is implemented by connections which can report the transport details of the
+// messages they read. ReadWithMeta is used in place of Read when WithResponseMeta is set.
+type MetaConnection interface {
+	Connection
+	ReadWithMeta() ([]byte, TransportMeta, error)
+}
+
+// ResponseMeta describes how a response was received. Enqueued and Written are left empty for
+// responses which do not answer a request sent by the client, and Written is also left empty if the
+// response arrived before the write of the request had completed.
+type ResponseMeta struct {
+	TransportMeta
+	// Connection is the connection the response was read from.
+	Connection Connection
+	// Size is the length of the response json on the wire, or of the element of a batch.
+	Size int
+	// Enqueued is when the request was sent by the caller.
+	Enqueued time.Time
+	// Written is when the request was written to the connection.
+	Written time.Time
+	// Received is when the response was read from the connection.
+	Received time.Time
+}
+
+// Latency returns the time from the request being sent to the response being received, or zero if
+// either is unknown.
+func (m *ResponseMeta) Latency() time.Duration {
+	if m.Enqueued.IsZero() || m.Received.IsZero() {
+		return 0
+	}
+	return m.Received.Sub(m.Enqueued)
+}
+
+// Meta returns the transport and timing details of the response, or nil unless the client which
+// received it was created with WithResponseMeta.
+func (r *Response) Meta() *ResponseMeta {
+	return r.meta
+}
+
+// withSize returns a copy of m for a message of size, or nil if m is nil.
+func (m *ResponseMeta) withSize(size int) *ResponseMeta {
+	if m == nil {
+		return nil
+	}
+	meta := *m
+	meta.Size = size
+	return &meta
+}
+
+// readMessage reads the next message from conn, along with its details if they are recorded.
+func (c *client) readMessage(conn Connection) ([]byte, *ResponseMeta, error) {
+	if !c.opts.ResponseMeta {
+		data, err := conn.Read()
+		return data, nil, err
+	}
+
+	var transport TransportMeta
+	var data []byte
+	var err error
+	if mc, ok := conn.(MetaConnection); ok {
+		data, transport, err = mc.ReadWithMeta()
+	} else {
+		data, err = conn.Read()
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, &ResponseMeta{TransportMeta: transport, Connection: conn, Received: c.opts.Clock.Now()}, nil
+}
+
+// completeMeta adds the timings of the request answered by resp to its details.
+func (r *inFlightRequest) completeMeta(resp *Response) {
+	if resp.meta == nil {
+		return
+	}
+	resp.meta.Enqueued = r.start
+	if written := r.written.Load(); written != 0 {
+		resp.meta.Written = time.Unix(0, written)
+	}
+}
+
+// parseServerTiming returns the duration of the "total" metric of a Server-Timing header, or of the
+// first metric with a duration if there is no total.
+func parseServerTiming(header string) time.Duration {
+	var first time.Duration
+	found := false
+	for _, metric := range strings.Split(header, ",") {
+		params := strings.Split(metric, ";")
+		name := strings.TrimSpace(params[0])
+		for _, param := range params[1:] {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if key != "dur" {
+				continue
+			}
+			ms, err := strconv.ParseFloat(strings.Trim(value, `"`), 64)
+			if err != nil {
+				continue
+			}
+			d := time.Duration(ms * float64(time.Millisecond))
+			if name == "total" {
+				return d
+			}
+			if !found {
+				first, found = d, true
+			}
+		}
+	}
+	return first
+}
@@ -0,0 +1,97 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/41north/jsonrpc.go"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+func TestResponseMeta_HTTP(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.Nil(t, err)
+
+		var req jsonrpc.Request
+		assert.Nil(t, json.Unmarshal(body, &req))
+
+		w.Header().Set("X-RateLimit-Remaining", "99")
+		w.Header().Set("Server-Timing", `db;dur=2, total;dur=12.5;desc="Total"`)
+		assert.Nil(t, json.NewEncoder(w).Encode(jsonrpc.Response{Id: req.Id, Result: json.RawMessage(`true`), Version: req.Version}))
+	})
+	srv := httptest.NewServer(h2c.NewHandler(handler, &http2.Server{}))
+	defer srv.Close()
+
+	client := jsonrpc.NewClient(jsonrpc.NewHTTP2Dialer(srv.URL), jsonrpc.WithResponseMeta())
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	var resp jsonrpc.Response
+	assert.Nil(t, client.Send(*newRequest("ping", nil), &resp))
+
+	meta := resp.Meta()
+	assert.NotNil(t, meta)
+	assert.Equal(t, http.StatusOK, meta.StatusCode)
+	assert.Equal(t, "99", meta.Header.Get("X-RateLimit-Remaining"))
+	assert.Equal(t, 12500*time.Microsecond, meta.ServerLatency)
+	assert.NotNil(t, meta.Connection)
+	assert.Greater(t, meta.Size, 0)
+
+	assert.False(t, meta.Enqueued.IsZero())
+	assert.False(t, meta.Written.Before(meta.Enqueued))
+	assert.False(t, meta.Received.Before(meta.Enqueued))
+	assert.Equal(t, meta.Received.Sub(meta.Enqueued), meta.Latency())
+}
+
+func TestResponseMeta_Stream(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		clientConn, serverConn := net.Pipe()
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			_ = endpoint("a", nil).Serve(ctx, jsonrpc.NewStreamConnection(serverConn, jsonrpc.FramingNewline))
+		}()
+
+		var options []jsonrpc.ClientOption
+		if enabled {
+			options = append(options, jsonrpc.WithResponseMeta())
+		}
+		client := jsonrpc.NewClientWithConnection(jsonrpc.NewStreamConnection(clientConn, jsonrpc.FramingNewline), options...)
+		assert.Nil(t, client.Connect())
+
+		responses, err := client.SendBatchContext(ctx, jsonrpc.BatchRequest{Requests: []jsonrpc.TimedRequest{
+			{Request: *newRequest("name", nil)},
+			{Request: *newRequest("name", []int{1, 2, 3})},
+		}})
+		assert.Nil(t, err)
+
+		for _, resp := range responses {
+			if !enabled {
+				assert.Nil(t, resp.Meta())
+				continue
+			}
+			// fields the transport does not support are left empty
+			meta := resp.Meta()
+			assert.Zero(t, meta.StatusCode)
+			assert.Nil(t, meta.Header)
+
+			// the size is that of the element of the batch
+			data, err := json.Marshal(resp)
+			assert.Nil(t, err)
+			assert.Equal(t, len(data), meta.Size)
+			assert.False(t, meta.Enqueued.IsZero())
+		}
+
+		_ = client.Close()
+		cancel()
+	}
+}
@@ -1,6 +1,7 @@
 package jsonrpc_test
 
 import (
+	"context"
 	"encoding/json"
 	"testing"
 
@@ -71,3 +72,96 @@ func newResponseError(error jsonrpc.Error, options ...jsonrpc.ResponseOption) *j
 	}
 	return resp
 }
+
+func TestResponse_Kind(t *testing.T) {
+	testCases := []struct {
+		json string
+		kind jsonrpc.Kind
+	}{
+		{"{\"id\":1,\"result\":\"pong\",\"jsonrpc\":\"2.0\"}", jsonrpc.KindReply},
+		{"{\"id\":1,\"result\":null,\"jsonrpc\":\"2.0\"}", jsonrpc.KindReply},
+		{"{\"method\":\"update\",\"params\":[1],\"jsonrpc\":\"2.0\"}", jsonrpc.KindNotification},
+		{"{\"id\":\"srv-1\",\"method\":\"ping\",\"jsonrpc\":\"2.0\"}", jsonrpc.KindRequest},
+		{"{\"id\":1,\"error\":{\"code\":-32601,\"message\":\"method not found\"},\"jsonrpc\":\"2.0\"}", jsonrpc.KindError},
+		{"{\"error\":{\"code\":-32700,\"message\":\"parse error\"},\"jsonrpc\":\"2.0\"}", jsonrpc.KindError},
+	}
+
+	for _, tc := range testCases {
+		var resp jsonrpc.Response
+		err := json.Unmarshal([]byte(tc.json), &resp)
+		assert.Nil(t, err, "failed to unmarshal from json")
+		assert.Equal(t, tc.kind, resp.Kind(), tc.json)
+	}
+}
+
+func TestResponse_Extensions(t *testing.T) {
+	input := "{\"id\":1,\"result\":\"pong\",\"jsonrpc\":\"2.0\",\"latency\":12,\"node\":\"eu-1\"}"
+
+	var resp jsonrpc.Response
+	err := json.Unmarshal([]byte(input), &resp)
+	assert.Nil(t, err)
+	assert.Equal(t, json.RawMessage("12"), resp.Extension("latency"))
+	assert.Equal(t, json.RawMessage("\"eu-1\""), resp.Extension("node"))
+	assert.ErrorIs(t, resp.SetExtension("result", 1), jsonrpc.ErrReservedExtension)
+
+	bytes, err := json.Marshal(resp)
+	assert.Nil(t, err)
+	assert.Equal(t, input, string(bytes))
+}
+
+func TestResponse_NullResult(t *testing.T) {
+	var null, absent, failed jsonrpc.Response
+	assert.Nil(t, json.Unmarshal([]byte(`{"jsonrpc":"2.0","id":1,"result":null}`), &null))
+	assert.Nil(t, json.Unmarshal([]byte(`{"jsonrpc":"2.0","id":1}`), &absent))
+	assert.Nil(t, json.Unmarshal([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"failed"}}`), &failed))
+
+	assert.True(t, null.IsNull())
+	assert.False(t, absent.IsNull())
+	assert.False(t, failed.IsNull())
+
+	// by default a null result leaves the target unchanged
+	result := "unchanged"
+	assert.Nil(t, null.UnmarshalResult(&result))
+	assert.Equal(t, "unchanged", result)
+
+	// whereas an absent one is an error
+	assert.ErrorIs(t, absent.UnmarshalResult(&result), jsonrpc.ErrMissingResult)
+}
+
+func TestClient_NullResultError(t *testing.T) {
+	server := jsonrpc.NewServer()
+	server.Register("eth_getTransactionByHash", func(ctx context.Context, req jsonrpc.Request) (any, error) {
+		var params []string
+		if err := req.UnmarshalParams(&params); err != nil {
+			return nil, err
+		}
+		if params[0] == "0xunknown" {
+			return nil, nil
+		}
+		return map[string]string{"hash": params[0]}, nil
+	})
+
+	client := jsonrpc.NewClient(serverDialer(server, nil), jsonrpc.WithNullResultError(true))
+	assert.Nil(t, client.Connect())
+	defer func() { _ = client.Close() }()
+
+	type tx struct {
+		Hash string `json:"hash"`
+	}
+	futures := []jsonrpc.ResponseFuture{
+		client.SendAsync(*newRequest("eth_getTransactionByHash", []string{"0x1"})),
+	}
+	txs, err := jsonrpc.CollectTyped[tx](context.Background(), futures)
+	assert.Nil(t, err)
+	assert.Equal(t, []tx{{Hash: "0x1"}}, txs)
+
+	futures = append(futures, client.SendAsync(*newRequest("eth_getTransactionByHash", []string{"0xunknown"})))
+	_, err = jsonrpc.CollectTyped[tx](context.Background(), futures)
+	assert.ErrorIs(t, err, jsonrpc.ErrNullResult)
+
+	var resp jsonrpc.Response
+	assert.Nil(t, client.Send(*newRequest("eth_getTransactionByHash", []string{"0xunknown"}), &resp))
+	assert.True(t, resp.IsNull())
+	var result *tx
+	assert.ErrorIs(t, resp.UnmarshalResult(&result), jsonrpc.ErrNullResult)
+}
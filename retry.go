@@ -0,0 +1,176 @@
+package jsonrpc
+
+import (
+	"context"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultRetryAttempts is the default number of times a request is attempted by RetryInterceptor.
+	DefaultRetryAttempts = 3
+	// DefaultRetryInitialBackoff is the default wait before the first retry, doubling before each
+	// retry after it up to DefaultRetryMaxBackoff.
+	DefaultRetryInitialBackoff = 100 * time.Millisecond
+	DefaultRetryMaxBackoff     = 5 * time.Second
+)
+
+// WithIdempotentMethods marks methods as safe to retry.
+func WithIdempotentMethods(methods ...string) RetryOption {
+	return func(opts *RetryOptions) {
+		if opts.IdempotentMethods == nil {
+			opts.IdempotentMethods = make(map[string]bool)
+		}
+		for _, method := range methods {
+			opts.IdempotentMethods[method] = true
+		}
+	}
+}
+
+// WithIdempotencyKeyFn marks requests for which fn returns a non empty key as safe to retry,
+// whatever their method, such as those carrying an idempotency key which the server uses to
+// discard duplicates:
+//
+//	WithIdempotencyKeyFn(func(req Request) string {
+//		var key string
+//		_ = json.Unmarshal(req.Extension("idempotencyKey"), &key)
+//		return key
+//	})
+func WithIdempotencyKeyFn(fn func(req Request) string) RetryOption {
+	return func(opts *RetryOptions) {
+		opts.IdempotencyKey = fn
+	}
+}
+
+// WithRetryAttempts sets the number of times a request is attempted, including the first.
+func WithRetryAttempts(n int) RetryOption {
+	return func(opts *RetryOptions) {
+		opts.Attempts = n
+	}
+}
+
+// WithRetryBackoff sets the wait between attempts. A retry after hint from the server takes
+// precedence if it asks for a longer wait.
+func WithRetryBackoff(backoff Backoff) RetryOption {
+	return func(opts *RetryOptions) {
+		opts.Backoff = backoff
+	}
+}
+
+// WithRetryClassifier sets the classifier which decides which failures are retryable, see
+// ErrorClassifier, in place of the one the client was created with, see WithErrorClassifier.
+func WithRetryClassifier(classifier ErrorClassifier) RetryOption {
+	return func(opts *RetryOptions) {
+		opts.Classifier = &classifier
+	}
+}
+
+// WithRetryClock sets the clock used to wait between attempts, see Clock, in place of the one the
+// client was created with, see WithClock.
+func WithRetryClock(clock Clock) RetryOption {
+	return func(opts *RetryOptions) {
+		opts.Clock = clock
+	}
+}
+
+type RetryOption = func(opts *RetryOptions)
+
+type RetryOptions struct {
+	Attempts          int
+	Backoff           Backoff
+	Classifier        *ErrorClassifier
+	IdempotentMethods map[string]bool
+	IdempotencyKey    func(req Request) string
+	Clock             Clock
+}
+
+func DefaultRetryOptions() RetryOptions {
+	return RetryOptions{
+		Attempts: DefaultRetryAttempts,
+		Backoff:  ExponentialBackoff(DefaultRetryInitialBackoff, DefaultRetryMaxBackoff),
+	}
+}
+
+// idempotent returns true if req is safe to retry.
+func (o *RetryOptions) idempotent(req Request) bool {
+	return o.IdempotentMethods[req.Method] || (o.IdempotencyKey != nil && o.IdempotencyKey(req) != "")
+}
+
+// RetryInterceptor retries calls which fail with a retryable error, according to the error
+// classifier of the client, whether the failure is an error response or a transport error. Retrying
+// a request which is not idempotent risks duplicating its side effects, so only requests marked as
+// safe to retry with WithIdempotentMethods or WithIdempotencyKeyFn are retried, and by default
+// nothing is. Other requests fail on their first error. Retries are refused once the retry budget
+// of the client is empty, failing with an error matching ErrRetryBudgetExhausted, see
+// WithRetryBudget. Install it with WithInterceptors.
+func RetryInterceptor(options ...RetryOption) UnaryInterceptor {
+	opts := DefaultRetryOptions()
+	for _, opt := range options {
+		opt(&opts)
+	}
+
+	return func(ctx context.Context, req Request, invoker UnaryInvoker) (Response, error) {
+		resp, err := invoker(ctx, req)
+		if !opts.idempotent(req) {
+			return resp, err
+		}
+
+		c := senderFromContext(ctx)
+		classifier, clock, logger := DefaultErrorClassifier, RealClock(), log.NewEntry(log.StandardLogger())
+		if c != nil {
+			classifier, clock, logger = c.opts.ErrorClassifier, c.opts.Clock, c.logger()
+		}
+		if opts.Classifier != nil {
+			classifier = *opts.Classifier
+		}
+		if opts.Clock != nil {
+			clock = opts.Clock
+		}
+
+		for attempt := 1; attempt < opts.Attempts; attempt++ {
+			failure := err
+			if failure == nil && resp.Error != nil {
+				failure = *resp.Error
+			}
+			if failure == nil {
+				return resp, nil
+			}
+			classification := classifier.Classify(failure)
+			if !classification.Retryable {
+				return resp, err
+			}
+			if c != nil && !c.withdrawRetry(req.Method) {
+				return resp, retryBudgetExhausted(failure, req.Method)
+			}
+
+			wait := classification.RetryAfter
+			if opts.Backoff != nil {
+				if backoff := opts.Backoff(attempt); backoff > wait {
+					wait = backoff
+				}
+			}
+			logger.WithError(failure).
+				WithField("method", req.Method).
+				WithField("attempt", attempt).
+				WithField("wait", wait).
+				Debug("retrying request")
+
+			if wait > 0 {
+				timer := clock.NewTimer(wait)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return resp, ctx.Err()
+				case <-timer.C():
+				}
+			}
+			if err := ctx.Err(); err != nil {
+				return resp, err
+			}
+
+			resp, err = invoker(ctx, req)
+		}
+		return resp, err
+	}
+}
@@ -0,0 +1,118 @@
+package jsonrpc
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+// ErrRetryBudgetExhausted is matched by the error of a request which was not retried because the
+// retry budget was empty, see WithRetryBudget.
+const ErrRetryBudgetExhausted = errors.ConstError("retry budget exhausted")
+
+// retryBudgetWindow bounds how many requests worth of tokens can be accumulated by a RetryBudget,
+// so that a long period of success cannot be spent on a burst of retries.
+const retryBudgetWindow = 1000
+
+// RetryBudgetObserver is an Observer which is also notified of each retry refused by the retry
+// budget, see WithRetryBudget.
+type RetryBudgetObserver interface {
+	Observer
+	OnRetryBudgetExhausted(method string)
+}
+
+// RetryBudget is a token bucket which limits retries to a ratio of the requests being made. Every
+// request deposits ratio tokens and every retry withdraws a single token, with a reserve of
+// minPerSec retries always available to allow for low request rates. When the budget is empty
+// retries are refused, so under sustained failure the client degrades gracefully to not retrying.
+type RetryBudget struct {
+	ratio     float64
+	minPerSec float64
+
+	mu          sync.Mutex
+	balance     float64
+	reserve     float64
+	lastRefresh time.Time
+	clock       Clock
+
+	exhausted atomic.Uint64
+}
+
+// NewRetryBudget creates a budget which permits ratio retries per request and minPerSec retries a
+// second, measured with RealClock until another is set with SetClock. A client sets the clock of
+// its budget to its own, see WithClock.
+func NewRetryBudget(ratio float64, minPerSec int) *RetryBudget {
+	clock := RealClock()
+	return &RetryBudget{
+		ratio:       ratio,
+		minPerSec:   float64(minPerSec),
+		reserve:     float64(minPerSec),
+		lastRefresh: clock.Now(),
+		clock:       clock,
+	}
+}
+
+// SetClock sets the clock with which the reserve is topped up, see Clock.
+func (b *RetryBudget) SetClock(clock Clock) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.clock = clock
+	b.lastRefresh = clock.Now()
+}
+
+// Deposit records a request, adding ratio tokens to the budget.
+func (b *RetryBudget) Deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.balance = math.Min(b.balance+b.ratio, math.Max(1, b.ratio*retryBudgetWindow))
+}
+
+// Withdraw returns true and removes a token if a retry is permitted, false otherwise.
+func (b *RetryBudget) Withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// top up the reserve based on the time elapsed
+	now := b.clock.Now()
+	elapsed := now.Sub(b.lastRefresh).Seconds()
+	b.reserve = math.Min(b.reserve+elapsed*b.minPerSec, b.minPerSec)
+	b.lastRefresh = now
+
+	switch {
+	case b.reserve >= 1:
+		b.reserve--
+		return true
+	case b.balance >= 1:
+		b.balance--
+		return true
+	default:
+		b.exhausted.Add(1)
+		return false
+	}
+}
+
+// Exhausted returns the number of retries which have been refused because the budget was empty.
+func (b *RetryBudget) Exhausted() uint64 {
+	return b.exhausted.Load()
+}
+
+// withdrawRetry returns true if the retry budget, if any, permits a retry of method. A retry which
+// is refused is reported to the observer.
+func (c *client) withdrawRetry(method string) bool {
+	if c.opts.RetryBudget == nil || c.opts.RetryBudget.Withdraw() {
+		return true
+	}
+	if o, ok := c.opts.Observer.(RetryBudgetObserver); ok {
+		o.OnRetryBudgetExhausted(method)
+	}
+	return false
+}
+
+// retryBudgetExhausted annotates failure, the error of a request to method which is not retried
+// because the budget is empty, so that it also matches ErrRetryBudgetExhausted.
+func retryBudgetExhausted(failure error, method string) error {
+	return errors.WithType(errors.Annotatef(failure, "%s not retried", method), ErrRetryBudgetExhausted)
+}
@@ -0,0 +1,58 @@
+package jsonrpc_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/41north/jsonrpc.go"
+	"github.com/41north/jsonrpc.go/testutil"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryBudget_Ratio(t *testing.T) {
+	budget := jsonrpc.NewRetryBudget(0.5, 0)
+
+	// no requests, no retries
+	assert.False(t, budget.Withdraw())
+	assert.Equal(t, uint64(1), budget.Exhausted())
+
+	for i := 0; i < 4; i++ {
+		budget.Deposit()
+	}
+
+	assert.True(t, budget.Withdraw())
+	assert.True(t, budget.Withdraw())
+	assert.False(t, budget.Withdraw())
+	assert.Equal(t, uint64(2), budget.Exhausted())
+}
+
+func TestRetryBudget_MinPerSec(t *testing.T) {
+	clock := testutil.NewClock(time.Time{})
+	budget := jsonrpc.NewRetryBudget(0, 2)
+	budget.SetClock(clock)
+
+	assert.True(t, budget.Withdraw())
+	assert.True(t, budget.Withdraw())
+	assert.False(t, budget.Withdraw())
+	assert.Equal(t, uint64(1), budget.Exhausted())
+
+	// the reserve is topped up over time
+	clock.Advance(500 * time.Millisecond)
+	assert.True(t, budget.Withdraw())
+	assert.False(t, budget.Withdraw())
+}
+
+func TestRetryBudget_Window(t *testing.T) {
+	budget := jsonrpc.NewRetryBudget(1, 0)
+
+	// deposits beyond the window are discarded
+	for i := 0; i < 2000; i++ {
+		budget.Deposit()
+	}
+
+	for i := 0; i < 1000; i++ {
+		assert.True(t, budget.Withdraw())
+	}
+	assert.False(t, budget.Withdraw())
+}
@@ -0,0 +1,301 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/41north/jsonrpc.go"
+	"github.com/41north/jsonrpc.go/testutil"
+
+	log "github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+// flakyServer fails the first failures calls of each method with err, counting the calls made.
+func flakyServer(failures int, err error) (*jsonrpc.Server, func(method string) int) {
+	var mu sync.Mutex
+	calls := make(map[string]int)
+	handler := func(ctx context.Context, req jsonrpc.Request) (any, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls[req.Method]++
+		if calls[req.Method] <= failures {
+			return nil, err
+		}
+		return "ok", nil
+	}
+
+	server := jsonrpc.NewServer()
+	server.Register("read", handler)
+	server.Register("write", handler)
+	return server, func(method string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return calls[method]
+	}
+}
+
+func TestRetryInterceptor(t *testing.T) {
+	idempotencyKey := func(req jsonrpc.Request) string {
+		var key string
+		_ = json.Unmarshal(req.Extension("idempotencyKey"), &key)
+		return key
+	}
+
+	testCases := []struct {
+		name     string
+		options  []jsonrpc.RetryOption
+		req      jsonrpc.Request
+		failures int
+		err      error
+		calls    int
+		ok       bool
+	}{
+		{
+			name:     "nothing is retried by default",
+			req:      *newRequest("read", nil),
+			failures: 1,
+			err:      jsonrpc.ErrInternal,
+			calls:    1,
+		},
+		{
+			name:     "idempotent methods are retried",
+			options:  []jsonrpc.RetryOption{jsonrpc.WithIdempotentMethods("read")},
+			req:      *newRequest("read", nil),
+			failures: 2,
+			err:      jsonrpc.ErrInternal,
+			calls:    3,
+			ok:       true,
+		},
+		{
+			name:     "other methods fail on their first error",
+			options:  []jsonrpc.RetryOption{jsonrpc.WithIdempotentMethods("read")},
+			req:      *newRequest("write", nil),
+			failures: 1,
+			err:      jsonrpc.ErrInternal,
+			calls:    1,
+		},
+		{
+			name:     "requests with an idempotency key are retried",
+			options:  []jsonrpc.RetryOption{jsonrpc.WithIdempotencyKeyFn(idempotencyKey)},
+			req:      withExtension(*newRequest("write", nil), "idempotencyKey", "abc"),
+			failures: 1,
+			err:      jsonrpc.ErrInternal,
+			calls:    2,
+			ok:       true,
+		},
+		{
+			name:     "requests without one are not",
+			options:  []jsonrpc.RetryOption{jsonrpc.WithIdempotencyKeyFn(idempotencyKey)},
+			req:      *newRequest("write", nil),
+			failures: 1,
+			err:      jsonrpc.ErrInternal,
+			calls:    1,
+		},
+		{
+			name:     "errors which are not retryable are not retried",
+			options:  []jsonrpc.RetryOption{jsonrpc.WithIdempotentMethods("read")},
+			req:      *newRequest("read", nil),
+			failures: 1,
+			err:      jsonrpc.ErrInvalidParams,
+			calls:    1,
+		},
+		{
+			name:     "attempts are limited",
+			options:  []jsonrpc.RetryOption{jsonrpc.WithIdempotentMethods("read"), jsonrpc.WithRetryAttempts(2)},
+			req:      *newRequest("read", nil),
+			failures: 5,
+			err:      jsonrpc.ErrInternal,
+			calls:    2,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server, calls := flakyServer(tc.failures, tc.err)
+			options := append([]jsonrpc.RetryOption{jsonrpc.WithRetryBackoff(nil)}, tc.options...)
+			client := jsonrpc.NewClient(
+				serverDialer(server, nil),
+				jsonrpc.WithInterceptors(jsonrpc.RetryInterceptor(options...)),
+			)
+			assert.Nil(t, client.Connect())
+			defer func() { _ = client.Close() }()
+
+			var resp jsonrpc.Response
+			assert.Nil(t, client.Send(tc.req, &resp))
+			assert.Equal(t, tc.ok, resp.Error == nil)
+			assert.Equal(t, tc.calls, calls(tc.req.Method))
+		})
+	}
+}
+
+func TestRetryInterceptor_ClientOptions(t *testing.T) {
+	// invalid params are retryable according to the client, but not the interceptor's override
+	classifier := jsonrpc.ErrorClassifier{
+		RetryableCodes: []jsonrpc.CodeRange{{Min: jsonrpc.ErrInvalidParams.Code, Max: jsonrpc.ErrInvalidParams.Code}},
+	}
+	logger, hook := logtest.NewNullLogger()
+	logger.SetLevel(log.DebugLevel)
+
+	for _, override := range []bool{false, true} {
+		options := []jsonrpc.RetryOption{jsonrpc.WithIdempotentMethods("read"), jsonrpc.WithRetryBackoff(nil)}
+		if override {
+			options = append(options, jsonrpc.WithRetryClassifier(jsonrpc.DefaultErrorClassifier))
+		}
+		server, calls := flakyServer(1, jsonrpc.ErrInvalidParams)
+		client := jsonrpc.NewClient(
+			serverDialer(server, nil),
+			jsonrpc.WithErrorClassifier(classifier),
+			jsonrpc.WithLogger(log.NewEntry(logger)),
+			jsonrpc.WithInterceptors(jsonrpc.RetryInterceptor(options...)),
+		)
+		assert.Nil(t, client.Connect())
+
+		var resp jsonrpc.Response
+		assert.Nil(t, client.Send(*newRequest("read", nil), &resp))
+		assert.Equal(t, override, resp.Error != nil)
+		if override {
+			assert.Equal(t, 1, calls("read"))
+		} else {
+			assert.Equal(t, 2, calls("read"))
+			// logged by the client's logger
+			retried := false
+			for _, entry := range hook.AllEntries() {
+				retried = retried || entry.Message == "retrying request"
+			}
+			assert.True(t, retried)
+		}
+		assert.Nil(t, client.Close())
+	}
+}
+
+func TestRetryInterceptor_Backoff(t *testing.T) {
+	server, calls := flakyServer(2, jsonrpc.ErrInternal)
+	clock := testutil.NewClock(time.Time{})
+	client := jsonrpc.NewClient(
+		serverDialer(server, nil),
+		jsonrpc.WithClock(clock),
+		jsonrpc.WithInterceptors(jsonrpc.RetryInterceptor(
+			jsonrpc.WithIdempotentMethods("read"),
+			jsonrpc.WithRetryBackoff(jsonrpc.ExponentialBackoff(time.Second, time.Minute)),
+		)),
+	)
+	assert.Nil(t, client.Connect())
+	defer func() { _ = client.Close() }()
+
+	done := make(chan error, 1)
+	var resp jsonrpc.Response
+	go func() { done <- client.Send(*newRequest("read", nil), &resp) }()
+
+	clock.BlockUntil(1)
+	assert.Equal(t, 1, calls("read"))
+	clock.Advance(time.Second)
+
+	clock.BlockUntil(1)
+	assert.Equal(t, 2, calls("read"))
+	clock.Advance(2 * time.Second)
+
+	assert.Nil(t, <-done)
+	assert.Nil(t, resp.Error)
+	assert.Equal(t, 3, calls("read"))
+
+	// a caller which stops waiting stops the retries
+	server, calls = flakyServer(5, jsonrpc.ErrInternal)
+	client = jsonrpc.NewClient(
+		serverDialer(server, nil),
+		jsonrpc.WithInterceptors(jsonrpc.RetryInterceptor(
+			jsonrpc.WithIdempotentMethods("read"),
+			jsonrpc.WithRetryBackoff(jsonrpc.ConstantBackoff(time.Hour)),
+		)),
+	)
+	assert.Nil(t, client.Connect())
+	defer func() { _ = client.Close() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, client.SendContext(ctx, *newRequest("read", nil), &resp), context.DeadlineExceeded)
+	assert.Equal(t, 1, calls("read"))
+}
+
+// refusalObserver counts the retries refused by the retry budget.
+type refusalObserver struct {
+	refused atomic.Int32
+}
+
+func (o *refusalObserver) OnRequest(method string, size int)  {}
+func (o *refusalObserver) OnResponse(method string, size int) {}
+func (o *refusalObserver) OnRetryBudgetExhausted(method string) {
+	o.refused.Add(1)
+}
+
+func TestRetryInterceptor_Budget(t *testing.T) {
+	server, calls := flakyServer(5, jsonrpc.ErrInternal)
+	observer := &refusalObserver{}
+	client := jsonrpc.NewClient(
+		serverDialer(server, nil),
+		jsonrpc.WithRetryBudget(0.5, 0),
+		jsonrpc.WithObserver(observer),
+		jsonrpc.WithInterceptors(jsonrpc.RetryInterceptor(
+			jsonrpc.WithIdempotentMethods("read"),
+			jsonrpc.WithRetryBackoff(nil),
+		)),
+	)
+	assert.Nil(t, client.Connect())
+	defer func() { _ = client.Close() }()
+
+	// the first request deposits half a token, which is not enough for a retry
+	var resp jsonrpc.Response
+	err := client.Send(*newRequest("read", nil), &resp)
+	assert.ErrorIs(t, err, jsonrpc.ErrRetryBudgetExhausted)
+	var rpcErr jsonrpc.Error
+	assert.ErrorAs(t, err, &rpcErr)
+	assert.Equal(t, jsonrpc.ErrInternal.Code, rpcErr.Code)
+	assert.Equal(t, 1, calls("read"))
+	assert.Equal(t, int32(1), observer.refused.Load())
+
+	// the second tops it up to one, which is spent on a single retry
+	err = client.Send(*newRequest("read", nil), &resp)
+	assert.ErrorIs(t, err, jsonrpc.ErrRetryBudgetExhausted)
+	assert.Equal(t, 3, calls("read"))
+	assert.Equal(t, int32(2), observer.refused.Load())
+}
+
+func TestRetryInterceptor_BudgetClock(t *testing.T) {
+	server, calls := flakyServer(10, jsonrpc.ErrInternal)
+	clock := testutil.NewClock(time.Time{})
+	client := jsonrpc.NewClient(
+		serverDialer(server, nil),
+		jsonrpc.WithClock(clock),
+		jsonrpc.WithRetryBudget(0, 1),
+		jsonrpc.WithInterceptors(jsonrpc.RetryInterceptor(
+			jsonrpc.WithIdempotentMethods("read"),
+			jsonrpc.WithRetryAttempts(2),
+			jsonrpc.WithRetryBackoff(nil),
+		)),
+	)
+	assert.Nil(t, client.Connect())
+	defer func() { _ = client.Close() }()
+
+	// the reserve permits a single retry a second of the client's clock
+	var resp jsonrpc.Response
+	assert.Nil(t, client.Send(*newRequest("read", nil), &resp))
+	assert.Equal(t, 2, calls("read"))
+	assert.ErrorIs(t, client.Send(*newRequest("read", nil), &resp), jsonrpc.ErrRetryBudgetExhausted)
+	assert.Equal(t, 3, calls("read"))
+
+	clock.Advance(time.Second)
+	assert.Nil(t, client.Send(*newRequest("read", nil), &resp))
+	assert.Equal(t, 5, calls("read"))
+}
+
+func withExtension(req jsonrpc.Request, key string, value any) jsonrpc.Request {
+	if err := req.SetExtension(key, value); err != nil {
+		panic(err)
+	}
+	return req
+}
@@ -0,0 +1,100 @@
+package jsonrpc
+
+import (
+	"context"
+	"io"
+	"net/rpc"
+
+	"github.com/juju/errors"
+)
+
+// codecResult is the outcome of a call made through a net/rpc codec.
+type codecResult struct {
+	seq    uint64
+	method string
+	resp   Response
+	err    error
+}
+
+// clientCodec adapts a Client to a net/rpc ClientCodec.
+type clientCodec struct {
+	client  Client
+	ctx     context.Context
+	cancel  context.CancelFunc
+	results chan codecResult
+	current codecResult
+}
+
+// NewClientCodec adapts client to a net/rpc ClientCodec, so that code written against net/rpc can
+// make its calls over any transport. Each call is sent with SendContext, with the service method as
+// the method and the args as the only positional param, as the net/rpc/jsonrpc codec does. Error
+// responses are returned to the caller as an rpc.ServerError holding the error message, as are
+// failures of individual requests such as timeouts. Once client closes every outstanding call fails
+// and the rpc.Client is shut down. Closing the codec closes client.
+func NewClientCodec(client Client) rpc.ClientCodec {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &clientCodec{
+		client:  client,
+		ctx:     ctx,
+		cancel:  cancel,
+		results: make(chan codecResult),
+	}
+}
+
+// NewRPCClient returns a net/rpc client which makes its calls with client, see NewClientCodec.
+func NewRPCClient(client Client) *rpc.Client {
+	return rpc.NewClientWithCodec(NewClientCodec(client))
+}
+
+func (c *clientCodec) WriteRequest(r *rpc.Request, body any) error {
+	req, err := NewRequest(r.ServiceMethod, []any{body})
+	if err != nil {
+		return err
+	}
+
+	seq, method := r.Seq, r.ServiceMethod
+	go func() {
+		var resp Response
+		err := c.client.SendContext(c.ctx, *req, &resp)
+		select {
+		case c.results <- codecResult{seq: seq, method: method, resp: resp, err: err}:
+		case <-c.ctx.Done():
+		}
+	}()
+	return nil
+}
+
+func (c *clientCodec) ReadResponseHeader(r *rpc.Response) error {
+	select {
+	case <-c.ctx.Done():
+		return io.EOF
+	case result := <-c.results:
+		if errors.Is(result.err, ErrClosed) {
+			return result.err
+		}
+
+		c.current = result
+		r.Seq = result.seq
+		r.ServiceMethod = result.method
+		r.Error = ""
+		switch {
+		case result.err != nil:
+			r.Error = result.err.Error()
+		case result.resp.Error != nil:
+			r.Error = result.resp.Error.Error()
+		}
+		return nil
+	}
+}
+
+func (c *clientCodec) ReadResponseBody(body any) error {
+	if body == nil || len(c.current.resp.Result) == 0 {
+		return nil
+	}
+	return unmarshal(c.current.resp.Result, body, c.current.resp.useNumber)
+}
+
+func (c *clientCodec) Close() error {
+	c.cancel()
+	return c.client.Close()
+}
@@ -0,0 +1,67 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"net/rpc"
+	"testing"
+
+	"github.com/41north/jsonrpc.go"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type arithArgs struct {
+	A, B int
+}
+
+func TestNewRPCClient(t *testing.T) {
+	release := make(chan struct{})
+	server := endpoint("a", release)
+	jsonrpc.Handle(server, "Arith.Multiply", func(ctx context.Context, args arithArgs) (int, error) {
+		return args.A * args.B, nil
+	})
+
+	client := jsonrpc.NewClient(serverDialer(server, nil))
+	assert.Nil(t, client.Connect())
+	rpcClient := jsonrpc.NewRPCClient(client)
+
+	var product int
+	assert.Nil(t, rpcClient.Call("Arith.Multiply", arithArgs{7, 6}, &product))
+	assert.Equal(t, 42, product)
+
+	// calls run concurrently, completing in any order
+	waiting := rpcClient.Go("wait", struct{}{}, new(string), nil)
+	assert.Nil(t, rpcClient.Call("Arith.Multiply", arithArgs{2, 3}, &product))
+	assert.Equal(t, 6, product)
+	close(release)
+	<-waiting.Done
+	assert.Nil(t, waiting.Error)
+	assert.Equal(t, "a", *waiting.Reply.(*string))
+
+	// error responses are server errors
+	err := rpcClient.Call("Arith.Divide", arithArgs{1, 0}, &product)
+	assert.IsType(t, rpc.ServerError(""), err)
+	assert.Equal(t, jsonrpc.ErrMethodNotFound.Error(), err.Error())
+
+	// closing shuts down the rpc client along with the client
+	assert.Nil(t, rpcClient.Close())
+	assert.ErrorIs(t, rpcClient.Call("Arith.Multiply", arithArgs{1, 1}, &product), rpc.ErrShutdown)
+	assert.ErrorIs(t, client.Connect(), jsonrpc.ErrClosed)
+}
+
+func TestNewRPCClient_ClientClosed(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	client := jsonrpc.NewClient(serverDialer(endpoint("a", release), nil))
+	assert.Nil(t, client.Connect())
+	rpcClient := jsonrpc.NewRPCClient(client)
+
+	// outstanding calls fail when the client closes
+	waiting := rpcClient.Go("wait", struct{}{}, new(string), nil)
+	assert.Nil(t, client.Close())
+	<-waiting.Done
+	assert.ErrorIs(t, waiting.Error, jsonrpc.ErrClosed)
+
+	assert.ErrorIs(t, rpcClient.Call("wait", struct{}{}, new(string)), rpc.ErrShutdown)
+}
@@ -0,0 +1,232 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+// ErrNoSchema is returned by SchemaFor for methods registered without a schema.
+const ErrNoSchema = errors.ConstError("method has no schema")
+
+// DescribeMethod is the introspection method registered by ServerDescribe.
+const DescribeMethod = "rpc.describe"
+
+// Schema is a JSON Schema, covering the keywords needed to describe Go types.
+type Schema struct {
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+}
+
+// JSONSchemaProvider is implemented by types which describe themselves, taking the place of the
+// schema which would otherwise be generated for them.
+type JSONSchemaProvider interface {
+	JSONSchema() *Schema
+}
+
+// MethodSchema describes the params and result of a method, either of which may be nil. Methods
+// registered with Handle or RegisterService are described automatically.
+func MethodSchema(params *Schema, result *Schema) MethodOption {
+	return func(opts *MethodOptions) {
+		opts.ParamsSchema = params
+		opts.ResultSchema = result
+	}
+}
+
+// ServerDescribe registers DescribeMethod, which returns the schemas of every registered method,
+// keyed by method name, see MethodDescription.
+func ServerDescribe() ServerOption {
+	return func(opts *ServerOptions) {
+		opts.Describe = true
+	}
+}
+
+// MethodDescription is the description of a method returned by DescribeMethod and SchemaFor.
+type MethodDescription struct {
+	Params     *Schema `json:"params,omitempty"`
+	Result     *Schema `json:"result,omitempty"`
+	Deprecated string  `json:"deprecated,omitempty"`
+}
+
+// SchemaFor returns the description of method, which may be an alias, as json. ErrNoSchema is
+// returned if neither its params nor its result are described.
+func (s *Server) SchemaFor(method string) ([]byte, error) {
+	s.mu.RLock()
+	name := method
+	if target, isAlias := s.aliases[name]; isAlias {
+		name = target
+	}
+	m, ok := s.methods[name]
+	var description MethodDescription
+	if ok {
+		description = s.description(method, m)
+	}
+	s.mu.RUnlock()
+
+	if !ok {
+		return nil, errors.Annotate(ErrMethodNotRegistered, method)
+	}
+	if description.Params == nil && description.Result == nil {
+		return nil, errors.Annotate(ErrNoSchema, method)
+	}
+	return json.Marshal(description)
+}
+
+// description must be called whilst holding the lock.
+func (s *Server) description(name string, m *serverMethod) MethodDescription {
+	return MethodDescription{
+		Params:     m.opts.ParamsSchema,
+		Result:     m.opts.ResultSchema,
+		Deprecated: s.deprecations[name],
+	}
+}
+
+func (s *Server) describe(ctx context.Context, req Request) (any, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	methods := make(map[string]MethodDescription, len(s.methods)+len(s.aliases))
+	for name, m := range s.methods {
+		methods[name] = s.description(name, m)
+	}
+	for name, target := range s.aliases {
+		if m, ok := s.methods[target]; ok {
+			methods[name] = s.description(name, m)
+		}
+	}
+	return map[string]any{"methods": methods}, nil
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	rawMessageType    = reflect.TypeOf(json.RawMessage{})
+	providerType      = reflect.TypeOf((*JSONSchemaProvider)(nil)).Elem()
+	marshalerType     = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// SchemaOf generates a schema for T according to how encoding/json marshals it, honouring json
+// struct tags. Fields are required unless they are pointers or tagged omitempty. Types which
+// implement JSONSchemaProvider supply their own schema, and those with custom json marshalling, as
+// well as recursive references, are described by the empty schema, which matches anything.
+func SchemaOf[T any]() *Schema {
+	return schemaOf(reflect.TypeOf((*T)(nil)).Elem(), make(map[reflect.Type]bool))
+}
+
+func schemaOf(t reflect.Type, visiting map[reflect.Type]bool) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Interface && reflect.PointerTo(t).Implements(providerType) {
+		if schema := reflect.New(t).Interface().(JSONSchemaProvider).JSONSchema(); schema != nil {
+			return schema
+		}
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawMessageType, reflect.PointerTo(t).Implements(marshalerType):
+		return &Schema{}
+	case reflect.PointerTo(t).Implements(textMarshalerType):
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 && t.Kind() == reflect.Slice {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: schemaOf(t.Elem(), visiting)}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: schemaOf(t.Elem(), visiting)}
+	case reflect.Struct:
+		if visiting[t] {
+			return &Schema{}
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+		addFields(schema, t, visiting)
+		sort.Strings(schema.Required)
+		return schema
+	default:
+		// interfaces, and anything else, can hold any value
+		return &Schema{}
+	}
+}
+
+// addFields adds the fields of struct t to schema, flattening untagged embedded structs as
+// encoding/json does.
+func addFields(schema *Schema, t reflect.Type, visiting map[reflect.Type]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				addFields(schema, ft, visiting)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := schemaOf(field.Type, visiting)
+		if hasTagOption(options, "string") {
+			property = &Schema{Type: "string"}
+		}
+		schema.Properties[name] = property
+
+		if field.Type.Kind() != reflect.Pointer && !hasTagOption(options, "omitempty") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
+
+func hasTagOption(options string, option string) bool {
+	for options != "" {
+		var next string
+		next, options, _ = strings.Cut(options, ",")
+		if next == option {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/41north/jsonrpc.go"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type address string
+
+func (a address) JSONSchema() *jsonrpc.Schema {
+	return &jsonrpc.Schema{Type: "string", Format: "hex"}
+}
+
+type page struct {
+	Cursor string `json:"cursor,omitempty"`
+}
+
+type query struct {
+	page
+	From    address           `json:"from"`
+	Limit   *int              `json:"limit"`
+	Since   time.Time         `json:"since"`
+	Tags    []string          `json:"tags,omitempty"`
+	Labels  map[string]int    `json:"labels,omitempty"`
+	Block   int64             `json:"block,string"`
+	Raw     json.RawMessage   `json:"raw,omitempty"`
+	Ignored string            `json:"-"`
+	Next    *query            `json:"next,omitempty"`
+	Extra   map[string]string `json:",omitempty"`
+	private int
+}
+
+func TestSchemaOf(t *testing.T) {
+	schema, err := json.Marshal(jsonrpc.SchemaOf[query]())
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{
+		"type": "object",
+		"properties": {
+			"cursor": {"type": "string"},
+			"from": {"type": "string", "format": "hex"},
+			"limit": {"type": "integer"},
+			"since": {"type": "string", "format": "date-time"},
+			"tags": {"type": "array", "items": {"type": "string"}},
+			"labels": {"type": "object", "additionalProperties": {"type": "integer"}},
+			"block": {"type": "string"},
+			"raw": {},
+			"next": {},
+			"Extra": {"type": "object", "additionalProperties": {"type": "string"}}
+		},
+		"required": ["block", "from", "since"]
+	}`, string(schema))
+}
+
+func TestServer_SchemaFor(t *testing.T) {
+	server := jsonrpc.NewServer(jsonrpc.ServerDescribe())
+	jsonrpc.Handle(server, "transfer", handleTransfer)
+	server.Register("untyped", func(ctx context.Context, req jsonrpc.Request) (any, error) {
+		return nil, nil
+	})
+	assert.Nil(t, server.Alias("send", "transfer"))
+	server.Deprecate("send", "use transfer")
+
+	expected := `{
+		"params": {
+			"type": "object",
+			"properties": {"from": {"type": "string"}, "to": {"type": "string"}, "amount": {"type": "integer"}},
+			"required": ["amount", "from", "to"]
+		},
+		"result": {"type": "string"}
+	}`
+
+	schema, err := server.SchemaFor("transfer")
+	assert.Nil(t, err)
+	assert.JSONEq(t, expected, string(schema))
+
+	_, err = server.SchemaFor("untyped")
+	assert.ErrorIs(t, err, jsonrpc.ErrNoSchema)
+	_, err = server.SchemaFor("missing")
+	assert.ErrorIs(t, err, jsonrpc.ErrMethodNotRegistered)
+
+	var resp jsonrpc.Response
+	assert.Nil(t, json.Unmarshal(server.Handle(context.Background(), []byte(`{"id":1,"method":"rpc.describe"}`)), &resp))
+	assert.Nil(t, resp.Error)
+
+	var description struct {
+		Methods map[string]json.RawMessage `json:"methods"`
+	}
+	assert.Nil(t, resp.UnmarshalResult(&description))
+	assert.JSONEq(t, expected, string(description.Methods["transfer"]))
+	assert.JSONEq(t, `{}`, string(description.Methods["untyped"]))
+	assert.Contains(t, string(description.Methods["send"]), `"deprecated":"use transfer"`)
+	assert.Contains(t, description.Methods, jsonrpc.DescribeMethod)
+}
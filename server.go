@@ -0,0 +1,516 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"runtime/debug"
+	"sync"
+
+	"github.com/juju/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	ErrMethodNotRegistered = errors.ConstError("method is not registered")
+	ErrMethodRegistered    = errors.ConstError("method is already registered")
+	ErrNotAlias            = errors.ConstError("method is not an alias")
+)
+
+// DeprecationExtension is the response extension field holding the message of a deprecated method.
+const DeprecationExtension = "deprecation"
+
+type (
+	// Handler responds to a request received by a Server. The result is marshalled into the
+	// response. Errors which are, or wrap, an Error or ErrorCoder are returned to the caller as is,
+	// anything else is reported as an internal error.
+	Handler = func(ctx context.Context, req Request) (any, error)
+
+	// Middleware wraps the handling of every request, for example to authenticate it.
+	Middleware = func(next Handler) Handler
+
+	// Validator checks the params of a request before its handler is called. A returned Error is
+	// sent to the caller as is, any other error is reported as invalid params.
+	Validator = func(ctx context.Context, method string, params json.RawMessage) error
+)
+
+// MethodValidator sets a validator which is run for the method only, after any server wide
+// validator.
+func MethodValidator(validator Validator) MethodOption {
+	return func(opts *MethodOptions) {
+		opts.Validator = validator
+	}
+}
+
+type MethodOption = func(opts *MethodOptions)
+
+type MethodOptions struct {
+	Validator    Validator
+	ParamsSchema *Schema
+	ResultSchema *Schema
+}
+
+// ServerErrorRegistry sets the registry used to encode the errors returned by handlers.
+func ServerErrorRegistry(reg *ErrorRegistry) ServerOption {
+	return func(opts *ServerOptions) {
+		opts.ErrorRegistry = reg
+	}
+}
+
+// ServerClock sets the clock used to track the activity of connections, see Clock.
+func ServerClock(clock Clock) ServerOption {
+	return func(opts *ServerOptions) {
+		opts.Clock = clock
+	}
+}
+
+type ServerOption = func(opts *ServerOptions)
+
+type ServerOptions struct {
+	ErrorRegistry *ErrorRegistry
+	Limits        ServerLimits
+	OnOverload    func(event OverloadEvent)
+	Describe      bool
+	Clock         Clock
+	ConnStore     func() ConnStore
+	MethodFilter  MethodFilter
+	// MaxRequestSize and ConnRequestSize limit the size of requests read by Serve, see
+	// ServerMaxRequestSize
+	MaxRequestSize  int64
+	ConnRequestSize func(conn net.Conn) int64
+	// HandlerObserver is notified as each handler runs, see ServerHandlerObserver
+	HandlerObserver HandlerObserver
+}
+
+func DefaultServerOptions() ServerOptions {
+	return ServerOptions{
+		ErrorRegistry: NewErrorRegistry(),
+		Clock:         RealClock(),
+		ConnStore:     NewConnStore,
+	}
+}
+
+type serverMethod struct {
+	handler Handler
+	opts    MethodOptions
+}
+
+// ConnectionInfo describes the connection a request was received on, see ConnectionInfoFrom.
+type ConnectionInfo struct {
+	// Conn is the connection being served.
+	Conn Connection
+	// RemoteAddr is the address of the peer, or nil if the connection does not report one.
+	RemoteAddr net.Addr
+	// Store holds the state of the connection, see ConnStore.
+	Store ConnStore
+}
+
+type connectionInfoKey struct{}
+
+// ConnectionInfoFrom returns the connection a request was received on by Serve, from the context
+// passed to its handler. The zero value is returned for messages passed to Handle directly.
+func ConnectionInfoFrom(ctx context.Context) ConnectionInfo {
+	info, _ := ctx.Value(connectionInfoKey{}).(ConnectionInfo)
+	return info
+}
+
+func withConnectionInfo(ctx context.Context, conn Connection, store ConnStore) context.Context {
+	info := ConnectionInfo{Conn: conn, Store: store}
+	if addr, ok := conn.(interface{ RemoteAddr() net.Addr }); ok {
+		info.RemoteAddr = addr.RemoteAddr()
+	}
+	return context.WithValue(ctx, connectionInfoKey{}, info)
+}
+
+// Server dispatches the requests received on a Connection to the handlers registered for their
+// methods, writing back the responses. Batches are supported, with each element handled
+// independently.
+type Server struct {
+	opts ServerOptions
+	log  *log.Entry
+
+	mu         sync.RWMutex
+	methods    map[string]*serverMethod
+	middleware []Middleware
+	validator  Validator
+	// aliases maps an alternative method name to the name it was registered under
+	aliases      map[string]string
+	deprecations map[string]string
+	filter       *methodFilter
+
+	load serverLoad
+}
+
+// NewServer creates a server as BuildServer does, panicking if the options are invalid.
+func NewServer(options ...ServerOption) *Server {
+	s, err := BuildServer(options...)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// BuildServer creates a server, returning an error if the options are invalid, such as a method
+// whitelist combined with a blacklist, see MethodFilter.
+func BuildServer(options ...ServerOption) (*Server, error) {
+	opts := DefaultServerOptions()
+	for _, opt := range options {
+		opt(&opts)
+	}
+	if err := opts.MethodFilter.Validate(); err != nil {
+		return nil, err
+	}
+	s := &Server{
+		opts:         opts,
+		log:          log.WithField("component", "server"),
+		methods:      make(map[string]*serverMethod),
+		aliases:      make(map[string]string),
+		deprecations: make(map[string]string),
+		filter:       newMethodFilter(opts.MethodFilter),
+	}
+	s.load.conns = make(map[*serverConn]struct{})
+	s.SetLimits(opts.Limits)
+	if opts.Describe {
+		s.Register(DescribeMethod, s.describe)
+	}
+	return s, nil
+}
+
+// Register sets the handler for method, replacing any handler or alias previously registered.
+func (s *Server) Register(method string, handler Handler, options ...MethodOption) {
+	var opts MethodOptions
+	for _, opt := range options {
+		opt(&opts)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.methods[method] = &serverMethod{handler: handler, opts: opts}
+	// a registered method takes the place of any alias with the same name
+	delete(s.aliases, method)
+}
+
+// Alias makes the method registered as existing callable as name too, for example to support clients
+// of a method which has been renamed. Calls made by either name are handled by whichever handler is
+// registered for existing at the time.
+func (s *Server) Alias(name string, existing string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.methods[name]; ok {
+		return errors.Annotate(ErrMethodRegistered, name)
+	}
+	if _, ok := s.aliases[name]; ok {
+		return errors.Annotate(ErrMethodRegistered, name)
+	}
+	if target, ok := s.aliases[existing]; ok {
+		existing = target
+	}
+	if _, ok := s.methods[existing]; !ok {
+		return errors.Annotate(ErrMethodNotRegistered, existing)
+	}
+	s.aliases[name] = existing
+	return nil
+}
+
+// Unalias removes an alias added with Alias.
+func (s *Server) Unalias(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.aliases[name]; !ok {
+		return errors.Annotate(ErrNotAlias, name)
+	}
+	delete(s.aliases, name)
+	return nil
+}
+
+// Deprecate marks method, which may be an alias, as deprecated. Calls to it are logged and msg is
+// included in their responses as the DeprecationExtension field.
+func (s *Server) Deprecate(method string, msg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deprecations[method] = msg
+}
+
+// Use adds middleware which wraps every request, the first added being the outermost. Middleware
+// runs before validation.
+func (s *Server) Use(middleware ...Middleware) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.middleware = append(s.middleware, middleware...)
+}
+
+// SetValidator sets a validator which is run for every method, before any method validator.
+func (s *Server) SetValidator(validator Validator) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.validator = validator
+}
+
+// Serve handles the messages received on conn until it is closed or ctx is done, each message being
+// handled concurrently. conn is closed when Serve returns. If the server is at its connection limit,
+// see ServerLimits, ErrTooManyConnections is returned immediately.
+func (s *Server) Serve(ctx context.Context, conn Connection) error {
+	defer conn.Close()
+
+	sc, err := s.addConn(conn)
+	if err != nil {
+		return err
+	}
+	defer s.removeConn(sc)
+
+	// closed once every request has been handled
+	var store ConnStore
+	if s.opts.ConnStore != nil {
+		store = s.opts.ConnStore()
+	}
+	defer s.closeConnStore(store)
+
+	ctx = withConnectionInfo(ctx, conn, store)
+
+	limit := s.requestSizeLimit(conn)
+	if limiter, ok := conn.(readLimiter); ok && limit > 0 {
+		limiter.limitReads(limit)
+	}
+	addr := ConnectionInfoFrom(ctx).RemoteAddr
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.Close()
+		case <-stop:
+		}
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		data, err := conn.Read()
+		if err := s.checkRequestSize(addr, data, err, limit); err != nil {
+			// the connection is closed on return
+			return err
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if errors.Is(err, ErrClosed) {
+				return nil
+			}
+			return err
+		}
+
+		// messages over the pending limit are answered without being handled
+		shed := !s.acquirePending(sc)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if !shed {
+				defer sc.pending.Add(-1)
+			}
+			if resp := s.handle(ctx, data, shed); resp != nil {
+				if err := conn.Write(resp); err != nil {
+					s.log.WithError(err).Warn("failed to write response")
+				}
+			}
+		}()
+	}
+}
+
+// Handle processes a single message or batch, returning the json to send in reply. Nil is returned
+// if there is nothing to send, such as when the message only contained notifications.
+func (s *Server) Handle(ctx context.Context, data []byte) []byte {
+	return s.handle(ctx, data, false)
+}
+
+// handle processes a message, responding to any requests it contains with the overload error
+// instead of handling them if shed is true.
+func (s *Server) handle(ctx context.Context, data []byte, shed bool) []byte {
+	if !isBatch(data) {
+		resp := s.handleMessage(ctx, data, shed)
+		if resp == nil {
+			return nil
+		}
+		return s.marshal(resp)
+	}
+
+	var elements []json.RawMessage
+	if err := json.Unmarshal(data, &elements); err != nil {
+		return s.marshal(errorResponse(nil, ErrParse))
+	}
+	if len(elements) == 0 {
+		return s.marshal(errorResponse(nil, ErrInvalidRequest))
+	}
+
+	responses := make([]*Response, len(elements))
+	var wg sync.WaitGroup
+	for i, element := range elements {
+		wg.Add(1)
+		go func(i int, element json.RawMessage) {
+			defer wg.Done()
+			responses[i] = s.handleMessage(ctx, element, shed)
+		}(i, element)
+	}
+	wg.Wait()
+
+	// notifications have no response
+	var batch []*Response
+	for _, resp := range responses {
+		if resp != nil {
+			batch = append(batch, resp)
+		}
+	}
+	if len(batch) == 0 {
+		return nil
+	}
+	return s.marshal(batch)
+}
+
+func (s *Server) marshal(v any) []byte {
+	bytes, err := json.Marshal(v)
+	if err != nil {
+		s.log.WithError(err).Error("failed to marshal response")
+		return nil
+	}
+	return bytes
+}
+
+// marshalResult marshals the result of a handler to json, except for a json.RawMessage which is
+// validated and sent as is.
+func marshalResult(result any) (json.RawMessage, error) {
+	raw, ok := result.(json.RawMessage)
+	if !ok {
+		return json.Marshal(result)
+	}
+	if raw == nil {
+		return json.RawMessage("null"), nil
+	}
+	if !json.Valid(raw) {
+		return nil, errors.New("invalid raw json")
+	}
+	return raw, nil
+}
+
+func errorResponse(id json.RawMessage, e Error) *Response {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	return &Response{Id: id, Error: &e, Version: "2.0"}
+}
+
+// handleMessage handles a single request, returning nil if it was a notification.
+func (s *Server) handleMessage(ctx context.Context, data []byte, shed bool) *Response {
+	var req Request
+	if err := json.Unmarshal(data, &req); err != nil {
+		return errorResponse(nil, ErrParse)
+	}
+	if req.Method == "" {
+		return errorResponse(req.Id, ErrInvalidRequest)
+	}
+
+	if shed || !s.acquireRequest() {
+		if req.Id == nil {
+			return nil
+		}
+		return errorResponse(req.Id, s.overloadError())
+	}
+	defer s.releaseRequest()
+
+	result, err := s.call(ctx, req)
+	if req.Id == nil {
+		// notification
+		if err != nil {
+			s.log.WithError(err).WithField("method", req.Method).Debug("notification failed")
+		}
+		return nil
+	}
+	if err != nil {
+		return s.deprecate(req.Method, errorResponse(req.Id, s.opts.ErrorRegistry.Encode(err)))
+	}
+
+	bytes, err := marshalResult(result)
+	if err != nil {
+		s.log.WithError(err).WithField("method", req.Method).Error("failed to marshal result")
+		return errorResponse(req.Id, ErrInternal)
+	}
+	return s.deprecate(req.Method, &Response{Id: req.Id, Result: bytes, Version: "2.0"})
+}
+
+// deprecate adds the deprecation message to resp if method is deprecated.
+func (s *Server) deprecate(method string, resp *Response) *Response {
+	s.mu.RLock()
+	msg, ok := s.deprecations[method]
+	s.mu.RUnlock()
+
+	if ok {
+		s.log.WithField("method", method).Warn("deprecated method called")
+		_ = resp.SetExtension(DeprecationExtension, msg)
+	}
+	return resp
+}
+
+// call runs the middleware, validators and handler for req.
+func (s *Server) call(ctx context.Context, req Request) (result any, err error) {
+	if !s.filter.accepts(req.Method) {
+		return nil, ErrMethodNotFound
+	}
+
+	s.mu.RLock()
+	name := req.Method
+	if target, isAlias := s.aliases[name]; isAlias {
+		name = target
+	}
+	method, ok := s.methods[name]
+	middleware := s.middleware
+	validator := s.validator
+	s.mu.RUnlock()
+
+	if !ok {
+		return nil, ErrMethodNotFound
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			s.log.
+				WithField("method", req.Method).
+				WithField("panic", r).
+				WithField("stack", string(debug.Stack())).
+				Error("panic while handling request")
+			result, err = nil, ErrInternal
+		}
+	}()
+
+	handler := func(ctx context.Context, req Request) (any, error) {
+		for _, validate := range []Validator{validator, method.opts.Validator} {
+			if validate == nil {
+				continue
+			}
+			if err := validate(ctx, req.Method, req.Params); err != nil {
+				return nil, invalidParams(req.Method, err)
+			}
+		}
+		return s.invoke(ctx, req, method.handler)
+	}
+
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+
+	return handler(ctx, req)
+}
+
+// invalidParams converts a validation failure into an error response, passing an Error through as
+// is and otherwise reporting invalid params with the failure in the data.
+func invalidParams(method string, err error) error {
+	if e, ok := asError(err); ok {
+		return e
+	}
+	e := ErrInvalidParams
+	e.Data, _ = json.Marshal(map[string]string{"method": method, "reason": err.Error()})
+	return e
+}
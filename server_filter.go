@@ -0,0 +1,102 @@
+package jsonrpc
+
+import (
+	"path"
+
+	"github.com/juju/errors"
+)
+
+var ErrMethodFilterConflict = errors.ConstError("method whitelist and blacklist cannot be combined")
+
+// ServerMethodWhitelist restricts the server to methods, rejecting calls to any other method with
+// ErrMethodNotFound before middleware or handlers run. Methods are matched by the name they were
+// called with, so an alias must be listed itself. It cannot be combined with ServerMethodBlacklist.
+func ServerMethodWhitelist(methods ...string) ServerOption {
+	return func(opts *ServerOptions) {
+		opts.MethodFilter.Whitelist = append(opts.MethodFilter.Whitelist, methods...)
+	}
+}
+
+// ServerMethodPattern adds the methods matching pattern, in the syntax of path.Match, to the
+// whitelist, e.g. "eth_*". See ServerMethodWhitelist.
+func ServerMethodPattern(pattern string) ServerOption {
+	return func(opts *ServerOptions) {
+		opts.MethodFilter.Patterns = append(opts.MethodFilter.Patterns, pattern)
+	}
+}
+
+// ServerMethodBlacklist rejects calls to methods with ErrMethodNotFound before middleware or
+// handlers run. It cannot be combined with ServerMethodWhitelist or ServerMethodPattern.
+func ServerMethodBlacklist(methods ...string) ServerOption {
+	return func(opts *ServerOptions) {
+		opts.MethodFilter.Blacklist = append(opts.MethodFilter.Blacklist, methods...)
+	}
+}
+
+// MethodFilter determines which methods a server accepts calls to. Only one of a whitelist, which
+// may include patterns, and a blacklist can be set. The zero value accepts every method.
+type MethodFilter struct {
+	Whitelist []string
+	Patterns  []string
+	Blacklist []string
+}
+
+// Validate returns ErrMethodFilterConflict if both a whitelist and a blacklist are set, or an error
+// if a pattern is malformed.
+func (f MethodFilter) Validate() error {
+	if (len(f.Whitelist) > 0 || len(f.Patterns) > 0) && len(f.Blacklist) > 0 {
+		return ErrMethodFilterConflict
+	}
+	for _, pattern := range f.Patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return errors.Annotatef(err, "method pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+// methodFilter is the compiled form of a MethodFilter.
+type methodFilter struct {
+	allow    map[string]bool
+	patterns []string
+	deny     map[string]bool
+}
+
+func newMethodFilter(f MethodFilter) *methodFilter {
+	if len(f.Whitelist) == 0 && len(f.Patterns) == 0 && len(f.Blacklist) == 0 {
+		return nil
+	}
+	filter := &methodFilter{patterns: f.Patterns}
+	if len(f.Whitelist) > 0 || len(f.Patterns) > 0 {
+		filter.allow = make(map[string]bool, len(f.Whitelist))
+		for _, method := range f.Whitelist {
+			filter.allow[method] = true
+		}
+	}
+	if len(f.Blacklist) > 0 {
+		filter.deny = make(map[string]bool, len(f.Blacklist))
+		for _, method := range f.Blacklist {
+			filter.deny[method] = true
+		}
+	}
+	return filter
+}
+
+// accepts returns true if calls to method are accepted. A nil filter accepts every method.
+func (f *methodFilter) accepts(method string) bool {
+	if f == nil {
+		return true
+	}
+	if f.deny != nil {
+		return !f.deny[method]
+	}
+	if f.allow[method] {
+		return true
+	}
+	for _, pattern := range f.patterns {
+		if matched, _ := path.Match(pattern, method); matched {
+			return true
+		}
+	}
+	return false
+}
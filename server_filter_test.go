@@ -0,0 +1,66 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/41north/jsonrpc.go"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_MethodFilter(t *testing.T) {
+	call := func(server *jsonrpc.Server, method string) *jsonrpc.Error {
+		var resp jsonrpc.Response
+		msg := []byte(`{"id":1,"method":"` + method + `","jsonrpc":"2.0"}`)
+		assert.Nil(t, json.Unmarshal(server.Handle(context.Background(), msg), &resp))
+		return resp.Error
+	}
+
+	register := func(server *jsonrpc.Server, called *[]string) {
+		server.Use(func(next jsonrpc.Handler) jsonrpc.Handler {
+			return func(ctx context.Context, req jsonrpc.Request) (any, error) {
+				*called = append(*called, req.Method)
+				return next(ctx, req)
+			}
+		})
+		for _, method := range []string{"eth_call", "eth_chainId", "net_version", "debug_traceCall"} {
+			server.Register(method, echo)
+		}
+	}
+
+	var called []string
+	server, err := jsonrpc.BuildServer(
+		jsonrpc.ServerMethodWhitelist("net_version"),
+		jsonrpc.ServerMethodPattern("eth_*"),
+	)
+	assert.Nil(t, err)
+	register(server, &called)
+
+	assert.Nil(t, call(server, "eth_call"))
+	assert.Nil(t, call(server, "eth_chainId"))
+	assert.Nil(t, call(server, "net_version"))
+	assert.Equal(t, jsonrpc.ErrMethodNotFound.Code, call(server, "debug_traceCall").Code)
+	// rejected before the middleware runs
+	assert.Equal(t, []string{"eth_call", "eth_chainId", "net_version"}, called)
+
+	called = nil
+	server, err = jsonrpc.BuildServer(jsonrpc.ServerMethodBlacklist("debug_traceCall"))
+	assert.Nil(t, err)
+	register(server, &called)
+
+	assert.Nil(t, call(server, "eth_call"))
+	assert.Equal(t, jsonrpc.ErrMethodNotFound.Code, call(server, "debug_traceCall").Code)
+	assert.Equal(t, []string{"eth_call"}, called)
+
+	// only one of the whitelist and blacklist can be set
+	_, err = jsonrpc.BuildServer(jsonrpc.ServerMethodPattern("eth_*"), jsonrpc.ServerMethodBlacklist("debug_traceCall"))
+	assert.ErrorIs(t, err, jsonrpc.ErrMethodFilterConflict)
+	assert.Panics(t, func() {
+		jsonrpc.NewServer(jsonrpc.ServerMethodWhitelist("eth_call"), jsonrpc.ServerMethodBlacklist("debug_traceCall"))
+	})
+
+	_, err = jsonrpc.BuildServer(jsonrpc.ServerMethodPattern("eth_["))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,215 @@
+package jsonrpc
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/juju/errors"
+)
+
+var (
+	// ErrOverloaded is the default error sent in response to requests shed by a Server.
+	ErrOverloaded = Error{
+		Code:    -32005,
+		Message: "server overloaded",
+	}
+
+	ErrTooManyConnections = errors.ConstError("too many connections")
+)
+
+// ShedPolicy determines how a Server sheds load once a limit has been reached.
+type ShedPolicy int
+
+const (
+	// ShedReject responds to requests over a limit with the overload error, and refuses connections
+	// over the connection limit.
+	ShedReject ShedPolicy = iota
+	// ShedCloseIdle makes room for connections over the connection limit by closing the one which
+	// has been idle the longest. Requests over a limit are rejected as with ShedReject.
+	ShedCloseIdle
+)
+
+// Limit identifies one of the limits of a Server.
+type Limit string
+
+const (
+	LimitConcurrentRequests   Limit = "concurrentRequests"
+	LimitConnections          Limit = "connections"
+	LimitPendingPerConnection Limit = "pendingPerConnection"
+)
+
+// ServerLimits bounds the work a Server accepts. A limit of zero is unlimited. OverloadError is
+// sent in response to shed requests, and may carry a retry after hint in its data, see
+// InspectRetryAfter. If it is unset ErrOverloaded is used.
+type ServerLimits struct {
+	MaxConcurrentRequests   int
+	MaxConnections          int
+	MaxPendingPerConnection int
+	Policy                  ShedPolicy
+	OverloadError           *Error
+}
+
+// Utilization is a snapshot of the work a Server is doing, along with its limits.
+type Utilization struct {
+	ConcurrentRequests int
+	Connections        int
+	Limits             ServerLimits
+}
+
+// Saturation returns the highest ratio of use to limit across the limited resources, e.g. 0.5 if
+// half of the concurrent requests permitted are in progress. It returns 0 if nothing is limited.
+func (u Utilization) Saturation() float64 {
+	var saturation float64
+	for _, l := range []struct{ used, limit int }{
+		{u.ConcurrentRequests, u.Limits.MaxConcurrentRequests},
+		{u.Connections, u.Limits.MaxConnections},
+	} {
+		if l.limit > 0 && float64(l.used)/float64(l.limit) > saturation {
+			saturation = float64(l.used) / float64(l.limit)
+		}
+	}
+	return saturation
+}
+
+// OverloadEvent describes load shed by a Server.
+type OverloadEvent struct {
+	Limit       Limit
+	Utilization Utilization
+}
+
+// ServerLoadLimits sets the initial limits of the server, which can be changed while it is running
+// with SetLimits.
+func ServerLoadLimits(limits ServerLimits) ServerOption {
+	return func(opts *ServerOptions) {
+		opts.Limits = limits
+	}
+}
+
+// ServerOnOverload sets a callback which is called whenever load is shed, for metrics or alerting.
+// It is called synchronously and must not block.
+func ServerOnOverload(fn func(event OverloadEvent)) ServerOption {
+	return func(opts *ServerOptions) {
+		opts.OnOverload = fn
+	}
+}
+
+// serverConn tracks a connection being served.
+type serverConn struct {
+	conn       Connection
+	clock      Clock
+	lastActive atomic.Int64
+	pending    atomic.Int32
+}
+
+func (c *serverConn) touch() {
+	c.lastActive.Store(c.clock.Now().UnixNano())
+}
+
+// serverLoad tracks the work of a Server against its limits.
+type serverLoad struct {
+	limits atomic.Pointer[ServerLimits]
+	active atomic.Int32
+
+	mu    sync.Mutex
+	conns map[*serverConn]struct{}
+}
+
+// SetLimits replaces the limits of the server. Work already accepted is unaffected.
+func (s *Server) SetLimits(limits ServerLimits) {
+	s.load.limits.Store(&limits)
+}
+
+// Utilization returns the current load of the server, e.g. for an autoscaler.
+func (s *Server) Utilization() Utilization {
+	s.load.mu.Lock()
+	connections := len(s.load.conns)
+	s.load.mu.Unlock()
+
+	return Utilization{
+		ConcurrentRequests: int(s.load.active.Load()),
+		Connections:        connections,
+		Limits:             *s.load.limits.Load(),
+	}
+}
+
+func (s *Server) overloaded(limit Limit) {
+	s.log.WithField("limit", limit).Debug("shedding load")
+	if s.opts.OnOverload != nil {
+		s.opts.OnOverload(OverloadEvent{Limit: limit, Utilization: s.Utilization()})
+	}
+}
+
+// overloadError returns the error to send in response to a shed request.
+func (s *Server) overloadError() Error {
+	if e := s.load.limits.Load().OverloadError; e != nil {
+		return *e
+	}
+	return ErrOverloaded
+}
+
+// acquireRequest reserves a slot for a request, returning false if the concurrent request limit
+// has been reached.
+func (s *Server) acquireRequest() bool {
+	limit := s.load.limits.Load().MaxConcurrentRequests
+	if n := s.load.active.Add(1); limit > 0 && int(n) > limit {
+		s.load.active.Add(-1)
+		s.overloaded(LimitConcurrentRequests)
+		return false
+	}
+	return true
+}
+
+func (s *Server) releaseRequest() {
+	s.load.active.Add(-1)
+}
+
+// addConn registers a connection, applying the connection limit.
+func (s *Server) addConn(conn Connection) (*serverConn, error) {
+	sc := &serverConn{conn: conn, clock: s.opts.Clock}
+	sc.touch()
+
+	limits := s.load.limits.Load()
+
+	s.load.mu.Lock()
+	var idlest *serverConn
+	if limits.MaxConnections > 0 && len(s.load.conns) >= limits.MaxConnections {
+		if limits.Policy != ShedCloseIdle {
+			s.load.mu.Unlock()
+			s.overloaded(LimitConnections)
+			return nil, ErrTooManyConnections
+		}
+		for c := range s.load.conns {
+			if idlest == nil || c.lastActive.Load() < idlest.lastActive.Load() {
+				idlest = c
+			}
+		}
+		delete(s.load.conns, idlest)
+	}
+	s.load.conns[sc] = struct{}{}
+	s.load.mu.Unlock()
+
+	if idlest != nil {
+		s.overloaded(LimitConnections)
+		_ = idlest.conn.Close()
+	}
+	return sc, nil
+}
+
+func (s *Server) removeConn(sc *serverConn) {
+	s.load.mu.Lock()
+	defer s.load.mu.Unlock()
+	delete(s.load.conns, sc)
+}
+
+// acquirePending reserves a slot for a message on sc, returning false if the pending limit for a
+// connection has been reached.
+func (s *Server) acquirePending(sc *serverConn) bool {
+	sc.touch()
+	limit := s.load.limits.Load().MaxPendingPerConnection
+	if n := sc.pending.Add(1); limit > 0 && int(n) > limit {
+		sc.pending.Add(-1)
+		s.overloaded(LimitPendingPerConnection)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,132 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/41north/jsonrpc.go"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_ConcurrentRequestLimit(t *testing.T) {
+	release := make(chan struct{})
+
+	var mu sync.Mutex
+	var events []jsonrpc.OverloadEvent
+	overloadErr := jsonrpc.ErrOverloaded
+	overloadErr.Data = json.RawMessage(`{"retryAfter":"5s"}`)
+
+	limited := jsonrpc.NewServer(
+		jsonrpc.ServerLoadLimits(jsonrpc.ServerLimits{MaxConcurrentRequests: 2, OverloadError: &overloadErr}),
+		jsonrpc.ServerOnOverload(func(event jsonrpc.OverloadEvent) {
+			mu.Lock()
+			events = append(events, event)
+			mu.Unlock()
+		}),
+	)
+	limited.Register("wait", func(ctx context.Context, req jsonrpc.Request) (any, error) {
+		<-release
+		return nil, nil
+	})
+	limited.Register("name", func(ctx context.Context, req jsonrpc.Request) (any, error) {
+		return "a", nil
+	})
+
+	client := jsonrpc.NewClient(serverDialer(limited, nil))
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	futures := []jsonrpc.ResponseFuture{
+		client.SendAsync(*newRequest("wait", nil)),
+		client.SendAsync(*newRequest("wait", nil)),
+	}
+	assert.Eventually(t, func() bool {
+		return limited.Utilization().ConcurrentRequests == 2
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, 1.0, limited.Utilization().Saturation())
+
+	// further requests are shed
+	var resp jsonrpc.Response
+	assert.Nil(t, client.Send(*newRequest("name", nil), &resp))
+	err := resp.UnmarshalResult(nil)
+	assert.ErrorIs(t, err, jsonrpc.ErrServerBusy)
+	after, ok := jsonrpc.RetryAfter(err)
+	assert.True(t, ok)
+	assert.Equal(t, 5*time.Second, after)
+
+	mu.Lock()
+	assert.Len(t, events, 1)
+	assert.Equal(t, jsonrpc.LimitConcurrentRequests, events[0].Limit)
+	mu.Unlock()
+
+	// limits can be raised at runtime
+	limited.SetLimits(jsonrpc.ServerLimits{MaxConcurrentRequests: 3})
+	assert.Nil(t, client.Send(*newRequest("name", nil), &resp))
+	assert.Nil(t, resp.Error)
+
+	close(release)
+	_, err = jsonrpc.WaitAll(context.Background(), futures)
+	assert.Nil(t, err)
+}
+
+func TestServer_PendingPerConnectionLimit(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	server := endpoint("a", release)
+	server.SetLimits(jsonrpc.ServerLimits{MaxPendingPerConnection: 1})
+
+	client := jsonrpc.NewClient(serverDialer(server, nil))
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	client.SendAsync(*newRequest("wait", nil))
+	assert.Eventually(t, func() bool {
+		return server.Utilization().ConcurrentRequests == 1
+	}, time.Second, time.Millisecond)
+
+	var resp jsonrpc.Response
+	assert.Nil(t, client.Send(*newRequest("name", nil), &resp))
+	assert.Equal(t, jsonrpc.ErrOverloaded.Code, resp.Error.Code)
+
+	// other connections are unaffected
+	other := jsonrpc.NewClient(serverDialer(server, nil))
+	assert.Nil(t, other.Connect())
+	defer other.Close()
+	assert.Nil(t, other.Send(*newRequest("name", nil), &resp))
+	assert.Nil(t, resp.Error)
+}
+
+func TestServer_ConnectionLimit(t *testing.T) {
+	server := endpoint("a", nil)
+	server.SetLimits(jsonrpc.ServerLimits{MaxConnections: 1})
+
+	serve := func() (chan error, net.Conn) {
+		clientConn, serverConn := net.Pipe()
+		served := make(chan error, 1)
+		go func() {
+			served <- server.Serve(context.Background(), jsonrpc.NewStreamConnection(serverConn, jsonrpc.FramingNewline))
+		}()
+		return served, clientConn
+	}
+
+	first, firstConn := serve()
+	defer firstConn.Close()
+	assert.Eventually(t, func() bool { return server.Utilization().Connections == 1 }, time.Second, time.Millisecond)
+
+	// connections over the limit are refused
+	second, secondConn := serve()
+	defer secondConn.Close()
+	assert.ErrorIs(t, <-second, jsonrpc.ErrTooManyConnections)
+
+	// or make room by closing the idlest
+	server.SetLimits(jsonrpc.ServerLimits{MaxConnections: 1, Policy: jsonrpc.ShedCloseIdle})
+	_, thirdConn := serve()
+	defer thirdConn.Close()
+	assert.Nil(t, <-first)
+	assert.Equal(t, 1, server.Utilization().Connections)
+}
@@ -0,0 +1,61 @@
+package jsonrpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+// HandlerStatus is the outcome of running a handler, see HandlerObserver.
+type HandlerStatus string
+
+const (
+	HandlerSuccess  HandlerStatus = "success"
+	HandlerRPCError HandlerStatus = "rpc_error"
+	HandlerPanic    HandlerStatus = "handler_panic"
+	// HandlerTimeout is the status of a handler which failed with context.DeadlineExceeded.
+	HandlerTimeout HandlerStatus = "timeout"
+)
+
+// HandlerObserver is notified as each handler of a server runs, for example to collect metrics.
+// The duration is measured from when the handler is called, after middleware and validation, until
+// it returns. Implementations must be safe for concurrent use.
+type HandlerObserver interface {
+	OnHandlerStart(method string)
+	OnHandlerEnd(method string, duration time.Duration, status HandlerStatus)
+}
+
+// ServerHandlerObserver sets an observer which is notified as each handler runs.
+func ServerHandlerObserver(observer HandlerObserver) ServerOption {
+	return func(opts *ServerOptions) {
+		opts.HandlerObserver = observer
+	}
+}
+
+// invoke calls handler for req, notifying the handler observer, if any.
+func (s *Server) invoke(ctx context.Context, req Request, handler Handler) (result any, err error) {
+	observer := s.opts.HandlerObserver
+	if observer == nil {
+		return handler(ctx, req)
+	}
+
+	observer.OnHandlerStart(req.Method)
+	start := s.opts.Clock.Now()
+	// a panic leaves the status unchanged, it is recovered further up
+	status := HandlerPanic
+	defer func() {
+		observer.OnHandlerEnd(req.Method, s.opts.Clock.Now().Sub(start), status)
+	}()
+
+	result, err = handler(ctx, req)
+	switch {
+	case err == nil:
+		status = HandlerSuccess
+	case errors.Is(err, context.DeadlineExceeded):
+		status = HandlerTimeout
+	default:
+		status = HandlerRPCError
+	}
+	return result, err
+}
@@ -0,0 +1,93 @@
+package jsonrpc
+
+import (
+	"net"
+
+	"github.com/gorilla/websocket"
+	"github.com/juju/errors"
+)
+
+// ServerMaxRequestSize limits the size in bytes of each message read by Serve, protecting the
+// server from peers which send huge requests. A message over the limit cannot be parsed, so no
+// error can be sent in reply; instead the connection is closed and Serve returns an error matching
+// ErrMessageTooLarge. Stream and websocket connections stop reading once the limit is exceeded,
+// lowering any limit of their own, whereas other connections are checked once the message has
+// been read. A size of zero or less removes the limit.
+func ServerMaxRequestSize(size int64) ServerOption {
+	return func(opts *ServerOptions) {
+		opts.MaxRequestSize = size
+	}
+}
+
+// ServerConnRequestSize sets a function which chooses the request size limit of each connection,
+// overriding ServerMaxRequestSize, e.g. to allow larger requests from trusted peers. It is passed
+// the underlying net.Conn of connections which implement NetConnection, and nil for any other. A
+// limit of zero falls back to ServerMaxRequestSize, and a negative limit removes it.
+func ServerConnRequestSize(fn func(conn net.Conn) int64) ServerOption {
+	return func(opts *ServerOptions) {
+		opts.ConnRequestSize = fn
+	}
+}
+
+// readLimiter is implemented by connections which can stop reading a message once it exceeds a
+// size, rather than reading it in full.
+type readLimiter interface {
+	limitReads(size int64)
+}
+
+// requestSizeLimit returns the request size limit for conn, or zero if it is unlimited.
+func (s *Server) requestSizeLimit(conn Connection) int64 {
+	limit := s.opts.MaxRequestSize
+	if s.opts.ConnRequestSize != nil {
+		var netConn net.Conn
+		if nc, ok := conn.(NetConnection); ok {
+			netConn = nc.NetConn()
+		}
+		if override := s.opts.ConnRequestSize(netConn); override != 0 {
+			limit = override
+		}
+	}
+	if limit < 0 {
+		return 0
+	}
+	return limit
+}
+
+// checkRequestSize returns an error matching ErrMessageTooLarge, and logs it, if the read which
+// returned data and err exceeded limit.
+func (s *Server) checkRequestSize(addr net.Addr, data []byte, err error, limit int64) error {
+	if limit <= 0 {
+		return nil
+	}
+	if err == nil && int64(len(data)) > limit {
+		err = errors.Annotatef(ErrMessageTooLarge, "%d bytes", len(data))
+	}
+	if !errors.Is(err, ErrMessageTooLarge) {
+		return nil
+	}
+
+	entry := s.log.WithError(err).WithField("limit", limit)
+	if addr != nil {
+		entry = entry.WithField("remoteAddr", addr.String())
+	}
+	entry.Warn("request exceeds maximum size, closing connection")
+	return err
+}
+
+func (s *streamConnection) limitReads(size int64) {
+	if s.opts.MaxMessageSize <= 0 || size < int64(s.opts.MaxMessageSize) {
+		s.opts.MaxMessageSize = int(size)
+	}
+}
+
+func (w *webSocketConnection) limitReads(size int64) {
+	w.conn.SetReadLimit(size)
+}
+
+// mapWebSocketError maps the error returned by a read over the read limit to ErrMessageTooLarge.
+func mapWebSocketError(err error) error {
+	if errors.Is(err, websocket.ErrReadLimit) {
+		return errors.Annotate(ErrMessageTooLarge, err.Error())
+	}
+	return err
+}
@@ -0,0 +1,86 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/41north/jsonrpc.go"
+
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+// oversized returns a request whose params make it larger than size bytes.
+func oversized(size int) []byte {
+	data, _ := json.Marshal(newRequest("echo", strings.Repeat("x", size)))
+	return append(data, '\n')
+}
+
+func TestServer_MaxRequestSize(t *testing.T) {
+	hook := logtest.NewGlobal()
+	defer hook.Reset()
+
+	server := jsonrpc.NewServer(jsonrpc.ServerMaxRequestSize(1024))
+	server.Register("echo", echo)
+
+	clientConn, serverConn := net.Pipe()
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve(context.Background(), jsonrpc.NewStreamConnection(serverConn, jsonrpc.FramingNewline))
+	}()
+
+	client := jsonrpc.NewClientWithConnection(jsonrpc.NewStreamConnection(clientConn, jsonrpc.FramingNewline))
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	// requests within the limit are handled
+	var resp jsonrpc.Response
+	assert.Nil(t, client.Send(*newRequest("echo", "small"), &resp))
+	assert.Nil(t, resp.Error)
+
+	// the server stops reading part way through an oversized request and closes the connection
+	go func() {
+		_, _ = clientConn.Write(oversized(1 << 20))
+	}()
+	assert.ErrorIs(t, <-served, jsonrpc.ErrMessageTooLarge)
+	assert.Equal(t, "request exceeds maximum size, closing connection", hook.LastEntry().Message)
+	assert.Equal(t, int64(1024), hook.LastEntry().Data["limit"])
+}
+
+func TestServer_ConnRequestSize(t *testing.T) {
+	server := jsonrpc.NewServer(
+		jsonrpc.ServerMaxRequestSize(1024),
+		jsonrpc.ServerConnRequestSize(func(conn net.Conn) int64 {
+			assert.NotNil(t, conn)
+			return 1 << 20
+		}),
+	)
+	server.Register("echo", echo)
+
+	clientConn, serverConn := net.Pipe()
+	go server.Serve(context.Background(), jsonrpc.NewStreamConnection(serverConn, jsonrpc.FramingNewline))
+
+	client := jsonrpc.NewClientWithConnection(jsonrpc.NewStreamConnection(clientConn, jsonrpc.FramingNewline))
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	// the connection is allowed more than the server default
+	var resp jsonrpc.Response
+	assert.Nil(t, client.Send(*newRequest("echo", strings.Repeat("x", 4096)), &resp))
+	assert.Nil(t, resp.Error)
+}
+
+func TestServer_MaxRequestSizeUnlimitedConnection(t *testing.T) {
+	server := jsonrpc.NewServer(jsonrpc.ServerMaxRequestSize(1024))
+	server.Register("echo", echo)
+
+	// the connection cannot limit its reads, so the message is checked once read
+	conn := newSinkConnection()
+	conn.reads <- oversized(4096)
+
+	assert.ErrorIs(t, server.Serve(context.Background(), conn), jsonrpc.ErrMessageTooLarge)
+	assert.Len(t, conn.written, 0)
+}
@@ -0,0 +1,147 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"sync/atomic"
+	"testing"
+
+	"github.com/41north/jsonrpc.go"
+
+	"github.com/juju/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func echo(ctx context.Context, req jsonrpc.Request) (any, error) {
+	return req.Params, nil
+}
+
+func TestServer_Serve(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+
+	server := jsonrpc.NewServer()
+	server.Register("echo", echo)
+
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve(context.Background(), jsonrpc.NewStreamConnection(serverConn, jsonrpc.FramingNewline))
+	}()
+
+	client := jsonrpc.NewClientWithConnection(jsonrpc.NewStreamConnection(clientConn, jsonrpc.FramingNewline))
+	assert.Nil(t, client.Connect())
+
+	var resp jsonrpc.Response
+	assert.Nil(t, client.Send(*newRequest("echo", []string{"hello"}), &resp))
+
+	var result []string
+	assert.Nil(t, resp.UnmarshalResult(&result))
+	assert.Equal(t, []string{"hello"}, result)
+
+	assert.Nil(t, client.Send(*newRequest("unknown", nil), &resp))
+	assert.Equal(t, jsonrpc.ErrMethodNotFound.Code, resp.Error.Code)
+
+	assert.Nil(t, client.Close())
+	assert.Nil(t, <-served)
+}
+
+func TestServer_Validation(t *testing.T) {
+	server := jsonrpc.NewServer()
+
+	// params size cap for every method
+	server.SetValidator(func(ctx context.Context, method string, params json.RawMessage) error {
+		if len(params) > 16 {
+			return errors.New("params too large")
+		}
+		return nil
+	})
+
+	// auth middleware runs before validation
+	var validated atomic.Bool
+	server.Use(func(next jsonrpc.Handler) jsonrpc.Handler {
+		return func(ctx context.Context, req jsonrpc.Request) (any, error) {
+			if req.Method == "admin" {
+				return nil, jsonrpc.Error{Code: -32001, Message: "unauthorized"}
+			}
+			return next(ctx, req)
+		}
+	})
+
+	server.Register("echo", echo, jsonrpc.MethodValidator(
+		func(ctx context.Context, method string, params json.RawMessage) error {
+			validated.Store(true)
+			if string(params) == "[]" {
+				return &jsonrpc.Error{Code: -32602, Message: "params required", Data: json.RawMessage(`{"min":1}`)}
+			}
+			return nil
+		},
+	))
+	server.Register("admin", echo, jsonrpc.MethodValidator(
+		func(ctx context.Context, method string, params json.RawMessage) error {
+			validated.Store(true)
+			return nil
+		},
+	))
+
+	handle := func(msg string) string {
+		return string(server.Handle(context.Background(), []byte(msg)))
+	}
+
+	assert.Equal(t,
+		`{"id":1,"error":{"code":-32001,"message":"unauthorized"},"jsonrpc":"2.0"}`,
+		handle(`{"id":1,"method":"admin","params":[1],"jsonrpc":"2.0"}`),
+	)
+	assert.False(t, validated.Load())
+
+	// other errors are wrapped as invalid params, with details in the data
+	assert.Equal(t,
+		`{"id":2,"error":{"code":-32602,"message":"invalid params","data":{"method":"echo","reason":"params too large"}},"jsonrpc":"2.0"}`,
+		handle(`{"id":2,"method":"echo","params":["0123456789abcdef"],"jsonrpc":"2.0"}`),
+	)
+
+	// a returned Error passes through verbatim
+	assert.Equal(t,
+		`{"id":3,"error":{"code":-32602,"message":"params required","data":{"min":1}},"jsonrpc":"2.0"}`,
+		handle(`{"id":3,"method":"echo","params":[],"jsonrpc":"2.0"}`),
+	)
+	assert.True(t, validated.Load())
+
+	// batch members are validated independently
+	assert.Equal(t,
+		`[{"id":4,"result":[1],"jsonrpc":"2.0"},{"id":5,"error":{"code":-32602,"message":"params required","data":{"min":1}},"jsonrpc":"2.0"}]`,
+		handle(`[{"id":4,"method":"echo","params":[1],"jsonrpc":"2.0"},{"id":5,"method":"echo","params":[],"jsonrpc":"2.0"}]`),
+	)
+
+	// notifications receive no response, even when invalid
+	assert.Equal(t, "", handle(`{"method":"echo","params":[],"jsonrpc":"2.0"}`))
+}
+
+func TestServer_Alias(t *testing.T) {
+	server := jsonrpc.NewServer()
+	server.Register("eth_echo", echo)
+
+	assert.ErrorIs(t, server.Alias("echo", "unknown"), jsonrpc.ErrMethodNotRegistered)
+	assert.Nil(t, server.Alias("echo", "eth_echo"))
+	assert.ErrorIs(t, server.Alias("echo", "eth_echo"), jsonrpc.ErrMethodRegistered)
+	server.Deprecate("echo", "use eth_echo")
+
+	handle := func(msg string) string {
+		return string(server.Handle(context.Background(), []byte(msg)))
+	}
+
+	assert.Equal(t,
+		`{"id":1,"result":[1],"jsonrpc":"2.0"}`,
+		handle(`{"id":1,"method":"eth_echo","params":[1],"jsonrpc":"2.0"}`),
+	)
+	assert.Equal(t,
+		`{"id":2,"result":[2],"jsonrpc":"2.0","deprecation":"use eth_echo"}`,
+		handle(`{"id":2,"method":"echo","params":[2],"jsonrpc":"2.0"}`),
+	)
+
+	assert.Nil(t, server.Unalias("echo"))
+	assert.ErrorIs(t, server.Unalias("echo"), jsonrpc.ErrNotAlias)
+	assert.Equal(t,
+		`{"id":3,"error":{"code":-32601,"message":"method not found"},"jsonrpc":"2.0","deprecation":"use eth_echo"}`,
+		handle(`{"id":3,"method":"echo","params":[3],"jsonrpc":"2.0"}`),
+	)
+}
@@ -0,0 +1,192 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// Handle registers fn as the handler for method on srv, decoding the params of each request into
+// a P and returning the R it produces as the result. The params may be given by name, as an object
+// which unmarshals into P, or by position, as an array holding a single element which does. If P
+// is itself a slice or array the params are unmarshalled into it as is. Requests without params
+// are passed the zero value of P. Params which cannot be decoded are reported as invalid params.
+//
+// The result is marshalled to json, unless R is json.RawMessage, which is sent as is. The handler is
+// called through the middleware of srv, as any registered with Register is.
+//
+// P is inspected once, on registration, so neither decoding nor the call use reflection, which makes
+// it cheaper than the same method registered with RegisterService. The method is described by the
+// schemas of P and R, see SchemaOf, unless MethodSchema is given in options.
+func Handle[P, R any](srv *Server, method string, fn func(ctx context.Context, params P) (R, error), options ...MethodOption) {
+	decode := paramDecoder[P]()
+	options = append([]MethodOption{MethodSchema(SchemaOf[P](), SchemaOf[R]())}, options...)
+	srv.Register(method, func(ctx context.Context, req Request) (any, error) {
+		params, err := decode(req.Params)
+		if err != nil {
+			return nil, invalidParams(req.Method, err)
+		}
+		return fn(ctx, params)
+	}, options...)
+}
+
+// paramDecoder returns a function which decodes params into a P. Params given by position are
+// decoded in a single pass, rather than being split with positionalParam first.
+func paramDecoder[P any]() func(params json.RawMessage) (P, error) {
+	unwrap := unwrapsParams(reflect.TypeOf((*P)(nil)).Elem())
+
+	return func(params json.RawMessage) (P, error) {
+		var p P
+		params = bytes.TrimSpace(params)
+		if len(params) == 0 || string(params) == "null" {
+			return p, nil
+		}
+		if !unwrap || params[0] != '[' {
+			err := json.Unmarshal(params, &p)
+			return p, err
+		}
+		var elements []P
+		if err := json.Unmarshal(params, &elements); err != nil {
+			return p, err
+		}
+		if len(elements) != 1 {
+			return p, errors.Errorf("expected 1 positional param, received %d", len(elements))
+		}
+		return elements[0], nil
+	}
+}
+
+// unwrapsParams returns true if params given by position are unwrapped before they are decoded into
+// a t, which is the case unless t can hold the whole array.
+func unwrapsParams(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Kind() != reflect.Slice && t.Kind() != reflect.Array && t.Kind() != reflect.Interface
+}
+
+// positionalParam returns the params to decode, unwrapping the single element of params given by
+// position if unwrap is true. Nil is returned if there are no params.
+func positionalParam(params json.RawMessage, unwrap bool) (json.RawMessage, error) {
+	params = bytes.TrimSpace(params)
+	if len(params) == 0 || string(params) == "null" {
+		return nil, nil
+	}
+	if !unwrap || params[0] != '[' {
+		return params, nil
+	}
+	var elements []json.RawMessage
+	if err := json.Unmarshal(params, &elements); err != nil {
+		return nil, err
+	}
+	if len(elements) != 1 {
+		return nil, errors.Errorf("expected 1 positional param, received %d", len(elements))
+	}
+	return elements[0], nil
+}
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// RegisterService registers each exported method of service which has the signature of a handler
+// passed to Handle, func(ctx context.Context, params P) (R, error), or which takes no params,
+// func(ctx context.Context) (R, error). Methods with any other signature are skipped. Each method
+// is registered as the name of the service and the method joined by an underscore, with the first
+// letter of the method in lower case, so that the BlockNumber method of the "eth" service is
+// registered as "eth_blockNumber". An empty name registers the methods by their own names.
+//
+// Params are decoded and results encoded as they are by Handle, and each method is described by
+// the schemas of its P and R, unless MethodSchema is given in options. The options apply to every
+// method registered. An error matching errors.NotValid is returned, and nothing is registered, if
+// service has no suitable methods.
+func (s *Server) RegisterService(name string, service any, options ...MethodOption) error {
+	v := reflect.ValueOf(service)
+	t := v.Type()
+
+	type serviceMethod struct {
+		name    string
+		handler Handler
+		schema  MethodOption
+	}
+	var methods []serviceMethod
+	for i := 0; i < t.NumMethod(); i++ {
+		method := t.Method(i)
+		if !method.IsExported() {
+			continue
+		}
+		handler, schema, ok := methodHandler(v.Method(i))
+		if !ok {
+			continue
+		}
+		methods = append(methods, serviceMethod{
+			name:    serviceMethodName(name, method.Name),
+			handler: handler,
+			schema:  schema,
+		})
+	}
+	if len(methods) == 0 {
+		return errors.NotValidf("service %q of type %s without methods", name, t)
+	}
+
+	for _, m := range methods {
+		s.Register(m.name, m.handler, append([]MethodOption{m.schema}, options...)...)
+	}
+	return nil
+}
+
+// serviceMethodName returns the name with which method of service is registered.
+func serviceMethodName(service string, method string) string {
+	method = strings.ToLower(method[:1]) + method[1:]
+	if service == "" {
+		return method
+	}
+	return service + "_" + method
+}
+
+// methodHandler returns a handler calling fn, and the schema describing it, or false if fn does not
+// have the signature of a handler.
+func methodHandler(fn reflect.Value) (Handler, MethodOption, bool) {
+	t := fn.Type()
+	if t.NumIn() < 1 || t.NumIn() > 2 || t.In(0) != contextType || t.IsVariadic() {
+		return nil, nil, false
+	}
+	if t.NumOut() != 2 || t.Out(1) != errorType {
+		return nil, nil, false
+	}
+	resultSchema := schemaOf(t.Out(0), make(map[reflect.Type]bool))
+
+	if t.NumIn() == 1 {
+		return func(ctx context.Context, req Request) (any, error) {
+			return callMethod(fn, reflect.ValueOf(ctx))
+		}, MethodSchema(nil, resultSchema), true
+	}
+
+	paramsType := t.In(1)
+	unwrap := unwrapsParams(paramsType)
+	return func(ctx context.Context, req Request) (any, error) {
+		params := reflect.New(paramsType)
+		raw, err := positionalParam(req.Params, unwrap)
+		if err == nil && raw != nil {
+			err = json.Unmarshal(raw, params.Interface())
+		}
+		if err != nil {
+			return nil, invalidParams(req.Method, err)
+		}
+		return callMethod(fn, reflect.ValueOf(ctx), params.Elem())
+	}, MethodSchema(schemaOf(paramsType, make(map[reflect.Type]bool)), resultSchema), true
+}
+
+// callMethod calls fn with args, returning its result and error.
+func callMethod(fn reflect.Value, args ...reflect.Value) (any, error) {
+	out := fn.Call(args)
+	if err, _ := out[1].Interface().(error); err != nil {
+		return nil, err
+	}
+	return out[0].Interface(), nil
+}
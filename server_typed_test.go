@@ -0,0 +1,211 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/41north/jsonrpc.go"
+
+	"github.com/juju/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type transfer struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Amount int    `json:"amount"`
+}
+
+func handleTransfer(ctx context.Context, t transfer) (string, error) {
+	return t.From + ":" + t.To, nil
+}
+
+func TestHandle(t *testing.T) {
+	server := jsonrpc.NewServer()
+	jsonrpc.Handle(server, "transfer", handleTransfer)
+	jsonrpc.Handle(server, "transferPtr", func(ctx context.Context, t *transfer) (int, error) {
+		if t == nil {
+			return -1, nil
+		}
+		return t.Amount, nil
+	})
+	jsonrpc.Handle(server, "sum", func(ctx context.Context, values []int) (int, error) {
+		sum := 0
+		for _, v := range values {
+			sum += v
+		}
+		return sum, nil
+	})
+
+	testCases := []struct {
+		request string
+		result  string
+		code    int32
+	}{
+		// named
+		{`{"id":1,"method":"transfer","params":{"from":"a","to":"b"}}`, `"a:b"`, 0},
+		// positional
+		{`{"id":1,"method":"transfer","params":[{"from":"c","to":"d"}]}`, `"c:d"`, 0},
+		{`{"id":1,"method":"transferPtr","params":[{"amount":5}]}`, `5`, 0},
+		{`{"id":1,"method":"transferPtr"}`, `-1`, 0},
+		// slices take the whole array
+		{`{"id":1,"method":"sum","params":[1,2,3]}`, `6`, 0},
+		// invalid
+		{`{"id":1,"method":"transfer","params":[{"from":"a"},{"from":"b"}]}`, ``, jsonrpc.ErrInvalidParams.Code},
+		{`{"id":1,"method":"transfer","params":{"amount":"lots"}}`, ``, jsonrpc.ErrInvalidParams.Code},
+		{`{"id":1,"method":"sum","params":{"a":1}}`, ``, jsonrpc.ErrInvalidParams.Code},
+	}
+
+	for _, tt := range testCases {
+		var resp jsonrpc.Response
+		assert.Nil(t, json.Unmarshal(server.Handle(context.Background(), []byte(tt.request)), &resp))
+		if tt.code != 0 {
+			assert.NotNil(t, resp.Error, tt.request)
+			assert.Equal(t, tt.code, resp.Error.Code, tt.request)
+			continue
+		}
+		assert.Nil(t, resp.Error, tt.request)
+		assert.Equal(t, tt.result, string(resp.Result), tt.request)
+	}
+}
+
+func TestHandle_RawResultAndMiddleware(t *testing.T) {
+	server := jsonrpc.NewServer()
+
+	var called []string
+	server.Use(func(next jsonrpc.Handler) jsonrpc.Handler {
+		return func(ctx context.Context, req jsonrpc.Request) (any, error) {
+			called = append(called, req.Method)
+			return next(ctx, req)
+		}
+	})
+
+	jsonrpc.Handle(server, "raw", func(ctx context.Context, valid bool) (json.RawMessage, error) {
+		if !valid {
+			return json.RawMessage(`{"broken"`), nil
+		}
+		return json.RawMessage(`{"cached":true}`), nil
+	})
+
+	var resp jsonrpc.Response
+	assert.Nil(t, json.Unmarshal(server.Handle(context.Background(), []byte(`{"id":1,"method":"raw","params":[true]}`)), &resp))
+	assert.Nil(t, resp.Error)
+	assert.Equal(t, `{"cached":true}`, string(resp.Result))
+
+	// invalid raw results are not sent
+	assert.Nil(t, json.Unmarshal(server.Handle(context.Background(), []byte(`{"id":2,"method":"raw","params":[false]}`)), &resp))
+	assert.Equal(t, jsonrpc.ErrInternal.Code, resp.Error.Code)
+
+	// typed handlers are called through the middleware
+	assert.Equal(t, []string{"raw", "raw"}, called)
+}
+
+type ledger struct {
+	balance int
+}
+
+func (l *ledger) Transfer(ctx context.Context, t transfer) (string, error) {
+	return handleTransfer(ctx, t)
+}
+
+func (l *ledger) Balance(ctx context.Context) (int, error) {
+	return l.balance, nil
+}
+
+func (l *ledger) Sum(ctx context.Context, values []int) (int, error) {
+	sum := 0
+	for _, v := range values {
+		sum += v
+	}
+	return sum, nil
+}
+
+func (l *ledger) Fail(ctx context.Context) (int, error) {
+	return 0, jsonrpc.ErrInvalidRequest
+}
+
+// not handlers
+func (l *ledger) Reset()                                         {}
+func (l *ledger) Close(ctx context.Context, reason string) error { return nil }
+
+func TestServer_RegisterService(t *testing.T) {
+	server := jsonrpc.NewServer(jsonrpc.ServerDescribe())
+	assert.Nil(t, server.RegisterService("ledger", &ledger{balance: 42}))
+
+	testCases := []struct {
+		request string
+		result  string
+		code    int32
+	}{
+		{`{"id":1,"method":"ledger_transfer","params":{"from":"a","to":"b"}}`, `"a:b"`, 0},
+		{`{"id":1,"method":"ledger_transfer","params":[{"from":"c","to":"d"}]}`, `"c:d"`, 0},
+		{`{"id":1,"method":"ledger_balance"}`, `42`, 0},
+		{`{"id":1,"method":"ledger_sum","params":[1,2,3]}`, `6`, 0},
+		{`{"id":1,"method":"ledger_fail"}`, ``, jsonrpc.ErrInvalidRequest.Code},
+		{`{"id":1,"method":"ledger_transfer","params":{"amount":"lots"}}`, ``, jsonrpc.ErrInvalidParams.Code},
+		{`{"id":1,"method":"ledger_reset"}`, ``, jsonrpc.ErrMethodNotFound.Code},
+		{`{"id":1,"method":"ledger_close","params":["done"]}`, ``, jsonrpc.ErrMethodNotFound.Code},
+	}
+
+	for _, tt := range testCases {
+		var resp jsonrpc.Response
+		assert.Nil(t, json.Unmarshal(server.Handle(context.Background(), []byte(tt.request)), &resp))
+		if tt.code != 0 {
+			assert.NotNil(t, resp.Error, tt.request)
+			assert.Equal(t, tt.code, resp.Error.Code, tt.request)
+			continue
+		}
+		assert.Nil(t, resp.Error, tt.request)
+		assert.Equal(t, tt.result, string(resp.Result), tt.request)
+	}
+
+	schema, err := server.SchemaFor("ledger_transfer")
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{
+		"params": {
+			"type": "object",
+			"properties": {"from": {"type": "string"}, "to": {"type": "string"}, "amount": {"type": "integer"}},
+			"required": ["amount", "from", "to"]
+		},
+		"result": {"type": "string"}
+	}`, string(schema))
+
+	schema, err = server.SchemaFor("ledger_balance")
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"result": {"type": "integer"}}`, string(schema))
+
+	// without a name the methods are registered by their own names
+	assert.Nil(t, server.RegisterService("", &ledger{}))
+	_, err = server.SchemaFor("sum")
+	assert.Nil(t, err)
+
+	// values without handler methods are rejected
+	assert.ErrorIs(t, server.RegisterService("ledger", ledger{}), errors.NotValid)
+	assert.ErrorIs(t, server.RegisterService("other", 5), errors.NotValid)
+}
+
+// the typed handler is compared with the same method registered by reflection with RegisterService
+var benchmarkRequest = []byte(`{"id":1,"method":"transfer","params":[{"from":"a","to":"b","amount":10}]}`)
+
+func BenchmarkHandle_Typed(b *testing.B) {
+	server := jsonrpc.NewServer()
+	jsonrpc.Handle(server, "transfer", handleTransfer)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		server.Handle(context.Background(), benchmarkRequest)
+	}
+}
+
+func BenchmarkHandle_Reflective(b *testing.B) {
+	server := jsonrpc.NewServer()
+	if err := server.RegisterService("", &ledger{}); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		server.Handle(context.Background(), benchmarkRequest)
+	}
+}
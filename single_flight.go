@@ -0,0 +1,85 @@
+package jsonrpc
+
+import (
+	"sync"
+)
+
+// WithSingleFlight coalesces requests which are in flight at the same time and share a key, so that
+// only the first is sent and every caller receives its response. key returns the key of a request
+// and whether it may be coalesced at all, which must only be the case for idempotent requests. The
+// key should not include the id, which differs between callers, see SingleFlightMethods.
+//
+// Callers share the response, including the id it was sent with, and must not modify it. Requests
+// sent with SendCancelable are never coalesced, as cancelling one would cancel the others.
+func WithSingleFlight(key func(req Request) (string, bool)) ClientOption {
+	return func(opts *ClientOptions) {
+		opts.SingleFlightKey = key
+	}
+}
+
+// SingleFlightMethods returns a key for WithSingleFlight which coalesces requests for any of
+// methods with the same params.
+func SingleFlightMethods(methods ...string) func(req Request) (string, bool) {
+	set := make(map[string]bool, len(methods))
+	for _, method := range methods {
+		set[method] = true
+	}
+	return func(req Request) (string, bool) {
+		if !set[req.Method] {
+			return "", false
+		}
+		return req.Method + "\x00" + string(req.Params), true
+	}
+}
+
+// flight is a request whose response is shared by every caller which sent a request with the same
+// key while it was in flight.
+type flight struct {
+	future ResponseFuture
+	id     string
+}
+
+type singleFlight struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+func newSingleFlight() *singleFlight {
+	return &singleFlight{flights: make(map[string]*flight)}
+}
+
+// do returns the flight in progress for key, or starts a new one with send, which is removed once
+// its response has been received.
+func (s *singleFlight) do(key string, send func() (ResponseFuture, string)) (ResponseFuture, string) {
+	s.mu.Lock()
+	if f, ok := s.flights[key]; ok {
+		s.mu.Unlock()
+		return f.future, f.id
+	}
+	// the lock is held while sending, which only blocks on a lazy connect, so concurrent callers
+	// cannot both send the same key
+	future, id := send()
+	f := &flight{future: future, id: id}
+	s.flights[key] = f
+	s.mu.Unlock()
+
+	go func() {
+		<-future.Get()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.flights[key] == f {
+			delete(s.flights, key)
+		}
+	}()
+
+	return future, id
+}
+
+// mayShare returns true if req may be coalesced with others which share its key.
+func (c *client) mayShare(req Request) bool {
+	if c.flights == nil {
+		return false
+	}
+	_, ok := c.opts.SingleFlightKey(req)
+	return ok
+}
@@ -0,0 +1,61 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/41north/jsonrpc.go"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_SingleFlight(t *testing.T) {
+	release := make(chan struct{})
+	var calls atomic.Int32
+
+	server := jsonrpc.NewServer()
+	handler := func(ctx context.Context, req jsonrpc.Request) (any, error) {
+		calls.Add(1)
+		<-release
+		return req.Params, nil
+	}
+	server.Register("get", handler)
+	server.Register("put", handler)
+
+	client := jsonrpc.NewClient(serverDialer(server, nil), jsonrpc.WithSingleFlight(jsonrpc.SingleFlightMethods("get")))
+	assert.Nil(t, client.Connect())
+	defer func() { _ = client.Close() }()
+
+	var futures []jsonrpc.ResponseFuture
+	for i := 0; i < 5; i++ {
+		futures = append(futures, client.SendAsync(*newRequest("get", []string{"a"})))
+	}
+	// different params and methods which are not idempotent are sent
+	other := client.SendAsync(*newRequest("get", []string{"b"}))
+	for i := 0; i < 2; i++ {
+		futures = append(futures, client.SendAsync(*newRequest("put", []string{"a"})))
+	}
+
+	assert.Eventually(t, func() bool {
+		return calls.Load() == 4
+	}, time.Second, time.Millisecond)
+	close(release)
+
+	for _, future := range futures {
+		resp, err := (<-future.Get()).Unwrap()
+		assert.Nil(t, err)
+		assert.Equal(t, `["a"]`, string(resp.Result))
+	}
+	resp, err := (<-other.Get()).Unwrap()
+	assert.Nil(t, err)
+	assert.Equal(t, `["b"]`, string(resp.Result))
+
+	// once complete, the next request is sent again
+	assert.Eventually(t, func() bool {
+		var resp jsonrpc.Response
+		assert.Nil(t, client.Send(*newRequest("get", []string{"a"}), &resp))
+		return calls.Load() > 4
+	}, time.Second, time.Millisecond)
+}
@@ -0,0 +1,216 @@
+package jsonrpc
+
+import (
+	"path"
+	"sync"
+	"sync/atomic"
+
+	"github.com/juju/errors"
+)
+
+var ErrAlreadySubscribed = errors.ConstError("method already has a subscription")
+
+// DefaultSubscriptionBuffer is the number of notifications a subscription holds for its consumer.
+const DefaultSubscriptionBuffer = 64
+
+// OverflowPolicy determines what happens to a notification when a subscription's buffer is full.
+type OverflowPolicy int
+
+const (
+	// DropOldest discards the oldest buffered notification to make room. Without a buffer, a
+	// notification is discarded unless the consumer is waiting for it.
+	DropOldest OverflowPolicy = iota
+	// DropNewest discards the notification which has just been received.
+	DropNewest
+	// Block waits for the consumer, which also stops the client reading any further messages.
+	Block
+)
+
+// WithSubscriptionBuffer sets the number of notifications each subscription buffers for its
+// consumer. The default is DefaultSubscriptionBuffer.
+func WithSubscriptionBuffer(size int) ClientOption {
+	return func(opts *ClientOptions) {
+		opts.SubscriptionBuffer = size
+	}
+}
+
+// WithSubscriptionOverflow sets the policy applied when a subscription's consumer cannot keep up.
+// The default is DropOldest. Dropped notifications are counted, see Subscription.Dropped.
+func WithSubscriptionOverflow(policy OverflowPolicy) ClientOption {
+	return func(opts *ClientOptions) {
+		opts.SubscriptionOverflow = policy
+	}
+}
+
+// Subscription delivers the notifications received for a method, instead of passing them to the
+// request handler.
+type Subscription struct {
+	method  string
+	policy  OverflowPolicy
+	ch      chan Request
+	dropped atomic.Uint64
+
+	mu     sync.RWMutex
+	closed bool
+	done   chan struct{}
+	once   sync.Once
+	remove func()
+}
+
+func newSubscription(method string, size int, policy OverflowPolicy, remove func()) *Subscription {
+	if size < 0 {
+		size = 0
+	}
+	return &Subscription{
+		method: method,
+		policy: policy,
+		ch:     make(chan Request, size),
+		done:   make(chan struct{}),
+		remove: remove,
+	}
+}
+
+// Method returns the notification method which was subscribed to, or the pattern for a
+// subscription made with SubscribePattern.
+func (s *Subscription) Method() string {
+	return s.method
+}
+
+// C returns the channel on which notifications are delivered. It is closed when the subscription
+// is cancelled or the client is closed.
+func (s *Subscription) C() <-chan Request {
+	return s.ch
+}
+
+// Done returns a channel which is closed once the subscription has ended, whether by Unsubscribe
+// or because the client closed.
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Dropped returns the number of notifications which have been discarded by the overflow policy.
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Unsubscribe stops delivery and closes C. Subsequent notifications for the method are passed to
+// the request handler.
+func (s *Subscription) Unsubscribe() {
+	s.once.Do(func() {
+		// unblock any delivery waiting on the consumer before closing the channel
+		close(s.done)
+		s.mu.Lock()
+		s.closed = true
+		close(s.ch)
+		s.mu.Unlock()
+		s.remove()
+	})
+}
+
+// deliver passes req to the consumer, applying the overflow policy if the buffer is full. It is
+// only called from the read loop.
+func (s *Subscription) deliver(req Request) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return
+	}
+
+	switch s.policy {
+	case Block:
+		select {
+		case s.ch <- req:
+		case <-s.done:
+		}
+	case DropNewest:
+		select {
+		case s.ch <- req:
+		default:
+			s.dropped.Add(1)
+		}
+	default:
+		if cap(s.ch) == 0 {
+			// there is nothing buffered to discard in favour of req
+			select {
+			case s.ch <- req:
+			default:
+				s.dropped.Add(1)
+			}
+			return
+		}
+		for {
+			select {
+			case s.ch <- req:
+				return
+			default:
+			}
+			select {
+			case <-s.ch:
+				s.dropped.Add(1)
+			default:
+			}
+		}
+	}
+}
+
+func (c *client) Subscribe(method string) (*Subscription, error) {
+	if c.closed.Load() {
+		return nil, ErrClosed
+	}
+	if c.oneShot.Load() {
+		return nil, errors.NotSupportedf("subscriptions over one shot connections")
+	}
+	sub := newSubscription(method, c.opts.SubscriptionBuffer, c.opts.SubscriptionOverflow, func() {
+		c.subscriptions.Delete(method)
+	})
+	if _, loaded := c.subscriptions.LoadOrStore(method, sub); loaded {
+		return nil, errors.Annotate(ErrAlreadySubscribed, method)
+	}
+	return sub, nil
+}
+
+func (c *client) SubscribePattern(pattern string) (*Subscription, error) {
+	if err := validatePattern(pattern); err != nil {
+		return nil, err
+	}
+	if c.closed.Load() {
+		return nil, ErrClosed
+	}
+	if c.oneShot.Load() {
+		return nil, errors.NotSupportedf("subscriptions over one shot connections")
+	}
+	sub := newSubscription(pattern, c.opts.SubscriptionBuffer, c.opts.SubscriptionOverflow, func() {
+		c.patternSubscriptions.Delete(pattern)
+	})
+	if _, loaded := c.patternSubscriptions.LoadOrStore(pattern, sub); loaded {
+		return nil, errors.Annotate(ErrAlreadySubscribed, pattern)
+	}
+	return sub, nil
+}
+
+func (c *client) UnsubscribePattern(pattern string) {
+	if value, ok := c.patternSubscriptions.Load(pattern); ok {
+		value.(*Subscription).Unsubscribe()
+	}
+}
+
+// subscriptionsFor returns the subscriptions a notification is delivered to: the subscription for
+// its method if there is one, otherwise every pattern subscription it matches. It returns nil for
+// other messages.
+func (c *client) subscriptionsFor(resp *Response) []*Subscription {
+	if resp.Kind() != KindNotification {
+		return nil
+	}
+	if value, ok := c.subscriptions.Load(resp.Method); ok {
+		return []*Subscription{value.(*Subscription)}
+	}
+	var subs []*Subscription
+	c.patternSubscriptions.Range(func(key, value any) bool {
+		if ok, _ := path.Match(key.(string), resp.Method); ok {
+			subs = append(subs, value.(*Subscription))
+		}
+		return true
+	})
+	return subs
+}
@@ -0,0 +1,152 @@
+package jsonrpc_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/41north/jsonrpc.go"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_SubscriptionOverflow(t *testing.T) {
+	testCases := []struct {
+		policy   jsonrpc.OverflowPolicy
+		expected []string
+	}{
+		{jsonrpc.DropOldest, []string{"[4]", "[5]"}},
+		{jsonrpc.DropNewest, []string{"[1]", "[2]"}},
+	}
+
+	for _, tc := range testCases {
+		clientConn, serverConn := net.Pipe()
+		server := jsonrpc.NewStreamConnection(serverConn, jsonrpc.FramingNewline)
+
+		client := jsonrpc.NewClientWithConnection(
+			jsonrpc.NewStreamConnection(clientConn, jsonrpc.FramingNewline),
+			jsonrpc.WithSubscriptionBuffer(2),
+			jsonrpc.WithSubscriptionOverflow(tc.policy),
+		)
+
+		// notifications without a subscription are still passed to the handler
+		handled := make(chan string, 1)
+		client.SetRequestHandler(func(req jsonrpc.Request) {
+			handled <- req.Method
+		})
+
+		sub, err := client.Subscribe("update")
+		assert.Nil(t, err)
+
+		_, err = client.Subscribe("update")
+		assert.ErrorIs(t, err, jsonrpc.ErrAlreadySubscribed)
+
+		assert.Nil(t, client.Connect())
+
+		for i := 1; i <= 5; i++ {
+			notification, err := json.Marshal(jsonrpc.Request{Method: "update", Params: json.RawMessage(fmt.Sprintf("[%d]", i)), Version: "2.0"})
+			assert.Nil(t, err)
+			assert.Nil(t, server.Write(notification))
+		}
+		assert.Nil(t, server.Write([]byte(`{"method":"done","jsonrpc":"2.0"}`)))
+
+		// messages are handled in order, so every update has been delivered
+		assert.Equal(t, "done", <-handled)
+		assert.Equal(t, uint64(3), sub.Dropped())
+
+		for _, params := range tc.expected {
+			req := <-sub.C()
+			assert.Equal(t, params, string(req.Params))
+		}
+
+		// closing the client ends the subscription
+		assert.Nil(t, client.Close())
+		_, ok := <-sub.C()
+		assert.False(t, ok)
+	}
+}
+
+func TestClient_SubscriptionOverflow_Unbuffered(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	server := jsonrpc.NewStreamConnection(serverConn, jsonrpc.FramingNewline)
+
+	client := jsonrpc.NewClientWithConnection(
+		jsonrpc.NewStreamConnection(clientConn, jsonrpc.FramingNewline),
+		jsonrpc.WithSubscriptionBuffer(0),
+		jsonrpc.WithSubscriptionOverflow(jsonrpc.DropOldest),
+	)
+	defer client.Close()
+
+	handled := make(chan string, 1)
+	client.SetRequestHandler(func(req jsonrpc.Request) {
+		handled <- req.Method
+	})
+
+	sub, err := client.Subscribe("update")
+	assert.Nil(t, err)
+	assert.Nil(t, client.Connect())
+
+	// with no consumer waiting, every update is dropped rather than stalling the read loop
+	for i := 1; i <= 3; i++ {
+		assert.Nil(t, server.Write([]byte(`{"method":"update","params":[1],"jsonrpc":"2.0"}`)))
+	}
+	assert.Nil(t, server.Write([]byte(`{"method":"done","jsonrpc":"2.0"}`)))
+
+	assert.Equal(t, "done", <-handled)
+	assert.Equal(t, uint64(3), sub.Dropped())
+}
+
+func TestClient_SubscribePattern(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	server := jsonrpc.NewStreamConnection(serverConn, jsonrpc.FramingNewline)
+
+	client := jsonrpc.NewClientWithConnection(jsonrpc.NewStreamConnection(clientConn, jsonrpc.FramingNewline))
+	handled := make(chan string, 1)
+	client.SetRequestHandler(func(req jsonrpc.Request) {
+		handled <- req.Method
+	})
+
+	_, err := client.SubscribePattern("account/[")
+	assert.NotNil(t, err)
+
+	account, err := client.SubscribePattern("account/*")
+	assert.Nil(t, err)
+	changed, err := client.SubscribePattern("*/changed")
+	assert.Nil(t, err)
+	exact, err := client.Subscribe("account/closed")
+	assert.Nil(t, err)
+
+	_, err = client.SubscribePattern("account/*")
+	assert.ErrorIs(t, err, jsonrpc.ErrAlreadySubscribed)
+
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	for _, method := range []string{"account/changed", "account/closed", "workspace/changed", "other"} {
+		assert.Nil(t, server.Write([]byte(fmt.Sprintf(`{"method":%q,"jsonrpc":"2.0"}`, method))))
+	}
+	assert.Equal(t, "other", <-handled)
+
+	methods := func(sub *jsonrpc.Subscription) []string {
+		var methods []string
+		for len(sub.C()) > 0 {
+			methods = append(methods, (<-sub.C()).Method)
+		}
+		return methods
+	}
+
+	// overlapping patterns each receive a copy, and the exact subscription takes priority
+	assert.Equal(t, []string{"account/changed"}, methods(account))
+	assert.Equal(t, []string{"account/changed", "workspace/changed"}, methods(changed))
+	assert.Equal(t, []string{"account/closed"}, methods(exact))
+
+	client.UnsubscribePattern("account/*")
+	_, ok := <-account.C()
+	assert.False(t, ok)
+
+	// notifications no longer matched are passed to the handler
+	assert.Nil(t, server.Write([]byte(`{"method":"account/opened","jsonrpc":"2.0"}`)))
+	assert.Equal(t, "account/opened", <-handled)
+	assert.Equal(t, "*/changed", changed.Method())
+}
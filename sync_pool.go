@@ -0,0 +1,154 @@
+package jsonrpc
+
+import (
+	"context"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// SyncPool is a fixed size set of clients which are lent out exclusively, for CLI tools and short
+// lived processes which do not need the load balancing and health checks of a Pool. A client is
+// taken with AcquireContext, which blocks until one is available, and given back with Release. No
+// goroutines run in the background, except to replace connections found to be broken.
+type SyncPool struct {
+	dialer  Dialer
+	options []ClientOption
+	clock   Clock
+	log     *log.Entry
+	clients chan Client
+
+	mu     sync.Mutex
+	all    map[Client]bool
+	closed bool
+	done   chan struct{}
+}
+
+// NewSyncPool creates a pool of size clients, created with options, dialing each of them before it
+// returns. Connections which cannot be dialled are replaced in the background, as broken ones are,
+// so the pool always reaches its size once the server is reachable.
+func NewSyncPool(dialer Dialer, size int, options ...ClientOption) *SyncPool {
+	opts := DefaultClientOptions()
+	for _, opt := range options {
+		opt(&opts)
+	}
+	p := &SyncPool{
+		dialer:  dialer,
+		options: options,
+		clock:   opts.Clock,
+		log:     log.WithField("component", "syncPool"),
+		clients: make(chan Client, size),
+		all:     make(map[Client]bool, size),
+		done:    make(chan struct{}),
+	}
+	for i := 0; i < size; i++ {
+		client := NewClient(dialer, options...)
+		if err := client.Connect(); err != nil {
+			p.log.WithError(err).Warn("failed to dial, replacing in the background")
+			go p.replace()
+			continue
+		}
+		p.all[client] = true
+		p.clients <- client
+	}
+	return p
+}
+
+// AcquireContext takes a client from the pool, waiting until one is available or ctx is done. The
+// client must be given back with Release.
+func (p *SyncPool) AcquireContext(ctx context.Context) (Client, error) {
+	select {
+	case <-p.done:
+		return nil, ErrClosed
+	default:
+	}
+
+	select {
+	case client := <-p.clients:
+		return client, nil
+	case <-p.done:
+		return nil, ErrClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Release gives back a client taken with AcquireContext. A client whose connection is broken is
+// closed, and a replacement dialled in the background. Clients released after the pool has closed
+// are closed.
+func (p *SyncPool) Release(c Client) {
+	p.mu.Lock()
+	switch {
+	case p.closed:
+		p.mu.Unlock()
+		_ = c.Close()
+		return
+	case !p.all[c]:
+		p.mu.Unlock()
+		p.log.Warn("ignoring the release of a client which does not belong to the pool")
+		return
+	case !isConnected(c):
+		delete(p.all, c)
+		p.mu.Unlock()
+		_ = c.Close()
+		p.log.Debug("replacing broken connection")
+		go p.replace()
+		return
+	}
+	p.mu.Unlock()
+
+	p.clients <- c
+}
+
+// replace dials a new client, retrying with backoff until it succeeds or the pool closes, and adds
+// it to the pool.
+func (p *SyncPool) replace() {
+	backoff := ExponentialBackoff(replacementInitialBackoff, replacementMaxBackoff)
+	for retry := 1; ; retry++ {
+		client := NewClient(p.dialer, p.options...)
+		err := client.Connect()
+		if err == nil {
+			p.mu.Lock()
+			if p.closed {
+				p.mu.Unlock()
+				_ = client.Close()
+				return
+			}
+			p.all[client] = true
+			p.mu.Unlock()
+
+			p.clients <- client
+			return
+		}
+
+		wait := backoff(retry)
+		p.log.WithError(err).WithField("wait", wait).Warn("failed to dial replacement connection")
+
+		timer := p.clock.NewTimer(wait)
+		select {
+		case <-p.done:
+			timer.Stop()
+			return
+		case <-timer.C():
+		}
+	}
+}
+
+// Close closes every client, including those which have been acquired and not yet released.
+func (p *SyncPool) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return ErrClosed
+	}
+	p.closed = true
+	close(p.done)
+	clients := p.all
+	p.all = make(map[Client]bool)
+	p.mu.Unlock()
+
+	for client := range clients {
+		_ = client.Close()
+	}
+	return nil
+}
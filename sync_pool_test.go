@@ -0,0 +1,113 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/41north/jsonrpc.go"
+	"github.com/41north/jsonrpc.go/testutil"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncPool(t *testing.T) {
+	server := jsonrpc.NewServer()
+	server.Register("echo", echo)
+
+	pool := jsonrpc.NewSyncPool(serverDialer(server, nil), 2)
+	defer pool.Close()
+
+	a, err := pool.AcquireContext(context.Background())
+	assert.Nil(t, err)
+	b, err := pool.AcquireContext(context.Background())
+	assert.Nil(t, err)
+	assert.NotEqual(t, a, b)
+
+	// acquiring blocks while every client is in use
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = pool.AcquireContext(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	acquired := make(chan jsonrpc.Client)
+	go func() {
+		client, err := pool.AcquireContext(context.Background())
+		assert.Nil(t, err)
+		acquired <- client
+	}()
+	pool.Release(a)
+	assert.Equal(t, a, <-acquired)
+
+	var resp jsonrpc.Response
+	assert.Nil(t, a.Send(*newRequest("echo", nil), &resp))
+
+	// clients released or acquired after closing are closed
+	assert.Nil(t, pool.Close())
+	pool.Release(b)
+	assert.ErrorIs(t, b.Send(*newRequest("echo", nil), &resp), jsonrpc.ErrClosed)
+	_, err = pool.AcquireContext(context.Background())
+	assert.ErrorIs(t, err, jsonrpc.ErrClosed)
+}
+
+func TestSyncPool_Replace(t *testing.T) {
+	server := jsonrpc.NewServer()
+	server.Register("echo", echo)
+
+	var mu sync.Mutex
+	var serverConns []net.Conn
+	dialer := jsonrpc.DialFunc(func() (jsonrpc.Connection, error) {
+		clientConn, serverConn := net.Pipe()
+		mu.Lock()
+		serverConns = append(serverConns, serverConn)
+		mu.Unlock()
+		go server.Serve(context.Background(), jsonrpc.NewStreamConnection(serverConn, jsonrpc.FramingNewline))
+		return jsonrpc.NewStreamConnection(clientConn, jsonrpc.FramingNewline), nil
+	})
+
+	pool := jsonrpc.NewSyncPool(dialer, 1)
+	defer pool.Close()
+
+	broken, err := pool.AcquireContext(context.Background())
+	assert.Nil(t, err)
+
+	// the server drops the connection while the client is in use
+	closed := make(chan struct{})
+	broken.SetCloseHandler(func(error) { close(closed) })
+	mu.Lock()
+	_ = serverConns[0].Close()
+	mu.Unlock()
+	<-closed
+
+	// and it is replaced once released
+	pool.Release(broken)
+	client, err := pool.AcquireContext(context.Background())
+	assert.Nil(t, err)
+	assert.NotEqual(t, broken, client)
+
+	var resp jsonrpc.Response
+	assert.Nil(t, client.Send(*newRequest("echo", nil), &resp))
+	mu.Lock()
+	assert.Len(t, serverConns, 2)
+	mu.Unlock()
+}
+
+func TestSyncPool_FailedDial(t *testing.T) {
+	server := jsonrpc.NewServer()
+	server.Register("echo", echo)
+
+	// the first two dials fail, the second being the first attempt at a replacement
+	clock := testutil.NewClock(time.Time{})
+	pool := jsonrpc.NewSyncPool(serverDialer(server, func(n int32) bool { return n <= 2 }), 1, jsonrpc.WithClock(clock))
+	defer pool.Close()
+
+	clock.BlockUntil(1)
+	clock.Advance(100 * time.Millisecond)
+
+	client, err := pool.AcquireContext(context.Background())
+	assert.Nil(t, err)
+	var resp jsonrpc.Response
+	assert.Nil(t, client.Send(*newRequest("echo", nil), &resp))
+}
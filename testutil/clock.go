@@ -0,0 +1,206 @@
+package testutil
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/41north/jsonrpc.go"
+)
+
+// Clock is a jsonrpc.Clock whose time only moves when advanced, making timeouts, intervals and
+// backoffs deterministic in tests:
+//
+//	clock := testutil.NewClock(time.Time{})
+//	client := jsonrpc.NewClient(dialer, jsonrpc.WithClock(clock), jsonrpc.WithKeepAlive(time.Second, 0, "ping"))
+//	clock.BlockUntil(1) // the keep alive ticker has been created
+//	clock.Advance(time.Second)
+//
+// Timers and tickers whose time is reached by Advance fire in order, as of their own time. As with
+// the time package, ticks are dropped if the channel is not drained, and functions passed to
+// AfterFunc are run without holding any lock, on the goroutine calling Advance.
+type Clock struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	waiters []*clockWaiter
+}
+
+// NewClock returns a clock set to now, or to an arbitrary fixed time if now is zero.
+func NewClock(now time.Time) *Clock {
+	if now.IsZero() {
+		now = time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
+	c := &Clock{now: now}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *Clock) After(d time.Duration) <-chan time.Time {
+	return c.NewTimer(d).C()
+}
+
+func (c *Clock) AfterFunc(d time.Duration, f func()) jsonrpc.Timer {
+	return c.add(&clockWaiter{clock: c, fn: f}, d)
+}
+
+func (c *Clock) NewTimer(d time.Duration) jsonrpc.Timer {
+	return c.add(&clockWaiter{clock: c, ch: make(chan time.Time, 1)}, d)
+}
+
+func (c *Clock) NewTicker(d time.Duration) jsonrpc.Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	return clockTicker{c.add(&clockWaiter{clock: c, ch: make(chan time.Time, 1), period: d}, d)}
+}
+
+// Advance moves the clock forward by d, firing the timers and tickers which fall due in order.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	target := c.now.Add(d)
+	for {
+		w := c.next(target)
+		if w == nil {
+			break
+		}
+		c.now = w.at
+		fn := c.fire(w)
+		if fn != nil {
+			c.mu.Unlock()
+			fn()
+			c.mu.Lock()
+		}
+	}
+	c.now = target
+	c.mu.Unlock()
+}
+
+// BlockUntil waits until at least n timers and tickers are active, so that a test can be sure a
+// goroutine is waiting on the clock before advancing it.
+func (c *Clock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.waiters) < n {
+		c.cond.Wait()
+	}
+}
+
+// Waiters returns the number of active timers and tickers.
+func (c *Clock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+func (c *Clock) add(w *clockWaiter, d time.Duration) *clockWaiter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.schedule(w, d)
+	return w
+}
+
+// schedule activates w to fire after d, firing it immediately if d is not positive.
+func (c *Clock) schedule(w *clockWaiter, d time.Duration) {
+	w.at = c.now.Add(d)
+	if d <= 0 && w.period == 0 {
+		if fn := c.fire(w); fn != nil {
+			// the caller may hold locks which fn needs, as with time.AfterFunc
+			go fn()
+		}
+		return
+	}
+	c.waiters = append(c.waiters, w)
+	c.cond.Broadcast()
+}
+
+// next returns the earliest waiter due by target, if any.
+func (c *Clock) next(target time.Time) *clockWaiter {
+	sort.SliceStable(c.waiters, func(i, j int) bool {
+		return c.waiters[i].at.Before(c.waiters[j].at)
+	})
+	if len(c.waiters) == 0 || c.waiters[0].at.After(target) {
+		return nil
+	}
+	return c.waiters[0]
+}
+
+// fire delivers the current time to w, rescheduling it if it is a ticker, and returns its function
+// if it has one.
+func (c *Clock) fire(w *clockWaiter) func() {
+	if w.period > 0 {
+		w.at = w.at.Add(w.period)
+	} else {
+		c.remove(w)
+	}
+	if w.ch != nil {
+		select {
+		case w.ch <- c.now:
+		default:
+		}
+	}
+	return w.fn
+}
+
+func (c *Clock) remove(w *clockWaiter) bool {
+	for i, other := range c.waiters {
+		if other == w {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// clockWaiter is a timer or, if it has a period, a ticker.
+type clockWaiter struct {
+	clock  *Clock
+	at     time.Time
+	period time.Duration
+	ch     chan time.Time
+	fn     func()
+}
+
+func (w *clockWaiter) C() <-chan time.Time {
+	return w.ch
+}
+
+func (w *clockWaiter) Stop() bool {
+	w.clock.mu.Lock()
+	defer w.clock.mu.Unlock()
+	return w.clock.remove(w)
+}
+
+func (w *clockWaiter) Reset(d time.Duration) bool {
+	w.clock.mu.Lock()
+	defer w.clock.mu.Unlock()
+	active := w.clock.remove(w)
+	w.clock.schedule(w, d)
+	return active
+}
+
+// clockTicker adapts a periodic clockWaiter to jsonrpc.Ticker.
+type clockTicker struct {
+	*clockWaiter
+}
+
+func (t clockTicker) Stop() {
+	t.clockWaiter.Stop()
+}
+
+func (t clockTicker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("non-positive interval for Ticker.Reset")
+	}
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	t.clock.remove(t.clockWaiter)
+	t.period = d
+	t.clock.schedule(t.clockWaiter, d)
+}
@@ -0,0 +1,96 @@
+package testutil_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/41north/jsonrpc.go/testutil"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClock_Timer(t *testing.T) {
+	clock := testutil.NewClock(time.Time{})
+	start := clock.Now()
+
+	timer := clock.NewTimer(time.Second)
+	clock.Advance(999 * time.Millisecond)
+	select {
+	case <-timer.C():
+		t.Fatal("timer fired early")
+	default:
+	}
+
+	clock.Advance(time.Millisecond)
+	assert.Equal(t, start.Add(time.Second), <-timer.C())
+	assert.False(t, timer.Stop())
+
+	// a timer can be reset once fired
+	assert.False(t, timer.Reset(time.Second))
+	assert.True(t, timer.Stop())
+	clock.Advance(time.Hour)
+	assert.Equal(t, 0, clock.Waiters())
+	assert.Equal(t, start.Add(time.Hour+time.Second), clock.Now())
+}
+
+func TestClock_AfterFunc(t *testing.T) {
+	clock := testutil.NewClock(time.Time{})
+
+	var fired []time.Duration
+	start := clock.Now()
+	for _, d := range []time.Duration{3 * time.Second, time.Second, 2 * time.Second} {
+		clock.AfterFunc(d, func() {
+			fired = append(fired, clock.Now().Sub(start))
+			// functions may use the clock
+			if len(fired) == 1 {
+				clock.AfterFunc(500*time.Millisecond, func() {
+					fired = append(fired, clock.Now().Sub(start))
+				})
+			}
+		})
+	}
+
+	clock.Advance(10 * time.Second)
+	assert.Equal(t, []time.Duration{
+		time.Second, 1500 * time.Millisecond, 2 * time.Second, 3 * time.Second,
+	}, fired)
+}
+
+func TestClock_Ticker(t *testing.T) {
+	clock := testutil.NewClock(time.Time{})
+
+	ticker := clock.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	ticks := make(chan time.Time, 10)
+	go func() {
+		for tick := range ticker.C() {
+			ticks <- tick
+		}
+	}()
+
+	start := clock.Now()
+	for i := 1; i <= 3; i++ {
+		clock.Advance(time.Second)
+		assert.Equal(t, start.Add(time.Duration(i)*time.Second), <-ticks)
+	}
+
+	// resetting changes the interval
+	ticker.Reset(time.Minute)
+	clock.Advance(time.Minute)
+	assert.Equal(t, start.Add(3*time.Second+time.Minute), <-ticks)
+}
+
+func TestClock_BlockUntil(t *testing.T) {
+	clock := testutil.NewClock(time.Time{})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		<-clock.After(time.Minute)
+	}()
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+	<-done
+}
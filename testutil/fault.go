@@ -0,0 +1,444 @@
+package testutil
+
+import (
+	"encoding/json"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/41north/jsonrpc.go"
+
+	"github.com/juju/errors"
+)
+
+// ErrInjected is returned by Write when a write error is injected without a specific error.
+const ErrInjected = errors.ConstError("injected fault")
+
+// Direction is the direction of a message relative to the wrapped connection.
+type Direction int
+
+const (
+	// Inbound messages are those returned by Read, e.g. responses received by a client.
+	Inbound Direction = iota
+	// Outbound messages are those passed to Write, e.g. requests sent by a client.
+	Outbound
+)
+
+// Action is a fault applied to a message.
+type Action int
+
+const (
+	// Delay holds the message for Step.Delay before it is read or written.
+	Delay Action = iota
+	// Drop discards the message. Dropped writes still report success.
+	Drop
+	// Duplicate delivers the message twice.
+	Duplicate
+	// Corrupt overwrites a byte of the message.
+	Corrupt
+	// Fail returns Step.Err, or ErrInjected, from Write without writing the message. It has no
+	// effect on inbound messages.
+	Fail
+	// Close closes the connection in place of reading or writing the message.
+	Close
+)
+
+// Step is a scripted fault, applied to the first message in its direction which it matches. A step
+// matches the Nth message in its direction, counting from 1, if Message is set, and messages for
+// Method if it is set. Responses are matched to the method of the request with the same id. A step
+// with neither set matches the next message.
+type Step struct {
+	Direction Direction
+	Message   int
+	Method    string
+	Action    Action
+	Delay     time.Duration
+	Err       error
+}
+
+// DelayFunc returns how long to hold a message.
+type DelayFunc = func(r *rand.Rand) time.Duration
+
+// FixedDelay holds every message for d.
+func FixedDelay(d time.Duration) DelayFunc {
+	return func(r *rand.Rand) time.Duration {
+		return d
+	}
+}
+
+// UniformDelay holds each message for a duration chosen uniformly from [min, max).
+func UniformDelay(min time.Duration, max time.Duration) DelayFunc {
+	return func(r *rand.Rand) time.Duration {
+		if max <= min {
+			return min
+		}
+		return min + time.Duration(r.Int63n(int64(max-min)))
+	}
+}
+
+// WithSeed seeds the source of randomness used for probabilistic faults, making them repeatable.
+func WithSeed(seed int64) FaultOption {
+	return func(opts *FaultOptions) {
+		opts.Seed = seed
+	}
+}
+
+// WithReadDelay delays every read by a duration from fn.
+func WithReadDelay(fn DelayFunc) FaultOption {
+	return func(opts *FaultOptions) {
+		opts.ReadDelay = fn
+	}
+}
+
+// WithWriteDelay delays every write by a duration from fn.
+func WithWriteDelay(fn DelayFunc) FaultOption {
+	return func(opts *FaultOptions) {
+		opts.WriteDelay = fn
+	}
+}
+
+// WithDropEvery drops every nth inbound message.
+func WithDropEvery(n int) FaultOption {
+	return func(opts *FaultOptions) {
+		opts.DropEvery = n
+	}
+}
+
+// WithDuplicateRate delivers inbound messages twice with probability p.
+func WithDuplicateRate(p float64) FaultOption {
+	return func(opts *FaultOptions) {
+		opts.DuplicateRate = p
+	}
+}
+
+// WithCorruptRate corrupts inbound messages with probability p.
+func WithCorruptRate(p float64) FaultOption {
+	return func(opts *FaultOptions) {
+		opts.CorruptRate = p
+	}
+}
+
+// WithWriteErrorRate fails writes with err, or ErrInjected if err is nil, with probability p.
+func WithWriteErrorRate(p float64, err error) FaultOption {
+	return func(opts *FaultOptions) {
+		opts.WriteErrorRate = p
+		opts.WriteError = err
+	}
+}
+
+// WithCloseAfter closes the connection once n messages, in either direction, have been read or
+// written.
+func WithCloseAfter(n int) FaultOption {
+	return func(opts *FaultOptions) {
+		opts.CloseAfter = n
+	}
+}
+
+// WithScenario scripts faults, each step being applied once, in order. A message is only checked
+// against the next step, so later steps wait until the earlier ones have been applied.
+func WithScenario(steps ...Step) FaultOption {
+	return func(opts *FaultOptions) {
+		opts.Scenario = append(opts.Scenario, steps...)
+	}
+}
+
+// WithFaultClock sets the clock with which delays are waited out, such as a Clock from this
+// package. The default is jsonrpc.RealClock.
+func WithFaultClock(clock jsonrpc.Clock) FaultOption {
+	return func(opts *FaultOptions) {
+		opts.Clock = clock
+	}
+}
+
+type FaultOption = func(opts *FaultOptions)
+
+type FaultOptions struct {
+	Seed           int64
+	ReadDelay      DelayFunc
+	WriteDelay     DelayFunc
+	DropEvery      int
+	DuplicateRate  float64
+	CorruptRate    float64
+	WriteErrorRate float64
+	WriteError     error
+	CloseAfter     int
+	Scenario       []Step
+	Clock          jsonrpc.Clock
+}
+
+func DefaultFaultOptions() FaultOptions {
+	return FaultOptions{
+		Seed:  1,
+		Clock: jsonrpc.RealClock(),
+	}
+}
+
+// plan is the faults to apply to a single message.
+type plan struct {
+	delay     time.Duration
+	drop      bool
+	duplicate bool
+	corrupt   int
+	err       error
+	close     bool
+}
+
+// FaultInjectingConnection wraps a connection, injecting faults into the messages which pass
+// through it, see NewFaultInjectingConnection.
+type FaultInjectingConnection struct {
+	conn jsonrpc.Connection
+	opts FaultOptions
+
+	mu       sync.Mutex
+	rand     *rand.Rand
+	reads    int
+	writes   int
+	steps    []Step
+	methods  map[string]string
+	repeated []byte
+
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// NewFaultInjectingConnection wraps conn, injecting faults according to options. Probabilistic
+// faults are repeatable for a given seed, see WithSeed, as long as reads and writes are not
+// interleaved differently between runs. Scripted faults, see WithScenario, are deterministic.
+func NewFaultInjectingConnection(conn jsonrpc.Connection, options ...FaultOption) *FaultInjectingConnection {
+	opts := DefaultFaultOptions()
+	for _, opt := range options {
+		opt(&opts)
+	}
+	return &FaultInjectingConnection{
+		conn:    conn,
+		opts:    opts,
+		rand:    rand.New(rand.NewSource(opts.Seed)),
+		steps:   opts.Scenario,
+		methods: make(map[string]string),
+		closed:  make(chan struct{}),
+	}
+}
+
+func (f *FaultInjectingConnection) Write(data []byte) error {
+	p := f.plan(Outbound, data)
+	if err := f.apply(p); err != nil {
+		return err
+	}
+	if p.drop {
+		return nil
+	}
+	data = corrupt(data, p.corrupt)
+	if err := f.conn.Write(data); err != nil || !p.duplicate {
+		return err
+	}
+	return f.conn.Write(data)
+}
+
+func (f *FaultInjectingConnection) Read() ([]byte, error) {
+	for {
+		f.mu.Lock()
+		if data := f.repeated; data != nil {
+			f.repeated = nil
+			f.mu.Unlock()
+			return data, nil
+		}
+		f.mu.Unlock()
+
+		data, err := f.conn.Read()
+		if err != nil {
+			return data, err
+		}
+
+		p := f.plan(Inbound, data)
+		if err := f.apply(p); err != nil {
+			return nil, err
+		}
+		if p.drop {
+			continue
+		}
+
+		data = corrupt(data, p.corrupt)
+		if p.duplicate {
+			f.mu.Lock()
+			f.repeated = data
+			f.mu.Unlock()
+		}
+		return data, nil
+	}
+}
+
+func (f *FaultInjectingConnection) Close() error {
+	f.closeOnce.Do(func() {
+		close(f.closed)
+	})
+	return f.conn.Close()
+}
+
+// apply waits out any delay in p, returning an error if the message should not be delivered.
+func (f *FaultInjectingConnection) apply(p plan) error {
+	if p.delay > 0 {
+		timer := f.opts.Clock.NewTimer(p.delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C():
+		case <-f.closed:
+			return jsonrpc.ErrClosed
+		}
+	}
+	if p.close {
+		_ = f.Close()
+		return jsonrpc.ErrClosed
+	}
+	return p.err
+}
+
+// plan decides the faults to apply to a message.
+func (f *FaultInjectingConnection) plan(direction Direction, data []byte) plan {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var p plan
+	var count int
+	if direction == Inbound {
+		f.reads++
+		count = f.reads
+	} else {
+		f.writes++
+		count = f.writes
+	}
+
+	methods := f.messageMethods(direction, data)
+	if len(f.steps) > 0 && matches(f.steps[0], direction, count, methods) {
+		step := f.steps[0]
+		f.steps = f.steps[1:]
+		switch step.Action {
+		case Delay:
+			p.delay = step.Delay
+		case Drop:
+			p.drop = true
+		case Duplicate:
+			p.duplicate = true
+		case Corrupt:
+			p.corrupt = 1
+		case Fail:
+			if direction == Outbound {
+				p.err = step.Err
+				if p.err == nil {
+					p.err = ErrInjected
+				}
+			}
+		case Close:
+			p.close = true
+		}
+	}
+
+	opts := f.opts
+	if direction == Inbound {
+		if opts.ReadDelay != nil {
+			p.delay += opts.ReadDelay(f.rand)
+		}
+		if opts.DropEvery > 0 && count%opts.DropEvery == 0 {
+			p.drop = true
+		}
+		if opts.DuplicateRate > 0 && f.rand.Float64() < opts.DuplicateRate {
+			p.duplicate = true
+		}
+		if opts.CorruptRate > 0 && f.rand.Float64() < opts.CorruptRate {
+			p.corrupt = 1
+		}
+	} else {
+		if opts.WriteDelay != nil {
+			p.delay += opts.WriteDelay(f.rand)
+		}
+		if p.err == nil && opts.WriteErrorRate > 0 && f.rand.Float64() < opts.WriteErrorRate {
+			p.err = opts.WriteError
+			if p.err == nil {
+				p.err = ErrInjected
+			}
+		}
+	}
+
+	if opts.CloseAfter > 0 && f.reads+f.writes > opts.CloseAfter {
+		p.close = true
+	}
+
+	// the position of a corrupted byte is chosen here, whilst holding the lock on the source
+	if p.corrupt > 0 && len(data) > 0 {
+		p.corrupt = 1 + f.rand.Intn(len(data))
+	}
+
+	return p
+}
+
+// messageMethods returns the methods of the requests in a message, or of the requests which the
+// responses in a message answer.
+func (f *FaultInjectingConnection) messageMethods(direction Direction, data []byte) []string {
+	type envelope struct {
+		Id     json.RawMessage `json:"id"`
+		Method string          `json:"method"`
+	}
+
+	var envelopes []envelope
+	if err := json.Unmarshal(data, &envelopes); err != nil {
+		var e envelope
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil
+		}
+		envelopes = []envelope{e}
+	}
+
+	// requests are only correlated with responses travelling in the other direction
+	received := direction == Inbound
+	var methods []string
+	for _, e := range envelopes {
+		id := string(e.Id)
+		switch {
+		case e.Method != "":
+			if id != "" && id != "null" {
+				f.methods[directionKey(!received, id)] = e.Method
+			}
+			methods = append(methods, e.Method)
+		case id != "":
+			key := directionKey(received, id)
+			if method, ok := f.methods[key]; ok {
+				methods = append(methods, method)
+				delete(f.methods, key)
+			}
+		}
+	}
+	return methods
+}
+
+// directionKey keys a request id by whether the response to it is inbound.
+func directionKey(inbound bool, id string) string {
+	if inbound {
+		return "<" + id
+	}
+	return ">" + id
+}
+
+func matches(step Step, direction Direction, count int, methods []string) bool {
+	if step.Direction != direction || (step.Message > 0 && step.Message != count) {
+		return false
+	}
+	if step.Method == "" {
+		return true
+	}
+	for _, method := range methods {
+		if method == step.Method {
+			return true
+		}
+	}
+	return false
+}
+
+// corrupt returns a copy of data with the byte at position n, counting from 1, inverted. Data is
+// returned as is if n is zero.
+func corrupt(data []byte, n int) []byte {
+	if n == 0 || n > len(data) {
+		return data
+	}
+	corrupted := append([]byte(nil), data...)
+	corrupted[n-1] ^= 0xff
+	return corrupted
+}
@@ -0,0 +1,145 @@
+package testutil_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/41north/jsonrpc.go"
+	"github.com/41north/jsonrpc.go/testutil"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func echoServer() *jsonrpc.Server {
+	server := jsonrpc.NewServer()
+	for _, method := range []string{"a", "b"} {
+		method := method
+		server.Register(method, func(ctx context.Context, req jsonrpc.Request) (any, error) {
+			return method, nil
+		})
+	}
+	return server
+}
+
+func send(client jsonrpc.Client, method string) error {
+	req, err := jsonrpc.NewRequest(method, nil)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	var resp jsonrpc.Response
+	return client.SendContext(ctx, *req, &resp)
+}
+
+func newClient(t *testing.T, options ...testutil.FaultOption) (jsonrpc.Client, *atomic.Int32) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	conn := testutil.NewFaultInjectingConnection(testutil.ServePipe(ctx, echoServer()), options...)
+	client := jsonrpc.NewClientWithConnection(conn)
+
+	var unmatched atomic.Int32
+	client.SetUnmatchedHandler(func(resp jsonrpc.Response) {
+		unmatched.Add(1)
+	})
+
+	assert.Nil(t, client.Connect())
+	t.Cleanup(func() { _ = client.Close() })
+	return client, &unmatched
+}
+
+func TestFaultInjectingConnection_Scenario(t *testing.T) {
+	writeErr := errors.New("boom")
+
+	client, unmatched := newClient(t, testutil.WithScenario(
+		testutil.Step{Direction: testutil.Inbound, Method: "b", Action: testutil.Drop},
+		testutil.Step{Direction: testutil.Outbound, Message: 4, Action: testutil.Fail, Err: writeErr},
+		testutil.Step{Direction: testutil.Inbound, Action: testutil.Duplicate},
+		testutil.Step{Direction: testutil.Inbound, Action: testutil.Delay, Delay: time.Second},
+	))
+
+	// the response to b is dropped, a is unaffected
+	assert.Nil(t, send(client, "a"))
+	assert.ErrorIs(t, send(client, "b"), context.DeadlineExceeded)
+	assert.Nil(t, send(client, "b"))
+
+	// the 4th write fails
+	assert.ErrorIs(t, send(client, "a"), writeErr)
+
+	// the next response is delivered twice, the second copy going unmatched
+	assert.Nil(t, send(client, "a"))
+	assert.Eventually(t, func() bool {
+		return unmatched.Load() == 1
+	}, time.Second, time.Millisecond)
+
+	// the next response is held past the deadline
+	assert.ErrorIs(t, send(client, "a"), context.DeadlineExceeded)
+}
+
+func TestFaultInjectingConnection_CloseAfter(t *testing.T) {
+	client, _ := newClient(t, testutil.WithCloseAfter(4))
+
+	assert.Nil(t, send(client, "a"))
+	assert.Nil(t, send(client, "a"))
+	assert.ErrorIs(t, send(client, "a"), jsonrpc.ErrClosed)
+}
+
+func TestFaultInjectingConnection_Probabilistic(t *testing.T) {
+	// messages are written to one end of a pipe and read through the faults at the other
+	read := func(options ...testutil.FaultOption) []string {
+		a, b := testutil.Pipe()
+		defer a.Close()
+
+		conn := testutil.NewFaultInjectingConnection(b, options...)
+		defer conn.Close()
+
+		go func() {
+			for _, msg := range []string{`"1"`, `"2"`, `"3"`, `"4"`, `"5"`, `"6"`} {
+				_ = a.Write([]byte(msg))
+			}
+			_ = a.Close()
+		}()
+
+		var received []string
+		for {
+			data, err := conn.Read()
+			if err != nil {
+				return received
+			}
+			received = append(received, string(data))
+		}
+	}
+
+	assert.Equal(t, []string{`"1"`, `"2"`, `"4"`, `"5"`}, read(testutil.WithDropEvery(3)))
+	assert.Len(t, read(testutil.WithDuplicateRate(1)), 12)
+
+	corrupted := read(testutil.WithCorruptRate(1))
+	assert.Len(t, corrupted, 6)
+	for i, msg := range corrupted {
+		assert.Len(t, msg, 3)
+		assert.NotEqual(t, read()[i], msg)
+	}
+
+	// the same seed corrupts the same bytes
+	assert.Equal(t, corrupted, read(testutil.WithCorruptRate(1)))
+
+	// every read is held for its delay
+	clock := testutil.NewClock(time.Time{})
+	delayed := make(chan []string)
+	go func() {
+		delayed <- read(
+			testutil.WithReadDelay(testutil.UniformDelay(5*time.Millisecond, 10*time.Millisecond)),
+			testutil.WithFaultClock(clock),
+		)
+	}()
+	for i := 0; i < 6; i++ {
+		clock.BlockUntil(1)
+		clock.Advance(10 * time.Millisecond)
+	}
+	assert.Len(t, <-delayed, 6)
+}
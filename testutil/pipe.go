@@ -0,0 +1,27 @@
+// Package testutil provides in memory transports, and transports which misbehave on demand, for
+// testing code built on jsonrpc.
+package testutil
+
+import (
+	"context"
+	"net"
+
+	"github.com/41north/jsonrpc.go"
+)
+
+// Pipe returns both ends of a synchronous, in memory connection, see net.Pipe.
+func Pipe() (jsonrpc.Connection, jsonrpc.Connection) {
+	a, b := net.Pipe()
+	return jsonrpc.NewStreamConnection(a, jsonrpc.FramingNewline), jsonrpc.NewStreamConnection(b, jsonrpc.FramingNewline)
+}
+
+// ServePipe serves server over an in memory connection until ctx is done or the returned end of it
+// is closed. The returned connection can be passed to jsonrpc.NewClientWithConnection, optionally
+// wrapped with NewFaultInjectingConnection.
+func ServePipe(ctx context.Context, server *jsonrpc.Server) jsonrpc.Connection {
+	client, conn := Pipe()
+	go func() {
+		_ = server.Serve(ctx, conn)
+	}()
+	return client
+}
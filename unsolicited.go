@@ -0,0 +1,53 @@
+package jsonrpc
+
+import (
+	"bytes"
+)
+
+// UnsolicitedErrorHandler handles error responses with a null or absent id, which cannot be matched
+// with a request. The spec sends these for requests which could not be parsed, and some servers
+// also send them in reply to notifications. Like request handlers, it is run off the read loop.
+type UnsolicitedErrorHandler = func(err *Error)
+
+// UnsolicitedErrorObserver is an Observer which is also notified of error responses with a null
+// or absent id, see UnsolicitedErrorHandler.
+type UnsolicitedErrorObserver interface {
+	Observer
+	OnUnsolicitedError(err Error)
+}
+
+// isUnsolicitedError returns true if resp is an error response with a null or absent id. Ids
+// assigned by positional correlation count, as they are assigned before this is checked.
+func isUnsolicitedError(resp *Response) bool {
+	return resp.Error != nil && (resp.Id == nil || bytes.Equal(bytes.TrimSpace(resp.Id), []byte("null")))
+}
+
+func (c *client) OnUnsolicitedError(handler UnsolicitedErrorHandler) {
+	c.unsolicited = handler
+}
+
+// onUnsolicitedError passes the error of resp to the unsolicited error handler, if one has been
+// set, without looking for a request awaiting it.
+func (c *client) onUnsolicitedError(resp *Response) {
+	if o, ok := c.opts.Observer.(UnsolicitedErrorObserver); ok {
+		o.OnUnsolicitedError(*resp.Error)
+	}
+
+	c.logger().
+		WithField("code", resp.Error.Code).
+		WithField("message", resp.Error.Message).
+		Debug("error received with a null id")
+
+	if c.unsolicited == nil {
+		return
+	}
+	e := *resp.Error
+	c.dispatcher.dispatch(func() {
+		defer func() {
+			if r := recover(); r != nil {
+				c.closeOnPanic(c.onPanic(r))
+			}
+		}()
+		c.unsolicited(&e)
+	})
+}
@@ -0,0 +1,67 @@
+package jsonrpc_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/41north/jsonrpc.go"
+
+	log "github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_UnsolicitedError(t *testing.T) {
+	hook := logtest.NewGlobal()
+	defer hook.Reset()
+	log.SetLevel(log.DebugLevel)
+	defer log.SetLevel(log.InfoLevel)
+
+	for _, withHandler := range []bool{false, true} {
+		clientConn, serverConn := net.Pipe()
+		server := jsonrpc.NewStreamConnection(serverConn, jsonrpc.FramingNewline)
+		client := jsonrpc.NewClientWithConnection(jsonrpc.NewStreamConnection(clientConn, jsonrpc.FramingNewline))
+
+		unsolicited := make(chan *jsonrpc.Error, 2)
+		if withHandler {
+			client.OnUnsolicitedError(func(err *jsonrpc.Error) {
+				unsolicited <- err
+			})
+		}
+		unmatched := make(chan jsonrpc.Response, 2)
+		client.SetUnmatchedHandler(func(resp jsonrpc.Response) {
+			unmatched <- resp
+		})
+		handled := make(chan struct{}, 1)
+		client.SetRequestHandler(func(req jsonrpc.Request) {
+			handled <- struct{}{}
+		})
+		assert.Nil(t, client.Connect())
+
+		// a reply to a notification, with either a null or an absent id
+		assert.Nil(t, server.Write([]byte(`{"id":null,"error":{"code":-32601,"message":"method not found"},"jsonrpc":"2.0"}`)))
+		assert.Nil(t, server.Write([]byte(`[{"error":{"code":-32700,"message":"parse error"},"jsonrpc":"2.0"}]`)))
+		// messages are handled in order, so the errors have been processed once this is handled
+		assert.Nil(t, server.Write([]byte(`{"method":"done","jsonrpc":"2.0"}`)))
+		<-handled
+
+		// neither is treated as a response with an unrecognised id, and each is logged at debug level
+		assert.Empty(t, unmatched)
+		var logged int
+		for _, entry := range hook.AllEntries() {
+			if entry.Message == "error received with a null id" {
+				assert.Equal(t, log.DebugLevel, entry.Level)
+				logged++
+			}
+		}
+		assert.Equal(t, 2, logged)
+
+		if withHandler {
+			assert.Equal(t, int32(-32601), (<-unsolicited).Code)
+			assert.Equal(t, int32(-32700), (<-unsolicited).Code)
+		}
+
+		assert.Nil(t, client.Close())
+		hook.Reset()
+	}
+}
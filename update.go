@@ -0,0 +1,118 @@
+package jsonrpc
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/juju/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+var ErrNotUpdatable = errors.ConstError("option cannot be updated")
+
+// updatableOptions are the fields of ClientOptions which can be changed with Update.
+var updatableOptions = map[string]bool{
+	"Logger":            true,
+	"Redactor":          true,
+	"MaxInFlight":       true,
+	"KeepAliveInterval": true,
+	"KeepAliveTimeout":  true,
+	"KeepAliveMethod":   true,
+}
+
+// liveOptions holds the options which can be changed with Update. It is replaced as a whole, so
+// that a change to several options is seen at once.
+type liveOptions struct {
+	logger            *log.Entry
+	redactor          Redactor
+	keepAliveInterval time.Duration
+	keepAliveTimeout  time.Duration
+	keepAliveMethod   string
+}
+
+func newLiveOptions(opts ClientOptions) *liveOptions {
+	return &liveOptions{
+		logger:            opts.Logger,
+		redactor:          opts.Redactor,
+		keepAliveInterval: opts.KeepAliveInterval,
+		keepAliveTimeout:  opts.KeepAliveTimeout,
+		keepAliveMethod:   opts.KeepAliveMethod,
+	}
+}
+
+func (c *client) liveOptions() *liveOptions {
+	return c.live.Load()
+}
+
+func (c *client) logger() *log.Entry {
+	return c.live.Load().logger
+}
+
+// Update changes options of the client without reconnecting. Only the following can be updated:
+//
+//   - WithLogger and WithRedactor
+//   - WithMaxInFlight, if the client was created with an in flight limit
+//   - WithKeepAlive, if the client was created with keep alive
+//
+// Any other option, or one which would add or remove an in flight limit or keep alive, fails with
+// ErrNotUpdatable and nothing is changed. Options are recognised by the fields they set, so options
+// which only set fields to their zero value cannot be told apart and are ignored.
+//
+// The options are applied together, so a send sees either all of them or none. A raised in flight
+// limit admits waiting requests straight away, while a lowered one takes effect as requests in
+// flight complete; either way, requests keep their place in the queue. A changed keep alive interval
+// restarts the interval from the time of the update.
+func (c *client) Update(options ...ClientOption) error {
+	c.updateMu.Lock()
+	defer c.updateMu.Unlock()
+
+	for _, opt := range options {
+		var probe ClientOptions
+		opt(&probe)
+		value := reflect.ValueOf(probe)
+		for i := 0; i < value.NumField(); i++ {
+			name := value.Type().Field(i).Name
+			if !updatableOptions[name] && !value.Field(i).IsZero() {
+				return errors.Annotatef(ErrNotUpdatable, "%s", name)
+			}
+		}
+	}
+
+	live := c.liveOptions()
+	opts := ClientOptions{
+		Logger:            live.logger,
+		Redactor:          live.redactor,
+		KeepAliveInterval: live.keepAliveInterval,
+		KeepAliveTimeout:  live.keepAliveTimeout,
+		KeepAliveMethod:   live.keepAliveMethod,
+	}
+	if c.admission != nil {
+		opts.MaxInFlight = c.admission.currentLimit()
+	}
+	for _, opt := range options {
+		opt(&opts)
+	}
+
+	if (opts.MaxInFlight > 0) != (c.admission != nil) {
+		return errors.Annotate(ErrNotUpdatable, "in flight limit can be changed, but not added or removed")
+	}
+	if (opts.KeepAliveInterval > 0) != (c.opts.KeepAliveInterval > 0) {
+		return errors.Annotate(ErrNotUpdatable, "keep alive interval can be changed, but not added or removed")
+	}
+	if opts.Logger == nil || opts.Redactor == nil {
+		return errors.Annotate(ErrNotUpdatable, "logger and redactor cannot be removed")
+	}
+
+	c.live.Store(newLiveOptions(opts))
+	if c.admission != nil {
+		c.admission.setLimit(opts.MaxInFlight)
+	}
+	if opts.KeepAliveInterval != live.keepAliveInterval {
+		select {
+		case c.keepAliveReset <- struct{}{}:
+		default:
+			// a reset is already pending, which will pick up the new interval
+		}
+	}
+	return nil
+}
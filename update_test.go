@@ -0,0 +1,111 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/41north/jsonrpc.go"
+	"github.com/41north/jsonrpc.go/testutil"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_Update(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	client := jsonrpc.NewClient(serverDialer(endpoint("a", release), nil), jsonrpc.WithMaxInFlight(1))
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	// options which cannot be changed at runtime are rejected, as is adding keep alive
+	assert.ErrorIs(t, client.Update(jsonrpc.WithRequestVersion("1.0")), jsonrpc.ErrNotUpdatable)
+	assert.ErrorIs(t, client.Update(jsonrpc.WithKeepAlive(time.Minute, time.Second, "ping")), jsonrpc.ErrNotUpdatable)
+	assert.ErrorIs(t, client.Update(jsonrpc.WithMaxInFlight(0)), jsonrpc.ErrNotUpdatable)
+
+	// nothing is changed by a rejected update
+	assert.ErrorIs(t, client.Update(jsonrpc.WithMaxInFlight(2), jsonrpc.WithAutoBatch(10, time.Millisecond)), jsonrpc.ErrNotUpdatable)
+
+	// occupy the only slot, so that the next request waits
+	blocked := client.SendAsync(*newRequest("wait", nil))
+	waiting := client.SendAsync(*newRequest("name", nil))
+	assert.Equal(t, map[string]int{"": 1}, client.InFlightByTenant(), "request was sent over the in flight limit")
+
+	// raising the limit admits the waiting request
+	assert.Nil(t, client.Update(jsonrpc.WithMaxInFlight(2)))
+	resp, err := (<-waiting.Get()).Unwrap()
+	assert.Nil(t, err)
+	assert.Equal(t, json.RawMessage(`"a"`), resp.Result)
+
+	select {
+	case <-blocked.Get():
+		t.Fatal("request completed before it was released")
+	default:
+	}
+}
+
+func TestClient_UpdateKeepAlive(t *testing.T) {
+	var pings atomic.Int32
+	server := jsonrpc.NewServer()
+	server.Register("ping", func(ctx context.Context, req jsonrpc.Request) (any, error) {
+		pings.Add(1)
+		return "pong", nil
+	})
+
+	clock := testutil.NewClock(time.Time{})
+	client := jsonrpc.NewClient(
+		serverDialer(server, nil),
+		jsonrpc.WithClock(clock),
+		jsonrpc.WithKeepAlive(time.Minute, time.Second, "ping"),
+	)
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	clock.BlockUntil(1)
+	assert.Nil(t, client.Update(jsonrpc.WithKeepAlive(time.Second, time.Second, "ping")))
+
+	// the interval restarts from the update
+	assert.Eventually(t, func() bool {
+		clock.Advance(time.Second)
+		return pings.Load() > 0
+	}, time.Second, 50*time.Millisecond)
+}
+
+func TestClient_UpdateUnderLoad(t *testing.T) {
+	server := jsonrpc.NewServer()
+	server.Register("echo", echo)
+
+	client := jsonrpc.NewClient(serverDialer(server, nil), jsonrpc.WithMaxInFlight(4))
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				var resp jsonrpc.Response
+				assert.Nil(t, client.Send(*newRequest("echo", []int{1}), &resp))
+			}
+		}()
+	}
+
+	logger := log.WithField("component", "updated")
+	for i := 0; i < 100; i++ {
+		assert.Nil(t, client.Update(
+			jsonrpc.WithMaxInFlight(1+i%8),
+			jsonrpc.WithLogger(logger),
+			jsonrpc.WithRedactor(jsonrpc.RedactAll),
+		))
+	}
+
+	cancel()
+	wg.Wait()
+}
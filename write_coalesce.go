@@ -0,0 +1,130 @@
+package jsonrpc
+
+import (
+	"sync"
+	"time"
+)
+
+// WithWriteCoalesce buffers outgoing messages on stream connections and writes them to the stream
+// together, in a single write, once maxBytes have accumulated or maxDelay has elapsed since the
+// first of them, whichever comes first. Unlike WithAutoBatch, each message remains a separate frame
+// and is correlated as usual, only the number of writes to the transport is reduced. Requests whose
+// write fails fail with its error. Notifications are written after Notify returns, so their failures
+// can only be logged. If maxDelay is zero messages are only written once maxBytes have
+// accumulated.
+//
+// Connections which do not frame messages on a stream, such as websockets, write each message as it
+// is sent.
+func WithWriteCoalesce(maxBytes int, maxDelay time.Duration) ClientOption {
+	return func(opts *ClientOptions) {
+		opts.WriteCoalesceMaxBytes = maxBytes
+		opts.WriteCoalesceMaxDelay = maxDelay
+	}
+}
+
+// frameWriter is implemented by connections which can write several messages at once.
+type frameWriter interface {
+	writeFrames(frames [][]byte) error
+}
+
+// coalescedWrite is a set of messages to be written to a connection together, with the functions
+// to call once they have been.
+type coalescedWrite struct {
+	conn      frameWriter
+	frames    [][]byte
+	onWritten []func(err error)
+	size      int
+	timer     Timer
+}
+
+// writeCoalescer accumulates messages until they are due to be written.
+type writeCoalescer struct {
+	mu       sync.Mutex
+	maxBytes int
+	maxDelay time.Duration
+	clock    Clock
+	// positional is set if responses are correlated by position, see WithPositionalCorrelation
+	positional *positionalQueue
+	pending    *coalescedWrite
+	// writeMu is taken before mu is released, so that messages are written in the order queued
+	writeMu sync.Mutex
+}
+
+func newWriteCoalescer(maxBytes int, maxDelay time.Duration, clock Clock, positional *positionalQueue) *writeCoalescer {
+	return &writeCoalescer{maxBytes: maxBytes, maxDelay: maxDelay, clock: clock, positional: positional}
+}
+
+// add queues data to be written to conn, calling onWritten once it has been. It only blocks to write
+// if the queued messages are now due.
+func (w *writeCoalescer) add(conn frameWriter, data []byte, onWritten func(err error)) {
+	w.mu.Lock()
+	var due []*coalescedWrite
+	if w.pending != nil && w.pending.conn != conn {
+		// the connection has changed, so what is waiting is written to the one it was sent on
+		due = append(due, w.take())
+	}
+	if w.pending == nil {
+		w.pending = &coalescedWrite{conn: conn}
+		if w.maxDelay > 0 {
+			pending := w.pending
+			w.pending.timer = w.clock.AfterFunc(w.maxDelay, func() {
+				w.flush(pending)
+			})
+		}
+	}
+	pending := w.pending
+	if w.positional != nil {
+		// messages are written in the order queued
+		w.positional.push(data)
+	}
+	pending.frames = append(pending.frames, data)
+	pending.onWritten = append(pending.onWritten, onWritten)
+	pending.size += len(data)
+	if pending.size >= w.maxBytes {
+		due = append(due, w.take())
+	}
+	if len(due) == 0 {
+		w.mu.Unlock()
+		return
+	}
+	w.writeMu.Lock()
+	w.mu.Unlock()
+	defer w.writeMu.Unlock()
+
+	for _, write := range due {
+		write.complete()
+	}
+}
+
+// flush writes pending, unless it has already been written.
+func (w *writeCoalescer) flush(pending *coalescedWrite) {
+	w.mu.Lock()
+	if w.pending != pending {
+		w.mu.Unlock()
+		return
+	}
+	w.take()
+	w.writeMu.Lock()
+	w.mu.Unlock()
+	defer w.writeMu.Unlock()
+
+	pending.complete()
+}
+
+// take removes the messages waiting to be written. It must be called with mu held.
+func (w *writeCoalescer) take() *coalescedWrite {
+	pending := w.pending
+	w.pending = nil
+	if pending.timer != nil {
+		pending.timer.Stop()
+	}
+	return pending
+}
+
+// complete writes the messages, passing the result to each of their functions.
+func (c *coalescedWrite) complete() {
+	err := c.conn.writeFrames(c.frames)
+	for _, onWritten := range c.onWritten {
+		onWritten(err)
+	}
+}
@@ -0,0 +1,88 @@
+package jsonrpc_test
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/41north/jsonrpc.go"
+	"github.com/41north/jsonrpc.go/testutil"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// countingConn counts the writes made to a net.Conn.
+type countingConn struct {
+	net.Conn
+	writes atomic.Int32
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	c.writes.Add(1)
+	return c.Conn.Write(b)
+}
+
+func TestClient_WriteCoalesce(t *testing.T) {
+	for _, tc := range framingModes {
+		t.Run(tc.name, func(t *testing.T) {
+			clientConn, serverConn := net.Pipe()
+			go serveStream(jsonrpc.NewStreamConnection(serverConn, tc.framing))
+
+			conn := &countingConn{Conn: clientConn}
+			clock := testutil.NewClock(time.Time{})
+			client := jsonrpc.NewClientWithConnection(
+				jsonrpc.NewStreamConnection(conn, tc.framing),
+				jsonrpc.WithClock(clock),
+				jsonrpc.WithWriteCoalesce(1024, time.Millisecond),
+			)
+			assert.Nil(t, client.Connect())
+			defer client.Close()
+
+			// small requests are written together once the delay elapses
+			var futures []jsonrpc.ResponseFuture
+			for i := 0; i < 5; i++ {
+				futures = append(futures, client.SendAsync(*newRequest("echo", []int{i})))
+			}
+			assert.Equal(t, int32(0), conn.writes.Load())
+			clock.Advance(time.Millisecond)
+			assert.Equal(t, int32(1), conn.writes.Load())
+
+			// and each is correlated with its own response
+			for i, future := range futures {
+				resp, err := (<-future.Get()).Unwrap()
+				assert.Nil(t, err)
+
+				var result []int
+				assert.Nil(t, resp.UnmarshalResult(&result))
+				assert.Equal(t, []int{i}, result)
+			}
+
+			// reaching the size threshold writes immediately
+			var resp jsonrpc.Response
+			assert.Nil(t, client.Send(*newRequest("echo", make([]int, 512)), &resp))
+			assert.Equal(t, int32(2), conn.writes.Load())
+			assert.Equal(t, 0, clock.Waiters())
+		})
+	}
+}
+
+func TestClient_WriteCoalesceFailure(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	clock := testutil.NewClock(time.Time{})
+	client := jsonrpc.NewClientWithConnection(
+		jsonrpc.NewStreamConnection(clientConn, jsonrpc.FramingNewline),
+		jsonrpc.WithClock(clock),
+		jsonrpc.WithWriteCoalesce(1024, time.Millisecond),
+	)
+	assert.Nil(t, client.Connect())
+	defer client.Close()
+
+	// requests fail with the error of the write
+	future := client.SendAsync(*newRequest("echo", nil))
+	assert.Nil(t, serverConn.Close())
+	clock.Advance(time.Millisecond)
+
+	_, err := (<-future.Get()).Unwrap()
+	assert.NotNil(t, err)
+}